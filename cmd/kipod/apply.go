@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/sohankunkerkar/kipod/pkg/state"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+// ignoredApplyFields only affect kipod build, not a running cluster
+var ignoredApplyFields = []string{"sources", "localBuilds", "baseImage", "baseDistro", "addons.certManager.trustOnHost"}
+
+// immutableFieldGuidance explains how to change fields apply rejects
+var immutableFieldGuidance = map[string]string{
+	"nodes":             "add missing workers with: kipod create cluster --reuse; removing nodes needs a new cluster",
+	"networking":        "subnets, the node network and the API server port are fixed when the cluster is created",
+	"podSubnet":         "subnets are fixed when kubeadm initializes the cluster",
+	"serviceSubnet":     "subnets are fixed when kubeadm initializes the cluster",
+	"versions":          "versions are those of the node image the cluster was created from",
+	"kubernetesVersion": "versions are those of the node image the cluster was created from",
+	"crioVersion":       "versions are those of the node image the cluster was created from",
+	"image":             "nodes keep the image they were created from",
+	"imageGC":           "the kubelet config is set when the cluster is created; free space now with: kipod prune node-images",
+	"labels":            "labels are set on the node containers when they are created",
+	"features":          "kubelet feature gates are set when nodes are created",
+}
+
+func applyCmd() *cobra.Command {
+	var (
+		clusterName string
+		configFile  string
+		dryRun      bool
+		force       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Applies config changes to a running cluster",
+		Long: `Compares a config file with the config the cluster was created or last
+applied with, and applies the changes to mutable fields node by node:
+
+  crioConfig, crioProfile,         CRI-O drop-ins and kubelet verbosity, restarting
+  componentLogLevels               CRI-O or the kubelet only on nodes that changed
+  addons.kwok.nodes                registers or removes fake nodes
+  addons.certManager               installs or upgrades cert-manager
+  helmCharts                       installs or upgrades the releases
+  postCreateManifests              applies the manifests again
+  registryAuth                     rewrites the credentials on nodes and the pull
+                                   secret in every namespace
+  signaturePolicy                  rewrites the policy, key and registries.d files
+  kubeconfig.contextName           used by the next kipod export kubeconfig
+
+Changes to any other field, such as subnets, versions or the node image, are
+rejected with guidance, since they need a new cluster.`,
+		Example: `  kipod apply --config kipod.yaml
+  kipod apply --config kipod.yaml --name dev --dry-run`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return applyConfig(clusterName, configFile, dryRun, force)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default the config's name, or kipod)")
+	cmd.Flags().StringVar(&configFile, "config", "", "path to the new kipod config file")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the changes without applying them")
+	cmd.Flags().BoolVar(&force, "force", false, "apply the changes even if another user created the cluster")
+	_ = cmd.MarkFlagRequired("config")
+
+	return cmd
+}
+
+func applyConfig(name, configFile string, dryRun, force bool) error {
+	resolver, err := resolveCreateConfig(createClusterOptions{Name: name, ConfigFile: configFile})
+	if err != nil {
+		return err
+	}
+	kipodCfg := resolver.Config
+	// The cluster name may come from the config
+	if err := cluster.CheckOwner(kipodCfg.Name, force || dryRun); err != nil {
+		return err
+	}
+	stored, err := state.Load(kipodCfg.Name)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := newClusterConfig(kipodCfg, kipodCfg.Image, false, "0s")
+	if err != nil {
+		return err
+	}
+	effective := effectiveConfig(kipodCfg, cfg)
+
+	// Credentials may change in the auth file without a config change, and
+	// namespaces created since the last run need the pull secret
+	opts := cluster.ApplyOptions{PullSecret: kipodCfg.RegistryAuth.ImagePullSecret}
+	var mutable, immutable []config.FieldChange
+	for _, change := range config.Changes(stored, effective) {
+		switch {
+		case hasFieldPrefix(change.Path, ignoredApplyFields...):
+			continue
+		case hasFieldPrefix(change.Path, "crioConfig", "crioProfile", "componentLogLevels"):
+		case hasFieldPrefix(change.Path, "addons.kwok.nodes"):
+			opts.FakeNodes = true
+		case hasFieldPrefix(change.Path, "addons.certManager"):
+			opts.CertManager = kipodCfg.Addons.CertManager.Enabled
+		case hasFieldPrefix(change.Path, "helmCharts"):
+			opts.HelmCharts = true
+		case hasFieldPrefix(change.Path, "postCreateManifests"):
+			opts.PostCreateManifests = true
+		case hasFieldPrefix(change.Path, "registryAuth", "signaturePolicy"):
+		case hasFieldPrefix(change.Path, "kubeconfig"):
+		default:
+			immutable = append(immutable, change)
+			continue
+		}
+		mutable = append(mutable, change)
+	}
+
+	if len(immutable) > 0 {
+		var sb strings.Builder
+		sb.WriteString("cannot apply changes to immutable fields:")
+		for _, change := range immutable {
+			sb.WriteString(fmt.Sprintf("\n  %s: %s", change.Path, describeFieldChange(change)))
+			if guidance := fieldGuidance(change.Path); guidance != "" {
+				sb.WriteString(fmt.Sprintf(" (%s)", guidance))
+			}
+		}
+		sb.WriteString(fmt.Sprintf("\nrecreate the cluster to change them: kipod delete cluster --name %s && kipod create cluster --config %s", kipodCfg.Name, configFile))
+		return errors.New(sb.String())
+	}
+
+	for _, change := range mutable {
+		style.Step("%s: %s", change.Path, describeFieldChange(change))
+	}
+	warnRemovedAddons(stored, effective)
+	if dryRun {
+		return nil
+	}
+
+	style.Header("Applying config to cluster %q ...", kipodCfg.Name)
+	if err := cluster.Apply(cfg, opts); err != nil {
+		return fmt.Errorf("failed to apply config: %w", err)
+	}
+	if err := state.Save(effective); err != nil {
+		return err
+	}
+	style.Success("Applied")
+	return nil
+}
+
+// warnRemovedAddons warns about addons apply leaves installed
+func warnRemovedAddons(stored, effective *config.ClusterConfig) {
+	if stored.Addons.CertManager.Enabled && !effective.Addons.CertManager.Enabled {
+		style.Info("Warning: cert-manager stays installed; delete its namespace to remove it")
+	}
+	charts := make(map[string]bool)
+	for _, chart := range effective.HelmCharts {
+		charts[chart.Namespace+"/"+chart.Name] = true
+	}
+	for _, chart := range stored.HelmCharts {
+		if !charts[chart.Namespace+"/"+chart.Name] {
+			style.Info("Warning: release %s stays installed; remove it with: helm uninstall -n %s %s", chart.Name, chart.Namespace, chart.Name)
+		}
+	}
+	if stored.RegistryAuth.ImagePullSecret && !effective.RegistryAuth.ImagePullSecret {
+		style.Info("Warning: the kipod-registry-auth pull secrets stay in every namespace")
+	}
+	if stored.Kubeconfig.ContextName != effective.Kubeconfig.ContextName {
+		style.Info("Warning: kubeconfigs keep the old context until exported again with: kipod export kubeconfig -n %s", effective.Name)
+	}
+	if len(effective.PostCreateManifests) < len(stored.PostCreateManifests) {
+		style.Info("Warning: resources of removed postCreateManifests stay in the cluster")
+	}
+}
+
+// hasFieldPrefix reports whether path is one of the fields or below it
+func hasFieldPrefix(path string, fields ...string) bool {
+	for _, field := range fields {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldGuidance returns how to change an immutable field, if known
+func fieldGuidance(path string) string {
+	field, _, _ := strings.Cut(path, ".")
+	return immutableFieldGuidance[field]
+}
+
+// describeFieldChange formats the old and new value of a field
+func describeFieldChange(change config.FieldChange) string {
+	before, after := change.Old, change.New
+	if before == "" {
+		before = "<unset>"
+	}
+	if after == "" {
+		after = "<unset>"
+	}
+	return fmt.Sprintf("%s -> %s", before, after)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+// benchStats summarizes a duration across benchmark runs
+type benchStats struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// benchPhase is the summary of one creation phase across runs
+type benchPhase struct {
+	Phase string `json:"phase"`
+	benchStats
+}
+
+// benchReport is the JSON report printed by bench create, in seconds
+type benchReport struct {
+	Cluster  string       `json:"cluster"`
+	Config   string       `json:"config,omitempty"`
+	Image    string       `json:"image"`
+	Runs     int          `json:"runs"`
+	Failures int          `json:"failures"`
+	Phases   []benchPhase `json:"phases"`
+	Total    benchStats   `json:"total"`
+}
+
+func benchCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Benchmarks one of [create]",
+		Long: `Measures how long kipod operations take, for comparing kipod, CRI-O and
+node image versions on the same host. Reports are JSON on stdout.`,
+		Example: `  kipod bench create --runs 5 > create.json`,
+	}
+
+	cmd.AddCommand(benchCreateCmd())
+
+	return cmd
+}
+
+func benchCreateCmd() *cobra.Command {
+	var (
+		configFile  string
+		clusterName string
+		nodeImage   string
+		runs        int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Repeatedly create and delete a cluster and report phase timings",
+		Long: `Creates and deletes a cluster several times and prints min/avg/max timings
+for each creation phase as JSON on stdout. Progress is written to stderr.
+
+Use it to compare bring-up performance between kipod, CRI-O or image versions.`,
+		Example: `  kipod bench create
+  kipod bench create --config kipod.yaml --image localhost/kipod-node:dev --runs 5 > create.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if clusterName == "" {
+				clusterName = "kipod-bench"
+			}
+			if runs < 1 {
+				return fmt.Errorf("--runs must be at least 1")
+			}
+			return benchCreate(clusterName, configFile, nodeImage, runs)
+		},
+	}
+
+	cmd.Flags().StringVar(&configFile, "config", "", "path to a kipod config file")
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "cluster name used for the benchmark (default kipod-bench)")
+	cmd.Flags().StringVar(&nodeImage, "image", "", "node image to use for booting the cluster")
+	cmd.Flags().IntVar(&runs, "runs", 3, "number of create/delete cycles")
+
+	return cmd
+}
+
+func benchCreate(name, configFile, nodeImage string, runs int) error {
+	// Keep stdout for the JSON report
+	style.SetOutput(os.Stderr)
+
+	clusters, err := cluster.List()
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+	for _, existing := range clusters {
+		if existing == name {
+			return fmt.Errorf("cluster %q already exists, delete it or pick another --name", name)
+		}
+	}
+
+	var (
+		image    string
+		phases   []string
+		samples  = make(map[string][]float64)
+		totals   []float64
+		failures int
+	)
+
+	for run := 1; run <= runs; run++ {
+		style.Header("Run %d/%d: creating cluster %q ...", run, runs, name)
+
+		resolver, err := resolveCreateConfig(createClusterOptions{Name: name, ConfigFile: configFile, NodeImage: nodeImage})
+		if err != nil {
+			return err
+		}
+		kipodCfg := resolver.Config
+		cfg, err := newClusterConfig(kipodCfg, kipodCfg.Image, false, "")
+		if err != nil {
+			return err
+		}
+		c, err := cluster.NewCluster(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create cluster: %w", err)
+		}
+		image = cfg.Image
+
+		start := time.Now()
+		createErr := c.Create()
+		total := time.Since(start)
+
+		// A failed create already cleaned up after itself
+		exists, err := cluster.Exists(name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if err := cluster.Delete(name); err != nil {
+				return fmt.Errorf("failed to delete cluster after run %d: %w", run, err)
+			}
+		}
+
+		if createErr != nil {
+			style.Info("Warning: run %d failed: %v", run, createErr)
+			failures++
+			continue
+		}
+
+		for _, t := range c.Timings() {
+			if _, ok := samples[t.Phase]; !ok {
+				phases = append(phases, t.Phase)
+			}
+			samples[t.Phase] = append(samples[t.Phase], t.Seconds)
+		}
+		totals = append(totals, total.Seconds())
+		style.Step("Run %d/%d took %.1fs", run, runs, total.Seconds())
+	}
+
+	if len(totals) == 0 {
+		return fmt.Errorf("all %d runs failed", runs)
+	}
+
+	report := benchReport{
+		Cluster:  name,
+		Config:   configFile,
+		Image:    image,
+		Runs:     runs,
+		Failures: failures,
+		Total:    summarize(totals),
+	}
+	for _, phase := range phases {
+		report.Phases = append(report.Phases, benchPhase{
+			Phase:      phase,
+			benchStats: summarize(samples[phase]),
+		})
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	fmt.Println(string(data))
+
+	return nil
+}
+
+// summarize computes min/avg/max of the given samples
+func summarize(samples []float64) benchStats {
+	stats := benchStats{Min: math.Inf(1), Max: math.Inf(-1)}
+	var sum float64
+	for _, v := range samples {
+		stats.Min = math.Min(stats.Min, v)
+		stats.Max = math.Max(stats.Max, v)
+		sum += v
+	}
+	stats.Avg = sum / float64(len(samples))
+	return stats
+}
@@ -2,40 +2,91 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/sohankunkerkar/kipod/pkg/build"
 	"github.com/sohankunkerkar/kipod/pkg/config"
+	"gopkg.in/yaml.v3"
 )
 
-func buildNodeImage(configFile, k8sVersion, crioVersion, image string, rebuild bool) error {
-	// Load config from file or use defaults
-	var cfg *config.ClusterConfig
-	var err error
+type buildNodeImageOptions struct {
+	ConfigFile       string
+	K8sVersion       string
+	CRIOVersion      string
+	Image            string
+	Rebuild          bool
+	WithWasm         bool
+	SandboxRuntime   string
+	VerifySignatures bool
+	RequireVerified  bool
+	Containerized    bool
+	BuilderImage     string
+	BaseImage        string
+	BaseDistro       string
+	Variant          string
+	ExplainConfig    bool
+}
 
-	if configFile != "" {
-		cfg, err = config.LoadFromFile(configFile)
-		if err != nil {
-			return fmt.Errorf("failed to load config file: %w", err)
-		}
-		if !quietMode {
-			fmt.Printf("Using configuration from: %s\n", configFile)
-		}
-	} else {
-		cfg = config.DefaultConfig()
+// resolveBuildConfig loads the config of build node-image and applies its
+// flags on top
+func resolveBuildConfig(o buildNodeImageOptions) (*config.Resolver, error) {
+	resolver, err := config.NewResolver(o.ConfigFile, "")
+	if err != nil {
+		return nil, err
 	}
+	cfg := resolver.Config
 
-	// Command-line flags override config file
-	finalK8sVersion := cfg.Versions.Kubernetes
-	if k8sVersion != "" {
-		finalK8sVersion = k8sVersion
+	for _, flag := range []struct {
+		path, name, value string
+		field             *string
+	}{
+		{"versions.kubernetes", "k8s-version", o.K8sVersion, &cfg.Versions.Kubernetes},
+		{"versions.crio", "crio-version", o.CRIOVersion, &cfg.Versions.CRIO},
+		{"image", "image", o.Image, &cfg.Image},
+		{"baseImage", "base-image", o.BaseImage, &cfg.BaseImage},
+		{"baseDistro", "base-distro", o.BaseDistro, &cfg.BaseDistro},
+		{"runtimes.sandboxed", "sandbox-runtime", o.SandboxRuntime, &cfg.Runtimes.Sandboxed},
+	} {
+		field, value := flag.field, flag.value
+		_ = resolver.Flag(flag.path, flag.name, value != "", func() error {
+			*field = value
+			return nil
+		})
+	}
+	_ = resolver.Flag("runtimes.wasm", "with-wasm", o.WithWasm, func() error {
+		cfg.Runtimes.Wasm = true
+		return nil
+	})
+	if cfg.Image == "" {
+		cfg.Image = build.GetImageFullName(build.DefaultImageName, build.DefaultImageTag)
+	}
+
+	if err := cfg.ValidateSources(); err != nil {
+		return nil, err
+	}
+	if cfg.Runtimes.Sandboxed != "" && cfg.Runtimes.Sandboxed != "kata" && cfg.Runtimes.Sandboxed != "gvisor" {
+		return nil, fmt.Errorf("sandboxed runtime must be 'kata' or 'gvisor', got: %s", cfg.Runtimes.Sandboxed)
 	}
+	return resolver, nil
+}
 
-	finalCRIOVersion := cfg.Versions.CRIO
-	if crioVersion != "" {
-		finalCRIOVersion = crioVersion
+func buildNodeImage(o buildNodeImageOptions) error {
+	resolver, err := resolveBuildConfig(o)
+	if err != nil {
+		return err
+	}
+	if o.ExplainConfig {
+		printResolvedFields(resolver.Fields())
+		return nil
+	}
+	cfg := resolver.Config
+	if o.ConfigFile != "" && !quietMode {
+		fmt.Printf("Using configuration from: %s\n", o.ConfigFile)
 	}
 
 	// Parse image name and tag from image string (format: name:tag)
+	image := cfg.Image
 	imageName := image
 	imageTag := "latest"
 
@@ -50,12 +101,33 @@ func buildNodeImage(configFile, k8sVersion, crioVersion, image string, rebuild b
 		}
 	}
 
+	if o.RequireVerified {
+		// fetch-artifact.sh in the build reads it as a build arg
+		if err := os.Setenv(build.RequireVerifiedEnv, "1"); err != nil {
+			return err
+		}
+	}
+
 	opts := &build.ImageBuildOptions{
 		ImageName:         imageName,
 		ImageTag:          imageTag,
-		KubernetesVersion: finalK8sVersion,
-		CRIOVersion:       finalCRIOVersion,
-		Rebuild:           rebuild,
+		KubernetesVersion: cfg.Versions.Kubernetes,
+		CRIOVersion:       cfg.Versions.CRIO,
+		CRIOSource:        cfg.Sources.CRIO.Type,
+		CRIOSourceURL:     cfg.Sources.CRIO.URL,
+		CRIOSHA256:        cfg.Sources.CRIO.SHA256,
+		KubernetesRepoURL: cfg.Sources.Kubernetes.URL,
+		CrunVersion:       cfg.Versions.Crun,
+		RuncVersion:       cfg.Versions.Runc,
+		BaseImage:         cfg.BaseImage,
+		BaseDistro:        cfg.BaseDistro,
+		Variant:           o.Variant,
+		VerifySignatures:  o.VerifySignatures,
+		Containerized:     o.Containerized || o.BuilderImage != "",
+		BuilderImage:      o.BuilderImage,
+		Rebuild:           o.Rebuild,
+		WithWasm:          cfg.Runtimes.Wasm,
+		SandboxRuntime:    cfg.Runtimes.Sandboxed,
 	}
 
 	if err := build.BuildImage(opts); err != nil {
@@ -64,3 +136,56 @@ func buildNodeImage(configFile, k8sVersion, crioVersion, image string, rebuild b
 
 	return nil
 }
+
+// artifactManifestHeader is written above the generated artifact manifest
+const artifactManifestHeader = `# Artifacts kipod downloads while building node images and setting up
+# nodes, with the sha256 of every pinned release per architecture.
+#
+# Regenerate after adding a release with:
+#   kipod build checksums [--add NAME=VERSION] > pkg/build/artifacts.yaml
+`
+
+// pinChecksums prints the artifact manifest with the checksums of all
+// unpinned releases filled in
+func pinChecksums(add []string) error {
+	releases := make(map[string]string)
+	for _, spec := range add {
+		name, version, ok := strings.Cut(spec, "=")
+		if !ok || name == "" || version == "" {
+			return fmt.Errorf("invalid release %q, expected NAME=VERSION", spec)
+		}
+		releases[name] = version
+	}
+
+	manifest, err := build.LoadArtifactManifest()
+	if err != nil {
+		return err
+	}
+	if err := build.PinArtifactChecksums(manifest, releases); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal artifact manifest: %w", err)
+	}
+	fmt.Print(artifactManifestHeader)
+	fmt.Print(string(data))
+	return nil
+}
+
+// checkChecksums fails when a release of the artifact manifest lacks a
+// checksum for one of the supported architectures
+func checkChecksums() error {
+	manifest, err := build.LoadArtifactManifest()
+	if err != nil {
+		return err
+	}
+	missing := manifest.MissingChecksums()
+	if len(missing) == 0 {
+		fmt.Printf("All artifact releases are pinned for %s\n", strings.Join(build.ArtifactArchitectures, ", "))
+		return nil
+	}
+	return fmt.Errorf("%d artifact releases have no pinned checksum:\n  %s\npin them with: kipod build checksums > pkg/build/artifacts.yaml",
+		len(missing), strings.Join(missing, "\n  "))
+}
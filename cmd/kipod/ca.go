@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+	"github.com/spf13/cobra"
+)
+
+func caCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Manages the CA of the cert-manager addon, one of [print, trust, untrust]",
+		Long: `Works with the CA behind the kipod-ca ClusterIssuer of the cert-manager
+addon: print its certificate, or add it to or remove it from the system trust
+store so HTTPS services of the cluster are trusted on this host.`,
+		Example: `  kipod ca print -n dev > kipod-ca.crt
+  kipod ca trust -n dev
+  kipod ca untrust -n dev`,
+	}
+
+	cmd.AddCommand(caPrintCmd())
+	cmd.AddCommand(caTrustCmd())
+	cmd.AddCommand(caUntrustCmd())
+
+	return cmd
+}
+
+func caPrintCmd() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "print",
+		Short: "Prints the PEM encoded CA certificate of a cluster",
+		Long: `Prints the CA certificate of the cert-manager addon's kipod-ca ClusterIssuer,
+e.g. to import it into a browser or pass it to curl --cacert.`,
+		Example: `  kipod ca print -n dev > kipod-ca.crt`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name = resolveClusterName(name)
+			pem, err := cluster.ClusterCA(name)
+			if err != nil {
+				return err
+			}
+			fmt.Print(pem)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+
+	return cmd
+}
+
+func caTrustCmd() *cobra.Command {
+	var (
+		name string
+		yes  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "trust",
+		Short: "Adds the CA of a cluster to the host trust store",
+		Long: `Adds the CA of the cert-manager addon to the system trust store, so HTTPS
+Ingresses with certificates from the kipod-ca ClusterIssuer are trusted by
+curl and other tools using it. Runs update-ca-trust or update-ca-certificates
+through sudo after asking for confirmation.
+
+Browsers with their own certificate store (e.g. Firefox) need the CA imported
+separately: kipod ca print > kipod-ca.crt`,
+		Example: `  kipod ca trust
+  kipod ca trust -n dev --yes`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name = resolveClusterName(name)
+			return trustClusterCA(name, yes)
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "do not ask for confirmation")
+
+	return cmd
+}
+
+func caUntrustCmd() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "untrust",
+		Short: "Removes the CA of a cluster from the host trust store",
+		Long: `Removes the CA added by kipod ca trust and regenerates the system bundle
+through sudo. Deleting a cluster leaves its trusted CA in place, so run this
+afterwards.`,
+		Example: `  kipod ca untrust -n dev`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name = resolveClusterName(name)
+			if err := system.UntrustHostCA(name); err != nil {
+				return err
+			}
+			style.Success("Removed the CA of cluster %q from the host trust store", name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+
+	return cmd
+}
+
+// trustClusterCA installs the cluster CA into the host trust store once the
+// user confirms, or right away with yes
+func trustClusterCA(name string, yes bool) error {
+	pem, err := cluster.ClusterCA(name)
+	if err != nil {
+		return err
+	}
+	dest, err := system.HostCAPath(name)
+	if err != nil {
+		return err
+	}
+
+	if !yes {
+		question := fmt.Sprintf("Install the CA of cluster %q as %s? Every certificate it signs will be trusted by this host", name, dest)
+		confirmed, err := confirm(question)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			style.Info("Skipped trusting the cluster CA; run kipod ca trust -n %s later", name)
+			return nil
+		}
+	}
+
+	if err := system.TrustHostCA(name, pem); err != nil {
+		return err
+	}
+	style.Success("Trusted the CA of cluster %q; remove it with kipod ca untrust -n %s", name, name)
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no
+func confirm(question string) (bool, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("cannot ask for confirmation without a terminal; pass --yes")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
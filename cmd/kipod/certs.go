@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func certsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Checks or renews the control-plane certificates of a cluster",
+		Long: `Works with the kubeadm certificates of the control-plane nodes: check when
+they expire, or renew them on a long-lived cluster before they do.`,
+		Example: `  kipod certs check
+  kipod certs renew -n dev`,
+	}
+
+	cmd.AddCommand(certsCheckCmd())
+	cmd.AddCommand(certsRenewCmd())
+
+	return cmd
+}
+
+func certsCheckCmd() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Prints when the certificates of every control-plane node expire",
+		Long: `Runs kubeadm certs check-expiration on every control-plane node. kubeadm
+certificates last a year; run kipod certs renew on long-lived clusters before
+they expire. Kubelets rotate their own client certificates.`,
+		Example: `  kipod certs check
+  kipod certs check -n dev`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name = resolveClusterName(name)
+
+			reports, err := cluster.CheckCertificates(name)
+			if err != nil {
+				return err
+			}
+			for i, report := range reports {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("%s:\n%s\n", report.Node, report.Report)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+
+	return cmd
+}
+
+func certsRenewCmd() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "renew",
+		Short: "Renews the control-plane certificates and restarts the static pods",
+		Long: `Runs kubeadm certs renew all on every control-plane node, restarts the
+API server, controller manager, scheduler and etcd static pods so they load
+the new certificates, and rewrites the cluster kubeconfig on the host, since
+the admin client certificate is renewed too.`,
+		Example: `  kipod certs renew
+  kipod certs renew -n dev`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name = resolveClusterName(name)
+
+			style.Step("Renewing control-plane certificates of cluster %q 🔐", name)
+			if err := cluster.RenewCertificates(name); err != nil {
+				return err
+			}
+
+			path, err := writeClusterKubeconfig(name, "")
+			if err != nil {
+				return err
+			}
+			style.Success("Renewed certificates; updated kubeconfig %s", path)
+			style.Info("Run kipod export kubeconfig -n %s to refresh a merged kubeconfig", name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(name)} })
+
+	return cmd
+}
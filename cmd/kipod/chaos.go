@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+// defaultChaosDuration is how long a fault lasts before it is undone
+const defaultChaosDuration = 30 * time.Second
+
+// chaosOptions holds the flags shared by the chaos commands
+type chaosOptions struct {
+	Name     string
+	Duration time.Duration
+}
+
+func chaosCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "chaos",
+		Short: "Injects one of [kill-node, pause-node, restart-service, partition] and undoes it",
+		Long: `Injects a failure into a cluster to test how controllers and applications
+cope, waits --for the given duration (default 30s, 0 to wait for Ctrl-C) and
+undoes it. Ctrl-C undoes the failure early.
+
+Nodes are given by their name without the cluster prefix, e.g. worker-0.`,
+		Example: `  kipod chaos kill-node worker-0 --for 2m
+  kipod chaos pause-node worker-1 --for 0
+  kipod chaos restart-service kubelet --node control-plane-0
+  kipod chaos partition control-plane-0 worker-0 -n dev`,
+	}
+
+	cmd.AddCommand(chaosKillNodeCmd())
+	cmd.AddCommand(chaosPauseNodeCmd())
+	cmd.AddCommand(chaosRestartServiceCmd())
+	cmd.AddCommand(chaosPartitionCmd())
+
+	return cmd
+}
+
+func chaosKillNodeCmd() *cobra.Command {
+	var opts chaosOptions
+
+	cmd := &cobra.Command{
+		Use:   "kill-node NODE",
+		Short: "Kills a node container and starts it again",
+		Long: `Kills a node container, as if its host crashed, and starts it again once the
+duration is over. Nodes with tmpfs storage come back without pulled images.`,
+		Example: `  kipod chaos kill-node worker-0 --for 2m`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodes, err := selectNodes(opts.clusterName(), args)
+			if err != nil {
+				return err
+			}
+			return runFault(opts, func() (*cluster.Fault, error) { return cluster.KillNode(nodes[0]) })
+		},
+	}
+	addChaosFlags(cmd, &opts)
+
+	return cmd
+}
+
+func chaosPauseNodeCmd() *cobra.Command {
+	var opts chaosOptions
+
+	cmd := &cobra.Command{
+		Use:   "pause-node NODE",
+		Short: "Freezes a node container and resumes it",
+		Long: `Freezes every process of a node container, so the node stops answering
+without exiting, like a hung machine, and resumes it once the duration is over.`,
+		Example: `  kipod chaos pause-node worker-0 --for 1m`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodes, err := selectNodes(opts.clusterName(), args)
+			if err != nil {
+				return err
+			}
+			return runFault(opts, func() (*cluster.Fault, error) { return cluster.PauseNode(nodes[0]) })
+		},
+	}
+	addChaosFlags(cmd, &opts)
+
+	return cmd
+}
+
+func chaosRestartServiceCmd() *cobra.Command {
+	var (
+		opts chaosOptions
+		node string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restart-service SERVICE --node NODE",
+		Short: "Stops a node service, e.g. crio or kubelet, and starts it again",
+		Long: `Stops a systemd service in a node and starts it again once the duration is
+over, to test how workloads and controllers cope with a runtime or kubelet
+outage. Running containers are left alone while CRI-O is down.`,
+		Example: `  kipod chaos restart-service crio --node worker-0
+  kipod chaos restart-service kubelet --node control-plane-0 --for 10s`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodes, err := selectNodes(opts.clusterName(), []string{node})
+			if err != nil {
+				return err
+			}
+			return runFault(opts, func() (*cluster.Fault, error) { return cluster.StopService(nodes[0], args[0]) })
+		},
+	}
+	addChaosFlags(cmd, &opts)
+	cmd.Flags().StringVar(&node, "node", "", "node running the service, e.g. worker-0")
+	_ = cmd.MarkFlagRequired("node")
+
+	return cmd
+}
+
+func chaosPartitionCmd() *cobra.Command {
+	var opts chaosOptions
+
+	cmd := &cobra.Command{
+		Use:   "partition NODE_A NODE_B",
+		Short: "Drops all traffic between two nodes and restores it",
+		Long: `Inserts iptables rules in both nodes that drop the traffic between them,
+including that of their pods, and deletes them once the duration is over.`,
+		Example: `  kipod chaos partition control-plane-0 worker-0 --for 1m`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[0] == args[1] {
+				return fmt.Errorf("cannot partition node %s from itself", args[0])
+			}
+			nodes, err := selectNodes(opts.clusterName(), args)
+			if err != nil {
+				return err
+			}
+			return runFault(opts, func() (*cluster.Fault, error) { return cluster.PartitionNodes(nodes[0], nodes[1]) })
+		},
+	}
+	addChaosFlags(cmd, &opts)
+
+	return cmd
+}
+
+func addChaosFlags(cmd *cobra.Command, opts *chaosOptions) {
+	cmd.Flags().StringVarP(&opts.Name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().DurationVar(&opts.Duration, "for", defaultChaosDuration, "how long the failure lasts before it is undone, 0 to wait for Ctrl-C")
+	guardOwner(cmd, "force", func([]string) []string { return []string{opts.clusterName()} })
+}
+
+func (o chaosOptions) clusterName() string {
+	return resolveClusterName(o.Name)
+}
+
+// runFault injects a fault, waits for the duration or a signal and undoes it
+func runFault(opts chaosOptions, inject func() (*cluster.Fault, error)) error {
+	// Listen before injecting, so Ctrl-C right away still undoes the fault
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	fault, err := inject()
+	if err != nil {
+		return err
+	}
+
+	var timeout <-chan time.Time
+	if opts.Duration > 0 {
+		style.Step("Chaos: %s; undoing in %s (Ctrl-C to undo now) 💥", fault.Description, opts.Duration)
+		timeout = time.After(opts.Duration)
+	} else {
+		style.Step("Chaos: %s; press Ctrl-C to undo 💥", fault.Description)
+	}
+
+	select {
+	case <-timeout:
+	case <-signals:
+	}
+
+	if err := fault.Undo(); err != nil {
+		return fmt.Errorf("failed to undo (%s): %w", fault.Description, err)
+	}
+	style.Success("Undid: %s", fault.Description)
+	return nil
+}
@@ -1,16 +1,64 @@
 package main
 
 import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/sohankunkerkar/kipod/pkg/style"
 	"github.com/sohankunkerkar/kipod/pkg/system"
 )
 
-func checkSystem() error {
+func checkSystem(configFile string, report system.ReportOptions) error {
 	results, err := system.ValidateSystem()
 	if err != nil {
 		return err
 	}
 
-	system.PrintValidationResults(results)
+	// Size resource checks for the intended cluster topology
+	topology := system.DefaultTopology()
+	if configFile != "" {
+		cfg, err := config.LoadFromFile(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config file: %w", err)
+		}
+		topology = system.Topology{
+			Nodes:       cfg.TotalNodes(),
+			StorageType: cfg.Storage.Type,
+			StorageSize: cfg.Storage.Size,
+		}
+	}
+	results = append(results, system.ValidateResources(topology)...)
+
+	return system.PrintValidationResults(results, report)
+}
+
+func checkNodeImage(image string, report system.ReportOptions) error {
+	style.Step("Validating node image %s...", image)
+
+	results, err := system.ValidateNodeImage(image)
+	if err != nil {
+		return fmt.Errorf("failed to validate node image: %w", err)
+	}
+
+	return system.PrintValidationResults(results, report)
+}
 
-	return nil
+func simulateNode(configFile, nodeImage string, report system.ReportOptions) error {
+	resolver, err := resolveCreateConfig(createClusterOptions{ConfigFile: configFile, NodeImage: nodeImage})
+	if err != nil {
+		return err
+	}
+	kipodCfg := resolver.Config
+	cfg, err := newClusterConfig(kipodCfg, kipodCfg.Image, false, "0s")
+	if err != nil {
+		return err
+	}
+
+	style.Step("Booting a simulated node from %s...", cfg.Image)
+	results, err := cluster.SimulateNode(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to simulate a node: %w", err)
+	}
+	return system.PrintValidationResults(results, report)
 }
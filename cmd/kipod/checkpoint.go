@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/state"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+// checkpointOptions holds the flags of checkpoint pod
+type checkpointOptions struct {
+	Name      string
+	Namespace string
+	Container string
+	Dir       string
+	Output    string
+}
+
+func checkpointCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "checkpoint",
+		Short: "Checkpoints one of [pod]",
+		Long: `Checkpoints running workloads with CRIU, for forensic analysis or to restore
+them elsewhere. The cluster must be created with features.checkpointRestore.`,
+		Example: `  kipod checkpoint pod counter --dir ./checkpoints`,
+	}
+
+	cmd.AddCommand(checkpointPodCmd())
+
+	return cmd
+}
+
+func checkpointPodCmd() *cobra.Command {
+	var opts checkpointOptions
+
+	cmd := &cobra.Command{
+		Use:   "pod POD",
+		Short: "Checkpoints the containers of a running pod with CRIU",
+		Long: `Checkpoints every container of a pod, or the one given with --container,
+through the kubelet checkpoint API, as forensic container checkpointing does,
+and copies the archives from the node to --dir on the host. The pod keeps
+running.
+
+The cluster must be created with features.checkpointRestore, which enables
+CRIU support in CRI-O and the kubelet's ContainerCheckpoint feature gate.
+Inspect an archive with checkpointctl, or restore it by building it into an
+image with the io.kubernetes.cri-o.annotations.checkpoint.name annotation.`,
+		Example: `  kipod checkpoint pod counter
+  kipod checkpoint pod web-0 --namespace shop --container app --dir ./checkpoints`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = resolveClusterName(opts.Name)
+			return checkpointPod(args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&opts.Namespace, "namespace", "default", "namespace of the pod")
+	cmd.Flags().StringVarP(&opts.Container, "container", "c", "", "checkpoint only this container (default every container of the pod)")
+	cmd.Flags().StringVar(&opts.Dir, "dir", ".", "directory on the host to copy the checkpoint archives to")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output format: json")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(opts.Name)} })
+
+	return cmd
+}
+
+func checkpointPod(pod string, opts checkpointOptions) error {
+	switch opts.Output {
+	case "", "json":
+	default:
+		return fmt.Errorf("unsupported output format %q (supported: json)", opts.Output)
+	}
+	if stored, err := state.Load(opts.Name); err == nil && !stored.Features.CheckpointRestore {
+		return fmt.Errorf("cluster %q was created without checkpoint/restore; recreate it with features.checkpointRestore: true", opts.Name)
+	}
+
+	if !quietMode && opts.Output == "" {
+		style.Header("Checkpointing pod %s/%s ...", opts.Namespace, pod)
+	}
+	checkpoints, err := cluster.CheckpointPod(opts.Name, opts.Namespace, pod, opts.Container, opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		data, err := json.MarshalIndent(checkpoints, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode checkpoints: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("%-24s %-24s %s\n", "CONTAINER", "NODE", "ARCHIVE")
+	for _, checkpoint := range checkpoints {
+		fmt.Printf("%-24s %-24s %s\n", checkpoint.Container, checkpoint.Node, checkpoint.Path)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/events"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// progress is the --progress mode: auto, plain for CI logs, or json events
+var progress string
+
+// inCI reports whether kipod runs in CI. KIPOD_IN_CI=true|false overrides
+// the detection based on the CI variable most CI systems set.
+func inCI() bool {
+	if v := os.Getenv("KIPOD_IN_CI"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		return err == nil && enabled
+	}
+	enabled, _ := strconv.ParseBool(os.Getenv("CI"))
+	return enabled
+}
+
+// setupProgress applies the --progress mode; auto switches to plain in CI
+// and json replaces all progress output with JSON events on stderr
+func setupProgress() error {
+	switch progress {
+	case "auto":
+		style.SetPlain(inCI())
+	case "plain":
+		style.SetPlain(true)
+	case "json":
+		style.SetPlain(true)
+		events.Enable(os.Stderr)
+	default:
+		return fmt.Errorf("unsupported progress mode %q (supported: auto, plain, json)", progress)
+	}
+	return nil
+}
+
+// reportError prints a failed command's error, with an error annotation when
+// running in GitHub Actions so the failure shows up in the job summary
+func reportError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if os.Getenv("GITHUB_ACTIONS") == "true" {
+		msg, _, _ := strings.Cut(err.Error(), "\n")
+		fmt.Fprintf(os.Stderr, "::error title=kipod::%s\n", msg)
+	}
+}
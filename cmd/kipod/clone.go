@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/state"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+// cloneClusterOptions holds the flags of clone cluster
+type cloneClusterOptions struct {
+	Source         string
+	Name           string
+	KubeconfigPath string
+	Retain         bool
+	WaitAll        bool
+}
+
+func cloneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clone",
+		Short: "Clones one of [cluster]",
+		Long: `Creates new clusters from the state of running ones, e.g. to fork a prepared
+environment for parallel test runs.`,
+		Example: `  kipod clone cluster dev dev-2`,
+	}
+
+	cmd.AddCommand(cloneClusterCmd())
+
+	return cmd
+}
+
+func cloneClusterCmd() *cobra.Command {
+	var opts cloneClusterOptions
+
+	cmd := &cobra.Command{
+		Use:   "cluster SOURCE NAME",
+		Short: "Creates a new cluster from the state of a running one",
+		Long: `Creates cluster NAME with the recorded configuration of cluster SOURCE and
+starts it from SOURCE's state: node storage volumes (images) are copied, the
+cluster CA and service account keys are reused with new serving certificates,
+and a snapshot of SOURCE's etcd is restored, so workloads and objects carry
+over. Use it to fork a prepared environment for parallel test runs.
+
+SOURCE must be running and use stacked etcd. Volumes are copied while SOURCE
+runs; pause busy workloads first for a consistent copy.`,
+		Example: `  kipod clone cluster dev dev-2`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Source, opts.Name = args[0], args[1]
+			return cloneCluster(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.KubeconfigPath, "kubeconfig", "", "sets kubeconfig path instead of $KUBECONFIG or $HOME/.kube/config")
+	cmd.Flags().BoolVar(&opts.Retain, "retain", false, "retain nodes for debugging when cloning fails")
+	cmd.Flags().BoolVar(&opts.WaitAll, "wait-all", false, "wait for all nodes and kube-system pods to be Ready")
+
+	return cmd
+}
+
+func cloneCluster(opts cloneClusterOptions) error {
+	kipodCfg, err := state.Load(opts.Source)
+	if err != nil {
+		return err
+	}
+	kipodCfg.Name = opts.Name
+
+	exists, err := cluster.Exists(opts.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("cluster %q already exists", opts.Name)
+	}
+
+	if !quietMode {
+		style.Header("Cloning cluster %q into %q ...", opts.Source, opts.Name)
+	}
+
+	cfg, err := newClusterConfig(kipodCfg, kipodCfg.Image, opts.Retain, "")
+	if err != nil {
+		return err
+	}
+	cfg.CloneFrom = opts.Source
+	cfg.WaitAll = opts.WaitAll
+
+	c, err := cluster.NewCluster(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster: %w", err)
+	}
+
+	start := time.Now()
+	if err := c.Create(); err != nil {
+		return fmt.Errorf("failed to clone cluster: %w", err)
+	}
+	total := time.Since(start)
+
+	if err := saveClusterState(kipodCfg, cfg); err != nil {
+		style.Info("Warning: failed to record cluster state: %v", err)
+	}
+	if err := recordClusterInfo(c, kipodCfg, cfg); err != nil {
+		style.Info("Warning: failed to record cluster info in the cluster: %v", err)
+	}
+
+	exportedPath, err := writeClusterKubeconfig(opts.Name, opts.KubeconfigPath)
+	if err != nil {
+		return err
+	}
+
+	if !quietMode {
+		printTimings(c.Timings(), total)
+		style.Header("\nCluster %q cloned from %q!", opts.Name, opts.Source)
+		style.Header("\nTo start using your cluster, run:")
+		style.Header("  export KUBECONFIG=%s", exportedPath)
+		style.Header("  kubectl get nodes")
+	}
+	return nil
+}
@@ -1,37 +1,193 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
-	"regexp"
-
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/sohankunkerkar/kipod/pkg/build"
 	"github.com/sohankunkerkar/kipod/pkg/cluster"
 	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/sohankunkerkar/kipod/pkg/kubeconfig"
+	"github.com/sohankunkerkar/kipod/pkg/paths"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/state"
 	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+	"gopkg.in/yaml.v3"
 )
 
-func createCluster(name, configFile, nodeImage, kubeconfigPath string, retain bool, waitDuration string) error {
-	// TODO: Implement nodeImage, kubeconfigPath, retain, and waitDuration support
+// createResult is the result of create cluster printed with -o json
+type createResult struct {
+	Name         string                `json:"name"`
+	Kubeconfig   string                `json:"kubeconfig"`
+	Phases       []cluster.PhaseTiming `json:"phases"`
+	TotalSeconds float64               `json:"totalSeconds"`
+	// Error and Reason are set when provisioning failed; Reason is a
+	// WaitReason constant when --wait timed out
+	Error  string `json:"error,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
 
-	// Load config from file or use defaults
-	var kipodCfg *config.ClusterConfig
-	var err error
+// createClusterOptions holds the flags of create cluster
+type createClusterOptions struct {
+	Name            string
+	ConfigFile      string
+	NodeImage       string
+	KubeconfigPath  string
+	Retain          bool
+	WaitDuration    string
+	Output          string
+	Reuse           bool
+	LogLevels       string
+	WaitAll         bool
+	StrictPreflight bool
+	FakeNodes       int
+	Profile         string
+	ExplainConfig   bool
+	SaveConfig      string
+	ExecSessions    bool
+	Labels          []string
+	Force           bool
+}
 
-	if configFile != "" {
-		kipodCfg, err = config.LoadFromFile(configFile)
+// resolveCreateConfig loads the config of create cluster and applies its
+// environment variables and flags on top
+func resolveCreateConfig(opts createClusterOptions) (*config.Resolver, error) {
+	resolver, err := config.NewResolver(opts.ConfigFile, opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+	kipodCfg := resolver.Config
+
+	if err := resolver.Env("name", "KIPOD_CLUSTER_NAME", func(value string) error {
+		kipodCfg.Name = value
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := resolver.Env("cgroupManager", "KIPOD_CGROUP_MANAGER", func(value string) error {
+		kipodCfg.CgroupManager = value
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := resolver.Flag("name", "name", opts.Name != "", func() error {
+		kipodCfg.Name = opts.Name
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := resolver.Flag("image", "image", opts.NodeImage != "", func() error {
+		kipodCfg.Image = opts.NodeImage
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if kipodCfg.Image == "" {
+		kipodCfg.Image = build.GetImageFullName(build.DefaultImageName, build.DefaultImageTag)
+	}
+
+	// Log levels from the flag override the config per component
+	if opts.LogLevels != "" {
+		levels, err := config.ParseComponentLogLevels(opts.LogLevels)
 		if err != nil {
-			return fmt.Errorf("failed to load config file: %w", err)
+			return nil, fmt.Errorf("invalid --component-log-level: %w", err)
 		}
-	} else {
-		kipodCfg = config.DefaultConfig()
+		if kipodCfg.ComponentLogLevels == nil {
+			kipodCfg.ComponentLogLevels = make(map[string]string)
+		}
+		for component, level := range levels {
+			_ = resolver.Flag("componentLogLevels."+component, "component-log-level", true, func() error {
+				kipodCfg.ComponentLogLevels[component] = level
+				return nil
+			})
+		}
+	}
+
+	// Labels from the flag are added to those of the config
+	for _, label := range opts.Labels {
+		key, _, _ := strings.Cut(label, "=")
+		if err := resolver.Flag("labels."+key, "label", true, func() error {
+			return addLabel(kipodCfg, label)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := resolver.Flag("addons.kwok.nodes", "fake-nodes", opts.FakeNodes != 0, func() error {
+		if opts.FakeNodes < 0 || opts.FakeNodes > config.MaxFakeNodes {
+			return fmt.Errorf("must be between 1 and %d, got: %d", config.MaxFakeNodes, opts.FakeNodes)
+		}
+		kipodCfg.Addons.Kwok.Nodes = opts.FakeNodes
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := kipodCfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return resolver, nil
+}
+
+// addLabel adds a key=value label to a cluster config, replacing the value
+// the config gives the key
+func addLabel(kipodCfg *config.ClusterConfig, label string) error {
+	key, value, err := config.ParseLabel(label)
+	if err != nil {
+		return err
+	}
+	if kipodCfg.Labels == nil {
+		kipodCfg.Labels = make(map[string]string)
+	}
+	kipodCfg.Labels[key] = value
+	return nil
+}
+
+// printResolvedFields prints the effective config fields with where their
+// values came from
+func printResolvedFields(fields []config.ResolvedField) {
+	fmt.Printf("%-40s %-40s %s\n", "FIELD", "VALUE", "SOURCE")
+	for _, field := range fields {
+		source := string(field.Source)
+		if field.Origin != "" {
+			source += " " + field.Origin
+		}
+		fmt.Printf("%-40s %-40s %s\n", field.Path, field.Value, source)
+	}
+}
+
+func createCluster(opts createClusterOptions) error {
+	configFile, output := opts.ConfigFile, opts.Output
+
+	switch output {
+	case "":
+	case "json":
+		// Keep stdout for the JSON result
+		style.SetOutput(os.Stderr)
+	default:
+		return fmt.Errorf("unsupported output format %q (supported: json)", output)
 	}
 
-	// Override cluster name if provided via flag
-	if name != "" {
-		kipodCfg.Name = name
+	resolver, err := resolveCreateConfig(opts)
+	if err != nil {
+		return err
+	}
+	if opts.ExplainConfig {
+		printResolvedFields(resolver.Fields())
+		return nil
 	}
+	kipodCfg := resolver.Config
 
 	// Print header now that we know the cluster name
 	if !quietMode {
@@ -39,21 +195,177 @@ func createCluster(name, configFile, nodeImage, kubeconfigPath string, retain bo
 		if configFile != "" {
 			style.Header("Using configuration from: %s", configFile)
 		}
+		if opts.Profile != "" {
+			style.Header("Using profile: %s", opts.Profile)
+		}
+	}
+
+	result, exists, err := provisionCluster(kipodCfg, opts)
+	if err != nil {
+		if output == "json" && result != nil {
+			if data, err := json.MarshalIndent(result, "", "  "); err == nil {
+				fmt.Println(string(data))
+			}
+		}
+		return err
+	}
+
+	if output == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if !quietMode {
+		printTimings(result.Phases, time.Duration(result.TotalSeconds*float64(time.Second)))
+		if exists {
+			style.Header("\nCluster %q is up to date!", result.Name)
+		} else {
+			style.Header("\nCluster %q created successfully!", result.Name)
+		}
+		style.Header("\nTo start using your cluster, run:")
+		style.Header("  export KUBECONFIG=%s", result.Kubeconfig)
+		style.Header("  kubectl get nodes")
+	}
+
+	return nil
+}
+
+// provisionCluster creates the cluster of a resolved config, or adopts it
+// with opts.Reuse, records its state and writes its kubeconfig. It reports
+// whether the cluster existed. The result is nil when provisioning did not
+// start, and carries the error when provisioning failed.
+func provisionCluster(kipodCfg *config.ClusterConfig, opts createClusterOptions) (*createResult, bool, error) {
+	cfg, err := newClusterConfig(kipodCfg, kipodCfg.Image, opts.Retain, opts.WaitDuration)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cfg.WaitAll = opts.WaitAll
+	cfg.StrictPreflight = opts.StrictPreflight
+
+	if err := system.RequireCgroupV2(); err != nil {
+		return nil, false, err
+	}
+
+	exists, err := cluster.Exists(cfg.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	if exists && !opts.Reuse {
+		return nil, true, fmt.Errorf("cluster %q already exists (use --reuse to adopt it, or delete it first)", cfg.Name)
+	}
+	if exists {
+		if err := cluster.CheckOwner(cfg.Name, opts.Force); err != nil {
+			return nil, true, err
+		}
+		// New workers keep the owner of the cluster they join
+		owners, err := cluster.Owners()
+		if err != nil {
+			return nil, true, err
+		}
+		cfg.Owner = owners[cfg.Name]
+	}
+	if !exists {
+		warnTmpfsCapacity(cfg)
+	}
+
+	c, err := cluster.NewCluster(cfg)
+	if err != nil {
+		return nil, exists, fmt.Errorf("failed to create cluster: %w", err)
+	}
+
+	if opts.ExecSessions {
+		podman.EnableExecPool()
+		defer podman.CloseExecSessions()
+	}
+
+	start := time.Now()
+	var provisionErr error
+	if exists {
+		if err := c.Reuse(); err != nil {
+			provisionErr = fmt.Errorf("failed to reuse cluster: %w", err)
+		}
+	} else if err := c.Create(); err != nil {
+		provisionErr = fmt.Errorf("failed to provision cluster: %w", err)
+	}
+	result := &createResult{
+		Name:         kipodCfg.Name,
+		Phases:       c.Timings(),
+		TotalSeconds: time.Since(start).Seconds(),
+	}
+
+	// Save the config even when provisioning failed, to reproduce the failure
+	if opts.SaveConfig != "" {
+		if err := config.SaveToFile(effectiveConfig(kipodCfg, cfg), opts.SaveConfig); err != nil {
+			style.Info("Warning: failed to save the effective config: %v", err)
+		} else if !quietMode {
+			style.Header("Saved the effective config to %s", opts.SaveConfig)
+		}
+	}
+	if provisionErr != nil {
+		result.Error = provisionErr.Error()
+		var waitErr *cluster.WaitError
+		if errors.As(provisionErr, &waitErr) {
+			result.Reason = waitErr.Reason
+		}
+		return result, exists, provisionErr
+	}
+
+	if err := saveClusterState(kipodCfg, cfg); err != nil {
+		style.Info("Warning: failed to record cluster state: %v", err)
+	}
+	if err := recordClusterInfo(c, kipodCfg, cfg); err != nil {
+		style.Info("Warning: failed to record cluster info in the cluster: %v", err)
+	}
+
+	// Automatically export kubeconfig
+	result.Kubeconfig, err = writeClusterKubeconfig(kipodCfg.Name, opts.KubeconfigPath)
+	if err != nil {
+		return nil, exists, err
 	}
 
+	if kipodCfg.Addons.CertManager.TrustOnHost && !exists {
+		if err := trustClusterCA(kipodCfg.Name, false); err != nil {
+			style.Info("Warning: did not trust the cluster CA: %v", err)
+		}
+	}
+	return result, exists, nil
+}
+
+// newClusterConfig maps a kipod config to a cluster.Config and validates
+// host-side inputs such as local binaries and devices
+func newClusterConfig(kipodCfg *config.ClusterConfig, nodeImage string, retain bool, waitDuration string) (*cluster.Config, error) {
 	// Map config to cluster.Config
 	cfg := &cluster.Config{
 		Name:          kipodCfg.Name,
-		Nodes:         kipodCfg.Nodes.ControlPlanes + kipodCfg.Nodes.Workers,
-		ControlPlanes: kipodCfg.Nodes.ControlPlanes,
-		Workers:       kipodCfg.Nodes.Workers,
+		Labels:        kipodCfg.Labels,
+		Owner:         cluster.CurrentOwner(),
+		Nodes:         kipodCfg.TotalNodes(),
+		ControlPlanes: kipodCfg.ControlPlaneCount(),
+		Workers:       kipodCfg.WorkerCount(),
 		Image:         nodeImage, // Use flag value if provided
 		PodSubnet:     kipodCfg.Networking.PodSubnet,
 		ServiceSubnet: kipodCfg.Networking.ServiceSubnet,
+		Network:       kipodCfg.Networking.Network,
+		APIServerPort: kipodCfg.Networking.APIServerPort,
 		CgroupManager: kipodCfg.CgroupManager,
+		CRIOProfile:   kipodCfg.CRIOProfile,
+		// Experimental features
+		EventedPLEG:       kipodCfg.Features.EventedPLEG || kipodCfg.CRIOProfile == "evented-pleg",
+		CheckpointRestore: kipodCfg.Features.CheckpointRestore,
+		ImageVolume:       kipodCfg.Features.ImageVolume || kipodCfg.CRIOProfile == "image-volume",
 		// Storage
-		StorageType: kipodCfg.Storage.Type,
-		StorageSize: kipodCfg.Storage.Size,
+		StorageType:   kipodCfg.Storage.Type,
+		StorageSize:   kipodCfg.Storage.Size,
+		StorageDriver: kipodCfg.Storage.Driver,
+		// Kubelet image garbage collection
+		ImageGCHighThresholdPercent: kipodCfg.ImageGC.HighThresholdPercent,
+		ImageGCLowThresholdPercent:  kipodCfg.ImageGC.LowThresholdPercent,
+		ImageMinimumGCAge:           kipodCfg.ImageGC.MinimumAge,
 		// Local builds
 		CRIOBinary: kipodCfg.LocalBuilds.CRIOBinary,
 		CrunBinary: kipodCfg.LocalBuilds.CrunBinary,
@@ -62,6 +374,156 @@ func createCluster(name, configFile, nodeImage, kubeconfigPath string, retain bo
 		// Scheduler configuration
 		SchedulerConfigPath: kipodCfg.Scheduler.ConfigPath,
 		SchedulerExtraArgs:  kipodCfg.Scheduler.ExtraArgs,
+		SchedulerBinary:     kipodCfg.LocalBuilds.KubeSchedulerBinary,
+		SchedulerImage:      kipodCfg.Scheduler.Image,
+		ComponentImages:     kipodCfg.ComponentImages.StaticPodImages(),
+		// Image mirrors
+		ImageRepository:     kipodCfg.ImageRepository,
+		DNSImageRepository:  kipodCfg.DNS.ImageRepository,
+		DNSImageTag:         kipodCfg.DNS.ImageTag,
+		EtcdImageRepository: kipodCfg.Etcd.ImageRepository,
+		EtcdImageTag:        kipodCfg.Etcd.ImageTag,
+		// Optional runtimes
+		WasmRuntime:    kipodCfg.Runtimes.Wasm,
+		SandboxRuntime: kipodCfg.Runtimes.Sandboxed,
+		// Netfilter backend of the nodes
+		IptablesBackend: iptablesBackend(kipodCfg.Networking.IptablesBackend),
+		// Inotify limits
+		InotifyMaxUserWatches:   kipodCfg.Inotify.MaxUserWatches,
+		InotifyMaxUserInstances: kipodCfg.Inotify.MaxUserInstances,
+		// Node container restarts and healthcheck
+		RestartPolicy:       kipodCfg.NodeHealth.RestartPolicy,
+		DisableHealthcheck:  kipodCfg.NodeHealth.Healthcheck.Disabled,
+		HealthcheckInterval: kipodCfg.NodeHealth.Healthcheck.Interval,
+		// Experimental external etcd
+		ExternalEtcd: kipodCfg.Etcd.External,
+		EtcdImage:    kipodCfg.Etcd.Image,
+		EtcdReplicas: kipodCfg.Etcd.Replicas,
+		// etcd tuning
+		EtcdAutoCompactionMode:      kipodCfg.Etcd.AutoCompactionMode,
+		EtcdAutoCompactionRetention: kipodCfg.Etcd.AutoCompactionRetention,
+		EtcdDataOnVolume:            kipodCfg.Etcd.DataOnVolume,
+		EtcdUnsafeNoFsync:           kipodCfg.Etcd.UnsafeNoFsync,
+		// Node component log levels
+		LogLevels: kipodCfg.ComponentLogLevels,
+		// kubeadm
+		IgnorePreflightErrors: kipodCfg.IgnorePreflightErrors,
+		SkipPhases:            kipodCfg.Kubeadm.SkipPhases,
+		ExtraInitArgs:         kipodCfg.Kubeadm.ExtraInitArgs,
+		CI:                    inCI(),
+	}
+
+	// Convert node pools; plain worker counts form the "worker" pool
+	if kipodCfg.Nodes.Workers > 0 {
+		cfg.Pools = append(cfg.Pools, cluster.NodePool{Name: "worker", Count: kipodCfg.Nodes.Workers})
+	}
+	for _, pool := range kipodCfg.Nodes.Pools {
+		taints, err := convertTaints(pool.Taints)
+		if err != nil {
+			return nil, err
+		}
+		if pool.Role == config.RoleControlPlane {
+			cfg.ControlPlaneLabels = pool.Labels
+			cfg.ControlPlaneTaints = taints
+			continue
+		}
+		cfg.Pools = append(cfg.Pools, cluster.NodePool{
+			Name:   pool.Name,
+			Count:  pool.Count,
+			Labels: pool.Labels,
+			Taints: taints,
+		})
+	}
+
+	for _, vol := range kipodCfg.NodeStorage.ExtraVolumes {
+		cfg.ExtraVolumes = append(cfg.ExtraVolumes, vol.MountPath)
+	}
+
+	// Resolve systemd units and drop-ins to their content
+	for _, unit := range kipodCfg.ExtraSystemdUnits {
+		content, err := systemdFileContent(unit.Path, unit.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read unit %s: %w", unit.Name, err)
+		}
+		cfg.SystemdUnits = append(cfg.SystemdUnits, cluster.SystemdUnit{
+			Name:    unit.Name,
+			Content: content,
+			Enable:  unit.Enable,
+		})
+	}
+	cfg.NodeEntrypoint = kipodCfg.NodeEntrypoint
+	cfg.NodeCommand = kipodCfg.NodeCommand
+	for _, override := range kipodCfg.UnitOverrides {
+		content, err := systemdFileContent(override.Path, override.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read drop-in for %s: %w", override.Unit, err)
+		}
+		cfg.UnitOverrides = append(cfg.UnitOverrides, cluster.UnitOverride{
+			Unit:    override.Unit,
+			Name:    override.Name,
+			Content: content,
+		})
+	}
+
+	if kipodCfg.Addons.CertManager.Enabled {
+		cfg.CertManagerVersion = kipodCfg.Addons.CertManager.Version
+		if cfg.CertManagerVersion == "" {
+			cfg.CertManagerVersion = cluster.DefaultCertManagerVersion
+		}
+	}
+
+	if kipodCfg.Addons.Kwok.Nodes > 0 {
+		cfg.FakeNodes = kipodCfg.Addons.Kwok.Nodes
+		cfg.KwokVersion = kipodCfg.Addons.Kwok.Version
+		if cfg.KwokVersion == "" {
+			cfg.KwokVersion = cluster.DefaultKwokVersion
+		}
+	}
+
+	for _, chart := range kipodCfg.HelmCharts {
+		helmChart, err := convertHelmChart(chart)
+		if err != nil {
+			return nil, err
+		}
+		cfg.HelmCharts = append(cfg.HelmCharts, helmChart)
+	}
+
+	if kipodCfg.RegistryAuth.AuthFile != "" {
+		auth, err := readRegistryAuth(kipodCfg.RegistryAuth.AuthFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registryAuth.authFile: %w", err)
+		}
+		cfg.RegistryAuth = auth
+		cfg.RegistryAuthNodes = kipodCfg.RegistryAuth.Nodes
+		cfg.RegistryAuthPullSecret = kipodCfg.RegistryAuth.ImagePullSecret
+	}
+
+	if kipodCfg.SignaturePolicy.Enabled() {
+		if err := readSignaturePolicy(kipodCfg.SignaturePolicy, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read post-create manifests now so a bad path fails before any node exists
+	for _, source := range kipodCfg.PostCreateManifests {
+		content, err := readManifest(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read post-create manifest %s: %w", source, err)
+		}
+		cfg.PostCreateManifests = append(cfg.PostCreateManifests, cluster.Manifest{Source: source, Content: content})
+	}
+
+	// Merge node container options per role
+	cfg.NodeOptions = make(map[string]cluster.NodeOptions)
+	for _, role := range []string{"control-plane", "worker"} {
+		opts := kipodCfg.NodeOptions.ForRole(role)
+		cfg.NodeOptions[role] = cluster.NodeOptions{
+			Sysctls:      opts.Sysctls,
+			SecurityOpts: opts.SecurityOpts,
+			Devices:      opts.Devices,
+			Ulimits:      opts.Ulimits,
+			Env:          kipodCfg.NodeEnv.ForRole(role),
+		}
 	}
 
 	// Convert scheduler extra volumes
@@ -75,10 +537,18 @@ func createCluster(name, configFile, nodeImage, kubeconfigPath string, retain bo
 		})
 	}
 
+	if kipodCfg.Etcd.QuotaBackendBytes != "" {
+		quota, err := system.ParseSize(kipodCfg.Etcd.QuotaBackendBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid etcd.quotaBackendBytes: %w", err)
+		}
+		cfg.EtcdQuotaBackendBytes = quota
+	}
+
 	if waitDuration != "" {
 		d, err := time.ParseDuration(waitDuration)
 		if err != nil {
-			return fmt.Errorf("invalid wait duration: %w", err)
+			return nil, fmt.Errorf("invalid wait duration: %w", err)
 		}
 		cfg.WaitDuration = d
 	}
@@ -86,7 +556,7 @@ func createCluster(name, configFile, nodeImage, kubeconfigPath string, retain bo
 	// Validate local build paths exist
 	if cfg.CRIOBinary != "" {
 		if _, err := os.Stat(cfg.CRIOBinary); err != nil {
-			return fmt.Errorf("CRI-O binary not found at %s: %w", cfg.CRIOBinary, err)
+			return nil, fmt.Errorf("CRI-O binary not found at %s: %w", cfg.CRIOBinary, err)
 		}
 		if !quietMode {
 			style.Header("Using local CRI-O binary: %s", cfg.CRIOBinary)
@@ -94,7 +564,7 @@ func createCluster(name, configFile, nodeImage, kubeconfigPath string, retain bo
 	}
 	if cfg.CrunBinary != "" {
 		if _, err := os.Stat(cfg.CrunBinary); err != nil {
-			return fmt.Errorf("crun binary not found at %s: %w", cfg.CrunBinary, err)
+			return nil, fmt.Errorf("crun binary not found at %s: %w", cfg.CrunBinary, err)
 		}
 		if !quietMode {
 			style.Header("Using local crun binary: %s", cfg.CrunBinary)
@@ -102,76 +572,364 @@ func createCluster(name, configFile, nodeImage, kubeconfigPath string, retain bo
 	}
 	if cfg.RuncBinary != "" {
 		if _, err := os.Stat(cfg.RuncBinary); err != nil {
-			return fmt.Errorf("runc binary not found at %s: %w", cfg.RuncBinary, err)
+			return nil, fmt.Errorf("runc binary not found at %s: %w", cfg.RuncBinary, err)
 		}
 		if !quietMode {
 			style.Header("Using local runc binary: %s", cfg.RuncBinary)
 		}
 	}
 
-	c, err := cluster.NewCluster(cfg)
+	if cfg.SchedulerBinary != "" {
+		if _, err := os.Stat(cfg.SchedulerBinary); err != nil {
+			return nil, fmt.Errorf("kube-scheduler binary not found at %s: %w", cfg.SchedulerBinary, err)
+		}
+		if !quietMode {
+			style.Header("Using local kube-scheduler binary: %s", cfg.SchedulerBinary)
+		}
+	}
+
+	// Validate passthrough devices exist on the host
+	for role, opts := range cfg.NodeOptions {
+		for _, dev := range opts.Devices {
+			src, _, _ := strings.Cut(dev, ":")
+			if _, err := os.Stat(src); err != nil {
+				return nil, fmt.Errorf("device %s for %s nodes not found: %w", src, role, err)
+			}
+		}
+	}
+
+	// WSL setups that are not ready otherwise fail deep inside node boot
+	if system.IsWSL() {
+		results := system.ValidateHost()
+		for _, result := range results {
+			if !result.Passed {
+				style.Info("%s: %s", result.Name, result.Message)
+			}
+		}
+		if system.HasFatalErrors(results) {
+			return nil, fmt.Errorf("WSL is not set up to run kipod (run 'kipod check' for details)")
+		}
+	}
+
+	// Sandboxed runtimes depend on host features we can verify up front
+	if cfg.SandboxRuntime != "" {
+		results := system.ValidateSandboxRuntime(cfg.SandboxRuntime)
+		for _, result := range results {
+			if !result.Passed {
+				style.Info("%s: %s", result.Name, result.Message)
+			}
+		}
+		if system.HasFatalErrors(results) {
+			return nil, fmt.Errorf("host does not support the %s sandboxed runtime", cfg.SandboxRuntime)
+		}
+		if !quietMode {
+			style.Header("Using experimental sandboxed runtime: %s", cfg.SandboxRuntime)
+		}
+	}
+
+	return cfg, nil
+}
+
+// warnTmpfsCapacity warns when the tmpfs image storage of the nodes can
+// outgrow host memory, since nodes then fail on image pulls long after create
+func warnTmpfsCapacity(cfg *cluster.Config) {
+	if cfg.StorageType == "volume" {
+		return
+	}
+	topology := system.DefaultTopology()
+	topology.Nodes = cfg.Nodes
+	if cfg.StorageSize != "" {
+		topology.StorageSize = cfg.StorageSize
+	}
+	if result := system.CheckTmpfsCapacity(topology); !result.Passed {
+		style.Info("Warning: %s", result.Message)
+	}
+}
+
+// systemdFileContent returns inline content, or reads it from path
+func systemdFileContent(path, content string) (string, error) {
+	if path == "" {
+		return content, nil
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to create cluster: %w", err)
+		return "", err
 	}
+	return string(data), nil
+}
 
-	if err := c.Create(); err != nil {
-		return fmt.Errorf("failed to provision cluster: %w", err)
+// convertHelmChart reads the values of a chart into values files, the file
+// first so inline values override it
+func convertHelmChart(chart config.HelmChart) (cluster.HelmChart, error) {
+	helmChart := cluster.HelmChart{
+		Name:      chart.ReleaseName(),
+		Namespace: chart.Namespace,
+		Repo:      chart.Repo,
+		Chart:     chart.Chart,
+		Version:   chart.Version,
 	}
+	if chart.ValuesFile != "" {
+		data, err := os.ReadFile(chart.ValuesFile)
+		if err != nil {
+			return helmChart, fmt.Errorf("failed to read values of helm chart %s: %w", helmChart.Name, err)
+		}
+		helmChart.Values = append(helmChart.Values, string(data))
+	}
+	if len(chart.Values) > 0 {
+		data, err := yaml.Marshal(chart.Values)
+		if err != nil {
+			return helmChart, fmt.Errorf("failed to marshal values of helm chart %s: %w", helmChart.Name, err)
+		}
+		helmChart.Values = append(helmChart.Values, string(data))
+	}
+	return helmChart, nil
+}
 
-	// Use the final cluster name (from config or flag override)
-	clusterName := kipodCfg.Name
+// readManifest reads a manifest from a local path or downloads it from a URL
+func readManifest(source string) (string, error) {
+	if !config.IsManifestURL(source) {
+		data, err := os.ReadFile(source)
+		return string(data), err
+	}
 
-	// Automatically export kubeconfig
-	// fmt.Printf("\nExporting kubeconfig...\n")
-	kubeconfig, err := cluster.GetKubeconfig(clusterName)
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(source)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// readRegistryAuth reads a containers auth.json or Docker config.json and
+// checks it has inline credentials, since nodes cannot run the host's
+// credential helpers
+func readRegistryAuth(path string) (string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to get kubeconfig: %w", err)
+		return "", err
+	}
+	var auth struct {
+		Auths       map[string]json.RawMessage `json:"auths"`
+		CredHelpers map[string]string          `json:"credHelpers"`
+		CredsStore  string                     `json:"credsStore"`
+	}
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return "", fmt.Errorf("%s is not an auth.json file: %w", path, err)
+	}
+	if len(auth.Auths) == 0 {
+		if auth.CredsStore != "" || len(auth.CredHelpers) > 0 {
+			return "", fmt.Errorf("%s only refers to credential helpers; write inline credentials with: podman login --authfile FILE REGISTRY", path)
+		}
+		return "", fmt.Errorf("%s has no credentials under \"auths\"", path)
+	}
+	return string(data), nil
+}
+
+// iptablesBackend returns the iptables backend of the nodes: the configured
+// one, or for auto the backend of the host
+func iptablesBackend(configured string) string {
+	if configured != "" && configured != "auto" {
+		return configured
+	}
+	return system.HostIptablesBackend()
+}
+
+// readSignaturePolicy reads the policy, public key and registries.d files of
+// the signaturePolicy config, or generates the test policy, into cfg
+func readSignaturePolicy(policy config.SignaturePolicyConfig, cfg *cluster.Config) error {
+	cfg.SignatureRegistries = make(map[string]string)
+	if policy.PublicKey != "" {
+		data, err := os.ReadFile(policy.PublicKey)
+		if err != nil {
+			return fmt.Errorf("invalid signaturePolicy.publicKey: %w", err)
+		}
+		if !strings.Contains(string(data), "PUBLIC KEY-----") {
+			return fmt.Errorf("invalid signaturePolicy.publicKey: %s is not a PEM public key", policy.PublicKey)
+		}
+		cfg.SignatureKey = string(data)
 	}
 
-	// Patch kubeconfig to use localhost instead of the container/host IP
-	// This is necessary because the API server is published on localhost:6443
-	kubeconfigPatched := patchKubeconfigServer(kubeconfig)
+	if policy.PolicyFile != "" {
+		data, err := os.ReadFile(policy.PolicyFile)
+		if err != nil {
+			return fmt.Errorf("invalid signaturePolicy.policyFile: %w", err)
+		}
+		var doc struct {
+			Default []json.RawMessage `json:"default"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid signaturePolicy.policyFile: %s is not a containers-policy.json file: %w", policy.PolicyFile, err)
+		}
+		if len(doc.Default) == 0 {
+			// containers/image refuses policies without a default
+			return fmt.Errorf("invalid signaturePolicy.policyFile: %s has no \"default\" requirements", policy.PolicyFile)
+		}
+		cfg.SignaturePolicy = string(data)
+	} else {
+		var registries string
+		cfg.SignaturePolicy, registries = cluster.TestSignaturePolicy(policy.RequireSigned)
+		cfg.SignatureRegistries["require-signed.yaml"] = registries
+	}
 
-	// Create .kube directory if it doesn't exist
-	kubeconfigDir := fmt.Sprintf("%s/.kube", os.Getenv("HOME"))
-	if err := os.MkdirAll(kubeconfigDir, 0755); err != nil {
-		return fmt.Errorf("failed to create .kube directory: %w", err)
+	if policy.RegistriesDir != "" {
+		entries, err := os.ReadDir(policy.RegistriesDir)
+		if err != nil {
+			return fmt.Errorf("invalid signaturePolicy.registriesDir: %w", err)
+		}
+		for _, entry := range entries {
+			// Like containers/image, only read .yaml files
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+				continue
+			}
+			if _, ok := cfg.SignatureRegistries[entry.Name()]; ok {
+				return fmt.Errorf("invalid signaturePolicy.registriesDir: %s is reserved for the generated test policy", entry.Name())
+			}
+			data, err := os.ReadFile(filepath.Join(policy.RegistriesDir, entry.Name()))
+			if err != nil {
+				return fmt.Errorf("invalid signaturePolicy.registriesDir: %w", err)
+			}
+			cfg.SignatureRegistries[entry.Name()] = string(data)
+		}
 	}
+	return nil
+}
 
-	// Write kubeconfig to file
-	exportedPath := fmt.Sprintf("%s/%s-config", kubeconfigDir, clusterName)
-	if kubeconfigPath != "" {
-		exportedPath = kubeconfigPath
+// convertTaints parses kubectl-style taints into cluster taints
+func convertTaints(specs []string) ([]cluster.Taint, error) {
+	var taints []cluster.Taint
+	for _, spec := range specs {
+		taint, err := config.ParseTaint(spec)
+		if err != nil {
+			return nil, err
+		}
+		taints = append(taints, cluster.Taint{Key: taint.Key, Value: taint.Value, Effect: taint.Effect})
 	}
-	if err := os.WriteFile(exportedPath, []byte(kubeconfigPatched), 0600); err != nil {
-		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	return taints, nil
+}
+
+// saveClusterState records the effective config on the host
+func saveClusterState(kipodCfg *config.ClusterConfig, cfg *cluster.Config) error {
+	if cfg.Owner != "" {
+		if err := state.SaveOwner(cfg.Name, cfg.Owner); err != nil {
+			return err
+		}
 	}
+	return state.Save(effectiveConfig(kipodCfg, cfg))
+}
 
-	if !quietMode {
-		style.Header("\nCluster %q created successfully!", clusterName)
-		style.Header("\nTo start using your cluster, run:")
-		style.Header("  export KUBECONFIG=%s", exportedPath)
-		style.Header("  kubectl get nodes")
+// recordClusterInfo publishes the effective config and the provisioning
+// phases of a cluster inside it
+func recordClusterInfo(c *cluster.Cluster, kipodCfg *config.ClusterConfig, cfg *cluster.Config) error {
+	data, err := yaml.Marshal(effectiveConfig(kipodCfg, cfg))
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return c.RecordClusterInfo(cluster.ClusterInfo{KipodVersion: version, Config: string(data)})
+}
+
+// effectiveConfig returns the config including values resolved at creation
+// time, such as the node image and the versions it ships
+func effectiveConfig(kipodCfg *config.ClusterConfig, cfg *cluster.Config) *config.ClusterConfig {
+	effective := *kipodCfg
+	effective.Image = cfg.Image
+	if labels, err := build.GetImageLabels(cfg.Image); err == nil {
+		if v := labels[build.LabelKubernetesVersion]; v != "" {
+			effective.Versions.Kubernetes = strings.TrimPrefix(v, "v")
+		}
+		if v := labels[build.LabelCRIOVersion]; v != "" {
+			effective.Versions.CRIO = v
+		}
+	}
+	return &effective
+}
+
+// getCluster prints the effective config recorded for a cluster
+func getCluster(name, output string) error {
+	cfg, err := state.Load(name)
+	if err != nil {
+		return err
 	}
 
+	var data []byte
+	switch output {
+	case "", "yaml":
+		data, err = yaml.Marshal(cfg)
+	case "json":
+		data, err = json.MarshalIndent(cfg, "", "  ")
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unsupported output format %q (supported: yaml, json)", output)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode cluster config: %w", err)
+	}
+
+	fmt.Print(string(data))
 	return nil
 }
 
+// printTimings prints how long each creation phase took
+func printTimings(timings []cluster.PhaseTiming, total time.Duration) {
+	style.Header("\nTiming:")
+	for _, t := range timings {
+		style.Header("  %-16s %6.1fs", t.Phase, t.Seconds)
+	}
+	style.Header("  %-16s %6.1fs", "total", total.Seconds())
+}
+
+// kubeconfigMu serializes updates of the default kubeconfig
+var kubeconfigMu sync.Mutex
+
 func deleteCluster(name, kubeconfigPath string) error {
+	context := contextName(name, "")
 	if err := cluster.Delete(name); err != nil {
 		return fmt.Errorf("failed to delete cluster: %w", err)
 	}
 
+	if err := state.Delete(name); err != nil {
+		style.Info("Warning: %v", err)
+	}
+
+	// Remove the merged context, if any, from the default kubeconfig;
+	// clusters deleted concurrently share that file
+	kubeconfigMu.Lock()
+	defer kubeconfigMu.Unlock()
+	if merged, err := kubeconfig.Load(kubeconfig.DefaultPath()); err == nil && merged.RemoveCluster(context) {
+		if err := merged.Write(kubeconfig.DefaultPath()); err != nil {
+			style.Info("Warning: failed to remove context %s: %v", context, err)
+		}
+	}
+
 	// Delete the kubeconfig file
 	kubeconfigFile := kubeconfigPath
 	if kubeconfigFile == "" {
-		kubeconfigFile = fmt.Sprintf("%s/.kube/%s-config", os.Getenv("HOME"), name)
+		kubeconfigFile = clusterKubeconfigPath(name)
 	}
 	if err := os.Remove(kubeconfigFile); err != nil && !os.IsNotExist(err) {
 		// Log warning but don't fail - cluster deletion succeeded
 		style.Info("Warning: failed to remove kubeconfig %s: %v", kubeconfigFile, err)
 	}
 
+	// The host alias of the control-plane endpoint outlives the cluster too
+	if system.HasHostsEntry(cluster.ControlPlaneEndpoint(name)) {
+		style.Info("Warning: /etc/hosts still maps %s; remove it with kipod kubeconfig unalias %s", cluster.ControlPlaneEndpoint(name), name)
+	}
+
+	// A trusted CA outlives its cluster until removed, which needs sudo
+	if caPath, err := system.HostCAPath(name); err == nil {
+		if _, err := os.Stat(caPath); err == nil {
+			style.Info("Warning: the CA of cluster %q is still trusted by this host; remove it with kipod ca untrust -n %s", name, name)
+		}
+	}
+
 	if !quietMode {
 		style.Header("Cluster %q deleted successfully!", name)
 	}
@@ -179,33 +937,181 @@ func deleteCluster(name, kubeconfigPath string) error {
 }
 
 func getKubeconfig(name string, internal bool) error {
-	kubeconfig, err := cluster.GetKubeconfig(name)
+	cfg, err := clusterKubeconfig(name, contextName(name, ""), internal)
 	if err != nil {
-		return fmt.Errorf("failed to get kubeconfig: %w", err)
+		return err
 	}
+	data, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(data))
+	return nil
+}
 
-	// Patch kubeconfig based on internal flag
-	kubeconfigOutput := kubeconfig
+// getUserKubeconfig prints a kubeconfig for an RBAC-limited user
+func getUserKubeconfig(name string, opts cluster.UserOptions, internal bool) error {
+	data, err := cluster.UserKubeconfig(name, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create kubeconfig for user %s: %w", opts.User, err)
+	}
 	if !internal {
-		kubeconfigOutput = patchKubeconfigServer(kubeconfig)
+		cfg, err := kubeconfig.Parse([]byte(data))
+		if err != nil {
+			return err
+		}
+		if err := useHostServer(name, cfg); err != nil {
+			return err
+		}
+		out, err := cfg.Marshal()
+		if err != nil {
+			return err
+		}
+		data = string(out)
+	}
+
+	fmt.Print(data)
+	return nil
+}
+
+func exportKubeconfig(name, context, kubeconfigPath string, internal bool) error {
+	if kubeconfigPath == "" {
+		kubeconfigPath = kubeconfig.DefaultPath()
+	}
+	context = contextName(name, context)
+	if err := mergeKubeconfig(name, context, kubeconfigPath, internal); err != nil {
+		return err
 	}
 
-	fmt.Print(kubeconfigOutput)
+	if !quietMode {
+		style.Step("Set kubectl context to %q in %s", context, kubeconfigPath)
+	}
 	return nil
 }
 
-func exportKubeconfig(name, kubeconfigPath string, internal bool) error {
-	// TODO: Implement kubeconfigPath support and merging with existing kubeconfig
-	// For now, just print the kubeconfig like get kubeconfig
-	return getKubeconfig(name, internal)
+// clusterKubeconfigPath returns the per-cluster kubeconfig written on create
+func clusterKubeconfigPath(name string) string {
+	return filepath.Join(paths.KubeDir(), name+"-config")
+}
+
+// writeClusterKubeconfig writes a cluster's kubeconfig, reachable from the
+// host, to path (or the per-cluster default) and returns the path used
+func writeClusterKubeconfig(name, path string) (string, error) {
+	cfg, err := clusterKubeconfig(name, contextName(name, ""), false)
+	if err != nil {
+		return "", err
+	}
+
+	if path == "" {
+		path = clusterKubeconfigPath(name)
+	}
+	if err := cfg.Write(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// mergeKubeconfig merges a cluster's credentials into the kubeconfig at path
+// under context and makes it the current context
+func mergeKubeconfig(name, context, path string, internal bool) error {
+	entry, err := clusterKubeconfig(name, context, internal)
+	if err != nil {
+		return err
+	}
+
+	existing, err := kubeconfig.Load(path)
+	if err != nil {
+		return err
+	}
+	existing.Merge(entry)
+	if err := existing.UseContext(context); err != nil {
+		return err
+	}
+	return existing.Write(path)
+}
+
+// contextName returns the kubeconfig context of a cluster: override, the
+// cluster's kubeconfig.contextName, or kipod-<name>
+func contextName(name, override string) string {
+	if override != "" {
+		return override
+	}
+	if stored, err := state.Load(name); err == nil && stored.Kubeconfig.ContextName != "" {
+		return stored.Kubeconfig.ContextName
+	}
+	return kubeconfig.ContextName(name)
 }
 
-func listClusters() error {
-	clusters, err := cluster.List()
+// clusterKubeconfig returns the admin kubeconfig of a cluster with its
+// cluster, user and context named context, reachable from the host unless
+// internal
+func clusterKubeconfig(name, context string, internal bool) (*kubeconfig.Config, error) {
+	data, err := cluster.GetKubeconfig(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
+	}
+	cfg, err := kubeconfig.ForCluster([]byte(data), context)
+	if err != nil {
+		return nil, err
+	}
+	if !internal {
+		if err := useHostServer(name, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// useHostServer points a cluster's kubeconfig at the API server port
+// published on the host. Clusters advertise their stable control-plane
+// endpoint, which the host resolves once kipod kubeconfig alias added it to
+// /etc/hosts, as long as the API server is published on the port it listens
+// on; any other server, such as the node IP of clusters created before the
+// endpoint existed, is replaced by localhost and the published port.
+func useHostServer(name string, cfg *kubeconfig.Config) error {
+	endpoint := cluster.ControlPlaneEndpoint(name)
+	port := hostAPIServerPort(name)
+	if server, err := url.Parse(cfg.Server()); err == nil && server.Hostname() == endpoint && port == cluster.DefaultAPIServerPort && system.HasHostsEntry(endpoint) {
+		return nil
+	}
+	return cfg.SetServerHost("localhost", port)
+}
+
+// hostAPIServerPort returns the host port a cluster publishes its API server
+// on, as recorded in its state
+func hostAPIServerPort(name string) int {
+	if stored, err := state.Load(name); err == nil && stored.Networking.APIServerPort != 0 {
+		return stored.Networking.APIServerPort
+	}
+	return cluster.DefaultAPIServerPort
+}
+
+func listClusters(output, selector string, allUsers bool) error {
+	switch output {
+	case "", "wide", "json":
+	default:
+		return fmt.Errorf("unsupported output format %q (supported: wide, json)", output)
+	}
+
+	var clusters []string
+	var err error
+	if selector != "" {
+		clusters, err = selectClusters(selector)
+	} else {
+		clusters, err = cluster.List()
+	}
 	if err != nil {
 		return fmt.Errorf("failed to list clusters: %w", err)
 	}
+	clusters, err = ownClusters(clusters, allUsers, "--all-users")
+	if err != nil {
+		return err
+	}
 
+	if output != "" {
+		return printClusterStatuses(clusters, output, allUsers)
+	}
 	if len(clusters) == 0 {
 		fmt.Println("No clusters found.")
 		return nil
@@ -219,9 +1125,21 @@ func listClusters() error {
 	return nil
 }
 
-// patchKubeconfigServer replaces the server address in kubeconfig with localhost:6443
-func patchKubeconfigServer(kubeconfig string) string {
-	// Replace any server address with localhost:6443
-	re := regexp.MustCompile(`server:\s+https://[^\s:]+:6443`)
-	return re.ReplaceAllString(kubeconfig, "server: https://localhost:6443")
+// listNodes prints the node containers of a cluster with their podman state
+// and healthcheck status
+func listNodes(name string) error {
+	nodes, err := cluster.ListNodes(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-40s %-15s %-10s %s\n", "NAME", "ROLE", "STATE", "HEALTH")
+	for _, node := range nodes {
+		health := node.Health
+		if health == "" {
+			health = "-"
+		}
+		fmt.Printf("%-40s %-15s %-10s %s\n", node.NodeName(), node.Labels[podman.LabelRole], node.State, health)
+	}
+	return nil
 }
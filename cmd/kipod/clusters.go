@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/sohankunkerkar/kipod/pkg/state"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+	"github.com/spf13/cobra"
+)
+
+func createClustersCmd() *cobra.Command {
+	var (
+		file string
+		opts createClusterOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "clusters",
+		Short: "Creates several clusters concurrently from a list of configs",
+		Long: `Creates every cluster of a file holding several kipod configs, as YAML
+documents separated by --- or as a list, concurrently, and prints a result per
+cluster. Useful to test multi-cluster operators and fleet tooling.
+
+Clusters are isolated from each other: unless their config sets them, each
+gets its own podman network, kipod-NAME, and publishes its API server on a free
+host port instead of 6443. Both are recorded with the cluster, so kubeconfigs
+exported later point at the right port. Connect clusters afterwards with
+kipod network connect.
+
+It fails when any cluster could not be created; the others are kept.`,
+		Example: `  kipod create clusters -f clusters.yaml
+  kipod create clusters -f clusters.yaml --wait 5m -o json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return createClusters(file, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "path to a file with the kipod config of each cluster")
+	cmd.Flags().StringVar(&opts.NodeImage, "image", "", "node image of clusters whose config sets none (default localhost/kipod-node:latest)")
+	cmd.Flags().BoolVar(&opts.Retain, "retain", false, "retain nodes for debugging when cluster creation fails")
+	cmd.Flags().StringVar(&opts.WaitDuration, "wait", "0s", "wait until workloads can be scheduled on each cluster")
+	cmd.Flags().BoolVar(&opts.WaitAll, "wait-all", false, "wait for all nodes and kube-system pods of each cluster to be Ready")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output format for the results (json)")
+	cmd.Flags().StringArrayVar(&opts.Labels, "label", nil, "label every cluster with key=value, repeatable (adds to config labels)")
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// createClusters creates the clusters of a config list concurrently and
+// prints a result per cluster
+func createClusters(file string, opts createClusterOptions) error {
+	switch opts.Output {
+	case "":
+	case "json":
+		// Keep stdout for the JSON results
+		style.SetOutput(os.Stderr)
+	default:
+		return fmt.Errorf("unsupported output format %q (supported: json)", opts.Output)
+	}
+
+	configs, err := config.LoadAllFromFile(file)
+	if err != nil {
+		return err
+	}
+	if err := isolateClusters(configs); err != nil {
+		return err
+	}
+	for _, kipodCfg := range configs {
+		if kipodCfg.Image == "" {
+			kipodCfg.Image = opts.NodeImage
+		}
+		if kipodCfg.Image == "" {
+			kipodCfg.Image = build.GetImageFullName(build.DefaultImageName, build.DefaultImageTag)
+		}
+		for _, label := range opts.Labels {
+			if err := addLabel(kipodCfg, label); err != nil {
+				return fmt.Errorf("invalid --label: %w", err)
+			}
+		}
+	}
+
+	if !quietMode {
+		style.Header("Creating %d clusters from %s ...", len(configs), file)
+	}
+	results := make([]*createResult, len(configs))
+	var wg sync.WaitGroup
+	for i, kipodCfg := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, _, err := provisionCluster(kipodCfg, opts)
+			if result == nil {
+				result = &createResult{Name: kipodCfg.Name}
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	if opts.Output == "json" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode results: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("%-30s %-16s %s\n", "CLUSTER", "API SERVER", "RESULT")
+		for i, result := range results {
+			apiServer := fmt.Sprintf("localhost:%d", configs[i].Networking.APIServerPort)
+			outcome := fmt.Sprintf("created in %.1fs, kubeconfig %s", result.TotalSeconds, result.Kubeconfig)
+			if result.Error != "" {
+				outcome = "failed: " + result.Error
+			}
+			fmt.Printf("%-30s %-16s %s\n", result.Name, apiServer, outcome)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cluster(s) failed", failed, len(results))
+	}
+	return nil
+}
+
+// isolateClusters checks the clusters of a list can run side by side and
+// gives each one its own network and API server host port unless its config
+// sets them
+func isolateClusters(configs []*config.ClusterConfig) error {
+	names := make(map[string]bool)
+	ports := make(map[int]string)
+	for _, kipodCfg := range configs {
+		if names[kipodCfg.Name] {
+			return fmt.Errorf("cluster %q is listed more than once; give each config a unique name", kipodCfg.Name)
+		}
+		names[kipodCfg.Name] = true
+
+		if port := kipodCfg.Networking.APIServerPort; port != 0 {
+			if other, ok := ports[port]; ok {
+				return fmt.Errorf("clusters %q and %q both publish their API server on port %d", other, kipodCfg.Name, port)
+			}
+			ports[port] = kipodCfg.Name
+		}
+	}
+
+	for _, kipodCfg := range configs {
+		if kipodCfg.Networking.Network == "" {
+			kipodCfg.Networking.Network = "kipod-" + kipodCfg.Name
+		}
+		if kipodCfg.Networking.APIServerPort != 0 {
+			continue
+		}
+		for {
+			port, err := system.FreeHostPort()
+			if err != nil {
+				return err
+			}
+			if _, taken := ports[port]; !taken && port != cluster.DefaultAPIServerPort {
+				ports[port] = kipodCfg.Name
+				kipodCfg.Networking.APIServerPort = port
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// printClusterStatuses checks the health of clusters concurrently and prints
+// it as a wide table, with their owners if withOwner, or a JSON list
+func printClusterStatuses(names []string, output string, withOwner bool) error {
+	statuses := make([]cluster.ClusterStatus, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, err := cluster.GetClusterStatus(name, hostAPIServerPort(name))
+			if err != nil {
+				status = cluster.ClusterStatus{Name: name, State: "unknown", APIServer: cluster.APIServerUnreachable, Error: err.Error()}
+			}
+			// Stopped clusters still have the version they were created with
+			if status.KubernetesVersion == "" {
+				if stored, err := state.Load(name); err == nil && stored.Versions.Kubernetes != "" {
+					status.KubernetesVersion = "v" + stored.Versions.Kubernetes
+				}
+			}
+			if status.Owner == "" {
+				status.Owner = state.LoadOwner(name)
+			}
+			statuses[i] = status
+		}()
+	}
+	wg.Wait()
+
+	if output == "json" {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode cluster status: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(statuses) == 0 {
+		fmt.Println("No clusters found.")
+		return nil
+	}
+	header := fmt.Sprintf("%-24s %-10s %-7s %-7s %-12s %-10s %-6s", "NAME", "STATUS", "NODES", "READY", "API", "VERSION", "AGE")
+	if withOwner {
+		header += " OWNER"
+	}
+	fmt.Println(strings.TrimSpace(header))
+	for _, status := range statuses {
+		ready := "-"
+		if status.NodesRegistered > 0 {
+			ready = fmt.Sprintf("%d/%d", status.NodesReady, status.NodesRegistered)
+		}
+		version := status.KubernetesVersion
+		if version == "" {
+			version = "-"
+		}
+		line := fmt.Sprintf("%-24s %-10s %-7s %-7s %-12s %-10s %-6s", status.Name, status.State,
+			fmt.Sprintf("%d/%d", status.NodesRunning, status.Nodes), ready, status.APIServer, version, formatAge(status.Created))
+		if withOwner {
+			owner := status.Owner
+			if owner == "" {
+				owner = "-"
+			}
+			line += " " + owner
+		}
+		fmt.Println(strings.TrimSpace(line))
+	}
+	return nil
+}
+
+// formatAge formats the time since t like kubectl, e.g. 45s, 12m, 3h or 5d
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	age := time.Since(t)
+	switch {
+	case age < 2*time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < 2*time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
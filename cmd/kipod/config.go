@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+// diffContext is the number of unchanged lines shown around changes
+const diffContext = 3
+
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspects the node configuration of a cluster, one of [diff]",
+		Long: `Compares the node configuration kipod generates from a config file with what
+is installed on the nodes of a running cluster.`,
+		Example: `  kipod config diff --config kipod.yaml --name dev`,
+	}
+
+	cmd.AddCommand(configDiffCmd())
+
+	return cmd
+}
+
+func configDiffCmd() *cobra.Command {
+	var (
+		clusterName string
+		configFile  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Diffs the node configs kipod would generate against the ones installed",
+		Long: `Renders the files kipod generates for the nodes of a cluster from a config
+file (the kubeadm config, CRI-O drop-ins and storage.conf) and diffs them
+against the files installed on the running nodes.
+
+Lines starting with - are what kipod generates, lines starting with + are
+what is installed, so manual edits on a node show up as + lines.`,
+		Example: `  kipod config diff --config kipod.yaml
+  kipod config diff --name dev`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configDiff(clusterName, configFile)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default the config's name, or kipod)")
+	cmd.Flags().StringVar(&configFile, "config", "", "path to the kipod config file the cluster was created from")
+
+	return cmd
+}
+
+func configDiff(name, configFile string) error {
+	resolver, err := resolveCreateConfig(createClusterOptions{Name: name, ConfigFile: configFile})
+	if err != nil {
+		return err
+	}
+	kipodCfg := resolver.Config
+	cfg, err := newClusterConfig(kipodCfg, kipodCfg.Image, false, "0s")
+	if err != nil {
+		return err
+	}
+
+	diffs, err := cluster.DiffConfigs(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to diff node configs: %w", err)
+	}
+	if len(diffs) == 0 {
+		style.Success("Node configs of cluster %q match the config", cfg.Name)
+		return nil
+	}
+
+	for _, diff := range diffs {
+		installed := fmt.Sprintf("+++ %s:%s", diff.Node, diff.Path)
+		if diff.Missing {
+			installed += " (missing)"
+		}
+		fmt.Println(style.Bold(fmt.Sprintf("--- kipod:%s", diff.Path)))
+		fmt.Println(style.Bold(installed))
+		printDiffHunks(diff.Lines)
+	}
+	return nil
+}
+
+// printDiffHunks prints a line diff as unified diff hunks
+func printDiffHunks(lines []cluster.DiffLine) {
+	// Line numbers in the generated and installed file before each line
+	aLine := make([]int, len(lines)+1)
+	bLine := make([]int, len(lines)+1)
+	for i, line := range lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if line.Op != '+' {
+			aLine[i+1]++
+		}
+		if line.Op != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	for start := 0; start < len(lines); {
+		if lines[start].Op == ' ' {
+			start++
+			continue
+		}
+
+		// Extend the hunk while changes are at most two contexts apart
+		first := max(start-diffContext, 0)
+		end := start
+		for i := start; i < len(lines) && i <= end+2*diffContext; i++ {
+			if lines[i].Op != ' ' {
+				end = i
+			}
+		}
+		last := min(end+diffContext, len(lines)-1)
+
+		fmt.Printf("@@ -%d,%d +%d,%d @@\n", aLine[first]+1, aLine[last+1]-aLine[first], bLine[first]+1, bLine[last+1]-bLine[first])
+		for _, line := range lines[first : last+1] {
+			text := string(line.Op) + line.Text
+			switch line.Op {
+			case '-':
+				text = style.Red(text)
+			case '+':
+				text = style.Green(text)
+			}
+			fmt.Println(text)
+		}
+		start = last + 1
+	}
+}
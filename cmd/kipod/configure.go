@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func configureCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "Changes settings of a running cluster, one of [default-runtime]",
+		Long: `Changes settings of the nodes of a running cluster in place, without
+recreating it.`,
+		Example: `  kipod configure default-runtime runc
+  kipod configure default-runtime crun --smoke-test -n dev`,
+	}
+
+	cmd.AddCommand(configureDefaultRuntimeCmd())
+
+	return cmd
+}
+
+func configureDefaultRuntimeCmd() *cobra.Command {
+	var (
+		clusterName  string
+		smokeTest    bool
+		smokeTimeout time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "default-runtime crun|runc",
+		Short: "Switches the default OCI runtime used by CRI-O on all nodes",
+		Long: `Sets the default runtime of CRI-O to crun or runc on every node and restarts
+CRI-O. Running pods keep their runtime; new pods use the new one. With
+--smoke-test, a pod is run afterwards to check that the runtime works.`,
+		Example:   `  kipod configure default-runtime runc --smoke-test`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"crun", "runc"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+
+			return configureDefaultRuntime(clusterName, args[0], smokeTest, smokeTimeout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().BoolVar(&smokeTest, "smoke-test", false, "run a smoke pod after switching to verify the new runtime")
+	cmd.Flags().DurationVar(&smokeTimeout, "smoke-timeout", 2*time.Minute, "how long to wait for the smoke pod to complete")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
+
+func configureDefaultRuntime(name, runtime string, smokeTest bool, smokeTimeout time.Duration) error {
+	if !quietMode {
+		style.Header("Switching cluster %q to %s ...", name, runtime)
+	}
+
+	if err := cluster.SetDefaultRuntime(name, runtime); err != nil {
+		return fmt.Errorf("failed to set default runtime: %w", err)
+	}
+	style.Step("CRI-O restarted with default runtime %s", runtime)
+
+	if smokeTest {
+		style.Step("Running smoke pod (%s) 🧪", cluster.SmokeTestImage)
+		if err := cluster.RunSmokePod(name, smokeTimeout); err != nil {
+			return fmt.Errorf("smoke test failed: %w", err)
+		}
+		style.Step("Smoke pod completed successfully")
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/spf13/cobra"
+)
+
+// crioEndpoint is the CRI-O socket on nodes
+const crioEndpoint = "unix:///var/run/crio/crio.sock"
+
+func crictlCmd() *cobra.Command {
+	var (
+		clusterName string
+		node        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "crictl [--node NODE] -- ARGS...",
+		Short: "Runs crictl on a cluster node",
+		Long: `Runs crictl with the given arguments on a node, pointed at the CRI-O socket.
+
+Nodes are given by their name without the cluster prefix, e.g. worker-0; the
+default is the first control-plane node. Arguments after -- are passed to
+crictl unchanged. The terminal is attached when stdin is one, so crictl exec
+-it works.`,
+		Example: `  kipod crictl --node worker-0 -- ps -a
+  kipod crictl -n dev --node control-plane-0 -- logs -f CONTAINER
+  kipod crictl --node worker-0 -- exec -it CONTAINER sh`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			return runCrictl(clusterName, node, args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&node, "node", "", "node to run crictl on, e.g. worker-0 (default the first control-plane node)")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+	_ = cmd.RegisterFlagCompletionFunc("name", completeClusterNames)
+	_ = cmd.RegisterFlagCompletionFunc("node", completeNodeNames)
+
+	return cmd
+}
+
+func runCrictl(clusterName, node string, args []string) error {
+	var target podman.Container
+	if node == "" {
+		controlPlane, err := cluster.GetControlPlaneNode(clusterName)
+		if err != nil {
+			return err
+		}
+		target = controlPlane
+	} else {
+		nodes, err := selectNodes(clusterName, []string{node})
+		if err != nil {
+			return err
+		}
+		target = nodes[0]
+	}
+
+	execArgs := []string{"exec", "-i"}
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		execArgs = append(execArgs, "-t")
+	}
+	execArgs = append(execArgs, target.ID, "crictl", "--runtime-endpoint", crioEndpoint, "--image-endpoint", crioEndpoint)
+	execCmd := podman.Command(append(execArgs, args...)...)
+	execCmd.Stdin = os.Stdin
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+
+	err := execCmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// crictl has reported the error itself; keep its exit status
+		return &exitCodeError{code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// exitCodeError makes kipod exit with code without reporting an error, for
+// commands whose output already explains the failure
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// completeClusterNames completes the names of existing clusters
+func completeClusterNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, err := cluster.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeNodeNames completes the short node names of the cluster given by
+// --name, or of the default cluster
+func completeNodeNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clusterName, _ := cmd.Flags().GetString("name")
+	clusterName = resolveClusterName(clusterName)
+	nodes, err := cluster.ListNodes(clusterName)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, node := range nodes {
+		if name := shortNodeName(clusterName, node.NodeName()); strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/paths"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/tracing"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// traceVerbosity is the -v level at which podman commands are logged
+	traceVerbosity = 3
+
+	// keepCommandLogs is how many command logs are kept in the cache dir
+	keepCommandLogs = 20
+)
+
+var (
+	// commandLogPath is the command log of this run, if tracing is enabled
+	commandLogPath string
+
+	// traceFile and traceEndpoint receive the OpenTelemetry spans of this run
+	traceFile     string
+	traceEndpoint string
+)
+
+// commandLogDir returns the directory holding per-run podman command logs
+func commandLogDir() (string, error) {
+	if err := paths.CheckOverrides(); err != nil {
+		return "", err
+	}
+	dir := paths.CacheDir()
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("failed to find cache directory: no home directory")
+	}
+	return dir, nil
+}
+
+// listCommandLogs returns the command logs, oldest first
+func listCommandLogs() ([]string, error) {
+	dir, err := commandLogDir()
+	if err != nil {
+		return nil, err
+	}
+	logs, err := filepath.Glob(filepath.Join(dir, "commands-*.log"))
+	if err != nil {
+		return nil, err
+	}
+	// The timestamp in the name sorts chronologically
+	sort.Strings(logs)
+	return logs, nil
+}
+
+// setupTrace logs every podman invocation of this run to a new command log
+// when -v is at least traceVerbosity, and prunes old logs
+func setupTrace() error {
+	if verbosity < traceVerbosity {
+		return nil
+	}
+
+	dir, err := commandLogDir()
+	if err != nil {
+		return err
+	}
+	// The log holds every podman command of the run, keep it private
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create command log directory: %w", err)
+	}
+
+	logs, err := listCommandLogs()
+	if err == nil && len(logs) >= keepCommandLogs {
+		for _, old := range logs[:len(logs)-keepCommandLogs+1] {
+			os.Remove(old)
+		}
+	}
+
+	commandLogPath = filepath.Join(dir, fmt.Sprintf("commands-%s.log", time.Now().Format("20060102-150405.000")))
+	f, err := os.OpenFile(commandLogPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create command log: %w", err)
+	}
+	fmt.Fprintf(f, "# kipod %s: %s\n", version, podman.RedactSecrets(fmt.Sprintf("%q", os.Args)))
+	podman.EnableTrace(f)
+	style.Info("Logging podman commands to %s", commandLogPath)
+	return nil
+}
+
+// setupSpans records OpenTelemetry spans of this run under a root span named
+// after the command when --trace-file, --trace-endpoint or the standard
+// OTEL_EXPORTER_OTLP_* endpoint variables are set. main exports them once the
+// command returns.
+func setupSpans(cmd *cobra.Command) error {
+	endpoint, headers := tracing.EndpointFromEnv()
+	if traceEndpoint != "" {
+		endpoint = traceEndpoint
+	}
+
+	var exporters []tracing.Exporter
+	if traceFile != "" {
+		exporters = append(exporters, tracing.FileExporter(traceFile))
+	}
+	if endpoint != "" {
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			return fmt.Errorf("invalid trace endpoint %q: expected an http:// or https:// OTLP/HTTP URL", endpoint)
+		}
+		exporters = append(exporters, tracing.HTTPExporter(endpoint, headers))
+	}
+	if len(exporters) == 0 {
+		return nil
+	}
+
+	attrs := map[string]interface{}{"service.version": version}
+	tracing.Enable(cmd.CommandPath(), attrs, func(data []byte) error {
+		var errs []error
+		for _, export := range exporters {
+			if err := export(data); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+	return nil
+}
+
+func debugCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Helps debug kipod itself",
+		Long: `Inspects what kipod did, e.g. to attach to a bug report. Runs with -v 3 or
+higher record every podman command they run.`,
+		Example: `  kipod create cluster -v 3
+  kipod debug last-run`,
+	}
+
+	cmd.AddCommand(debugLastRunCmd())
+
+	return cmd
+}
+
+func debugLastRunCmd() *cobra.Command {
+	var pathOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "last-run",
+		Short: "Prints the podman commands of the last run traced with -v 3",
+		Long: `Prints the command log of the most recent kipod run with -v 3 or higher:
+every podman invocation with its start time, duration and exit status, as
+shell lines that can be replayed to reproduce a provisioning issue.`,
+		Example: `  kipod debug last-run
+  kipod debug last-run --path`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs, err := listCommandLogs()
+			if err != nil {
+				return err
+			}
+			// Skip the log of this very run
+			if len(logs) > 0 && logs[len(logs)-1] == commandLogPath {
+				logs = logs[:len(logs)-1]
+			}
+			if len(logs) == 0 {
+				return fmt.Errorf("no command logs found; run kipod with -v %d to record one", traceVerbosity)
+			}
+
+			last := logs[len(logs)-1]
+			if pathOnly {
+				fmt.Println(last)
+				return nil
+			}
+			data, err := os.ReadFile(last)
+			if err != nil {
+				return fmt.Errorf("failed to read command log: %w", err)
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&pathOnly, "path", false, "print only the path of the command log")
+
+	return cmd
+}
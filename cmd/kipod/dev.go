@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+// watchDebounce is how long a binary must stay unchanged before it is reloaded.
+// Compilers and linkers usually write the output in several steps.
+const watchDebounce = 500 * time.Millisecond
+
+func devCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "Developer workflows for one of [reload, watch, webhook, scheduler]",
+		Long: `Shortens the edit-build-test loop of node components and controllers: copy
+local builds into the nodes, rebuild on change, route webhooks to a server on
+the host or run a custom scheduler.`,
+		Example: `  kipod dev reload --binary crio=bin/crio
+  kipod dev watch --binary kubelet=_output/bin/kubelet
+  kipod dev webhook --name my-webhook --namespace system
+  kipod dev scheduler --binary _output/bin/kube-scheduler`,
+	}
+
+	cmd.AddCommand(devReloadCmd())
+	cmd.AddCommand(devWatchCmd())
+	cmd.AddCommand(devWebhookCmd())
+	cmd.AddCommand(devSchedulerCmd())
+
+	return cmd
+}
+
+func devReloadCmd() *cobra.Command {
+	var (
+		clusterName string
+		binaries    []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reload",
+		Short: "Copies local binaries into all nodes and restarts their services",
+		Long: `Copies locally built binaries of node components into every node and
+restarts the services using them, so a change to CRI-O or the kubelet can be
+tried without building a new node image. OCI runtimes have no service; new
+containers use the new binary.`,
+		Example: `  kipod dev reload --binary crio=bin/crio
+  kipod dev reload --binary kubelet=_output/bin/kubelet --name dev`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+
+			parsed, err := parseBinaryFlags(binaries)
+			if err != nil {
+				return err
+			}
+
+			return reloadBinaries(clusterName, parsed)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringArrayVar(&binaries, "binary", nil, fmt.Sprintf("component=path of a local binary to install, one of [%s] (repeatable)", strings.Join(cluster.ReloadComponents(), ", ")))
+	_ = cmd.MarkFlagRequired("binary")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
+
+func devWatchCmd() *cobra.Command {
+	var (
+		clusterName string
+		binaries    []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watches local binaries and reloads them into all nodes whenever they change",
+		Long: `Runs kipod dev reload for a binary each time it is rebuilt, until Ctrl-C.
+The directories of the binaries are watched, so builds that replace the file
+are picked up too.`,
+		Example: `  kipod dev watch --binary crio=bin/crio`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+
+			parsed, err := parseBinaryFlags(binaries)
+			if err != nil {
+				return err
+			}
+
+			return watchBinaries(clusterName, parsed)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringArrayVar(&binaries, "binary", nil, fmt.Sprintf("component=path of a local binary to watch, one of [%s] (repeatable)", strings.Join(cluster.ReloadComponents(), ", ")))
+	_ = cmd.MarkFlagRequired("binary")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
+
+func devWebhookCmd() *cobra.Command {
+	var (
+		clusterName string
+		opts        cluster.WebhookOptions
+		remove      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Routes an admission or conversion webhook Service to a server on the host",
+		Long: `Creates a Service in the cluster whose endpoint is this host, so an admission
+or conversion webhook server can run locally (e.g. from an IDE) against the
+cluster. The server must listen on --host-port on all interfaces, with the
+serving certificate written to --cert-dir (tls.crt, tls.key and ca.crt, the
+controller-runtime defaults). An existing certificate there is reused.
+
+Point the webhook configuration at the Service and the printed caBundle:
+
+  clientConfig:
+    service: {name: NAME, namespace: NAMESPACE, port: 443, path: /validate}
+    caBundle: <printed>`,
+		Example: `  kipod dev webhook --name my-webhook --namespace system
+  kipod dev webhook --name my-webhook --host-port 8443 --cert-dir ./certs
+  kipod dev webhook --name my-webhook --delete`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			if remove {
+				return cluster.DeleteDevWebhook(clusterName, opts)
+			}
+			if opts.HostPort < 1 || opts.HostPort > 65535 {
+				return fmt.Errorf("--host-port must be between 1 and 65535")
+			}
+			return devWebhook(clusterName, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&clusterName, "cluster", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&opts.Name, "name", "", "name of the webhook Service")
+	cmd.Flags().StringVar(&opts.Namespace, "namespace", "default", "namespace of the webhook Service, created if missing")
+	cmd.Flags().IntVar(&opts.HostPort, "host-port", 9443, "port the webhook server listens on, on the host")
+	cmd.Flags().StringVar(&opts.CertDir, "cert-dir", filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs"), "directory for the serving certificate")
+	cmd.Flags().BoolVar(&remove, "delete", false, "delete the webhook Service instead")
+	_ = cmd.MarkFlagRequired("name")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
+
+func devSchedulerCmd() *cobra.Command {
+	var (
+		clusterName string
+		override    cluster.SchedulerOverride
+		reset       bool
+		follow      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scheduler --binary PATH | --image IMAGE | --reset",
+		Short: "Runs a custom kube-scheduler binary or image on the control-plane",
+		Long: `Replaces what the kube-scheduler static pod runs on every control-plane and
+restarts it, keeping the flags and scheduler config kubeadm and kipod set up.
+
+--binary mounts a local, statically linked kube-scheduler over the one of the
+scheduler image; run it again after a rebuild to restart with the new binary.
+--image replaces the image, e.g. a scheduler built with out-of-tree plugins,
+which the nodes pull. --reset restores the kubeadm scheduler.
+--follow then tails the scheduler logs until Ctrl-C.`,
+		Example: `  kipod dev scheduler --binary _output/bin/kube-scheduler --follow
+  kipod dev scheduler --image quay.io/me/my-scheduler:dev
+  kipod dev scheduler --reset`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			set := 0
+			for _, given := range []bool{override.Binary != "", override.Image != "", reset} {
+				if given {
+					set++
+				}
+			}
+			if set != 1 {
+				return fmt.Errorf("set exactly one of --binary, --image or --reset")
+			}
+			if override.Binary != "" {
+				absPath, err := filepath.Abs(override.Binary)
+				if err != nil {
+					return fmt.Errorf("failed to resolve %s: %w", override.Binary, err)
+				}
+				if _, err := os.Stat(absPath); err != nil {
+					return fmt.Errorf("kube-scheduler binary not found at %s: %w", absPath, err)
+				}
+				override.Binary = absPath
+			}
+
+			if err := cluster.OverrideScheduler(clusterName, override); err != nil {
+				return fmt.Errorf("failed to replace kube-scheduler: %w", err)
+			}
+			style.Success("kube-scheduler restarted")
+			if !follow {
+				return nil
+			}
+			controlPlane, err := cluster.GetControlPlaneNode(clusterName)
+			if err != nil {
+				return err
+			}
+			return podman.ExecStream(controlPlane.ID, cluster.SchedulerLogsCommand(), os.Stdout, os.Stderr)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&override.Binary, "binary", "", "local kube-scheduler binary to run")
+	cmd.Flags().StringVar(&override.Image, "image", "", "kube-scheduler image to run")
+	cmd.Flags().BoolVar(&reset, "reset", false, "restore the scheduler kubeadm set up")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "tail the scheduler logs afterwards")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
+
+func devWebhook(name string, opts cluster.WebhookOptions) error {
+	endpoint, err := cluster.DevWebhook(name, opts)
+	if err != nil {
+		return fmt.Errorf("failed to route webhook: %w", err)
+	}
+
+	style.Success("Service %s routes to %s:%d", endpoint.Service, endpoint.HostIP, opts.HostPort)
+	style.Header("Serving certificate: %s", endpoint.CertDir)
+	style.Header("Remove it with: kipod dev webhook --name %s --namespace %s --delete", opts.Name, opts.Namespace)
+	fmt.Printf("caBundle: %s\n", endpoint.CABundle)
+	return nil
+}
+
+// parseBinaryFlags turns component=path flags into a map of absolute paths
+func parseBinaryFlags(flags []string) (map[string]string, error) {
+	binaries := make(map[string]string)
+	for _, flag := range flags {
+		component, path, ok := strings.Cut(flag, "=")
+		if !ok || component == "" || path == "" {
+			return nil, fmt.Errorf("invalid --binary %q, expected component=path", flag)
+		}
+		if err := cluster.ValidateReloadComponent(component); err != nil {
+			return nil, err
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if _, err := os.Stat(absPath); err != nil {
+			return nil, fmt.Errorf("%s binary not found at %s: %w", component, absPath, err)
+		}
+		binaries[component] = absPath
+	}
+	return binaries, nil
+}
+
+func reloadBinaries(name string, binaries map[string]string) error {
+	if err := cluster.ReloadBinaries(name, binaries); err != nil {
+		return fmt.Errorf("failed to reload binaries: %w", err)
+	}
+
+	return printServiceStatus(name)
+}
+
+func printServiceStatus(name string) error {
+	statuses, err := cluster.GetServiceStatus(name)
+	if err != nil {
+		return fmt.Errorf("failed to get service status: %w", err)
+	}
+
+	for _, status := range statuses {
+		services := make([]string, 0, len(status.Services))
+		for service, state := range status.Services {
+			services = append(services, fmt.Sprintf("%s=%s", service, state))
+		}
+		sort.Strings(services)
+		style.Step("%s: %s", status.Node, strings.Join(services, " "))
+	}
+
+	return nil
+}
+
+func watchBinaries(name string, binaries map[string]string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// Watch the parent directories rather than the files themselves: build tools
+	// typically replace the binary with a rename, which would drop a file watch.
+	components := make(map[string]string)
+	for component, path := range binaries {
+		components[path] = component
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", filepath.Dir(path), err)
+		}
+		style.Info("Watching %s binary %s", component, path)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	pending := make(map[string]string)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			component, watched := components[filepath.Clean(event.Name)]
+			if !watched || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			pending[component] = binaries[component]
+			debounce.Reset(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			style.Info("Warning: file watcher error: %v", err)
+
+		case <-debounce.C:
+			style.Header("\nDetected change in %s, reloading...", strings.Join(sortedKeys(pending), ", "))
+			if err := reloadBinaries(name, pending); err != nil {
+				// Keep watching: the next rebuild may well fix the problem
+				style.Info("Warning: %v", err)
+			}
+			pending = make(map[string]string)
+
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+	"github.com/spf13/cobra"
+)
+
+func etcdCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "etcd",
+		Short: "Inspects the etcd of a cluster",
+		Long: `Inspects the etcd members of a cluster, stacked or external, e.g. to see
+whether the database is close to its quota.`,
+		Example: `  kipod etcd status -n dev`,
+	}
+
+	cmd.AddCommand(etcdStatusCmd())
+
+	return cmd
+}
+
+func etcdStatusCmd() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows etcd database size and alarms",
+		Long: `Shows the database size, space in use, leader and raised alarms of every
+etcd member, for both stacked and external etcd. A NOSPACE alarm means the
+backend quota is exhausted; raise etcd.quotaBackendBytes or enable
+auto-compaction in the cluster config.`,
+		Example: `  kipod etcd status
+  kipod etcd status -n dev`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name = resolveClusterName(name)
+			return showEtcdStatus(name)
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+
+	return cmd
+}
+
+func showEtcdStatus(name string) error {
+	statuses, err := cluster.EtcdStatus(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-32s %-10s %-10s %-10s %-7s %s\n", "MEMBER", "VERSION", "DB SIZE", "IN USE", "LEADER", "ALARMS")
+	for _, status := range statuses {
+		alarms := "none"
+		if len(status.Alarms) > 0 {
+			alarms = strings.Join(status.Alarms, ",")
+		}
+		leader := "no"
+		if status.Leader {
+			leader = "yes"
+		}
+		fmt.Printf("%-32s %-10s %-10s %-10s %-7s %s\n", status.Member, status.Version,
+			system.FormatSize(status.DBSize), system.FormatSize(status.DBSizeInUse), leader, alarms)
+	}
+	return nil
+}
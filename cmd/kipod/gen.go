@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// genCmd holds generators used by packaging, hidden from users
+func genCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gen",
+		Short: "Generates one of [docs] for packaging",
+		Long: `Generates files packaging needs from the command tree, such as man pages.
+Hidden from users; run by make docs.`,
+		Example: `  kipod gen docs --format man --dir docs/man`,
+		Hidden:  true,
+	}
+
+	cmd.AddCommand(genDocsCmd())
+
+	return cmd
+}
+
+func genDocsCmd() *cobra.Command {
+	var (
+		dir    string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Generates man pages or a markdown reference of every command",
+		Long: `Writes one page per command of the kipod command tree to --dir: man pages
+in section 1 (kipod-create-cluster.1, ...) for rpm, deb and Homebrew packages,
+or a markdown command reference (kipod_create_cluster.md, ...) linking each
+command to its parent and subcommands. The output has no dates, so the same
+tree always generates the same files.`,
+		Example: `  kipod gen docs --format man --dir out/man1
+  kipod gen docs --format markdown --dir docs/reference`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var gen func(*cobra.Command) (string, []byte)
+			switch format {
+			case "man":
+				gen = manPage
+			case "markdown":
+				gen = markdownPage
+			default:
+				return fmt.Errorf("unsupported format %q (supported: man, markdown)", format)
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", dir, err)
+			}
+			count, err := writeDocs(cmd.Root(), dir, gen)
+			if err != nil {
+				return err
+			}
+			style.Success("Wrote %d pages to %s", count, dir)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "docs", "directory the pages are written to")
+	cmd.Flags().StringVar(&format, "format", "markdown", "page format, one of [man, markdown]")
+
+	return cmd
+}
+
+// writeDocs writes the page of cmd and of every documented command below it
+// to dir, returning the number of pages written. The pages follow the layout
+// of github.com/spf13/cobra/doc (GenManTree, GenMarkdownTree), which is not
+// vendored because its man renderer pulls in go-md2man and blackfriday; once
+// those are vendored, writeDocs and the renderers below give way to it.
+func writeDocs(cmd *cobra.Command, dir string, gen func(*cobra.Command) (string, []byte)) (int, error) {
+	name, page := gen(cmd)
+	if err := os.WriteFile(filepath.Join(dir, name), page, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	count := 1
+	for _, sub := range documentedCommands(cmd) {
+		n, err := writeDocs(sub, dir, gen)
+		if err != nil {
+			return 0, err
+		}
+		count += n
+	}
+	return count, nil
+}
+
+// documentedCommands returns the subcommands of cmd that get a page,
+// leaving out hidden ones and help
+func documentedCommands(cmd *cobra.Command) []*cobra.Command {
+	var subs []*cobra.Command
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() && !sub.IsAdditionalHelpTopicCommand() {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// markdownPage renders the reference page of cmd as markdown
+func markdownPage(cmd *cobra.Command) (string, []byte) {
+	var buf bytes.Buffer
+	path := cmd.CommandPath()
+
+	fmt.Fprintf(&buf, "## %s\n\n%s\n\n", path, cmd.Short)
+	if cmd.Long != "" {
+		fmt.Fprintf(&buf, "### Synopsis\n\n%s\n\n", cmd.Long)
+	}
+	if cmd.Runnable() {
+		fmt.Fprintf(&buf, "```\n%s\n```\n\n", cmd.UseLine())
+	}
+	if cmd.Example != "" {
+		fmt.Fprintf(&buf, "### Examples\n\n```\n%s\n```\n\n", cmd.Example)
+	}
+	if flags := cmd.NonInheritedFlags(); flags.HasAvailableFlags() {
+		fmt.Fprintf(&buf, "### Options\n\n```\n%s```\n\n", flags.FlagUsages())
+	}
+	if flags := cmd.InheritedFlags(); flags.HasAvailableFlags() {
+		fmt.Fprintf(&buf, "### Options inherited from parent commands\n\n```\n%s```\n\n", flags.FlagUsages())
+	}
+
+	buf.WriteString("### SEE ALSO\n\n")
+	if parent := cmd.Parent(); parent != nil {
+		fmt.Fprintf(&buf, "* [%s](%s)\t - %s\n", parent.CommandPath(), markdownName(parent), parent.Short)
+	}
+	for _, sub := range documentedCommands(cmd) {
+		fmt.Fprintf(&buf, "* [%s](%s)\t - %s\n", sub.CommandPath(), markdownName(sub), sub.Short)
+	}
+	return markdownName(cmd), buf.Bytes()
+}
+
+// markdownName returns the file name of the markdown page of cmd
+func markdownName(cmd *cobra.Command) string {
+	return strings.ReplaceAll(cmd.CommandPath(), " ", "_") + ".md"
+}
+
+// manPage renders the man page of cmd in section 1
+func manPage(cmd *cobra.Command) (string, []byte) {
+	var buf bytes.Buffer
+	name := manName(cmd)
+
+	fmt.Fprintf(&buf, ".TH \"%s\" \"1\" \"\" \"kipod %s\" \"kipod Manual\"\n", strings.ToUpper(name), version)
+	fmt.Fprintf(&buf, ".SH NAME\n%s \\- %s\n", name, roffEscape(cmd.Short))
+
+	buf.WriteString(".SH SYNOPSIS\n")
+	use := cmd.CommandPath()
+	if cmd.Runnable() {
+		use = cmd.UseLine()
+	} else {
+		use += " [command]"
+	}
+	fmt.Fprintf(&buf, ".nf\n%s\n.fi\n", roffEscape(use))
+
+	buf.WriteString(".SH DESCRIPTION\n")
+	description := cmd.Long
+	if description == "" {
+		description = cmd.Short
+	}
+	for _, paragraph := range strings.Split(description, "\n\n") {
+		fmt.Fprintf(&buf, ".PP\n%s\n", roffParagraph(paragraph))
+	}
+
+	if flags := cmd.NonInheritedFlags(); flags.HasAvailableFlags() {
+		buf.WriteString(".SH OPTIONS\n")
+		writeManFlags(&buf, flags)
+	}
+	if flags := cmd.InheritedFlags(); flags.HasAvailableFlags() {
+		buf.WriteString(".SH OPTIONS INHERITED FROM PARENT COMMANDS\n")
+		writeManFlags(&buf, flags)
+	}
+	if cmd.Example != "" {
+		fmt.Fprintf(&buf, ".SH EXAMPLE\n.nf\n%s\n.fi\n", roffEscape(cmd.Example))
+	}
+
+	var related []string
+	if parent := cmd.Parent(); parent != nil {
+		related = append(related, fmt.Sprintf("\\fB%s\\fP(1)", manName(parent)))
+	}
+	for _, sub := range documentedCommands(cmd) {
+		related = append(related, fmt.Sprintf("\\fB%s\\fP(1)", manName(sub)))
+	}
+	if len(related) > 0 {
+		fmt.Fprintf(&buf, ".SH SEE ALSO\n%s\n", strings.Join(related, ", "))
+	}
+	return name + ".1", buf.Bytes()
+}
+
+// manName returns the man page name of cmd, e.g. kipod-create-cluster
+func manName(cmd *cobra.Command) string {
+	return strings.ReplaceAll(cmd.CommandPath(), " ", "-")
+}
+
+// writeManFlags writes one tagged paragraph per visible flag
+func writeManFlags(buf *bytes.Buffer, flags *pflag.FlagSet) {
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Hidden {
+			return
+		}
+		names := fmt.Sprintf("\\fB\\-\\-%s\\fP", strings.ReplaceAll(flag.Name, "-", `\-`))
+		if flag.Shorthand != "" {
+			names = fmt.Sprintf("\\fB\\-%s\\fP, %s", flag.Shorthand, names)
+		}
+		varname, usage := pflag.UnquoteUsage(flag)
+		if varname != "" {
+			names += " \\fI" + varname + "\\fP"
+		}
+		if flag.DefValue != "" && flag.DefValue != "false" && flag.DefValue != "[]" && flag.DefValue != "0" && !strings.Contains(usage, "(default") {
+			usage += fmt.Sprintf(" (default %s)", flag.DefValue)
+		}
+		fmt.Fprintf(buf, ".TP\n%s\n%s\n", names, roffEscape(usage))
+	})
+}
+
+// roffParagraph escapes a paragraph of help text, keeping indented lines
+// such as tables and commands verbatim
+func roffParagraph(text string) string {
+	if strings.Contains(text, "\n  ") || strings.HasPrefix(text, "  ") {
+		return ".nf\n" + roffEscape(text) + "\n.fi"
+	}
+	return roffEscape(text)
+}
+
+// roffEscape escapes text for roff: backslashes, and control characters
+// at the start of a line
+func roffEscape(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, `\`, `\e`), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
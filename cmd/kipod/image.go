@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func saveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "save",
+		Short: "Save one of [node-image]",
+		Long: `Saves a node image to an archive, e.g. to copy it to a machine without
+registry access or to cache it in CI.`,
+		Example: `  kipod save node-image -o kipod-node.tar`,
+	}
+
+	cmd.AddCommand(saveNodeImageCmd())
+
+	return cmd
+}
+
+func saveNodeImageCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "node-image [IMAGE]",
+		Short: "Saves a node image to a tarball for use on another machine",
+		Long: `Writes a node image, by default localhost/kipod-node:latest, to a tarball so
+it can be copied to a machine that cannot build it, e.g. one without network
+access. Load it there with kipod load node-image.`,
+		Example: `  kipod save node-image
+  kipod save node-image localhost/kipod-node:v1.34 -o node-v1.34.tar`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			image := build.GetImageFullName("", "")
+			if len(args) > 0 {
+				image = args[0]
+			}
+			if output == "" {
+				output = defaultImageArchive(image)
+			}
+
+			style.Step("Saving %s to %s 📦", image, output)
+			if err := build.SaveImage(image, output); err != nil {
+				return err
+			}
+			style.Success("Saved node image")
+			style.Header("Load it on another machine with: kipod load node-image %s", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "tarball to write (default derived from the image name)")
+
+	return cmd
+}
+
+func loadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "load",
+		Short: "Load one of [node-image]",
+		Long: `Loads a node image saved with kipod save node-image into podman's image
+store.`,
+		Example: `  kipod load node-image kipod-node.tar`,
+	}
+
+	cmd.AddCommand(loadNodeImageCmd())
+
+	return cmd
+}
+
+func loadNodeImageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "node-image FILE",
+		Short: "Loads a node image tarball created with save node-image",
+		Long: `Loads the node images of a tarball written by kipod save node-image into
+podman, under the names they were saved with.`,
+		Example: `  kipod load node-image kipod-node-latest.tar`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			style.Step("Loading %s 📦", args[0])
+			images, err := build.LoadImage(args[0])
+			if err != nil {
+				return err
+			}
+			for _, image := range images {
+				style.Success("Loaded node image %s", image)
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// defaultImageArchive derives a tarball name from an image reference, e.g.
+// localhost/kipod-node:v1.34 becomes kipod-node-v1.34.tar
+func defaultImageArchive(image string) string {
+	name := image[strings.LastIndex(image, "/")+1:]
+	return strings.ReplaceAll(name, ":", "-") + ".tar"
+}
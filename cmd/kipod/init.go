@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func initCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Generates one of [config]",
+		Long: `Generates starting points for kipod files, such as a commented cluster
+config of a profile.`,
+		Example: `  kipod init config > kipod.yaml
+  kipod init config --profile ha > kipod.yaml`,
+	}
+
+	cmd.AddCommand(initConfigCmd())
+
+	return cmd
+}
+
+func initConfigCmd() *cobra.Command {
+	var profile string
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Prints a commented cluster config for a common scenario",
+		Long: fmt.Sprintf(`Prints a commented cluster config to stdout, as a starting point to edit
+and pass to create cluster --config.
+
+Profiles: %s`, strings.Join(config.Profiles(), ", ")),
+		Example: `  kipod init config --profile ha > kipod.yaml
+  kipod create cluster --config kipod.yaml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := config.Profile(profile)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&profile, "profile", "dev", fmt.Sprintf("config profile: %s", strings.Join(config.Profiles(), "|")))
+
+	return cmd
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/kubeconfig"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+	"github.com/spf13/cobra"
+)
+
+func kubeconfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kubeconfig",
+		Short: "Manages kubeconfig contexts of kipod clusters",
+		Long: `Manages how kubeconfig files reach kipod clusters: switch the current context
+to a cluster, or map a cluster's control-plane endpoint to this host.`,
+		Example: `  kipod kubeconfig use dev
+  kipod kubeconfig alias dev
+  kipod kubeconfig unalias dev`,
+	}
+
+	cmd.AddCommand(kubeconfigUseCmd())
+	cmd.AddCommand(kubeconfigAliasCmd())
+	cmd.AddCommand(kubeconfigUnaliasCmd())
+
+	return cmd
+}
+
+func kubeconfigUseCmd() *cobra.Command {
+	var (
+		kubeconfigPath string
+		context        string
+		envOnly        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "use NAME",
+		Short: "Switches the current kubectl context to a kipod cluster",
+		Long: `Merges the cluster's credentials into the kubeconfig as context kipod-NAME,
+or the config's kubeconfig.contextName, and makes it the current context.
+
+With --env, or when the kubeconfig cannot be written, prints an export line
+pointing KUBECONFIG at the cluster's own kubeconfig instead:
+
+  eval "$(kipod kubeconfig use my-cluster --env)"`,
+		Example: `  kipod kubeconfig use dev
+  kipod kubeconfig use dev --context-name dev-local`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return useKubeconfig(args[0], context, kubeconfigPath, envOnly)
+		},
+	}
+
+	cmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "kubeconfig to update instead of $KUBECONFIG or $HOME/.kube/config")
+	cmd.Flags().StringVar(&context, "context-name", "", "name of the context, cluster and user entries (default the config's kubeconfig.contextName, or kipod-NAME)")
+	cmd.Flags().BoolVar(&envOnly, "env", false, "only print the KUBECONFIG export line for this shell")
+
+	return cmd
+}
+
+func useKubeconfig(name, context, kubeconfigPath string, envOnly bool) error {
+	exists, err := cluster.Exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("cluster '%s' not found", name)
+	}
+
+	if !envOnly {
+		if kubeconfigPath == "" {
+			kubeconfigPath = kubeconfig.DefaultPath()
+		}
+		context = contextName(name, context)
+		err := mergeKubeconfig(name, context, kubeconfigPath, false)
+		if err == nil {
+			style.Step("Switched to context %q in %s", context, kubeconfigPath)
+			return nil
+		}
+		style.Info("Warning: could not update %s: %v", kubeconfigPath, err)
+		style.Info("Use the cluster in this shell instead with:")
+	}
+
+	// Fall back to the per-cluster kubeconfig written on create
+	path := clusterKubeconfigPath(name)
+	if _, err := os.Stat(path); err != nil {
+		if path, err = writeClusterKubeconfig(name, path); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("export KUBECONFIG=%s\n", path)
+	return nil
+}
+
+func kubeconfigAliasCmd() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "alias NAME",
+		Short: "Maps the control-plane endpoint of a cluster to this host in /etc/hosts",
+		Long: `Clusters advertise their API server as NAME-control-plane:6443, a name the
+nodes resolve on the cluster network. This adds it to /etc/hosts as
+127.0.0.1, where the API server port is published, through sudo after asking
+for confirmation. Kubeconfigs kipod writes afterwards keep that server
+instead of having it rewritten to localhost:6443.
+
+Rewrite existing kubeconfigs with kipod export kubeconfig -n NAME.`,
+		Example: `  kipod kubeconfig alias dev
+  kipod kubeconfig alias dev --yes`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return aliasControlPlaneEndpoint(args[0], yes)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "do not ask for confirmation")
+
+	return cmd
+}
+
+func kubeconfigUnaliasCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unalias NAME",
+		Short: "Removes the /etc/hosts entry added by kipod kubeconfig alias",
+		Long: `Removes the NAME-control-plane entry kipod kubeconfig alias added to
+/etc/hosts, through sudo. Kubeconfigs written afterwards use localhost:6443.`,
+		Example: `  kipod kubeconfig unalias dev`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint := cluster.ControlPlaneEndpoint(args[0])
+			if err := system.RemoveHostsEntry(endpoint); err != nil {
+				return err
+			}
+			style.Success("Removed %s from /etc/hosts", endpoint)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// aliasControlPlaneEndpoint adds the control-plane endpoint of a cluster to
+// /etc/hosts once the user confirms, or right away with yes
+func aliasControlPlaneEndpoint(name string, yes bool) error {
+	exists, err := cluster.Exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("cluster '%s' not found", name)
+	}
+	endpoint := cluster.ControlPlaneEndpoint(name)
+	if system.HasHostsEntry(endpoint) {
+		style.Info("%s is already in /etc/hosts", endpoint)
+		return nil
+	}
+
+	if !yes {
+		confirmed, err := confirm(fmt.Sprintf("Add %s as 127.0.0.1 to /etc/hosts?", endpoint))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			style.Info("Skipped the alias; kubeconfigs keep using localhost:6443")
+			return nil
+		}
+	}
+
+	if err := system.AddHostsEntry(endpoint); err != nil {
+		return err
+	}
+	style.Success("Mapped %s to 127.0.0.1; remove it with kipod kubeconfig unalias %s", endpoint, name)
+	return nil
+}
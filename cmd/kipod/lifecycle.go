@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func stopCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stops one of [cluster]",
+		Long: `Stops clusters without deleting them. Their node containers keep their state,
+and kipod start cluster brings them back.`,
+		Example: `  kipod stop cluster dev`,
+	}
+
+	cmd.AddCommand(stopClusterCmd())
+
+	return cmd
+}
+
+func stopClusterCmd() *cobra.Command {
+	var (
+		clusterName string
+		all         bool
+		selector    string
+		force       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "cluster [NAME...]",
+		Short: "Stops the node containers of clusters, keeping their state",
+		Long: `Stops the node containers of one or more clusters to free memory and CPU.
+Their storage is kept, so start cluster brings them back as they were.
+
+With --all, every kipod cluster is stopped concurrently and a result is
+printed per cluster; with --selector, every cluster matching the labels.`,
+		Example: `  kipod stop cluster
+  kipod stop cluster --all
+  kipod stop cluster -l team=payments`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := targetClusters(clusterName, args, all, selector, force)
+			if err != nil {
+				return err
+			}
+			return forEachCluster(names, "stopped", func(name string) error {
+				if err := cluster.CheckOwner(name, force); err != nil {
+					return err
+				}
+				if err := cluster.Stop(name); err != nil {
+					return err
+				}
+				if !quietMode {
+					style.Header("Cluster %q stopped", name)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().BoolVar(&all, "all", false, "stop every kipod cluster")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "stop the clusters matching a label selector, e.g. team=payments,env!=prod")
+	cmd.Flags().BoolVar(&force, "force", false, "also stop clusters other users created")
+
+	return cmd
+}
+
+func startCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Starts one of [cluster]",
+		Long: `Starts clusters stopped with kipod stop cluster, or whose nodes stopped with
+the host, and waits for their API servers.`,
+		Example: `  kipod start cluster dev`,
+	}
+
+	cmd.AddCommand(startClusterCmd())
+
+	return cmd
+}
+
+func startClusterCmd() *cobra.Command {
+	var (
+		clusterName string
+		all         bool
+		selector    string
+		force       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "cluster [NAME...]",
+		Short: "Starts the node containers of stopped clusters",
+		Long: `Starts the stopped node containers of one or more clusters, external etcd
+first and workers last, and waits for each API server to answer.
+
+With --all, every kipod cluster is started concurrently and a result is
+printed per cluster; with --selector, every cluster matching the labels.`,
+		Example: `  kipod start cluster
+  kipod start cluster --all
+  kipod start cluster -l team=payments`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := targetClusters(clusterName, args, all, selector, force)
+			if err != nil {
+				return err
+			}
+			return forEachCluster(names, "started", func(name string) error {
+				if err := cluster.CheckOwner(name, force); err != nil {
+					return err
+				}
+				if err := cluster.Start(name); err != nil {
+					return err
+				}
+				if !quietMode {
+					style.Header("Cluster %q started", name)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().BoolVar(&all, "all", false, "start every kipod cluster")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "start the clusters matching a label selector, e.g. team=payments,env!=prod")
+	cmd.Flags().BoolVar(&force, "force", false, "also start clusters other users created")
+
+	return cmd
+}
+
+func deleteClustersCmd() *cobra.Command {
+	var (
+		all            bool
+		selector       string
+		kubeconfigPath string
+		force          bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "clusters [NAME...]",
+		Short: "Deletes several kipod clusters concurrently",
+		Long: `Deletes the named clusters, every kipod cluster with --all, or the clusters
+matching a label selector with --selector, concurrently, and prints a result
+per cluster. Useful to clean up in CI or free resources quickly; it fails when
+any cluster could not be deleted.`,
+		Example: `  kipod delete clusters --all
+  kipod delete clusters dev-a dev-b
+  kipod delete clusters -l ci-job=1234`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !all && selector == "" {
+				return fmt.Errorf("name the clusters to delete or pass --all or --selector")
+			}
+			if len(args) > 1 && kubeconfigPath != "" {
+				return fmt.Errorf("--kubeconfig applies to a single cluster")
+			}
+			names, err := targetClusters("", args, all, selector, force)
+			if err != nil {
+				return err
+			}
+			return forEachCluster(names, "deleted", func(name string) error {
+				if err := cluster.CheckOwner(name, force); err != nil {
+					return err
+				}
+				return deleteCluster(name, kubeconfigPath)
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "delete every kipod cluster")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "delete the clusters matching a label selector, e.g. ci-job=1234")
+	cmd.Flags().BoolVar(&force, "force", false, "also delete clusters other users created")
+	cmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "sets kubeconfig path instead of $KUBECONFIG or $HOME/.kube/config")
+
+	return cmd
+}
+
+// targetClusters returns the clusters a batch command operates on: every
+// cluster with --all, those matching the label selector with --selector,
+// else the named ones, else the default cluster. --all and --selector only
+// pick the clusters of the caller, unless allUsers.
+func targetClusters(name string, args []string, all bool, selector string, allUsers bool) ([]string, error) {
+	if selector != "" {
+		if all || name != "" || len(args) > 0 {
+			return nil, fmt.Errorf("--selector cannot be combined with --all or cluster names")
+		}
+		names, err := selectClusters(selector)
+		if err != nil {
+			return nil, err
+		}
+		return ownClusters(names, allUsers, "--force")
+	}
+	if all {
+		if name != "" || len(args) > 0 {
+			return nil, fmt.Errorf("--all cannot be combined with cluster names")
+		}
+		names, err := cluster.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list clusters: %w", err)
+		}
+		return ownClusters(names, allUsers, "--force")
+	}
+	names := args
+	if name != "" {
+		names = append([]string{name}, names...)
+	}
+	if len(names) == 0 {
+		names = []string{resolveClusterName("")}
+	}
+	return names, nil
+}
+
+// selectClusters returns the clusters whose labels match a label selector
+func selectClusters(selector string) ([]string, error) {
+	sel, err := config.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := cluster.Labels()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name, clusterLabels := range labels {
+		if sel.Matches(clusterLabels) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ownClusters keeps the clusters the caller created, or whose owner was not
+// recorded, sorted by name. With allUsers, it keeps every cluster; else it
+// tells how many it skipped and the flag that includes them.
+func ownClusters(names []string, allUsers bool, flag string) ([]string, error) {
+	sort.Strings(names)
+	if allUsers {
+		return names, nil
+	}
+	owners, err := cluster.Owners()
+	if err != nil {
+		return nil, err
+	}
+	current := cluster.CurrentOwner()
+	var own []string
+	for _, name := range names {
+		if owner := owners[name]; owner == "" || owner == current {
+			own = append(own, name)
+		}
+	}
+	if hidden := len(names) - len(own); hidden > 0 {
+		style.Info("Skipping %d cluster(s) of other users; pass %s to include them", hidden, flag)
+	}
+	return own, nil
+}
+
+// forEachCluster runs fn on every cluster concurrently. With more than one
+// cluster it prints a result per cluster, done or the error, and fails if
+// any of them failed.
+func forEachCluster(names []string, done string, fn func(name string) error) error {
+	if len(names) == 0 {
+		style.Info("No clusters found.")
+		return nil
+	}
+	if len(names) == 1 {
+		return fn(names[0])
+	}
+
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(name)
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	fmt.Printf("%-30s %s\n", "CLUSTER", "RESULT")
+	for i, name := range names {
+		result := done
+		if errs[i] != nil {
+			failed++
+			result = "failed: " + errs[i].Error()
+		}
+		fmt.Printf("%-30s %s\n", name, result)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cluster(s) failed", failed, len(names))
+	}
+	return nil
+}
+
+// guardOwner makes a command that changes a cluster refuse to run on one
+// another user created. It registers flag, normally "force", to override
+// that and checks the owner of each cluster that clusters returns for the
+// parsed arguments before the command runs.
+func guardOwner(cmd *cobra.Command, flag string, clusters func(args []string) []string) {
+	var force bool
+	cmd.Flags().BoolVar(&force, flag, false, "operate on the cluster even if another user created it")
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		for _, name := range clusters(args) {
+			if err := cluster.CheckOwnerFlag(name, force, "--"+flag); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// resolveClusterName returns the cluster a command operates on: name when
+// given with --name or as an argument, else $KIPOD_CLUSTER_NAME, else kipod.
+// Every command taking a cluster name resolves it here.
+func resolveClusterName(name string) string {
+	if name != "" {
+		return name
+	}
+	if name := os.Getenv("KIPOD_CLUSTER_NAME"); name != "" {
+		return name
+	}
+	return "kipod"
+}
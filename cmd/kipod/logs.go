@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/spf13/cobra"
+)
+
+// defaultLogServices are the node services shown when --service is not given
+var defaultLogServices = []string{"crio", "kubelet"}
+
+// logsOptions holds the flags of the logs command
+type logsOptions struct {
+	Name     string
+	Nodes    []string
+	Services []string
+	Follow   bool
+	Since    string
+	Tail     int
+}
+
+func logsCmd() *cobra.Command {
+	var opts logsOptions
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Shows service logs of cluster nodes",
+		Long: `Shows the journal of node services (crio and kubelet by default).
+
+Nodes are given by their name without the cluster prefix, e.g. control-plane-0
+or worker-1. Without --node the logs of all nodes are shown, interleaved by
+time and prefixed with the node name.`,
+		Example: `  kipod logs --node worker-0 --service crio -f
+  kipod logs --name dev --since 10m --tail 100`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = resolveClusterName(opts.Name)
+			return showLogs(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringArrayVar(&opts.Nodes, "node", nil, "node to show logs of, e.g. worker-0 (repeatable, default all nodes)")
+	cmd.Flags().StringArrayVar(&opts.Services, "service", nil, "systemd service to show logs of (repeatable, default crio and kubelet)")
+	cmd.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "follow the logs")
+	cmd.Flags().StringVar(&opts.Since, "since", "", "show entries newer than a relative duration like 10m or a timestamp")
+	cmd.Flags().IntVar(&opts.Tail, "tail", -1, "number of recent entries to show per node, -1 for all")
+
+	return cmd
+}
+
+func showLogs(opts logsOptions) error {
+	nodes, err := selectNodes(opts.Name, opts.Nodes)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"journalctl", "--no-pager", "--output=short-iso-precise"}
+	services := opts.Services
+	if len(services) == 0 {
+		services = defaultLogServices
+	}
+	for _, service := range services {
+		args = append(args, "-u", service)
+	}
+	if opts.Since != "" {
+		args = append(args, "--since", journalSince(opts.Since))
+	}
+	if opts.Tail >= 0 {
+		args = append(args, "-n", strconv.Itoa(opts.Tail))
+	}
+	if opts.Follow {
+		args = append(args, "-f")
+	}
+
+	// A single node streams straight through
+	if len(nodes) == 1 {
+		return podman.ExecStream(nodes[0].ID, args, os.Stdout, os.Stderr)
+	}
+
+	if opts.Follow {
+		return followLogs(opts.Name, nodes, args)
+	}
+	return mergeLogs(opts.Name, nodes, args)
+}
+
+// selectNodes returns the nodes of a cluster matching the given short,
+// full or container names, or all nodes when none are given
+func selectNodes(clusterName string, names []string) ([]podman.Container, error) {
+	nodes, err := cluster.ListNodes(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeName() < nodes[j].NodeName() })
+	if len(names) == 0 {
+		return nodes, nil
+	}
+
+	var selected []podman.Container
+	for _, name := range names {
+		found := false
+		for _, node := range nodes {
+			if node.NodeName() == name || node.NodeName() == clusterName+"-"+name || node.Name == name {
+				selected = append(selected, node)
+				found = true
+				break
+			}
+		}
+		if !found {
+			available := make([]string, 0, len(nodes))
+			for _, node := range nodes {
+				available = append(available, shortNodeName(clusterName, node.NodeName()))
+			}
+			return nil, fmt.Errorf("node %q not found in cluster '%s' (available: %s)", name, clusterName, strings.Join(available, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// shortNodeName strips the cluster prefix from a node name
+func shortNodeName(clusterName, nodeName string) string {
+	return strings.TrimPrefix(nodeName, clusterName+"-")
+}
+
+// journalSince turns a relative duration like "10m" into the "-10m" form
+// journalctl expects; timestamps are passed through
+func journalSince(since string) string {
+	if since != "" && since[0] >= '0' && since[0] <= '9' && !strings.ContainsAny(since, "-: ") {
+		return "-" + since
+	}
+	return since
+}
+
+// prefixWriter prefixes each complete line with the node name before
+// writing it, so concurrent streams interleave line by line
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.mu.Lock()
+		_, err := fmt.Fprintf(w.out, "%s %s\n", w.prefix, w.buf[:i])
+		w.mu.Unlock()
+		if err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// followLogs streams the logs of several nodes concurrently
+func followLogs(clusterName string, nodes []podman.Container, args []string) error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, len(nodes))
+	)
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node podman.Container) {
+			defer wg.Done()
+			prefix := fmt.Sprintf("[%s]", shortNodeName(clusterName, node.NodeName()))
+			stdout := &prefixWriter{mu: &mu, out: os.Stdout, prefix: prefix}
+			stderr := &prefixWriter{mu: &mu, out: os.Stderr, prefix: prefix}
+			if err := podman.ExecStream(node.ID, args, stdout, stderr); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", node.NodeName(), err)
+			}
+		}(i, node)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logEntry is a journal entry, including its continuation lines
+type logEntry struct {
+	timestamp string
+	text      string
+}
+
+// mergeLogs reads the logs of several nodes and prints them ordered by time
+func mergeLogs(clusterName string, nodes []podman.Container, args []string) error {
+	var entries []logEntry
+	for _, node := range nodes {
+		output, err := podman.Exec(node.ID, args)
+		if err != nil {
+			return fmt.Errorf("failed to read logs of %s: %w", node.NodeName(), err)
+		}
+		prefix := fmt.Sprintf("[%s]", shortNodeName(clusterName, node.NodeName()))
+		entries = append(entries, parseJournal(output, prefix)...)
+	}
+
+	// ISO timestamps sort chronologically; keep node order for ties
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].timestamp < entries[j].timestamp })
+	for _, entry := range entries {
+		fmt.Print(entry.text)
+	}
+	return nil
+}
+
+// parseJournal splits short-iso journal output into entries; lines starting
+// with whitespace continue the previous entry and "-- ..." markers are dropped
+func parseJournal(output, prefix string) []logEntry {
+	var entries []logEntry
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" || strings.HasPrefix(line, "-- "):
+			continue
+		case (line[0] == ' ' || line[0] == '\t') && len(entries) > 0:
+			entries[len(entries)-1].text += fmt.Sprintf("%s %s\n", prefix, line)
+		default:
+			timestamp, _, _ := strings.Cut(line, " ")
+			entries = append(entries, logEntry{timestamp: timestamp, text: fmt.Sprintf("%s %s\n", prefix, line)})
+		}
+	}
+	return entries
+}
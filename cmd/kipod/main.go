@@ -1,10 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/sohankunkerkar/kipod/pkg/paths"
 	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+	"github.com/sohankunkerkar/kipod/pkg/tracing"
 	"github.com/spf13/cobra"
 )
 
@@ -18,77 +26,187 @@ var (
 
 func main() {
 	rootCmd := &cobra.Command{
-		Use:          "kipod",
-		Short:        "Kubernetes in Podman with CRI-O",
-		Long:         `kipod creates and manages local Kubernetes clusters using Podman container 'nodes' with CRI-O runtime`,
-		Version:      version,
-		SilenceUsage: true,
+		Use:   "kipod",
+		Short: "Kubernetes in Podman with CRI-O",
+		Long:  `kipod creates and manages local Kubernetes clusters using Podman container 'nodes' with CRI-O runtime`,
+		Example: `  kipod check
+  kipod create cluster
+  kipod get clusters
+  kubectl get nodes
+  kipod delete cluster`,
+		Version:       version,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := setupProgress(); err != nil {
+				return err
+			}
+			if err := paths.CheckOverrides(); err != nil {
+				return err
+			}
+			if err := checkPlatform(cmd); err != nil {
+				return err
+			}
+			if err := setupSpans(cmd); err != nil {
+				return err
+			}
+			return setupTrace()
+		},
 	}
 
 	// Global flags
 	rootCmd.PersistentFlags().BoolVarP(&quietMode, "quiet", "q", false, "silence all stderr output")
-	rootCmd.PersistentFlags().IntVarP(&verbosity, "verbosity", "v", 0, "info log verbosity, higher value produces more output")
+	rootCmd.PersistentFlags().IntVarP(&verbosity, "verbosity", "v", 0, "info log verbosity, higher value produces more output (3 or more logs all podman commands to ~/.cache/kipod)")
+	rootCmd.PersistentFlags().StringVar(&progress, "progress", "auto", "progress output: auto, plain (no emoji or colors; the default in CI, see KIPOD_IN_CI) or json (JSON events on stderr)")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "append OpenTelemetry spans of the provisioning phases and podman commands to this file as OTLP JSON")
+	rootCmd.PersistentFlags().StringVar(&traceEndpoint, "trace-endpoint", "", "export OpenTelemetry spans to this OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces (default $OTEL_EXPORTER_OTLP_TRACES_ENDPOINT)")
 
 	// Add commands
 	rootCmd.AddCommand(buildCmd())
+	rootCmd.AddCommand(saveCmd())
+	rootCmd.AddCommand(loadCmd())
+	rootCmd.AddCommand(initCmd())
 	rootCmd.AddCommand(createCmd())
+	rootCmd.AddCommand(cloneCmd())
 	rootCmd.AddCommand(deleteCmd())
+	rootCmd.AddCommand(stopCmd())
+	rootCmd.AddCommand(startCmd())
+	rootCmd.AddCommand(repairCmd())
 	rootCmd.AddCommand(exportCmd())
 	rootCmd.AddCommand(getCmd())
 	rootCmd.AddCommand(checkCmd())
-
-	if err := rootCmd.Execute(); err != nil {
+	rootCmd.AddCommand(devCmd())
+	rootCmd.AddCommand(configureCmd())
+	rootCmd.AddCommand(configCmd())
+	rootCmd.AddCommand(applyCmd())
+	rootCmd.AddCommand(joinCmd())
+	rootCmd.AddCommand(upgradeCmd())
+	rootCmd.AddCommand(benchCmd())
+	rootCmd.AddCommand(kubeconfigCmd())
+	rootCmd.AddCommand(logsCmd())
+	rootCmd.AddCommand(portForwardCmd())
+	rootCmd.AddCommand(etcdCmd())
+	rootCmd.AddCommand(caCmd())
+	rootCmd.AddCommand(syncCmd())
+	rootCmd.AddCommand(certsCmd())
+	rootCmd.AddCommand(chaosCmd())
+	rootCmd.AddCommand(checkpointCmd())
+	rootCmd.AddCommand(crictlCmd())
+	rootCmd.AddCommand(netemCmd())
+	rootCmd.AddCommand(networkCmd())
+	rootCmd.AddCommand(scaleCmd())
+	rootCmd.AddCommand(pruneCmd())
+	rootCmd.AddCommand(storageCmd())
+	rootCmd.AddCommand(debugCmd())
+	rootCmd.AddCommand(genCmd())
+
+	// Cobra adds its completion commands on Execute; add them now to give
+	// them examples
+	rootCmd.InitDefaultCompletionCmd()
+	addCompletionExamples(rootCmd)
+
+	err := rootCmd.Execute()
+	if exportErr := tracing.Shutdown(err); exportErr != nil {
+		style.Info("Warning: %v", exportErr)
+	}
+	if err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		if !quietMode {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			reportError(err)
 		}
 		os.Exit(1)
 	}
 }
 
+// addCompletionExamples documents how to load the script of each shell
+// cobra's completion command generates
+func addCompletionExamples(rootCmd *cobra.Command) {
+	examples := map[string]string{
+		"":           "  kipod completion bash > ~/.local/share/bash-completion/completions/kipod\n  kipod completion zsh > \"${fpath[1]}/_kipod\"",
+		"bash":       "  source <(kipod completion bash)\n  kipod completion bash > ~/.local/share/bash-completion/completions/kipod",
+		"zsh":        "  source <(kipod completion zsh)\n  kipod completion zsh > \"${fpath[1]}/_kipod\"",
+		"fish":       "  kipod completion fish | source\n  kipod completion fish > ~/.config/fish/completions/kipod.fish",
+		"powershell": "  kipod completion powershell | Out-String | Invoke-Expression",
+	}
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.Name() != "completion" {
+			continue
+		}
+		cmd.Example = examples[""]
+		for _, shell := range cmd.Commands() {
+			shell.Example = examples[shell.Name()]
+		}
+	}
+}
+
 func createCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "create",
-		Short: "Creates one of [cluster]",
+		Short: "Creates one of [cluster, clusters, node]",
+		Long: `Creates clusters from flags or a config file, several clusters at once, or a
+node container to join to a running cluster.`,
+		Example: `  kipod create cluster
+  kipod create cluster dev --config kipod.yaml
+  kipod create node --name gpu-0`,
 	}
 
 	cmd.AddCommand(createClusterCmd())
+	cmd.AddCommand(createClustersCmd())
+	cmd.AddCommand(createNodeCmd())
 
 	return cmd
 }
 
 func createClusterCmd() *cobra.Command {
-	var (
-		configFile     string
-		clusterName    string
-		nodeImage      string
-		kubeconfigPath string
-		retain         bool
-		waitDuration   string
-	)
+	var opts createClusterOptions
 
 	cmd := &cobra.Command{
-		Use:   "cluster",
+		Use:   "cluster [NAME]",
 		Short: "Creates a local Kubernetes cluster",
-		Long:  `Creates a local Kubernetes cluster using Podman container 'nodes'`,
+		Long: `Creates a local Kubernetes cluster using Podman container 'nodes': boots the
+node containers from the node image, initializes the control-plane with
+kubeadm, joins the workers and writes a kubeconfig to ~/.kube/NAME-config.
+
+The topology, versions and CRI-O settings come from --config or --profile;
+flags and KIPOD_* environment variables override them. Use --explain-config to
+see where each value came from without creating anything.`,
+		Example: `  kipod create cluster
+  kipod create cluster dev --config kipod.yaml --wait 5m
+  kipod create cluster --profile ha --explain-config`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Check positional args for cluster name
 			if len(args) > 0 {
-				clusterName = args[0]
+				opts.Name = args[0]
 			}
 
-			// Note: Don't default clusterName here - let createCluster use the config file name
+			// Note: Don't default the name here - let createCluster use the config file name
 			// The default "kipod" is set in the config's Normalize() method
 
-			return createCluster(clusterName, configFile, nodeImage, kubeconfigPath, retain, waitDuration)
+			return createCluster(opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&configFile, "config", "", "path to a kipod config file")
-	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "cluster name, overrides KIPOD_CLUSTER_NAME, config (default kipod)")
-	cmd.Flags().StringVar(&nodeImage, "image", "", "node image to use for booting the cluster")
-	cmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "sets kubeconfig path instead of $KUBECONFIG or $HOME/.kube/config")
-	cmd.Flags().BoolVar(&retain, "retain", false, "retain nodes for debugging when cluster creation fails")
-	cmd.Flags().StringVar(&waitDuration, "wait", "0s", "wait for control plane node to be ready (default 0s)")
+	cmd.Flags().StringVar(&opts.ConfigFile, "config", "", "path to a kipod config file")
+	cmd.Flags().StringVarP(&opts.Name, "name", "n", "", "cluster name, overrides KIPOD_CLUSTER_NAME, config (default kipod)")
+	cmd.Flags().StringVar(&opts.Profile, "profile", "", fmt.Sprintf("create from a built-in config profile instead of --config: %s", strings.Join(config.Profiles(), "|")))
+	cmd.Flags().StringVar(&opts.NodeImage, "image", "", "node image to use for booting the cluster (overrides config, default localhost/kipod-node:latest)")
+	cmd.Flags().StringVar(&opts.KubeconfigPath, "kubeconfig", "", "sets kubeconfig path instead of $KUBECONFIG or $HOME/.kube/config")
+	cmd.Flags().BoolVar(&opts.Retain, "retain", false, "retain nodes for debugging when cluster creation fails")
+	cmd.Flags().StringVar(&opts.WaitDuration, "wait", "0s", "wait until all nodes have joined, DaemonSets are rolled out and a probe pod runs (default 0s)")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "output format for the result and phase timings: json")
+	cmd.Flags().StringVar(&opts.LogLevels, "component-log-level", "", "node component log levels, e.g. kubelet=4,crio=debug (overrides config)")
+	cmd.Flags().BoolVar(&opts.WaitAll, "wait-all", false, "wait for all nodes and kube-system pods to be Ready (bounded by --wait, default 5m or 3m in CI)")
+	cmd.Flags().BoolVar(&opts.StrictPreflight, "strict-preflight", false, "run kubeadm preflight checks without ignoring any errors, to validate real host readiness")
+	cmd.Flags().IntVar(&opts.FakeNodes, "fake-nodes", 0, "register this many simulated kwok nodes for scale testing (overrides addons.kwok.nodes)")
+	cmd.Flags().BoolVar(&opts.Reuse, "reuse", false, "adopt an existing cluster with the same name: start stopped nodes, add missing workers and re-export kubeconfig")
+	cmd.Flags().BoolVar(&opts.ExplainConfig, "explain-config", false, "print the effective config and where each value came from (flag, env, config or default), then exit")
+	cmd.Flags().BoolVar(&opts.ExecSessions, "exec-sessions", false, "run node commands through one persistent shell per node instead of a podman exec each, to provision faster on slow machines")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "with --reuse, adopt the cluster even if another user created it")
+	cmd.Flags().StringArrayVar(&opts.Labels, "label", nil, "label the cluster with key=value, repeatable, to select it later with --selector (adds to config labels)")
+	cmd.Flags().StringVar(&opts.SaveConfig, "save-config", "", "write the effective config, with flags folded in, to this path to re-create the cluster with --config")
 
 	return cmd
 }
@@ -96,10 +214,15 @@ func createClusterCmd() *cobra.Command {
 func deleteCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "delete",
-		Short: "Deletes one of [cluster]",
+		Short: "Deletes one of [cluster, clusters]",
+		Long: `Deletes a cluster, or several clusters at once, with their node containers,
+volumes and kubeconfig entries.`,
+		Example: `  kipod delete cluster dev
+  kipod delete clusters --all`,
 	}
 
 	cmd.AddCommand(deleteClusterCmd())
+	cmd.AddCommand(deleteClustersCmd())
 
 	return cmd
 }
@@ -108,10 +231,11 @@ func deleteClusterCmd() *cobra.Command {
 	var (
 		clusterName    string
 		kubeconfigPath string
+		force          bool
 	)
 
 	cmd := &cobra.Command{
-		Use:   "cluster",
+		Use:   "cluster [NAME]",
 		Short: "Deletes a kipod cluster",
 		Long: `Deletes a kipod cluster from the system.
 
@@ -120,6 +244,8 @@ failing (like "rm -f"). If the cluster resources exist they will be deleted, and
 if the cluster is already gone it will just return success.
 
 Errors will only occur if the cluster resources exist and are not able to be deleted.`,
+		Example: `  kipod delete cluster
+  kipod delete cluster dev`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Check positional args for cluster name
 			if len(args) > 0 {
@@ -127,10 +253,11 @@ Errors will only occur if the cluster resources exist and are not able to be del
 			}
 
 			// Default cluster name
-			if clusterName == "" {
-				clusterName = "kipod"
-			}
+			clusterName = resolveClusterName(clusterName)
 
+			if err := cluster.CheckOwner(clusterName, force); err != nil {
+				return err
+			}
 			if !quietMode {
 				style.Header("Deleting cluster %q ...", clusterName)
 			}
@@ -138,8 +265,9 @@ Errors will only occur if the cluster resources exist and are not able to be del
 		},
 	}
 
-	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default kipod)")
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
 	cmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "sets kubeconfig path instead of $KUBECONFIG or $HOME/.kube/config")
+	cmd.Flags().BoolVar(&force, "force", false, "delete the cluster even if another user created it")
 
 	return cmd
 }
@@ -147,46 +275,154 @@ Errors will only occur if the cluster resources exist and are not able to be del
 func getCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "get",
-		Short: "Gets one of [clusters, kubeconfig]",
+		Short: "Gets one of [cluster, clusters, nodes, kubeconfig]",
+		Long: `Prints kipod clusters, their nodes and effective config, or a kubeconfig for
+a cluster.`,
+		Example: `  kipod get clusters
+  kipod get nodes -n dev
+  kipod get cluster dev -o yaml
+  kipod get kubeconfig -n dev > dev.kubeconfig`,
 	}
 
+	cmd.AddCommand(getClusterCmd())
 	cmd.AddCommand(getClustersCmd())
+	cmd.AddCommand(getNodesCmd())
 	cmd.AddCommand(getKubeconfigCmd())
 
 	return cmd
 }
 
+func getClusterCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "cluster NAME",
+		Short: "Prints the effective config of an existing cluster",
+		Long: `Prints the effective config a cluster was created with, including defaults
+and values resolved at creation time such as the node image.
+
+The output can be passed back to reproduce the cluster:
+
+  kipod get cluster my-cluster -o yaml > my-cluster.yaml
+  kipod create cluster --config my-cluster.yaml`,
+		Example: `  kipod get cluster dev
+  kipod get cluster dev -o json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return getCluster(args[0], output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "yaml", "output format: yaml or json")
+
+	return cmd
+}
+
 func getClustersCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		output, selector string
+		allUsers         bool
+	)
+
+	cmd := &cobra.Command{
 		Use:   "clusters",
-		Short: "Lists existing kipod clusters by their name",
+		Short: "Lists existing kipod clusters, optionally with their health",
+		Long: `Lists the clusters you created that have node containers on this host,
+stopped or running, one name per line. Clusters other users created, on a
+podman shared between them, are listed with --all-users.
+
+With -o wide or -o json, checks every cluster concurrently and prints its
+health at a glance:
+
+  STATUS    running, stopped, or degraded when some node containers are not
+            running or fail their healthcheck
+  NODES     node containers running out of all node containers
+  READY     Kubernetes nodes Ready out of all registered, including fake nodes
+  API       whether /readyz answers on the API server port published on the
+            host: ok, not-ready or unreachable
+  VERSION   the kubelet version of the nodes
+  AGE       time since the first node container was created
+
+With -l, lists only the clusters whose labels, given at create with --label or
+the labels config field, match the selector: key=value, key!=value, key or
+!key terms separated by commas, all of which must match.`,
+		Example: `  kipod get clusters
+  kipod get clusters -o wide
+  kipod get clusters -l team=payments,env!=prod
+  kipod get clusters -o json | jq -r '.[] | select(.apiServer != "ok") | .name'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listClusters(output, selector, allUsers)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output format: wide or json, with the health of each cluster")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "list only the clusters matching a label selector, e.g. team=payments")
+	cmd.Flags().BoolVarP(&allUsers, "all-users", "A", false, "also list the clusters of other users, with their owner in wide output")
+
+	return cmd
+}
+
+func getNodesCmd() *cobra.Command {
+	var clusterName string
+
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "Lists the node containers of a cluster with their state and health",
+		Long: `Lists the node containers of a cluster with their podman state and the
+result of their healthcheck (systemd up and CRI-O answering): healthy,
+unhealthy, starting, or - for nodes created without a healthcheck.`,
+		Example: `  kipod get nodes --name dev`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listClusters()
+			clusterName = resolveClusterName(clusterName)
+			return listNodes(clusterName)
 		},
 	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+
+	return cmd
 }
 
 func getKubeconfigCmd() *cobra.Command {
 	var (
 		clusterName string
 		internal    bool
+		user        cluster.UserOptions
 	)
 
 	cmd := &cobra.Command{
 		Use:   "kubeconfig",
 		Short: "Prints cluster kubeconfig",
+		Long: `Prints the admin kubeconfig of a cluster.
+
+With --user, prints a kubeconfig for another user instead: kipod has the
+cluster CA sign a client certificate for the user through a
+CertificateSigningRequest and binds a ClusterRole (view by default) to it in
+each of --namespaces, or cluster-wide without them. Use it to test RBAC
+without handcrafting certificates.`,
+		Example: `  kipod get kubeconfig --user viewer --namespaces team-a > viewer.kubeconfig
+  kipod get kubeconfig --user dev --group developers --role edit --namespaces team-a,team-b`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Default cluster name
-			if clusterName == "" {
-				clusterName = "kipod"
-			}
+			clusterName = resolveClusterName(clusterName)
 
+			if user.User != "" {
+				return getUserKubeconfig(clusterName, user, internal)
+			}
+			if len(user.Groups) > 0 || len(user.Namespaces) > 0 || cmd.Flags().Changed("role") {
+				return fmt.Errorf("--group, --role and --namespaces require --user")
+			}
 			return getKubeconfig(clusterName, internal)
 		},
 	}
 
-	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster context name (default kipod)")
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster context name (default $KIPOD_CLUSTER_NAME or kipod)")
 	cmd.Flags().BoolVar(&internal, "internal", false, "use internal address instead of external")
+	cmd.Flags().StringVar(&user.User, "user", "", "print a kubeconfig for this RBAC-limited user instead of the admin")
+	cmd.Flags().StringSliceVar(&user.Groups, "group", nil, "groups of the user (repeatable)")
+	cmd.Flags().StringVar(&user.ClusterRole, "role", cluster.DefaultUserRole, "ClusterRole bound to the user")
+	cmd.Flags().StringSliceVar(&user.Namespaces, "namespaces", nil, "namespaces the role is bound in, created if missing (default cluster-wide)")
 
 	return cmd
 }
@@ -194,44 +430,95 @@ func getKubeconfigCmd() *cobra.Command {
 func buildCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "build",
-		Short: "Build one of [node-image]",
+		Short: "Build one of [node-image, checksums]",
+		Long: `Builds the node image clusters boot from, and maintains the checksums of the
+artifacts the build downloads.`,
+		Example: `  kipod build node-image
+  kipod build checksums --check`,
 	}
 
 	cmd.AddCommand(buildNodeImageCmd())
+	cmd.AddCommand(buildChecksumsCmd())
 
 	return cmd
 }
 
 func buildNodeImageCmd() *cobra.Command {
-	var (
-		configFile  string
-		k8sVersion  string
-		crioVersion string
-		image       string
-		rebuild     bool
-	)
+	var opts buildNodeImageOptions
 
 	cmd := &cobra.Command{
 		Use:   "node-image",
 		Short: "Build the node image which contains Kubernetes build artifacts and other kipod requirements",
+		Long: `Builds the node image cluster nodes boot from: a systemd base image with
+CRI-O, crun and runc, the CNI plugins, and kubeadm, the kubelet and kubectl
+of the requested Kubernetes release. Versions and sources come from --config,
+overridden by flags. An existing image with the same name is reused unless
+--rebuild is set.`,
+		Example: `  kipod build node-image
+  kipod build node-image --k8s-version 1.34.2 --crio-version 1.34 --image localhost/kipod-node:v1.34
+  kipod build node-image --config kipod.yaml --containerized`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return buildNodeImage(configFile, k8sVersion, crioVersion, image, rebuild)
+			return buildNodeImage(opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&configFile, "config", "", "path to a kipod config file")
-	cmd.Flags().StringVar(&k8sVersion, "k8s-version", "", "Kubernetes version to install (overrides config)")
-	cmd.Flags().StringVar(&crioVersion, "crio-version", "", "CRI-O version to install (overrides config)")
-	cmd.Flags().StringVar(&image, "image", "localhost/kipod-node:latest", "name:tag of the resulting image to be built")
-	cmd.Flags().BoolVar(&rebuild, "rebuild", false, "force rebuild even if image already exists")
+	cmd.Flags().StringVar(&opts.ConfigFile, "config", "", "path to a kipod config file")
+	cmd.Flags().StringVar(&opts.K8sVersion, "k8s-version", "", "Kubernetes version to install (overrides config)")
+	cmd.Flags().StringVar(&opts.CRIOVersion, "crio-version", "", "CRI-O version to install, a minor version like 1.34 or a release like 1.34.2 (overrides config)")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "name:tag of the resulting image to be built (overrides config, default localhost/kipod-node:latest)")
+	cmd.Flags().BoolVar(&opts.Rebuild, "rebuild", false, "force rebuild even if image already exists")
+	cmd.Flags().BoolVar(&opts.WithWasm, "with-wasm", false, "install the WebAssembly runtime (crun-wasm/WasmEdge) in the image")
+	cmd.Flags().StringVar(&opts.SandboxRuntime, "sandbox-runtime", "", "install an experimental sandboxed runtime, one of [kata, gvisor] (overrides config)")
+	cmd.Flags().BoolVar(&opts.VerifySignatures, "verify-signatures", false, "also verify cosign signatures of artifacts that publish them (CRI-O bundles)")
+	cmd.Flags().BoolVar(&opts.RequireVerified, "require-verified", false, "fail on artifacts that have no pinned checksum instead of downloading them unverified (same as "+build.RequireVerifiedEnv+"=1)")
+	cmd.Flags().BoolVar(&opts.Containerized, "containerized", false, "run the build in a pinned podman builder container instead of with the host's podman")
+	cmd.Flags().StringVar(&opts.BuilderImage, "builder-image", "", fmt.Sprintf("builder image for --containerized (default %s)", build.DefaultBuilderImage))
+	cmd.Flags().StringVar(&opts.BaseImage, "base-image", "", "base image of the node image, e.g. quay.io/centos/centos:stream10 (overrides config)")
+	cmd.Flags().StringVar(&opts.BaseDistro, "base-distro", "", "distro of the base image, one of [fedora, centos-stream, ubuntu] (default: detected from --base-image)")
+	cmd.Flags().StringVar(&opts.Variant, "variant", "", "build an experimental image variant, one of [bootc]")
+	cmd.Flags().BoolVar(&opts.ExplainConfig, "explain-config", false, "print the effective config and where each value came from (flag, config or default), then exit")
+
+	return cmd
+}
+
+func buildChecksumsCmd() *cobra.Command {
+	var add []string
+	var check bool
+
+	cmd := &cobra.Command{
+		Use:   "checksums",
+		Short: "Print the artifact manifest with checksums of unpinned releases filled in",
+		Long: `Downloads every release in kipod's artifact manifest that has no pinned
+sha256 yet, for each supported architecture, and prints the updated manifest.
+Used by maintainers to pin new crun, runc, CNI plugin or CRI-O bundle releases:
+
+  kipod build checksums --add cri-o=1.34.2 > pkg/build/artifacts.yaml
+
+Downloads of releases without a checksum fail, so run it with --check (in CI)
+to make sure every release is pinned for every supported architecture.`,
+		Example: `  kipod build checksums --add cri-o=1.34.2 > pkg/build/artifacts.yaml
+  kipod build checksums --check`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if check {
+				return checkChecksums()
+			}
+			return pinChecksums(add)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&add, "add", nil, "add a release to pin, as NAME=VERSION (repeatable)")
+	cmd.Flags().BoolVar(&check, "check", false, "only check that every release has a checksum for each supported architecture")
 
 	return cmd
 }
 
 func exportCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "export",
-		Short: "Exports one of [kubeconfig]",
+		Use:     "export",
+		Short:   "Exports one of [kubeconfig]",
+		Long:    `Exports the credentials of a cluster for use outside kipod.`,
+		Example: `  kipod export kubeconfig --name dev`,
 	}
 
 	cmd.AddCommand(exportKubeconfigCmd())
@@ -242,6 +529,7 @@ func exportCmd() *cobra.Command {
 func exportKubeconfigCmd() *cobra.Command {
 	var (
 		clusterName    string
+		context        string
 		kubeconfigPath string
 		internal       bool
 	)
@@ -249,32 +537,68 @@ func exportKubeconfigCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "kubeconfig",
 		Short: "Exports cluster kubeconfig",
+		Long: `Merges the admin credentials of a cluster into a kubeconfig, by default
+$KUBECONFIG or ~/.kube/config, and makes its context current. Entries of other
+clusters in the file are kept.`,
+		Example: `  kipod export kubeconfig --name dev
+  kipod export kubeconfig --name dev --kubeconfig ./dev.kubeconfig --context-name dev-local`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Default cluster name
-			if clusterName == "" {
-				clusterName = "kipod"
-			}
+			clusterName = resolveClusterName(clusterName)
 
-			return exportKubeconfig(clusterName, kubeconfigPath, internal)
+			return exportKubeconfig(clusterName, context, kubeconfigPath, internal)
 		},
 	}
 
-	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster context name (default kipod)")
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster context name (default $KIPOD_CLUSTER_NAME or kipod)")
 	cmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "sets kubeconfig path instead of $KUBECONFIG or $HOME/.kube/config")
 	cmd.Flags().BoolVar(&internal, "internal", false, "use internal address instead of external")
+	cmd.Flags().StringVar(&context, "context-name", "", "name of the context, cluster and user entries (default the config's kubeconfig.contextName, or kipod-NAME)")
 
 	return cmd
 }
 
 func checkCmd() *cobra.Command {
+	var configFile string
+	var nodeImage string
+	var simulate bool
+	var report system.ReportOptions
+
 	cmd := &cobra.Command{
 		Use:   "check",
 		Short: "Check system prerequisites",
-		Long:  `Validate that the system meets requirements for running kipod clusters.`,
+		Long: `Validate that the system meets requirements for running kipod clusters.
+
+With --node-image, boot a throwaway container from the image instead and verify
+that systemd, CRI-O, kubelet/kubeadm and cgroups work on this host.
+
+With --simulate, boot a throwaway node with the exact container options create
+cluster would use for the config (privileged, systemd, private cgroup namespace,
+tmpfs storage, the cluster network) and verify that systemd boots and a
+published port answers from the host, before creating a full cluster.
+
+Exits non-zero when a check fails, or on warnings with --strict. Individual
+checks can be skipped with --ignore, e.g. --ignore selinux --ignore "Network Backend".`,
+		Example: `  kipod check
+  kipod check --config kipod.yaml --strict
+  kipod check --node-image localhost/kipod-node:dev
+  kipod check --simulate --config kipod.yaml`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return checkSystem()
+			if simulate {
+				return simulateNode(configFile, nodeImage, report)
+			}
+			if nodeImage != "" {
+				return checkNodeImage(nodeImage, report)
+			}
+			return checkSystem(configFile, report)
 		},
 	}
 
+	cmd.Flags().StringVar(&configFile, "config", "", "path to a kipod config file to size resource checks for its topology")
+	cmd.Flags().StringVar(&nodeImage, "node-image", "", "validate a node image by booting a throwaway container from it")
+	cmd.Flags().BoolVar(&simulate, "simulate", false, "boot a throwaway node with the container options of the config and check systemd and port publishing (--node-image selects the image)")
+	cmd.Flags().BoolVar(&report.Strict, "strict", false, "treat warnings as failures")
+	cmd.Flags().StringArrayVar(&report.Ignore, "ignore", nil, "skip a check by name (repeatable)")
+
 	return cmd
 }
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func netemCmd() *cobra.Command {
+	var (
+		name    string
+		between []string
+		loss    string
+		opts    cluster.NetemOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "netem --between NODE_A NODE_B [--latency D] [--jitter D] [--loss P%] [--rate R]",
+		Short: "Adds latency, loss or a bandwidth limit between two nodes",
+		Long: `Impairs the traffic between two nodes, in both directions, with tc netem
+inside the node containers, e.g. to test etcd or controllers over a slow
+network. Running it again for the same nodes replaces the impairment; it lasts
+until "kipod netem clear" or the node container restarts.
+
+The host kernel needs the sch_netem module (modprobe sch_netem).
+
+Nodes are given by their name without the cluster prefix, e.g. worker-0.`,
+		Example: `  kipod netem --between control-plane-0 worker-1 --latency 100ms --loss 1%
+  kipod netem --between worker-0 worker-1 --latency 50ms --jitter 10ms --rate 10mbit
+  kipod netem show
+  kipod netem clear`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("between") {
+				return cmd.Help()
+			}
+			name = resolveClusterName(name)
+			// --between takes the first node, the second one is an argument
+			nodeNames := append(append([]string{}, between...), args...)
+			if len(nodeNames) != 2 {
+				return fmt.Errorf("--between takes two nodes, got: %s", strings.Join(nodeNames, " "))
+			}
+			if nodeNames[0] == nodeNames[1] {
+				return fmt.Errorf("cannot impair traffic of node %s to itself", nodeNames[0])
+			}
+			if loss != "" {
+				percent, err := strconv.ParseFloat(strings.TrimSuffix(loss, "%"), 64)
+				if err != nil {
+					return fmt.Errorf("invalid --loss %q, expected a percentage like 1%%", loss)
+				}
+				opts.Loss = percent
+			}
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			nodes, err := selectNodes(name, nodeNames)
+			if err != nil {
+				return err
+			}
+			if err := cluster.SetNetem(nodes[0], nodes[1], opts); err != nil {
+				return err
+			}
+			style.Success("Impaired traffic between %s and %s", nodes[0].Name, nodes[1].Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringSliceVar(&between, "between", nil, "the two nodes to impair traffic between, e.g. --between worker-0 worker-1")
+	cmd.Flags().DurationVar(&opts.Latency, "latency", 0, "delay added to every packet, e.g. 100ms")
+	cmd.Flags().DurationVar(&opts.Jitter, "jitter", 0, "random variation of the latency, e.g. 10ms")
+	cmd.Flags().StringVar(&loss, "loss", "", "percentage of packets dropped, e.g. 1%")
+	cmd.Flags().StringVar(&opts.Rate, "rate", "", "bandwidth limit in tc units, e.g. 10mbit")
+	guardOwner(cmd, "force", func([]string) []string {
+		// Without --between it only prints help
+		if len(between) == 0 {
+			return nil
+		}
+		return []string{resolveClusterName(name)}
+	})
+
+	cmd.AddCommand(netemShowCmd())
+	cmd.AddCommand(netemClearCmd())
+
+	return cmd
+}
+
+func netemShowCmd() *cobra.Command {
+	var (
+		name string
+		node string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Lists the impairments between nodes",
+		Long: `Lists the netem qdiscs kipod netem installed, one line per node, peer and
+device.`,
+		Example: `  kipod netem show --node worker-0`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName := name
+			clusterName = resolveClusterName(clusterName)
+			var names []string
+			if node != "" {
+				names = []string{node}
+			}
+			nodes, err := selectNodes(clusterName, names)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%-20s %-20s %-10s %s\n", "NODE", "PEER", "DEVICE", "NETEM")
+			for _, n := range nodes {
+				if n.State != "running" {
+					continue
+				}
+				rules, err := cluster.NetemRules(n)
+				if err != nil {
+					return err
+				}
+				for _, rule := range rules {
+					fmt.Printf("%-20s %-20s %-10s %s\n", shortNodeName(clusterName, n.NodeName()), shortNodeName(clusterName, rule.Peer), rule.Device, rule.Netem)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&node, "node", "", "only show the impairments of this node")
+
+	return cmd
+}
+
+func netemClearCmd() *cobra.Command {
+	var (
+		name    string
+		between []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "clear [--between NODE_A NODE_B]",
+		Short: "Removes the impairments between two nodes, or all of them",
+		Long: `Removes the impairments set between two nodes in both directions, or every
+impairment of the cluster without --between.`,
+		Example: `  kipod netem clear --between control-plane-0 worker-1
+  kipod netem clear`,
+		Args: cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName := name
+			clusterName = resolveClusterName(clusterName)
+			if !cmd.Flags().Changed("between") {
+				if len(args) > 0 {
+					return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+				}
+				nodes, err := selectNodes(clusterName, nil)
+				if err != nil {
+					return err
+				}
+				for _, n := range nodes {
+					if n.State != "running" {
+						continue
+					}
+					if err := cluster.ClearNetem(n, nil); err != nil {
+						return err
+					}
+				}
+				style.Success("Cleared all impairments in cluster '%s'", clusterName)
+				return nil
+			}
+
+			nodeNames := append(append([]string{}, between...), args...)
+			if len(nodeNames) != 2 {
+				return fmt.Errorf("--between takes two nodes, got: %s", strings.Join(nodeNames, " "))
+			}
+			nodes, err := selectNodes(clusterName, nodeNames)
+			if err != nil {
+				return err
+			}
+			peer := nodes[1]
+			if err := cluster.ClearNetem(nodes[0], &peer); err != nil {
+				return err
+			}
+			style.Success("Cleared impairments between %s and %s", nodes[0].Name, nodes[1].Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringSliceVar(&between, "between", nil, "the two nodes to clear the impairments between")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(name)} })
+
+	return cmd
+}
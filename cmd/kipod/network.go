@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func networkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "Connects clusters with one of [connect, disconnect]",
+		Long: `Connects the nodes of two clusters on a shared podman network, optionally
+with pod routes, for developing multi-cluster applications.`,
+		Example: `  kipod network connect east west --routes
+  kipod network disconnect east west`,
+	}
+
+	cmd.AddCommand(networkConnectCmd())
+	cmd.AddCommand(networkDisconnectCmd())
+
+	return cmd
+}
+
+func networkConnectCmd() *cobra.Command {
+	var opts cluster.ConnectOptions
+
+	cmd := &cobra.Command{
+		Use:   "connect CLUSTER_A CLUSTER_B",
+		Short: "Attaches the nodes of two clusters to a shared network",
+		Long: `Attaches every node of two clusters to a shared podman network, so the nodes,
+NodePort services and host-network pods of each cluster reach the other, e.g.
+to develop multi-cluster applications.
+
+With --routes, pods reach the pods of the other cluster by their IPs too: each
+node hands out pod IPs from the podCIDR Kubernetes assigned to it, instead of
+the whole pod subnet, and routes the podCIDRs of all other nodes through the
+shared network. Pods on the nodes are recreated once for their new addresses.
+The clusters need pod subnets that do not overlap, e.g. create the second one
+with networking.podSubnet: 10.245.0.0/16. Routes are lost when a node
+restarts; connecting again restores them.`,
+		Example: `  kipod network connect east west
+  kipod network connect east west --routes`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			network, err := cluster.Connect(args[0], args[1], opts)
+			if err != nil {
+				return err
+			}
+			if opts.Routes {
+				style.Success("Connected clusters '%s' and '%s' on network %s, with pod routes", args[0], args[1], network)
+			} else {
+				style.Success("Connected clusters '%s' and '%s' on network %s", args[0], args[1], network)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.Routes, "routes", false, "route pod IPs between the clusters")
+	guardOwner(cmd, "force", func(args []string) []string { return args })
+
+	return cmd
+}
+
+func networkDisconnectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disconnect CLUSTER_A CLUSTER_B",
+		Short: "Removes the shared network and routes of two clusters",
+		Long: `Removes the pod routes between two connected clusters, detaches their nodes
+from the shared network and deletes it. Nodes keep handing out pod IPs from
+their own podCIDR.`,
+		Example: `  kipod network disconnect east west`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cluster.Disconnect(args[0], args[1]); err != nil {
+				return err
+			}
+			style.Success("Disconnected clusters '%s' and '%s'", args[0], args[1])
+			return nil
+		},
+	}
+	guardOwner(cmd, "force", func(args []string) []string { return args })
+
+	return cmd
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/state"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func createNodeCmd() *cobra.Command {
+	var (
+		clusterName string
+		opts        cluster.CreateNodeOptions
+		role        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "node",
+		Short: "Creates a node container for a running cluster without joining it",
+		Long: `Creates a node container with the options of the cluster's nodes, optionally
+from another image or with extra mounts, and leaves it unjoined so it can be
+customized first. Like the nodes of pools, the node is named after the
+cluster: --name gpu-0 creates node kipod-gpu-0 in container kipod-kipod-gpu-0.
+Join it with: kipod join node CONTAINER`,
+		Example: `  kipod create node --name gpu-0 --volume /dev/dri:/dev/dri
+  kipod join node kipod-kipod-gpu-0`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			if role != "worker" {
+				return fmt.Errorf("unsupported role %q: only worker nodes can be added", role)
+			}
+			return createNode(clusterName, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Name, "name", "", "the node name, prefixed with the cluster name (<cluster>-<name>) for its hostname and container")
+	cmd.Flags().StringVar(&clusterName, "cluster", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&role, "role", "worker", "the node role; only worker is supported")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "node image to use instead of the cluster's")
+	cmd.Flags().StringArrayVar(&opts.Volumes, "volume", nil, "extra podman --volume for the node (repeatable)")
+	_ = cmd.MarkFlagRequired("name")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
+
+func joinCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "join",
+		Short: "Joins one of [node, external] to a running cluster",
+		Long: `Joins extra nodes to a running cluster: node containers created with kipod
+create node, or external machines reached over SSH.`,
+		Example: `  kipod join node kipod-kipod-gpu-0
+  kipod join external --ssh fedora@192.168.122.10`,
+	}
+
+	cmd.AddCommand(joinNodeCmd())
+	cmd.AddCommand(joinExternalCmd())
+
+	return cmd
+}
+
+func joinNodeCmd() *cobra.Command {
+	var (
+		clusterName string
+		opts        cluster.JoinOptions
+		labels      []string
+		taints      []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "node CONTAINER",
+		Short: "Sets up CRI-O in a node container and joins it as a worker",
+		Long: `Waits for systemd and CRI-O in a node container, applies the cluster's CRI-O
+settings and joins it as a worker with a fresh bootstrap token.
+
+The container may come from kipod create node or be created by hand from a
+kipod node image (privileged, systemd). It is attached to the cluster network
+when needed. Containers created by hand lack kipod's labels, so delete cluster
+leaves them behind.`,
+		Example: `  kipod join node kipod-kipod-gpu-0
+  kipod join node my-node --node-name big-0 --label disk=nvme`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			var err error
+			if opts.Labels, err = parseNodeLabels(labels); err != nil {
+				return err
+			}
+			if opts.Taints, err = convertTaints(taints); err != nil {
+				return err
+			}
+			return joinNode(clusterName, args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&clusterName, "cluster", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&opts.NodeName, "node-name", "", "the Kubernetes node name (default the container hostname)")
+	cmd.Flags().StringArrayVar(&labels, "label", nil, "node label KEY=VALUE set at registration (repeatable)")
+	cmd.Flags().StringArrayVar(&taints, "taint", nil, "node taint KEY[=VALUE]:EFFECT set at registration (repeatable)")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
+
+func joinExternalCmd() *cobra.Command {
+	var (
+		clusterName string
+		opts        cluster.ExternalOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "external",
+		Short: "Installs CRI-O and the kubelet on a machine over SSH and joins it (experimental)",
+		Long: `Joins a VM or remote machine as a worker next to the node containers, for
+tests that need a real kernel. Over SSH, it installs the CRI-O release bundle,
+kubeadm and the kubelet of the control-plane's versions, the cluster's CRI-O
+drop-ins and bridge CNI config, then joins the machine with a fresh bootstrap
+token.
+
+The machine needs systemd, curl, iptables and conntrack, root or passwordless
+sudo, and must reach the API server port published on this host. The address
+it reaches this host on defaults to the client address of the SSH connection.
+Requests to the control-plane address are redirected there by a NAT rule that
+does not survive a reboot of the machine. Deleting the cluster does not touch
+the machine; run kubeadm reset -f on it.`,
+		Example: `  kipod join external --ssh fedora@192.168.122.10
+  kipod join external --ssh root@vm --identity ~/.ssh/vm --node-name kernel-test`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			return joinExternal(clusterName, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.SSH, "ssh", "", "the ssh destination, e.g. user@host")
+	cmd.Flags().StringVar(&clusterName, "cluster", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&opts.Identity, "identity", "", "ssh private key file")
+	cmd.Flags().StringVar(&opts.NodeName, "node-name", "", "the Kubernetes node name (default the machine hostname)")
+	cmd.Flags().StringVar(&opts.APIServerAddress, "api-server-address", "", "the address the machine reaches this host on (default the ssh client address)")
+	cmd.Flags().StringVar(&opts.KubernetesVersion, "kubernetes-version", "", "Kubernetes release to install (default the control-plane's)")
+	cmd.Flags().StringVar(&opts.CRIOVersion, "crio-version", "", "CRI-O release to install (default the control-plane's)")
+	_ = cmd.MarkFlagRequired("ssh")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
+
+// storedClusterConfig returns the cluster config recorded for a running
+// cluster, so nodes added later match the ones it was created with
+func storedClusterConfig(name string) (*cluster.Config, error) {
+	stored, err := state.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := newClusterConfig(stored, stored.Image, false, "0s")
+	if err != nil {
+		return nil, err
+	}
+	// Nodes added later belong to whoever created the cluster
+	cfg.Owner = state.LoadOwner(name)
+	return cfg, nil
+}
+
+func createNode(clusterName string, opts cluster.CreateNodeOptions) error {
+	cfg, err := storedClusterConfig(clusterName)
+	if err != nil {
+		return err
+	}
+
+	style.Step("Creating node %s for cluster %q 📦", opts.Name, clusterName)
+	container, err := cluster.CreateNode(cfg, opts)
+	if err != nil {
+		return err
+	}
+	style.Success("Created %s; join it with: kipod join node %s --cluster %s", container, container, clusterName)
+	return nil
+}
+
+func joinNode(clusterName, container string, opts cluster.JoinOptions) error {
+	cfg, err := storedClusterConfig(clusterName)
+	if err != nil {
+		return err
+	}
+
+	if !quietMode {
+		style.Header("Joining %s to cluster %q ...", container, clusterName)
+	}
+	if err := cluster.JoinNode(cfg, container, opts); err != nil {
+		return fmt.Errorf("failed to join node: %w", err)
+	}
+	style.Success("Joined %s", container)
+	return nil
+}
+
+func joinExternal(clusterName string, opts cluster.ExternalOptions) error {
+	cfg, err := storedClusterConfig(clusterName)
+	if err != nil {
+		return err
+	}
+
+	if !quietMode {
+		style.Header("Joining %s to cluster %q (experimental) ...", opts.SSH, clusterName)
+	}
+	if err := cluster.JoinExternal(cfg, opts); err != nil {
+		return fmt.Errorf("failed to join %s: %w", opts.SSH, err)
+	}
+	style.Success("Joined %s", opts.SSH)
+	return nil
+}
+
+// parseNodeLabels parses KEY=VALUE node labels
+func parseNodeLabels(specs []string) (map[string]string, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected KEY=VALUE", spec)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
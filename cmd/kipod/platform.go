@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sohankunkerkar/kipod/pkg/system"
+)
+
+// commandRequirements lists commands that run on more platforms than the
+// default of system.RequiresNodes. Subcommands inherit the requirement of
+// their closest listed parent.
+var commandRequirements = map[string]string{
+	"kipod help":            system.RequiresNothing,
+	"kipod completion":      system.RequiresNothing,
+	"kipod __complete":      system.RequiresNothing,
+	"kipod init config":     system.RequiresNothing,
+	"kipod debug last-run":  system.RequiresNothing,
+	"kipod build checksums": system.RequiresNothing,
+	"kipod check":           system.RequiresNothing,
+	"kipod gen":             system.RequiresNothing,
+
+	// podman machine provides podman, but not a kernel nodes can run on
+	"kipod build node-image": system.RequiresPodman,
+	"kipod save node-image":  system.RequiresPodman,
+	"kipod load node-image":  system.RequiresPodman,
+	"kipod get cluster":      system.RequiresPodman,
+	"kipod get clusters":     system.RequiresPodman,
+	"kipod get nodes":        system.RequiresPodman,
+}
+
+// commandRequirement returns the platform requirement of cmd
+func commandRequirement(cmd *cobra.Command) string {
+	for c := cmd; c != nil; c = c.Parent() {
+		if requirement, ok := commandRequirements[c.CommandPath()]; ok {
+			return requirement
+		}
+	}
+	return system.RequiresNodes
+}
+
+// checkPlatform fails early with an explanation when cmd cannot run on this
+// host, e.g. creating a cluster on macOS outside a podman machine
+func checkPlatform(cmd *cobra.Command) error {
+	return system.CheckPlatform(cmd.CommandPath(), commandRequirement(cmd))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// portForwardOptions holds the flags of the port-forward command
+type portForwardOptions struct {
+	Name      string
+	Namespace string
+	Address   string
+}
+
+func portForwardCmd() *cobra.Command {
+	var opts portForwardOptions
+
+	cmd := &cobra.Command{
+		Use:   "port-forward LOCAL:TYPE/NAME:REMOTE...",
+		Short: "Forwards local ports to a pod or service in the cluster",
+		Long: `Runs kubectl port-forward against the cluster without exporting KUBECONFIG.
+
+Each argument maps a local port to a port of a resource, e.g. 8080:svc/myapp:80.
+All arguments must name the same resource.`,
+		Example: `  kipod port-forward 8080:svc/myapp:80
+  kipod port-forward --name dev --namespace monitoring 3000:deploy/grafana:3000`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = resolveClusterName(opts.Name)
+			return portForward(opts, args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&opts.Namespace, "namespace", "", "namespace of the resource (default from the kubeconfig)")
+	cmd.Flags().StringVar(&opts.Address, "address", "", "local addresses to listen on, comma separated (default localhost)")
+
+	return cmd
+}
+
+func portForward(opts portForwardOptions, specs []string) error {
+	kubectl, err := exec.LookPath("kubectl")
+	if err != nil {
+		return fmt.Errorf("kubectl not found in PATH; it is needed for port-forward")
+	}
+
+	var resource string
+	var ports []string
+	for _, spec := range specs {
+		r, p, err := parsePortForwardSpec(spec)
+		if err != nil {
+			return err
+		}
+		if resource != "" && r != resource {
+			return fmt.Errorf("all port mappings must target the same resource, got %s and %s", resource, r)
+		}
+		resource = r
+		ports = append(ports, p)
+	}
+
+	// Refresh the per-cluster kubeconfig so a recreated cluster just works
+	kubeconfigPath, err := writeClusterKubeconfig(opts.Name, "")
+	if err != nil {
+		return err
+	}
+
+	args := []string{"--kubeconfig", kubeconfigPath, "port-forward", resource}
+	args = append(args, ports...)
+	if opts.Namespace != "" {
+		args = append(args, "--namespace", opts.Namespace)
+	}
+	if opts.Address != "" {
+		args = append(args, "--address", opts.Address)
+	}
+
+	cmd := exec.Command(kubectl, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("kubectl port-forward failed: %w", err)
+	}
+	return nil
+}
+
+// parsePortForwardSpec splits LOCAL:TYPE/NAME:REMOTE into the resource and
+// the LOCAL:REMOTE port pair kubectl expects
+func parsePortForwardSpec(spec string) (string, string, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 || !strings.Contains(parts[1], "/") {
+		return "", "", fmt.Errorf("invalid port mapping %q, expected LOCAL:TYPE/NAME:REMOTE (e.g. 8080:svc/myapp:80)", spec)
+	}
+	local, resource, remote := parts[0], parts[1], parts[2]
+
+	kind, name, _ := strings.Cut(resource, "/")
+	if kind == "" || name == "" {
+		return "", "", fmt.Errorf("invalid resource %q in port mapping %q", resource, spec)
+	}
+	if local != "" {
+		if port, err := strconv.Atoi(local); err != nil || port < 0 || port > 65535 {
+			return "", "", fmt.Errorf("invalid local port %q in port mapping %q", local, spec)
+		}
+	}
+	// Remote ports may be named, e.g. svc/myapp:http
+	if remote == "" {
+		return "", "", fmt.Errorf("missing remote port in port mapping %q", spec)
+	}
+
+	return resource, local + ":" + remote, nil
+}
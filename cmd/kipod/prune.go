@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+	"github.com/spf13/cobra"
+)
+
+func pruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Removes unused resources of one of [networks, volumes, node-images]",
+		Long: `Removes resources kipod created that are no longer used: networks and
+volumes of deleted clusters, or unused images inside the nodes of a cluster.`,
+		Example: `  kipod prune networks
+  kipod prune volumes --dry-run
+  kipod prune node-images -n dev`,
+	}
+
+	cmd.AddCommand(pruneNetworksCmd())
+	cmd.AddCommand(pruneVolumesCmd())
+	cmd.AddCommand(pruneNodeImagesCmd())
+
+	return cmd
+}
+
+func pruneNetworksCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "networks",
+		Short: "Deletes kipod networks no cluster uses",
+		Long: `Deletes the podman networks created by kipod that no container is attached
+to. Delete cluster does this automatically for the networks of the deleted
+cluster; prune catches networks left behind by failed or interrupted runs.`,
+		Example: `  kipod prune networks`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pruned, err := cluster.PruneNetworks()
+			for _, network := range pruned {
+				fmt.Printf("Deleted network: %s\n", network)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to prune networks: %w", err)
+			}
+			if len(pruned) == 0 {
+				fmt.Println("No unused networks found.")
+			}
+			return nil
+		},
+	}
+}
+
+func pruneVolumesCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "volumes",
+		Short: "Deletes kipod volumes no node uses",
+		Long: `Lists the podman volumes created by kipod that no container uses, with the
+space they hold, and deletes them. These are left behind when nodes are
+removed outside of kipod or by releases that did not label their volumes.`,
+		Example: `  kipod prune volumes --dry-run
+  kipod prune volumes`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			volumes, err := cluster.OrphanedVolumes()
+			if err != nil {
+				return fmt.Errorf("failed to list volumes: %w", err)
+			}
+			if len(volumes) == 0 {
+				fmt.Println("No orphaned volumes found.")
+				return nil
+			}
+
+			var total uint64
+			fmt.Printf("%-50s %-20s %s\n", "NAME", "CLUSTER", "SIZE")
+			for _, volume := range volumes {
+				owner := volume.Labels[podman.LabelCluster]
+				if owner == "" {
+					owner = "-"
+				}
+				size := "unknown"
+				if bytes, err := podman.VolumeSize(volume); err == nil {
+					size = system.FormatSize(bytes)
+					total += bytes
+				}
+				fmt.Printf("%-50s %-20s %s\n", volume.Name, owner, size)
+			}
+			fmt.Printf("\nTotal: %s in %d volume(s)\n", system.FormatSize(total), len(volumes))
+			if dryRun {
+				return nil
+			}
+
+			var failed int
+			for _, volume := range volumes {
+				if err := podman.DeleteVolume(volume.Name); err != nil {
+					style.Info("Warning: failed to delete volume %s: %v", volume.Name, err)
+					failed++
+					continue
+				}
+				fmt.Printf("Deleted volume: %s\n", volume.Name)
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to delete %d volume(s)", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the orphaned volumes without deleting them")
+
+	return cmd
+}
+
+func pruneNodeImagesCmd() *cobra.Command {
+	var (
+		clusterName string
+		nodeNames   []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "node-images",
+		Short: "Deletes the images no container uses inside the nodes of a cluster",
+		Long: `Runs crictl rmi --prune on every running node of a cluster, or the given
+nodes, and reports the space freed. Node image storage is on tmpfs by default,
+so images loaded during iterative development fill up memory long before the
+kubelet's image garbage collection kicks in; see imageGC in the config to tune
+its thresholds instead.`,
+		Example: `  kipod prune node-images
+  kipod prune node-images --name dev --node worker-0`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			nodes, err := selectNodes(clusterName, nodeNames)
+			if err != nil {
+				return err
+			}
+			if len(nodes) == 0 {
+				return fmt.Errorf("cluster '%s' not found", clusterName)
+			}
+
+			var (
+				total  uint64
+				failed int
+			)
+			for _, node := range nodes {
+				if node.State != "running" {
+					style.Info("Warning: skipping %s, which is %s", node.NodeName(), node.State)
+					continue
+				}
+				result, err := cluster.PruneImages(node.ID)
+				if err != nil {
+					style.Info("Warning: failed to prune images on %s: %v", node.NodeName(), err)
+					failed++
+					continue
+				}
+				total += result.Freed
+				fmt.Printf("%s: deleted %d image(s), freed %s\n", node.NodeName(), len(result.Removed), system.FormatSize(result.Freed))
+			}
+			fmt.Printf("\nTotal freed: %s\n", system.FormatSize(total))
+			if failed > 0 {
+				return fmt.Errorf("failed to prune images on %d node(s)", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringArrayVar(&nodeNames, "node", nil, "only prune this node (repeatable)")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
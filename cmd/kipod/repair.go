@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func repairCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repair",
+		Short: "Repairs one of [cluster]",
+		Long: `Restores clusters whose nodes were restarted behind kipod's back, e.g. by a
+host reboot or the OOM killer.`,
+		Example: `  kipod repair cluster dev`,
+	}
+
+	cmd.AddCommand(repairClusterCmd())
+
+	return cmd
+}
+
+func repairClusterCmd() *cobra.Command {
+	var (
+		clusterName string
+		force       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "cluster [NAME]",
+		Short: "Restores a cluster to health after its nodes were restarted",
+		Long: `Restores a cluster whose node containers were restarted, e.g. by a host
+reboot or the OOM killer, without recreating it.
+
+Stopped nodes are started. When podman gave the control-plane container a new
+IP, the static pod manifests, kubeconfigs and the kube-proxy and cluster-info
+configmaps are moved to it, and kubeadm issues API server and etcd serving
+certificates for it. Every kubelet is then restarted, so nodes report their
+current IPs, and each node is checked to reach the API server.`,
+		Example: `  kipod repair cluster
+  kipod repair cluster dev`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				clusterName = args[0]
+			}
+			clusterName = resolveClusterName(clusterName)
+
+			if err := cluster.CheckOwner(clusterName, force); err != nil {
+				return err
+			}
+			if !quietMode {
+				style.Header("Repairing cluster %q ...", clusterName)
+			}
+			if err := cluster.Repair(clusterName); err != nil {
+				return err
+			}
+			style.Success("Cluster %q is healthy", clusterName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().BoolVar(&force, "force", false, "repair the cluster even if another user created it")
+
+	return cmd
+}
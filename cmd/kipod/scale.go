@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func scaleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scale",
+		Short: "Scales one of [fake-nodes]",
+		Long: `Scales simulated parts of a cluster, such as kwok fake nodes, to test
+schedulers and controllers at sizes one host cannot run.`,
+		Example: `  kipod scale fake-nodes 500`,
+	}
+
+	cmd.AddCommand(scaleFakeNodesCmd())
+
+	return cmd
+}
+
+func scaleFakeNodesCmd() *cobra.Command {
+	var (
+		clusterName string
+		version     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fake-nodes COUNT",
+		Short: "Registers or removes simulated kwok nodes",
+		Long: `Registers or removes simulated nodes until the cluster has COUNT of them, so
+scheduler and controller developers can test hundreds of nodes without running
+a container per node. kwok is deployed first when the cluster does not run it.
+
+Fake nodes are named <cluster>-fake-N, labelled kipod.io/fake-node=true and
+tainted kwok.x-k8s.io/node=fake:NoSchedule; pods tolerating the taint are
+scheduled onto them and reported Running without running anything.`,
+		Example: `  kipod scale fake-nodes 500
+  kipod scale fake-nodes 0`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			count, err := strconv.Atoi(args[0])
+			if err != nil || count < 0 || count > config.MaxFakeNodes {
+				return fmt.Errorf("COUNT must be a number between 0 and %d, got: %s", config.MaxFakeNodes, args[0])
+			}
+
+			if err := cluster.ScaleFakeNodes(clusterName, count, version); err != nil {
+				return err
+			}
+			style.Success("Cluster %q has %d fake node(s)", clusterName, count)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&version, "kwok-version", cluster.DefaultKwokVersion, "kwok release to deploy when the cluster does not run kwok")
+	guardOwner(cmd, "force", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+
+	return cmd
+}
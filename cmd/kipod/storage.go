@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+	"github.com/spf13/cobra"
+)
+
+// storageWarnPercent is the image storage usage status warns at
+const storageWarnPercent = 85
+
+func storageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Inspects node image storage with one of [status]",
+		Long: `Inspects the container image storage of the nodes of a cluster, which is on
+tmpfs by default and counts against memory.`,
+		Example: `  kipod storage status -n dev`,
+	}
+
+	cmd.AddCommand(storageStatusCmd())
+
+	return cmd
+}
+
+func storageStatusCmd() *cobra.Command {
+	var clusterName string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Reports the image storage usage of each node",
+		Long: `Reports how much of /var/lib/containers/storage each running node uses,
+against the tmpfs size or, for volume storage, the host filesystem holding the
+volume, and warns about nodes near capacity. Full image storage makes image
+pulls and pod starts fail.`,
+		Example: `  kipod storage status
+  kipod storage status --name dev`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			return storageStatus(clusterName)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+
+	return cmd
+}
+
+func storageStatus(clusterName string) error {
+	nodes, err := selectNodes(clusterName, nil)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("cluster '%s' not found", clusterName)
+	}
+
+	var full []string
+	fmt.Printf("%-30s %-12s %-12s %-12s %s\n", "NODE", "FILESYSTEM", "USED", "SIZE", "USE%")
+	for _, node := range nodes {
+		if node.State != "running" {
+			fmt.Printf("%-30s %s\n", node.NodeName(), node.State)
+			continue
+		}
+		usage, err := cluster.NodeStorageUsage(node.ID)
+		if err != nil {
+			style.Info("Warning: %s: %v", node.NodeName(), err)
+			continue
+		}
+		var percent uint64
+		if usage.Size > 0 {
+			percent = usage.Used * 100 / usage.Size
+		}
+		use := fmt.Sprintf("%d%%", percent)
+		if percent >= storageWarnPercent {
+			use = style.Red(use)
+			full = append(full, node.NodeName())
+		}
+		fmt.Printf("%-30s %-12s %-12s %-12s %s\n", node.NodeName(), usage.Filesystem, system.FormatSize(usage.Used), system.FormatSize(usage.Size), use)
+	}
+
+	for _, node := range full {
+		style.Info("Warning: image storage of %s is at least %d%% full; free it with: kipod prune node-images --name %s", node, storageWarnPercent, clusterName)
+	}
+	return nil
+}
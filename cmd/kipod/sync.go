@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func syncCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Synchronizes one of [time]",
+		Long: `Brings the nodes of a cluster back in line with the host, e.g. their clocks
+after the host was suspended.`,
+		Example: `  kipod sync time`,
+	}
+
+	cmd.AddCommand(syncTimeCmd())
+
+	return cmd
+}
+
+func syncTimeCmd() *cobra.Command {
+	var (
+		name      string
+		checkOnly bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "time",
+		Short: "Resyncs node clocks with the host, e.g. after the host was suspended",
+		Long: fmt.Sprintf(`Measures the clock offset of every node from the host. Nodes off by more
+than %s get their clock stepped (with chronyc, or date) and kubelet
+restarted, so node leases and certificate checks recover.
+
+Nodes share the host clock on Linux; skew happens when podman runs them in a
+VM such as a podman machine, whose clock stops while the host sleeps. The
+same check runs when start cluster or create cluster --reuse starts a cluster
+again.`, cluster.MaxClockSkew),
+		Example: `  kipod sync time
+  kipod sync time -n dev --check`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name = resolveClusterName(name)
+			if checkOnly {
+				return printClockSkew(name)
+			}
+			return syncTime(name)
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().BoolVar(&checkOnly, "check", false, "only print the clock offset of every node")
+	guardOwner(cmd, "force", func([]string) []string {
+		if checkOnly {
+			return nil
+		}
+		return []string{resolveClusterName(name)}
+	})
+
+	return cmd
+}
+
+func printClockSkew(name string) error {
+	clocks, err := cluster.ClockSkew(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-40s %-12s %s\n", "NODE", "SKEW", "STATUS")
+	for _, clock := range clocks {
+		status := "ok"
+		if clock.Skew.Abs() > cluster.MaxClockSkew {
+			status = "skewed"
+		}
+		fmt.Printf("%-40s %-12s %s\n", clock.Node, clock.Skew, status)
+	}
+	return nil
+}
+
+func syncTime(name string) error {
+	synced, err := cluster.SyncTime(name)
+	for _, clock := range synced {
+		style.Info("Resynced the clock of %s, which was off by %s, and restarted kubelet", clock.Node, clock.Skew)
+	}
+	if err != nil {
+		return err
+	}
+	if len(synced) == 0 {
+		style.Success("Node clocks of cluster %q are in sync", name)
+	}
+	return nil
+}
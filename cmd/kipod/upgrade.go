@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/cluster"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/spf13/cobra"
+)
+
+func upgradeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "upgrade",
+		Short:   "Upgrades one of [nodes]",
+		Long:    `Replaces parts of a running cluster with newer builds without recreating it.`,
+		Example: `  kipod upgrade nodes --image localhost/kipod-node:dev`,
+	}
+
+	cmd.AddCommand(upgradeNodesCmd())
+
+	return cmd
+}
+
+func upgradeNodesCmd() *cobra.Command {
+	var (
+		clusterName string
+		opts        cluster.UpgradeOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "Replaces worker nodes one at a time with nodes of another image",
+		Long: `Replaces the worker nodes of a running cluster one at a time with nodes of
+another node image, for iterating on node image changes without recreating the
+cluster. The control-plane is not touched.
+
+For each worker, a node of the new image joins its pool first, under the
+lowest free index of the pool (dev-worker-0 may be replaced by dev-worker-2),
+with the pool's settings and the labels and taints of the old node object.
+Once the new node is Ready, the old one is drained and deleted with its
+container and volumes, and the next worker is replaced.
+
+The kubelet of the new image may not be newer than the control-plane. The
+recorded config of the cluster keeps the original image, which nodes added
+later use.`,
+		Example: `  kipod upgrade nodes --image localhost/kipod-node:dev
+  kipod upgrade nodes --image localhost/kipod-node:dev --name dev --node dev-worker-0`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clusterName = resolveClusterName(clusterName)
+			return upgradeNodes(clusterName, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clusterName, "name", "n", "", "the cluster name (default $KIPOD_CLUSTER_NAME or kipod)")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "the node image of the new workers")
+	cmd.Flags().StringArrayVar(&opts.Nodes, "node", nil, "only replace this worker node (repeatable)")
+	cmd.Flags().DurationVar(&opts.DrainTimeout, "drain-timeout", 5*time.Minute, "how long to wait for the pods of a node to be evicted")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "also evict pods no controller manages, which are lost")
+	// --force already means evicting unmanaged pods
+	guardOwner(cmd, "force-owner", func([]string) []string { return []string{resolveClusterName(clusterName)} })
+	_ = cmd.MarkFlagRequired("image")
+
+	return cmd
+}
+
+func upgradeNodes(clusterName string, opts cluster.UpgradeOptions) error {
+	cfg, err := storedClusterConfig(clusterName)
+	if err != nil {
+		return err
+	}
+
+	if !quietMode {
+		style.Header("Upgrading the workers of cluster %q to %s ...", clusterName, opts.Image)
+	}
+	if err := cluster.UpgradeNodes(cfg, opts); err != nil {
+		return fmt.Errorf("failed to upgrade nodes: %w", err)
+	}
+	style.Success("Upgraded the workers to %s", opts.Image)
+	return nil
+}
@@ -28,9 +28,14 @@ EOF
 
 write_kubelet_config() {
     local driver=$1
+    # Log level set by kipod (logLevels.kubelet), kept across restarts
+    local verbosity=""
+    if [ -s /etc/kipod/kubelet-verbosity ]; then
+        verbosity=" --v=$(cat /etc/kipod/kubelet-verbosity)"
+    fi
     
     cat > /etc/sysconfig/kubelet <<EOF
-KUBELET_EXTRA_ARGS=--container-runtime-endpoint=unix:///var/run/crio/crio.sock --cgroup-driver=${driver} --fail-swap-on=false --feature-gates=KubeletInUserNamespace=true
+KUBELET_EXTRA_ARGS=--container-runtime-endpoint=unix:///var/run/crio/crio.sock --cgroup-driver=${driver} --fail-swap-on=false --feature-gates=KubeletInUserNamespace=true${KIPOD_KUBELET_EXTRA_ARGS:+ ${KIPOD_KUBELET_EXTRA_ARGS}}${verbosity}
 EOF
 }
 
@@ -0,0 +1,16 @@
+#!/bin/bash
+# Install node packages on CentOS Stream from the Kubernetes RPM repository
+# Expects K8S_VERSION and KUBERNETES_REPO_URL in the environment
+
+set -euo pipefail
+
+echo -e "[kubernetes]\nname=Kubernetes\nbaseurl=${KUBERNETES_REPO_URL}\nenabled=1\ngpgcheck=1\ngpgkey=${KUBERNETES_REPO_URL}repodata/repomd.xml.key" > /etc/yum.repos.d/kubernetes.repo
+
+dnf install -y --setopt=install_weak_deps=False \
+  systemd iproute iproute-tc iptables-nft procps-ng \
+  conntrack-tools socat ethtool ipset curl \
+  cri-tools containernetworking-plugins fuse-overlayfs conmon containers-common crun jq dbus-broker criu \
+  kubelet-${K8S_VERSION}* kubeadm-${K8S_VERSION}* kubectl-${K8S_VERSION}* \
+  skopeo
+dnf clean all
+rm -rf /var/cache/yum /var/cache/dnf
@@ -0,0 +1,17 @@
+#!/bin/bash
+# Install node packages on Fedora from the Kubernetes RPM repository
+# Expects CRIO_VERSION, K8S_VERSION and KUBERNETES_REPO_URL in the environment
+
+set -euo pipefail
+
+echo -e "[cri-o]\nname=CRI-O\nbaseurl=https://download.opensuse.org/repositories/isv:/cri-o:/stable:/v${CRIO_VERSION}/rpm/\nenabled=1\ngpgcheck=1\ngpgkey=https://download.opensuse.org/repositories/isv:/cri-o:/stable:/v${CRIO_VERSION}/rpm/repodata/repomd.xml.key" > /etc/yum.repos.d/cri-o.repo
+echo -e "[kubernetes]\nname=Kubernetes\nbaseurl=${KUBERNETES_REPO_URL}\nenabled=1\ngpgcheck=1\ngpgkey=${KUBERNETES_REPO_URL}repodata/repomd.xml.key" > /etc/yum.repos.d/kubernetes.repo
+
+microdnf install -y --setopt=install_weak_deps=False \
+  systemd iproute iproute-tc iptables iptables-legacy procps-ng \
+  conntrack-tools socat ethtool ebtables ipset curl \
+  cri-tools containernetworking-plugins fuse-overlayfs conmon containers-common crun slirp4netns jq dbus criu \
+  kubelet-${K8S_VERSION}* kubeadm-${K8S_VERSION}* kubectl-${K8S_VERSION}* \
+  skopeo
+microdnf clean all
+rm -rf /var/cache/yum /var/cache/dnf
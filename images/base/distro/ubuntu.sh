@@ -0,0 +1,29 @@
+#!/bin/bash
+# Install node packages on Ubuntu from the Kubernetes deb repository
+# Expects K8S_VERSION and KUBERNETES_REPO_URL in the environment
+
+set -euo pipefail
+
+export DEBIAN_FRONTEND=noninteractive
+
+apt-get update
+apt-get install -y --no-install-recommends ca-certificates curl gpg
+mkdir -p /etc/apt/keyrings
+curl -fsSL "${KUBERNETES_REPO_URL}Release.key" | gpg --dearmor -o /etc/apt/keyrings/kubernetes.gpg
+echo "deb [signed-by=/etc/apt/keyrings/kubernetes.gpg] ${KUBERNETES_REPO_URL} /" > /etc/apt/sources.list.d/kubernetes.list
+
+apt-get update
+apt-get install -y --no-install-recommends \
+  systemd systemd-sysv iproute2 iptables procps \
+  conntrack socat ethtool ebtables ipset kmod \
+  cri-tools kubernetes-cni fuse-overlayfs conmon containers-common crun slirp4netns jq dbus-broker \
+  "kubelet=${K8S_VERSION}.*" "kubeadm=${K8S_VERSION}.*" "kubectl=${K8S_VERSION}.*" \
+  skopeo
+# Checkpoint/restore; not packaged for every Ubuntu release
+apt-get install -y --no-install-recommends criu \
+  || echo "Warning: criu is not available, features.checkpointRestore will not work with this image"
+apt-get clean
+rm -rf /var/lib/apt/lists/*
+
+# The kubeadm drop-in reads kubelet flags from /etc/default on Debian systems
+ln -sf /etc/sysconfig/kubelet /etc/default/kubelet
@@ -10,9 +10,44 @@ echo "Kipod node container starting..."
 modprobe overlay 2>/dev/null || echo "Warning: Could not load overlay module (may already be loaded)"
 modprobe br_netfilter 2>/dev/null || echo "Warning: Could not load br_netfilter module (may already be loaded)"
 
+# Raise inotify limits: kubelet, CRI-O and pods all rely on inotify watches
+cat > /etc/sysctl.d/90-kipod-inotify.conf <<EOF
+fs.inotify.max_user_watches = ${KIPOD_INOTIFY_MAX_USER_WATCHES:-524288}
+fs.inotify.max_user_instances = ${KIPOD_INOTIFY_MAX_USER_INSTANCES:-512}
+user.max_inotify_watches = ${KIPOD_INOTIFY_MAX_USER_WATCHES:-524288}
+user.max_inotify_instances = ${KIPOD_INOTIFY_MAX_USER_INSTANCES:-512}
+EOF
+
 # Apply sysctl settings (might fail in rootless, that's okay)
 sysctl --system 2>/dev/null || echo "Warning: Could not apply sysctl settings (may require host configuration)"
 
+# Use the iptables backend of the host (KIPOD_IPTABLES_BACKEND=nft or
+# legacy). kube-proxy and the CNI plugins follow the rules the kubelet writes
+# with the node's iptables, and rules of both backends in one kernel break
+# service routing without an error.
+if [ -n "${KIPOD_IPTABLES_BACKEND}" ]; then
+    case "${KIPOD_IPTABLES_BACKEND}" in
+        nft) iptables_want="nf_tables" ;;
+        *) iptables_want="${KIPOD_IPTABLES_BACKEND}" ;;
+    esac
+    iptables_multi="/usr/sbin/xtables-${KIPOD_IPTABLES_BACKEND}-multi"
+    if iptables --version 2>/dev/null | grep -q "(${iptables_want})"; then
+        :
+    elif [ -x "$iptables_multi" ]; then
+        update-alternatives --set iptables "/usr/sbin/iptables-${KIPOD_IPTABLES_BACKEND}" >/dev/null 2>&1 || true
+        update-alternatives --set ip6tables "/usr/sbin/ip6tables-${KIPOD_IPTABLES_BACKEND}" >/dev/null 2>&1 || true
+        # Images without alternatives for iptables get the links directly
+        if ! iptables --version 2>/dev/null | grep -q "(${iptables_want})"; then
+            for tool in iptables iptables-save iptables-restore ip6tables ip6tables-save ip6tables-restore; do
+                ln -sf "$iptables_multi" "/usr/sbin/$tool"
+            done
+        fi
+    else
+        echo "Warning: the node image has no iptables-${KIPOD_IPTABLES_BACKEND} ($iptables_multi); service routing may fail"
+    fi
+    echo "Using $(iptables --version 2>/dev/null)"
+fi
+
 # Ensure cgroup hierarchy is set up
 if [ -d /sys/fs/cgroup ]; then
     # Mount cgroup v2 if needed
@@ -31,12 +66,33 @@ chmod 755 /var/run/crio
 chmod 755 /var/lib/crio
 chmod 755 /var/lib/kubelet
 
-# Copy user-provided CRI-O config if mounted
-if [ -f /tmp/crio-user-config.conf ]; then
+# Copy user-provided CRI-O config if mounted, on the first boot only:
+# kipod apply manages 99-user.conf afterwards and a restart must keep it
+if [ -f /tmp/crio-user-config.conf ] && [ ! -e /etc/kipod/crio-user-config.installed ]; then
     echo "Applying user-provided CRI-O configuration..."
     cp /tmp/crio-user-config.conf /etc/crio/crio.conf.d/99-user.conf
+    mkdir -p /etc/kipod
+    touch /etc/kipod/crio-user-config.installed
 fi
 
+# Let workloads reach the host as host.kipod.internal. podman rewrites
+# /etc/hosts on every start, so the alias is added here.
+read -r host_ip _ < <(getent ahostsv4 host.containers.internal) || true
+if [ -n "$host_ip" ] && ! grep -q ' host.kipod.internal$' /etc/hosts; then
+    echo "$host_ip host.kipod.internal" >> /etc/hosts
+fi
+
+# Write the cluster's nodeEnv variables for systemd units, which do not
+# inherit the container environment (EnvironmentFile=/etc/kipod/node.env)
+mkdir -p /etc/kipod
+: > /etc/kipod/node.env
+for name in ${KIPOD_NODE_ENV}; do
+    value="${!name}"
+    value="${value//\\/\\\\}"
+    value="${value//\"/\\\"}"
+    printf '%s="%s"\n' "$name" "$value" >> /etc/kipod/node.env
+done
+
 # Configure cgroup manager based on environment
 if [ -f /usr/local/bin/configure-cgroup-manager.sh ]; then
     /usr/local/bin/configure-cgroup-manager.sh
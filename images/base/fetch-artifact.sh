@@ -0,0 +1,45 @@
+#!/bin/bash
+# Download a build artifact and verify it before it is used
+# Usage: fetch-artifact.sh URL DEST SHA256 [SIGNATURE CERTIFICATE IDENTITY_REGEXP OIDC_ISSUER]
+# Without a sha256 the download is unverified, or fails when
+# KIPOD_REQUIRE_VERIFIED_ARTIFACTS=1; a cosign
+# keyless signature is checked too when its signature and certificate URLs
+# are given (cosign must be installed).
+
+set -euo pipefail
+
+url=$1
+dest=$2
+sha256=${3:-}
+signature=${4:-}
+certificate=${5:-}
+identity=${6:-}
+issuer=${7:-}
+
+if [ -z "$sha256" ] && [ "${KIPOD_REQUIRE_VERIFIED_ARTIFACTS:-}" = "1" ]; then
+    echo "No checksum pinned for $url; pin it in pkg/build/artifacts.yaml or unset KIPOD_REQUIRE_VERIFIED_ARTIFACTS" >&2
+    exit 1
+fi
+
+curl -fsSL -o "$dest" "$url"
+
+if [ -n "$sha256" ]; then
+    if ! echo "$sha256  $dest" | sha256sum -c --quiet -; then
+        echo "Checksum mismatch for $url (expected $sha256)" >&2
+        rm -f "$dest"
+        exit 1
+    fi
+else
+    echo "Warning: $url was not verified, no checksum is pinned for it" >&2
+fi
+
+if [ -n "$signature" ]; then
+    curl -fsSL -o "$dest.sig" "$signature"
+    curl -fsSL -o "$dest.cert" "$certificate"
+    cosign verify-blob "$dest" \
+        --signature "$dest.sig" \
+        --certificate "$dest.cert" \
+        --certificate-identity-regexp "$identity" \
+        --certificate-oidc-issuer "$issuer"
+    rm -f "$dest.sig" "$dest.cert"
+fi
@@ -0,0 +1,112 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// ImageArchitecture returns the architecture of a local image, e.g. amd64
+func ImageArchitecture(imageName string) (string, error) {
+	cmd := podman.Command("image", "inspect", "--format", "{{.Architecture}}", imageName)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ValidateNodeImageArchive checks that a local image is a kipod node image
+// that can run on this host
+func ValidateNodeImageArchive(imageName string) error {
+	labels, err := GetImageLabels(imageName)
+	if err != nil {
+		return err
+	}
+	if labels[LabelKubernetesVersion] == "" {
+		return fmt.Errorf("%s is not a kipod node image (missing %s label)", imageName, LabelKubernetesVersion)
+	}
+
+	arch, err := ImageArchitecture(imageName)
+	if err != nil {
+		return err
+	}
+	if arch != runtime.GOARCH {
+		return fmt.Errorf("image %s is built for %s, but this host is %s", imageName, arch, runtime.GOARCH)
+	}
+	return nil
+}
+
+// SaveImage writes a kipod node image to a tarball
+func SaveImage(imageName, path string) error {
+	labels, err := GetImageLabels(imageName)
+	if err != nil {
+		return err
+	}
+	if labels[LabelKubernetesVersion] == "" {
+		return fmt.Errorf("%s is not a kipod node image (missing %s label)", imageName, LabelKubernetesVersion)
+	}
+
+	cmd := podman.Command("save", "--output", path, imageName)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to save image %s: %w", imageName, err)
+	}
+	return nil
+}
+
+// LoadImage loads a node image tarball and returns the names of the loaded
+// images. Images that are not valid node images for this host are removed
+// again.
+func LoadImage(path string) ([]string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("image archive not found: %w", err)
+	}
+
+	cmd := podman.Command("load", "--input", path)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w\nOutput: %s", path, err, output)
+	}
+
+	images := parseLoadedImages(string(output))
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images found in %s", path)
+	}
+
+	for _, image := range images {
+		if err := ValidateNodeImageArchive(image); err != nil {
+			for _, loaded := range images {
+				_ = podman.Command("rmi", loaded).Run()
+			}
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// parseLoadedImages extracts image names from `podman load` output, e.g.
+// "Loaded image: localhost/kipod-node:latest" or
+// "Loaded image(s): a:latest,b:latest"
+func parseLoadedImages(output string) []string {
+	var images []string
+	for _, line := range strings.Split(output, "\n") {
+		_, names, ok := strings.Cut(line, "Loaded image")
+		if !ok {
+			continue
+		}
+		_, names, ok = strings.Cut(names, ":")
+		if !ok {
+			continue
+		}
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				images = append(images, name)
+			}
+		}
+	}
+	return images
+}
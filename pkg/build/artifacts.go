@@ -0,0 +1,291 @@
+package build
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/style"
+	"gopkg.in/yaml.v3"
+)
+
+// artifactSchemaVersion is the manifest format this build understands
+const artifactSchemaVersion = 1
+
+// ArtifactArchitectures are the architectures checksums are pinned for
+var ArtifactArchitectures = []string{"amd64", "arm64"}
+
+// RequireVerifiedEnv set to 1 makes artifacts without a pinned checksum
+// fail instead of being downloaded unverified with a warning, e.g. for
+// release builds
+const RequireVerifiedEnv = "KIPOD_REQUIRE_VERIFIED_ARTIFACTS"
+
+//go:embed artifacts.yaml
+var artifactManifestData []byte
+
+// ArtifactManifest lists the downloadable artifacts and their pinned checksums
+type ArtifactManifest struct {
+	SchemaVersion int              `yaml:"schemaVersion"`
+	Artifacts     []ArtifactSource `yaml:"artifacts"`
+}
+
+// ArtifactSource is where an artifact is downloaded from. URLs may contain
+// {version} and {arch}; signature URLs may also contain {url}.
+type ArtifactSource struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+
+	// Arch maps GOARCH values to the names used in the URL, where they differ
+	Arch map[string]string `yaml:"arch,omitempty"`
+
+	// Signature and Certificate locate a cosign keyless signature of the artifact
+	Signature                 string `yaml:"signature,omitempty"`
+	Certificate               string `yaml:"certificate,omitempty"`
+	CertificateIdentityRegexp string `yaml:"certificateIdentityRegexp,omitempty"`
+	CertificateOIDCIssuer     string `yaml:"certificateOIDCIssuer,omitempty"`
+
+	// Checksum locates a sha256 file published next to the artifact, used
+	// for releases that cannot all be pinned, such as Kubernetes binaries
+	Checksum string `yaml:"checksum,omitempty"`
+
+	Releases []ArtifactRelease `yaml:"releases,omitempty"`
+}
+
+// ArtifactRelease is a pinned release with its checksum per architecture
+type ArtifactRelease struct {
+	Version string            `yaml:"version"`
+	SHA256  map[string]string `yaml:"sha256,omitempty"`
+}
+
+// Artifact is one file to download and how to verify it
+type Artifact struct {
+	Name    string
+	Version string
+	Arch    string
+	URL     string
+
+	// SHA256 is empty when no checksum is pinned for this release
+	SHA256 string
+
+	Signature                 string
+	Certificate               string
+	CertificateIdentityRegexp string
+	CertificateOIDCIssuer     string
+
+	// ChecksumURL is the published sha256 file, see ResolveChecksum
+	ChecksumURL string
+}
+
+// LoadArtifactManifest returns the manifest embedded in the binary
+func LoadArtifactManifest() (*ArtifactManifest, error) {
+	var manifest ArtifactManifest
+	if err := yaml.Unmarshal(artifactManifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse artifact manifest: %w", err)
+	}
+	if manifest.SchemaVersion != artifactSchemaVersion {
+		return nil, fmt.Errorf("unsupported artifact manifest schema version %d", manifest.SchemaVersion)
+	}
+	return &manifest, nil
+}
+
+// LookupArtifact resolves the download URL and pinned checksum of an
+// artifact release for an architecture
+func LookupArtifact(name, version, arch string) (Artifact, error) {
+	manifest, err := LoadArtifactManifest()
+	if err != nil {
+		return Artifact{}, err
+	}
+	source := manifest.source(name)
+	if source == nil {
+		return Artifact{}, fmt.Errorf("unknown artifact %q", name)
+	}
+	return source.resolve(version, arch), nil
+}
+
+// Verified reports whether the artifact has a pinned checksum
+func (a Artifact) Verified() bool {
+	return a.SHA256 != ""
+}
+
+// RequireVerified reports whether RequireVerifiedEnv makes unpinned
+// artifacts fail
+func RequireVerified() bool {
+	return os.Getenv(RequireVerifiedEnv) == "1"
+}
+
+// Check warns that an artifact without a pinned checksum will be downloaded
+// unverified, or returns an error when RequireVerifiedEnv is set
+func (a Artifact) Check() error {
+	if a.Verified() {
+		return nil
+	}
+	if RequireVerified() {
+		return a.unpinnedError()
+	}
+	style.Info("Warning: no sha256 pinned for %s %s (%s), it will be downloaded unverified", a.Name, a.Version, a.Arch)
+	return nil
+}
+
+// unpinnedError tells how to pin an artifact without a pinned checksum
+func (a Artifact) unpinnedError() error {
+	return fmt.Errorf("no sha256 pinned for %s %s (%s); pin it in pkg/build/artifacts.yaml (kipod build checksums --add %s=%s), or unset %s to download it unverified",
+		a.Name, a.Version, a.Arch, a.Name, a.Version, RequireVerifiedEnv)
+}
+
+// FetchCommand returns a shell command downloading the artifact to dest and
+// verifying its pinned checksum. Without one the download is unverified, or
+// fails when RequireVerifiedEnv is set.
+func (a Artifact) FetchCommand(dest string) string {
+	fetch := fmt.Sprintf("curl -fsSL -o %s %s", dest, a.URL)
+	if !a.Verified() && RequireVerified() {
+		return fmt.Sprintf("{ echo %q >&2; false; }", a.unpinnedError().Error())
+	}
+	if !a.Verified() {
+		return fmt.Sprintf("%s && echo 'Warning: %s %s was not verified' >&2", fetch, a.Name, a.Version)
+	}
+	return fmt.Sprintf("%s && echo '%s  %s' | sha256sum -c -", fetch, a.SHA256, dest)
+}
+
+// MissingChecksums lists the releases of the manifest, as "NAME VERSION
+// (ARCH)", that lack a checksum for one of ArtifactArchitectures or are not
+// a fixed version. An empty list means every download is verified.
+func (m *ArtifactManifest) MissingChecksums() []string {
+	var missing []string
+	for _, source := range m.Artifacts {
+		for _, release := range source.Releases {
+			if isMovingVersion(release.Version) {
+				missing = append(missing, fmt.Sprintf("%s %s (not a fixed release)", source.Name, release.Version))
+				continue
+			}
+			for _, arch := range ArtifactArchitectures {
+				if !isSHA256(release.SHA256[arch]) {
+					missing = append(missing, fmt.Sprintf("%s %s (%s)", source.Name, release.Version, arch))
+				}
+			}
+		}
+	}
+	return missing
+}
+
+// isMovingVersion reports whether a version names a release that changes
+// over time, which cannot be pinned
+func isMovingVersion(version string) bool {
+	switch version {
+	case "", "latest", "nightly", "master", "main":
+		return true
+	}
+	return false
+}
+
+// isSHA256 reports whether s is a hex encoded sha256
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+func (m *ArtifactManifest) source(name string) *ArtifactSource {
+	for i := range m.Artifacts {
+		if m.Artifacts[i].Name == name {
+			return &m.Artifacts[i]
+		}
+	}
+	return nil
+}
+
+func (s *ArtifactSource) resolve(version, arch string) Artifact {
+	urlArch := arch
+	if mapped, ok := s.Arch[arch]; ok {
+		urlArch = mapped
+	}
+	url := strings.NewReplacer("{version}", version, "{arch}", urlArch).Replace(s.URL)
+	expand := strings.NewReplacer("{url}", url, "{version}", version, "{arch}", urlArch)
+
+	artifact := Artifact{
+		Name:                      s.Name,
+		Version:                   version,
+		Arch:                      arch,
+		URL:                       url,
+		Signature:                 expand.Replace(s.Signature),
+		Certificate:               expand.Replace(s.Certificate),
+		CertificateIdentityRegexp: s.CertificateIdentityRegexp,
+		CertificateOIDCIssuer:     s.CertificateOIDCIssuer,
+		ChecksumURL:               expand.Replace(s.Checksum),
+	}
+	for _, release := range s.Releases {
+		if release.Version == version {
+			artifact.SHA256 = release.SHA256[arch]
+		}
+	}
+	return artifact
+}
+
+// PinArtifactChecksums downloads every release of the manifest that is
+// missing a checksum, after adding the releases in add (name to version),
+// and fills in the sha256. Moving releases such as "latest" cannot be
+// pinned and are an error.
+func PinArtifactChecksums(manifest *ArtifactManifest, add map[string]string) error {
+	for name, version := range add {
+		source := manifest.source(name)
+		if source == nil {
+			return fmt.Errorf("unknown artifact %q", name)
+		}
+		found := false
+		for _, release := range source.Releases {
+			found = found || release.Version == version
+		}
+		if !found {
+			source.Releases = append(source.Releases, ArtifactRelease{Version: version})
+		}
+	}
+
+	for i := range manifest.Artifacts {
+		source := &manifest.Artifacts[i]
+		for j := range source.Releases {
+			release := &source.Releases[j]
+			if isMovingVersion(release.Version) {
+				return fmt.Errorf("%s %s is not a fixed release and cannot be pinned", source.Name, release.Version)
+			}
+			for _, arch := range ArtifactArchitectures {
+				if release.SHA256[arch] != "" {
+					continue
+				}
+				url := source.resolve(release.Version, arch).URL
+				sum, err := downloadSHA256(url)
+				if err != nil {
+					return err
+				}
+				if release.SHA256 == nil {
+					release.SHA256 = make(map[string]string)
+				}
+				release.SHA256[arch] = sum
+			}
+		}
+	}
+	return nil
+}
+
+// downloadSHA256 returns the sha256 of the file at url
+func downloadSHA256(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,72 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+const (
+	// DefaultBuilderImage is the podman image containerized builds run in.
+	// Pinning it pins the podman and buildah versions doing the build.
+	DefaultBuilderImage = "quay.io/podman/stable:v5.6.2"
+
+	// builderCacheVolume keeps the builder's image store between builds so
+	// base images and unchanged layers are reused
+	builderCacheVolume = "kipod-builder-cache"
+)
+
+// buildContainerized runs podman build with the given arguments inside a
+// builder container and loads the resulting image into the host's store.
+// baseDir is mounted as the build context at /context.
+func buildContainerized(builderImage, baseDir, containerfile, imageTag string, buildArgs []string) error {
+	if builderImage == "" {
+		builderImage = DefaultBuilderImage
+	}
+	contextDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve build context: %w", err)
+	}
+
+	outputDir, err := os.MkdirTemp("", "kipod-build-")
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	if err := podman.CreateVolume(builderCacheVolume, map[string]string{podman.LabelManaged: "true"}); err != nil {
+		return err
+	}
+
+	fmt.Printf("Building in %s\n", builderImage)
+	run := func(mounts []string, command ...string) error {
+		args := []string{"run", "--rm", "--privileged",
+			"--volume", builderCacheVolume + ":/var/lib/containers",
+		}
+		for _, mount := range mounts {
+			args = append(args, "--volume", mount)
+		}
+		args = append(args, builderImage)
+		cmd := podman.Command(append(args, command...)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+
+	build := append([]string{"podman"}, buildArgs...)
+	build = append(build, "--file", "/context/"+containerfile, "/context")
+	if err := run([]string{contextDir + ":/context:ro,Z"}, build...); err != nil {
+		return fmt.Errorf("failed to build image in builder container: %w", err)
+	}
+
+	archive := filepath.Join(outputDir, "image.tar")
+	if err := run([]string{outputDir + ":/output:Z"}, "podman", "save", "--output", "/output/image.tar", imageTag); err != nil {
+		return fmt.Errorf("failed to export image from builder container: %w", err)
+	}
+	if _, err := LoadImage(archive); err != nil {
+		return err
+	}
+	return nil
+}
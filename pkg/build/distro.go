@@ -0,0 +1,133 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Distro is an OS family node images can be built on
+type Distro struct {
+	// Name selects images/base/distro/<name>.sh, which installs the packages
+	Name string
+
+	// DefaultImage is the base image used when only the distro is given
+	DefaultImage string
+
+	// PackageFormat is the pkgs.k8s.io repository flavor, "rpm" or "deb"
+	PackageFormat string
+
+	// DefaultCRIOSource is how CRI-O is installed unless configured. CRI-O
+	// built from git links against the Fedora builder's glibc, so other
+	// distros default to the statically linked packages.
+	DefaultCRIOSource string
+
+	// CRIOSources are the CRI-O sources that work on the distro
+	CRIOSources []string
+
+	// Optional runtimes are only packaged for Fedora
+	SupportsWasm bool
+	SupportsKata bool
+}
+
+// Distros are the supported base distros
+var Distros = []Distro{
+	{
+		Name:              "fedora",
+		DefaultImage:      "registry.fedoraproject.org/fedora-minimal:43",
+		PackageFormat:     "rpm",
+		DefaultCRIOSource: "git",
+		CRIOSources:       []string{"git", "package", "bundle"},
+		SupportsWasm:      true,
+		SupportsKata:      true,
+	},
+	{
+		Name:              "centos-stream",
+		DefaultImage:      "quay.io/centos/centos:stream10",
+		PackageFormat:     "rpm",
+		DefaultCRIOSource: "package",
+		CRIOSources:       []string{"package", "bundle"},
+	},
+	{
+		Name:              "ubuntu",
+		DefaultImage:      "docker.io/library/ubuntu:24.04",
+		PackageFormat:     "deb",
+		DefaultCRIOSource: "package",
+		CRIOSources:       []string{"package", "bundle"},
+	},
+}
+
+// bootcDistro is the base of the bootc variant, which installs CRI-O with
+// rpm-ostree and so only from packages
+var bootcDistro = Distro{
+	Name:              "fedora-bootc",
+	DefaultImage:      "quay.io/fedora/fedora-bootc:43",
+	PackageFormat:     "rpm",
+	DefaultCRIOSource: "package",
+	CRIOSources:       []string{"package"},
+}
+
+// DefaultDistro is the distro of the default base image
+const DefaultDistro = "fedora"
+
+// LookupDistro returns a supported distro by name
+func LookupDistro(name string) (Distro, error) {
+	names := make([]string, 0, len(Distros))
+	for _, distro := range Distros {
+		if distro.Name == name {
+			return distro, nil
+		}
+		names = append(names, distro.Name)
+	}
+	return Distro{}, fmt.Errorf("unsupported base distro %q (supported: %s)", name, strings.Join(names, ", "))
+}
+
+// supportsCRIOSource reports whether CRI-O can be installed from source
+func (d Distro) supportsCRIOSource(source string) bool {
+	for _, supported := range d.CRIOSources {
+		if supported == source {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveBaseImage returns the base image and distro to build on. An empty
+// distro is detected from the image name; an empty image is the distro's
+// default image.
+func ResolveBaseImage(image, distroName string) (string, Distro, error) {
+	if distroName == "" {
+		distroName = detectDistro(image)
+		if distroName == "" {
+			return "", Distro{}, fmt.Errorf("cannot tell the distro of base image %s; set baseDistro to one of fedora, centos-stream or ubuntu", image)
+		}
+	}
+	distro, err := LookupDistro(distroName)
+	if err != nil {
+		return "", Distro{}, err
+	}
+	if image == "" {
+		image = distro.DefaultImage
+	}
+	return image, distro, nil
+}
+
+// detectDistro guesses the distro from an image reference, e.g.
+// quay.io/centos/centos:stream10 is centos-stream
+func detectDistro(image string) string {
+	if image == "" {
+		return DefaultDistro
+	}
+	repository := strings.ToLower(image)
+	if i := strings.LastIndex(repository, "/"); i >= 0 {
+		repository = repository[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(repository, "fedora"):
+		return "fedora"
+	case strings.HasPrefix(repository, "centos"):
+		return "centos-stream"
+	case strings.HasPrefix(repository, "ubuntu"):
+		return "ubuntu"
+	}
+	return ""
+}
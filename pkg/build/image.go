@@ -1,10 +1,16 @@
 package build
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/paths"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
 )
 
 const (
@@ -13,6 +19,31 @@ const (
 
 	// DefaultImageTag is the default tag
 	DefaultImageTag = "latest"
+
+	// LabelKubernetesVersion is the image label holding the installed Kubernetes version
+	LabelKubernetesVersion = "io.kipod.k8s-version"
+
+	// LabelCRIOVersion is the image label holding the CRI-O version, a minor
+	// version or an exact release
+	LabelCRIOVersion = "io.kipod.crio-version"
+
+	// LabelWasm is the image label set when the WebAssembly runtime is installed
+	LabelWasm = "io.kipod.wasm"
+
+	// LabelSandboxRuntime is the image label naming the installed sandboxed runtime
+	LabelSandboxRuntime = "io.kipod.sandbox-runtime"
+
+	// LabelBaseImage is the image label holding the base image the node image was built on
+	LabelBaseImage = "io.kipod.base-image"
+
+	// LabelDistro is the image label naming the distro of the base image
+	LabelDistro = "io.kipod.distro"
+
+	// LabelVariant is the image label naming the image variant, e.g. bootc
+	LabelVariant = "io.kipod.variant"
+
+	// VariantBootc builds the node image from a bootc/ostree base (experimental)
+	VariantBootc = "bootc"
 )
 
 // ImageBuildOptions contains options for building a node image
@@ -29,11 +60,60 @@ type ImageBuildOptions struct {
 	// KubernetesVersion is the Kubernetes version to install
 	KubernetesVersion string
 
-	// CRIOVersion is the CRI-O version to install
+	// CRIOVersion is the CRI-O version to install, a minor version (the tip
+	// of its release branch) or an exact release such as 1.34.2
 	CRIOVersion string
 
+	// CRIOSource is how CRI-O is installed: "git" (default), "package" or "bundle"
+	CRIOSource string
+
+	// CRIOSourceURL overrides the git repository, RPM repository or bundle URL
+	CRIOSourceURL string
+
+	// CRIOSHA256 is the expected checksum of a CRI-O bundle
+	CRIOSHA256 string
+
+	// KubernetesRepoURL overrides the Kubernetes package repository base URL
+	KubernetesRepoURL string
+
+	// BaseImage is the image the node image is built on (default: the
+	// distro's default image)
+	BaseImage string
+
+	// BaseDistro is the distro of BaseImage, detected from its name when empty
+	BaseDistro string
+
+	// Variant is "" for the default image or "bootc" (experimental)
+	Variant string
+
+	// CrunVersion is the crun release installed from GitHub
+	CrunVersion string
+
+	// RuncVersion is the runc release installed from GitHub
+	RuncVersion string
+
+	// RunscVersion is the dated gVisor release installed for the gvisor
+	// sandbox runtime (default DefaultRunscVersion)
+	RunscVersion string
+
+	// VerifySignatures verifies cosign signatures of artifacts that publish them
+	VerifySignatures bool
+
+	// Containerized runs the build in a builder container instead of with
+	// the host's podman, so the build toolchain is the same everywhere
+	Containerized bool
+
+	// BuilderImage is the image of the builder container (default DefaultBuilderImage)
+	BuilderImage string
+
 	// Rebuild forces a rebuild even if the image already exists
 	Rebuild bool
+
+	// WithWasm installs the WebAssembly runtime (crun-wasm/WasmEdge)
+	WithWasm bool
+
+	// SandboxRuntime installs an experimental sandboxed runtime ("kata" or "gvisor")
+	SandboxRuntime string
 }
 
 // DefaultImageBuildOptions returns default build options with latest versions
@@ -44,9 +124,16 @@ func DefaultImageBuildOptions() *ImageBuildOptions {
 		BaseDir:           "",
 		KubernetesVersion: "1.34", // Latest K8s (Nov 2025)
 		CRIOVersion:       "1.34", // Latest CRI-O (Nov 2025)
+		CrunVersion:       "1.25",
+		RuncVersion:       "1.3.3",
+		RunscVersion:      DefaultRunscVersion,
 	}
 }
 
+// DefaultRunscVersion is the dated gVisor release installed by default, a
+// fixed release so its checksum can be pinned
+const DefaultRunscVersion = "20240212"
+
 // BuildImage builds a kipod node image using podman build
 func BuildImage(opts *ImageBuildOptions) error {
 	if opts == nil {
@@ -63,12 +150,18 @@ func BuildImage(opts *ImageBuildOptions) error {
 		}
 		execDir := filepath.Dir(execPath)
 
-		// Try several possible locations
+		// Try a source checkout, then where packages install the build
+		// context: next to the binary's prefix (e.g. Homebrew), the user's
+		// data dir and the system data dirs
 		possiblePaths := []string{
 			filepath.Join(execDir, "..", "images", "base"),
 			filepath.Join(execDir, "images", "base"),
 			"./images/base",
-			"/usr/share/kipod/images/base",
+			filepath.Join(execDir, "..", "share", "kipod", "images", "base"),
+			filepath.Join(paths.DataDir(), "images", "base"),
+		}
+		for _, dir := range paths.SharedDataDirs() {
+			possiblePaths = append(possiblePaths, filepath.Join(dir, "images", "base"))
 		}
 
 		for _, path := range possiblePaths {
@@ -83,7 +176,37 @@ func BuildImage(opts *ImageBuildOptions) error {
 		}
 	}
 
-	containerfilePath := filepath.Join(baseDir, "Containerfile")
+	containerfile := "Containerfile"
+	var baseImage string
+	var distro Distro
+	switch opts.Variant {
+	case "":
+		var err error
+		baseImage, distro, err = ResolveBaseImage(opts.BaseImage, opts.BaseDistro)
+		if err != nil {
+			return err
+		}
+		if opts.WithWasm && !distro.SupportsWasm {
+			return fmt.Errorf("the WebAssembly runtime is not available on %s base images", distro.Name)
+		}
+		if opts.SandboxRuntime == "kata" && !distro.SupportsKata {
+			return fmt.Errorf("Kata Containers is not available on %s base images", distro.Name)
+		}
+	case VariantBootc:
+		if opts.WithWasm || opts.SandboxRuntime != "" {
+			return fmt.Errorf("optional runtimes are not available in the bootc variant")
+		}
+		containerfile = "Containerfile.bootc"
+		distro = bootcDistro
+		baseImage = opts.BaseImage
+		if baseImage == "" {
+			baseImage = distro.DefaultImage
+		}
+	default:
+		return fmt.Errorf("unknown image variant %q (supported: %s)", opts.Variant, VariantBootc)
+	}
+
+	containerfilePath := filepath.Join(baseDir, containerfile)
 	if _, err := os.Stat(containerfilePath); err != nil {
 		return fmt.Errorf("Containerfile not found at %s: %w", containerfilePath, err)
 	}
@@ -107,8 +230,21 @@ func BuildImage(opts *ImageBuildOptions) error {
 
 	fmt.Printf("Building kipod node image: %s\n", imageTag)
 	fmt.Printf("Using Containerfile from: %s\n", baseDir)
+	fmt.Printf("Base image: %s (%s)\n", baseImage, distro.Name)
+	if opts.Variant != "" {
+		fmt.Printf("Variant: %s (experimental)\n", opts.Variant)
+	}
 	fmt.Printf("Kubernetes version: %s\n", opts.KubernetesVersion)
 	fmt.Printf("CRI-O version: %s\n", opts.CRIOVersion)
+	if opts.CRIOSource != "" {
+		fmt.Printf("CRI-O source: %s\n", opts.CRIOSource)
+	}
+	if opts.WithWasm {
+		fmt.Printf("WebAssembly runtime: enabled\n")
+	}
+	if opts.SandboxRuntime != "" {
+		fmt.Printf("Sandboxed runtime: %s (experimental)\n", opts.SandboxRuntime)
+	}
 	fmt.Println()
 
 	// Parse versions to get major.minor and full version
@@ -149,21 +285,53 @@ func BuildImage(opts *ImageBuildOptions) error {
 		}
 	}
 
-	crioMajorMinor := opts.CRIOVersion
-	// crioFull := opts.CRIOVersion // Unused for now as we use release branch
+	crioArgs, err := crioBuildArgs(opts, distro)
+	if err != nil {
+		return err
+	}
+
+	// The bootc variant takes every component from packages
+	var artifactArgs []string
+	if opts.Variant != VariantBootc {
+		artifactArgs, err = artifactBuildArgs(opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	kubernetesRepo := opts.KubernetesRepoURL
+	if kubernetesRepo == "" {
+		kubernetesRepo = fmt.Sprintf("https://pkgs.k8s.io/core:/stable:/v%s/%s/", k8sMajorMinor, distro.PackageFormat)
+	}
 
 	// Build the image using podman build
 	args := []string{
 		"build",
 		"--tag", imageTag,
+		"--build-arg", fmt.Sprintf("BASE_IMAGE=%s", baseImage),
+		"--build-arg", fmt.Sprintf("BASE_DISTRO=%s", distro.Name),
 		"--build-arg", fmt.Sprintf("K8S_VERSION=%s", k8sMajorMinor),
 		"--build-arg", fmt.Sprintf("K8S_FULL_VERSION=%s", k8sFull),
-		"--build-arg", fmt.Sprintf("CRIO_VERSION=%s", crioMajorMinor),
-		"--file", containerfilePath,
-		baseDir,
+		"--build-arg", fmt.Sprintf("KUBERNETES_REPO_URL=%s", withTrailingSlash(kubernetesRepo)),
+		"--build-arg", fmt.Sprintf("WITH_WASM=%t", opts.WithWasm),
+		"--build-arg", fmt.Sprintf("SANDBOX_RUNTIME=%s", opts.SandboxRuntime),
+	}
+	args = append(args, crioArgs...)
+	args = append(args, artifactArgs...)
+	if RequireVerified() {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=1", RequireVerifiedEnv))
 	}
 
-	cmd := exec.Command("podman", args...)
+	if opts.Containerized {
+		if err := buildContainerized(opts.BuilderImage, baseDir, containerfile, imageTag, args); err != nil {
+			return err
+		}
+		fmt.Printf("\n✓ Successfully built image: %s\n", imageTag)
+		return nil
+	}
+	args = append(args, "--file", containerfilePath, baseDir)
+
+	cmd := podman.Command(args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -175,9 +343,134 @@ func BuildImage(opts *ImageBuildOptions) error {
 	return nil
 }
 
+// crioBuildArgs returns the build arguments selecting the CRI-O version and
+// how the builder stage obtains it for the base distro
+func crioBuildArgs(opts *ImageBuildOptions, distro Distro) ([]string, error) {
+	version := strings.TrimPrefix(opts.CRIOVersion, "v")
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid CRI-O version %q", opts.CRIOVersion)
+	}
+	minor := parts[0] + "." + parts[1]
+	exact := len(parts) == 3
+
+	source := opts.CRIOSource
+	if source == "" {
+		source = distro.DefaultCRIOSource
+	}
+	if !distro.supportsCRIOSource(source) {
+		return nil, fmt.Errorf("CRI-O source %q is not supported on %s base images (supported: %s)", source, distro.Name, strings.Join(distro.CRIOSources, ", "))
+	}
+
+	sourceURL := opts.CRIOSourceURL
+	sha256 := opts.CRIOSHA256
+	var signatureArgs []string
+	gitRef := "release-" + minor
+	pkg := "cri-o"
+	switch source {
+	case "git":
+		if sourceURL == "" {
+			sourceURL = "https://github.com/cri-o/cri-o.git"
+		}
+		if exact {
+			gitRef = "v" + version
+		}
+	case "package":
+		if sourceURL == "" {
+			sourceURL = fmt.Sprintf("https://pkgs.k8s.io/addons:/cri-o:/stable:/v%s/rpm/", minor)
+		}
+		sourceURL = withTrailingSlash(sourceURL)
+		if exact {
+			pkg = "cri-o-" + version
+		}
+	case "bundle":
+		if sourceURL != "" {
+			break
+		}
+		if !exact {
+			return nil, fmt.Errorf("a CRI-O bundle needs an exact version such as %s.0, got %s", minor, opts.CRIOVersion)
+		}
+		bundle, err := LookupArtifact("cri-o", version, runtime.GOARCH)
+		if err != nil {
+			return nil, err
+		}
+		sourceURL = bundle.URL
+		if sha256 == "" {
+			sha256 = bundle.SHA256
+		}
+		if sha256 == "" {
+			if err := bundle.Check(); err != nil {
+				return nil, err
+			}
+		}
+		if opts.VerifySignatures && bundle.Signature != "" {
+			signatureArgs = []string{
+				"--build-arg", fmt.Sprintf("CRIO_SIGNATURE=%s", bundle.Signature),
+				"--build-arg", fmt.Sprintf("CRIO_CERTIFICATE=%s", bundle.Certificate),
+				"--build-arg", fmt.Sprintf("CRIO_CERTIFICATE_IDENTITY_REGEXP=%s", bundle.CertificateIdentityRegexp),
+				"--build-arg", fmt.Sprintf("CRIO_CERTIFICATE_OIDC_ISSUER=%s", bundle.CertificateOIDCIssuer),
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown CRI-O source %q", source)
+	}
+
+	return append([]string{
+		"--build-arg", fmt.Sprintf("CRIO_VERSION=%s", minor),
+		"--build-arg", fmt.Sprintf("CRIO_FULL_VERSION=%s", version),
+		"--build-arg", fmt.Sprintf("CRIO_SOURCE=%s", source),
+		"--build-arg", fmt.Sprintf("CRIO_URL=%s", sourceURL),
+		"--build-arg", fmt.Sprintf("CRIO_GIT_REF=%s", gitRef),
+		"--build-arg", fmt.Sprintf("CRIO_PACKAGE=%s", pkg),
+		"--build-arg", fmt.Sprintf("CRIO_SHA256=%s", sha256),
+	}, signatureArgs...), nil
+}
+
+// artifactBuildArgs returns the URLs and pinned checksums of the binaries the
+// node image stage downloads
+func artifactBuildArgs(opts *ImageBuildOptions) ([]string, error) {
+	type download struct{ arg, name, version string }
+	downloads := []download{
+		{"CRUN", "crun", opts.CrunVersion},
+		{"RUNC", "runc", opts.RuncVersion},
+	}
+	if opts.SandboxRuntime == "gvisor" {
+		version := opts.RunscVersion
+		if version == "" {
+			version = DefaultRunscVersion
+		}
+		downloads = append(downloads, download{"RUNSC", "runsc", version})
+	}
+
+	var args []string
+	for _, download := range downloads {
+		artifact, err := LookupArtifact(download.name, download.version, runtime.GOARCH)
+		if err != nil {
+			return nil, err
+		}
+		if err := artifact.Check(); err != nil {
+			return nil, err
+		}
+		args = append(args,
+			"--build-arg", fmt.Sprintf("%s_URL=%s", download.arg, artifact.URL),
+			"--build-arg", fmt.Sprintf("%s_SHA256=%s", download.arg, artifact.SHA256),
+		)
+	}
+	return args, nil
+}
+
+// withTrailingSlash makes a repository base URL end in a slash so paths can
+// be appended to it
+func withTrailingSlash(url string) string {
+	if strings.HasSuffix(url, "/") {
+		return url
+	}
+	return url + "/"
+}
+
 // ImageExists checks if an image exists locally
 func ImageExists(imageName string) (bool, error) {
-	cmd := exec.Command("podman", "image", "exists", imageName)
+	cmd := podman.Command("image", "exists", imageName)
 	err := cmd.Run()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
@@ -191,6 +484,22 @@ func ImageExists(imageName string) (bool, error) {
 	return true, nil
 }
 
+// GetImageLabels returns the labels of a local image
+func GetImageLabels(imageName string) (map[string]string, error) {
+	cmd := podman.Command("image", "inspect", "--format", "{{json .Labels}}", imageName)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+	}
+
+	labels := make(map[string]string)
+	if err := json.Unmarshal(output, &labels); err != nil {
+		return nil, fmt.Errorf("failed to parse labels of image %s: %w", imageName, err)
+	}
+
+	return labels, nil
+}
+
 // GetImageFullName returns the full image name with tag
 func GetImageFullName(name, tag string) string {
 	if name == "" {
@@ -204,7 +513,7 @@ func GetImageFullName(name, tag string) string {
 
 // ListImages lists kipod node images
 func ListImages() ([]string, error) {
-	cmd := exec.Command("podman", "images",
+	cmd := podman.Command("images",
 		"--filter", "reference=*/kipod-node:*",
 		"--format", "{{.Repository}}:{{.Tag}}")
 
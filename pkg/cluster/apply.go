@@ -0,0 +1,165 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// ApplyOptions select the addons Apply installs again. Node configs are
+// always reconciled, since only drifted nodes are restarted.
+type ApplyOptions struct {
+	// FakeNodes scales the kwok nodes to the configured count
+	FakeNodes bool
+	// CertManager installs or upgrades cert-manager
+	CertManager bool
+	// HelmCharts installs or upgrades the Helm charts
+	HelmCharts bool
+	// PostCreateManifests applies the post-create manifests
+	PostCreateManifests bool
+	// PullSecret updates the image pull secret in every namespace
+	PullSecret bool
+}
+
+// Apply brings a running cluster in line with the mutable settings of cfg.
+// Node by node, it rewrites the registry credentials, signature policy, CRI-O
+// drop-ins and kubelet verbosity that differ from cfg and restarts only the
+// services affected, then installs the addons selected by opts on the
+// control-plane.
+func Apply(cfg *Config, opts ApplyOptions) error {
+	c, err := NewCluster(cfg)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := ListNodes(cfg.Name)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("cluster '%s' not found", cfg.Name)
+	}
+	sortByStartOrder(nodes)
+	for _, node := range nodes {
+		if node.State != "running" {
+			return fmt.Errorf("node %s is %s; start the cluster first with: kipod start cluster --name %s", node.NodeName(), node.State, cfg.Name)
+		}
+	}
+
+	for _, node := range nodes {
+		if err := c.applyNodeConfig(node); err != nil {
+			return fmt.Errorf("node %s: %w", node.NodeName(), err)
+		}
+	}
+
+	controlPlane, err := GetControlPlaneNode(cfg.Name)
+	if err != nil {
+		return err
+	}
+	if opts.PullSecret && c.config.RegistryAuthPullSecret {
+		if err := c.installPullSecret(controlPlane.ID); err != nil {
+			return fmt.Errorf("failed to install the image pull secret: %w", err)
+		}
+	}
+	if opts.FakeNodes {
+		if c.config.FakeNodes > 0 {
+			if err := ScaleFakeNodes(cfg.Name, c.config.FakeNodes, c.config.KwokVersion); err != nil {
+				return fmt.Errorf("failed to scale fake nodes: %w", err)
+			}
+		} else if err := scaleFakeNodes(controlPlane.ID, cfg.Name, 0); err != nil {
+			return fmt.Errorf("failed to remove fake nodes: %w", err)
+		}
+	}
+	if opts.CertManager && c.config.CertManagerVersion != "" {
+		if err := c.installCertManager(controlPlane.ID); err != nil {
+			return fmt.Errorf("failed to install cert-manager: %w", err)
+		}
+	}
+	if opts.HelmCharts && len(c.config.HelmCharts) > 0 {
+		if err := c.installHelmCharts(controlPlane.ID); err != nil {
+			return fmt.Errorf("failed to install helm charts: %w", err)
+		}
+	}
+	if opts.PostCreateManifests && len(c.config.PostCreateManifests) > 0 {
+		if err := c.applyPostCreateManifests(controlPlane.ID); err != nil {
+			return fmt.Errorf("failed to apply post-create manifests: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyNodeConfig rewrites the registry credentials, signature policy, CRI-O
+// drop-ins and kubelet verbosity of a running node that differ from the
+// config, restarting CRI-O or the kubelet only when their config changed
+func (c *Cluster) applyNodeConfig(node podman.Container) error {
+	if err := c.syncRegistryAuth(node.ID); err != nil {
+		return err
+	}
+	if err := c.syncSignaturePolicy(node.ID); err != nil {
+		return err
+	}
+
+	dropins, err := c.crioDropins(node)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, path := range managedCRIODropins {
+		want, ok := dropins[path]
+		installed, err := podman.Exec(node.ID, []string{"cat", path})
+		exists := err == nil
+		switch {
+		case ok && (!exists || installed != want):
+			if err := writeNodeFile(node.ID, path, want, 0644); err != nil {
+				return err
+			}
+			changed = true
+		case !ok && exists:
+			if _, err := podman.Exec(node.ID, []string{"rm", "-f", path}); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			changed = true
+		}
+	}
+	if changed {
+		style.Step("Restarting CRI-O on %s with the new drop-ins", node.NodeName())
+		if err := restartCRIO(node); err != nil {
+			return err
+		}
+	}
+
+	level := c.config.LogLevels["kubelet"]
+	current, err := kubeletVerbosity(node.ID)
+	if err != nil {
+		return err
+	}
+	if level != current {
+		style.Step("Restarting kubelet on %s with verbosity %q", node.NodeName(), level)
+		if err := setKubeletVerbosity(node.ID, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeNodeFile copies content to path in a node as is, with mode perm
+func writeNodeFile(containerID, path, content string, perm os.FileMode) error {
+	dir, err := os.MkdirTemp("", "kipod-apply-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, filepath.Base(path))
+	if err := os.WriteFile(src, []byte(content), perm); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", path, err)
+	}
+	if err := podman.CopyToContainer(containerID, src, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
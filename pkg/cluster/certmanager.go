@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+const (
+	// DefaultCertManagerVersion is the cert-manager release the addon installs
+	DefaultCertManagerVersion = "1.16.2"
+
+	// CAIssuerName is the ClusterIssuer signing certificates with the cluster CA
+	CAIssuerName = "kipod-ca"
+
+	// caSecretNamespace and caSecretName hold the cluster CA key pair
+	caSecretNamespace = "cert-manager"
+	caSecretName      = "kipod-ca"
+)
+
+// caIssuerManifest bootstraps a self-signed CA and a ClusterIssuer using it
+const caIssuerManifest = `apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: kipod-selfsigned
+spec:
+  selfSigned: {}
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+spec:
+  isCA: true
+  commonName: kipod %[3]s CA
+  secretName: %[1]s
+  duration: 87600h
+  privateKey:
+    algorithm: ECDSA
+    size: 256
+  issuerRef:
+    name: kipod-selfsigned
+    kind: ClusterIssuer
+---
+apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: %[4]s
+spec:
+  ca:
+    secretName: %[1]s`
+
+// installCertManager deploys cert-manager and a CA ClusterIssuer backed by a
+// self-signed CA, so Ingresses can request certificates with the
+// cert-manager.io/cluster-issuer: kipod-ca annotation. The manifest is
+// verified when its sha256 is pinned and downloaded with a warning otherwise.
+func (c *Cluster) installCertManager(controlPlaneID string) error {
+	version := c.config.CertManagerVersion
+	style.Step("Installing cert-manager %s 🔐", version)
+
+	deadline := time.Now().Add(c.waitTimeout())
+	remaining := remainingUntil(deadline)
+	artifact, err := build.LookupArtifact("cert-manager", version, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	if err := artifact.Check(); err != nil {
+		return err
+	}
+
+	const path = "/tmp/kipod-cert-manager.yaml"
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", artifact.FetchCommand(path)}); err != nil {
+		return fmt.Errorf("failed to download cert-manager %s: %w", version, err)
+	}
+	err = applyManifestFile(controlPlaneID, path, remaining)
+	_, _ = podman.Exec(controlPlaneID, []string{"rm", "-f", path})
+	if err != nil {
+		return fmt.Errorf("cert-manager: %w", err)
+	}
+
+	// The webhook serves a few seconds after its Deployment is available,
+	// until cainjector has patched its CA bundle
+	manifest := fmt.Sprintf(caIssuerManifest, caSecretName, caSecretNamespace, c.config.Name, CAIssuerName)
+	for {
+		err = applyManifest(controlPlaneID, manifest)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("failed to create the CA issuer: %w", err)
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	if _, err := podman.Exec(controlPlaneID, []string{
+		"kubectl", "wait", "--for=condition=Ready", "-n", caSecretNamespace,
+		"certificate/" + caSecretName, "--timeout=" + remaining(),
+	}); err != nil {
+		return fmt.Errorf("CA certificate not ready: %w", err)
+	}
+	return nil
+}
+
+// ClusterCA returns the PEM encoded CA certificate of the cert-manager addon
+func ClusterCA(name string) (string, error) {
+	controlPlane, err := GetControlPlaneNode(name)
+	if err != nil {
+		return "", err
+	}
+	output, err := podman.Exec(controlPlane.ID, []string{
+		"kubectl", "get", "secret", "-n", caSecretNamespace, caSecretName,
+		"-o", `jsonpath={.data.ca\.crt}`,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read the cluster CA (is the cert-manager addon enabled?): %w", err)
+	}
+	pem, err := base64.StdEncoding.DecodeString(strings.TrimSpace(output))
+	if err != nil || len(pem) == 0 {
+		return "", fmt.Errorf("cluster %s has no CA certificate in secret %s/%s", name, caSecretNamespace, caSecretName)
+	}
+	return string(pem), nil
+}
@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// controlPlaneComponents are the static pods that load the certificates
+// kubeadm renews
+var controlPlaneComponents = []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler", "etcd"}
+
+// NodeCertificates is the kubeadm certificate expiration report of a node
+type NodeCertificates struct {
+	Node   string
+	Report string
+}
+
+// CheckCertificates returns the output of kubeadm certs check-expiration on
+// every control-plane node of a cluster. Kubelets rotate their own client
+// certificates, so worker nodes have nothing to report.
+func CheckCertificates(name string) ([]NodeCertificates, error) {
+	nodes, err := runningControlPlanes(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []NodeCertificates
+	for _, node := range nodes {
+		output, err := podman.Exec(node.ID, []string{"kubeadm", "certs", "check-expiration"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check certificates of %s: %w", node.NodeName(), err)
+		}
+		reports = append(reports, NodeCertificates{Node: node.NodeName(), Report: strings.TrimRight(output, "\n")})
+	}
+	return reports, nil
+}
+
+// RenewCertificates renews the kubeadm certificates and kubeconfigs of every
+// control-plane node, then restarts the control-plane static pods so they
+// load them, and waits for the API server to come back
+func RenewCertificates(name string) error {
+	nodes, err := runningControlPlanes(name)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if _, err := podman.Exec(node.ID, []string{"kubeadm", "certs", "renew", "all"}); err != nil {
+			return fmt.Errorf("failed to renew certificates of %s: %w", node.NodeName(), err)
+		}
+
+		if err := restartControlPlanePods(node.ID); err != nil {
+			return fmt.Errorf("%s: %w", node.NodeName(), err)
+		}
+
+		// kubectl on the node uses a copy of the renewed admin.conf
+		if _, err := podman.Exec(node.ID, []string{"cp", "/etc/kubernetes/admin.conf", "/root/.kube/config"}); err != nil {
+			return fmt.Errorf("failed to update the kubeconfig of %s: %w", node.NodeName(), err)
+		}
+
+		if err := waitForAPIServerTimeout(node.ID, defaultWaitTimeout); err != nil {
+			return fmt.Errorf("API server on %s did not come back: %w", node.NodeName(), err)
+		}
+	}
+	return nil
+}
+
+// restartControlPlanePods stops the control-plane static pod containers of a
+// node; kubelet starts them again, loading their certificates and kubeconfigs
+func restartControlPlanePods(nodeID string) error {
+	for _, component := range controlPlaneComponents {
+		stop := fmt.Sprintf("crictl ps --name '^%s$' -q | xargs -r crictl stop", component)
+		if _, err := podman.Exec(nodeID, []string{"sh", "-c", stop}); err != nil {
+			return fmt.Errorf("failed to restart %s: %w", component, err)
+		}
+	}
+	return nil
+}
+
+// runningControlPlanes returns the control-plane nodes of a cluster, which
+// must all be running
+func runningControlPlanes(name string) ([]podman.Container, error) {
+	containers, err := podman.ListContainers(map[string]string{
+		podman.LabelCluster: name,
+		podman.LabelRole:    "control-plane",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster containers: %w", err)
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("cluster '%s' not found", name)
+	}
+	for _, container := range containers {
+		if container.State != "running" {
+			return nil, fmt.Errorf("node %s is not running", container.Name)
+		}
+	}
+	return containers, nil
+}
+
+// waitForAPIServerTimeout waits until kubectl on a control-plane node can
+// reach the API server
+func waitForAPIServerTimeout(controlPlaneID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "--raw", "/readyz"}); err == nil {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("timeout waiting for API server")
+}
@@ -0,0 +1,166 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// chaosComment tags the iptables rules PartitionNodes inserts
+const chaosComment = "kipod-chaos"
+
+// Fault is a failure injected into a cluster and how to undo it
+type Fault struct {
+	Description string
+	Undo        func() error
+}
+
+// KillNode kills a node container; undoing starts it again. Nodes with tmpfs
+// storage come back without their pulled images.
+func KillNode(node podman.Container) (*Fault, error) {
+	if err := podman.KillContainer(node.ID); err != nil {
+		return nil, fmt.Errorf("failed to kill node %s: %w", node.NodeName(), err)
+	}
+	return &Fault{
+		Description: fmt.Sprintf("killed node %s", node.NodeName()),
+		Undo: func() error {
+			if err := podman.StartContainer(node.ID); err != nil {
+				return fmt.Errorf("failed to start node %s: %w", node.NodeName(), err)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// PauseNode freezes every process of a node, which then looks hung to the
+// rest of the cluster; undoing resumes it
+func PauseNode(node podman.Container) (*Fault, error) {
+	if err := podman.PauseContainer(node.ID); err != nil {
+		return nil, fmt.Errorf("failed to pause node %s: %w", node.NodeName(), err)
+	}
+	return &Fault{
+		Description: fmt.Sprintf("paused node %s", node.NodeName()),
+		Undo: func() error {
+			if err := podman.UnpauseContainer(node.ID); err != nil {
+				return fmt.Errorf("failed to unpause node %s: %w", node.NodeName(), err)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// StopService stops a systemd service of a node, e.g. crio or kubelet;
+// undoing starts it again
+func StopService(node podman.Container, service string) (*Fault, error) {
+	if _, err := podman.Exec(node.ID, []string{"systemctl", "stop", service}); err != nil {
+		return nil, fmt.Errorf("failed to stop %s on %s: %w", service, node.NodeName(), err)
+	}
+	return &Fault{
+		Description: fmt.Sprintf("stopped %s on %s", service, node.NodeName()),
+		Undo: func() error {
+			if _, err := podman.Exec(node.ID, []string{"systemctl", "start", service}); err != nil {
+				return fmt.Errorf("failed to start %s on %s: %w", service, node.NodeName(), err)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// PartitionNodes drops all traffic between two nodes with iptables rules in
+// both; undoing deletes the rules. Besides the node IPs, the rules cover
+// traffic pods forward between the nodes: to and from the other node and its
+// podCIDR, when the API server answers with one.
+func PartitionNodes(a, b podman.Container) (*Fault, error) {
+	ipA, err := nodeIP(a)
+	if err != nil {
+		return nil, err
+	}
+	ipB, err := nodeIP(b)
+	if err != nil {
+		return nil, err
+	}
+	cidrA, cidrB := nodePodCIDR(a), nodePodCIDR(b)
+
+	var undos []func() error
+	undo := func() error {
+		var errs []string
+		for _, fn := range undos {
+			if err := fn(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%s", strings.Join(errs, "; "))
+		}
+		return nil
+	}
+
+	for _, side := range []struct {
+		node        podman.Container
+		peerIP      string
+		peerPodCIDR string
+	}{{a, ipB, cidrB}, {b, ipA, cidrA}} {
+		for _, rule := range partitionRules(side.peerIP, side.peerPodCIDR) {
+			if _, err := podman.Exec(side.node.ID, append([]string{"iptables", "-I"}, rule...)); err != nil {
+				undo()
+				return nil, fmt.Errorf("failed to partition %s from %s: %w", side.node.NodeName(), side.peerIP, err)
+			}
+			node, rule := side.node, rule
+			undos = append(undos, func() error {
+				if _, err := podman.Exec(node.ID, append([]string{"iptables", "-D"}, rule...)); err != nil {
+					return fmt.Errorf("failed to remove partition rule on %s: %w", node.NodeName(), err)
+				}
+				return nil
+			})
+		}
+	}
+
+	return &Fault{
+		Description: fmt.Sprintf("partitioned %s from %s", a.Name, b.Name),
+		Undo:        undo,
+	}, nil
+}
+
+// partitionRules returns the iptables rules, without the -I/-D command,
+// dropping traffic from and to peerIP, and traffic forwarded from and to
+// peerIP and peerPodCIDR unless it is empty
+func partitionRules(peerIP, peerPodCIDR string) [][]string {
+	comment := []string{"-m", "comment", "--comment", chaosComment, "-j", "DROP"}
+	rules := [][]string{
+		append([]string{"INPUT", "-s", peerIP}, comment...),
+		append([]string{"OUTPUT", "-d", peerIP}, comment...),
+		append([]string{"FORWARD", "-s", peerIP}, comment...),
+		append([]string{"FORWARD", "-d", peerIP}, comment...),
+	}
+	if peerPodCIDR != "" {
+		rules = append(rules,
+			append([]string{"FORWARD", "-s", peerPodCIDR}, comment...),
+			append([]string{"FORWARD", "-d", peerPodCIDR}, comment...),
+		)
+	}
+	return rules
+}
+
+// nodePodCIDR returns the podCIDR assigned to a node, or "" when its
+// cluster's API server does not answer or assigned none
+func nodePodCIDR(node podman.Container) string {
+	cidrs, err := nodePodCIDRs(node.Labels[podman.LabelCluster])
+	if err != nil {
+		return ""
+	}
+	return cidrs[node.NodeName()]
+}
+
+// nodeIP returns the IP of a running node on its cluster network
+func nodeIP(node podman.Container) (string, error) {
+	ip, err := podman.ContainerNetworkIP(node.ID, nodeNetwork(node))
+	if err != nil {
+		return "", fmt.Errorf("failed to get the IP of %s: %w", node.NodeName(), err)
+	}
+	ip = strings.TrimSpace(ip)
+	if ip == "" {
+		return "", fmt.Errorf("node %s has no IP; is it running?", node.NodeName())
+	}
+	return ip, nil
+}
@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// Client certificate the API server authenticates to kubelets with, which
+// kubeadm leaves on control-plane nodes
+const (
+	kubeletClientCert = "/etc/kubernetes/pki/apiserver-kubelet-client.crt"
+	kubeletClientKey  = "/etc/kubernetes/pki/apiserver-kubelet-client.key"
+)
+
+// Checkpoint is the checkpoint archive of a container copied to the host
+type Checkpoint struct {
+	Container string `json:"container"`
+	Node      string `json:"node"`
+	// Path is the archive on the host
+	Path string `json:"path"`
+}
+
+// CheckpointPod checkpoints the containers of a pod, or only container when
+// set, with the kubelet checkpoint API and copies the archives into dir on
+// the host. The pod keeps running. The cluster needs
+// features.checkpointRestore.
+func CheckpointPod(clusterName, namespace, pod, container, dir string) ([]Checkpoint, error) {
+	controlPlane, err := GetControlPlaneNode(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := podman.Exec(controlPlane.ID, []string{"kubectl", "get", "pod", pod, "--namespace", namespace,
+		"-o", `jsonpath={.spec.nodeName}{"\n"}{.spec.containers[*].name}`})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s: %w", namespace, pod, err)
+	}
+	nodeName, names, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	if nodeName == "" {
+		return nil, fmt.Errorf("pod %s/%s is not scheduled to a node", namespace, pod)
+	}
+	containers := strings.Fields(names)
+	if container != "" {
+		found := false
+		for _, name := range containers {
+			found = found || name == container
+		}
+		if !found {
+			return nil, fmt.Errorf("pod %s/%s has no container %q (containers: %s)", namespace, pod, container, strings.Join(containers, ", "))
+		}
+		containers = []string{container}
+	}
+
+	nodes, err := ListNodes(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	var node *podman.Container
+	for i := range nodes {
+		if nodes[i].NodeName() == nodeName {
+			node = &nodes[i]
+		}
+	}
+	if node == nil {
+		return nil, fmt.Errorf("pod %s/%s runs on %s, which is not a node container of cluster %q", namespace, pod, nodeName, clusterName)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	var checkpoints []Checkpoint
+	for _, name := range containers {
+		archive, err := checkpointContainer(controlPlane.ID, nodeName, namespace, pod, name)
+		if err != nil {
+			return checkpoints, err
+		}
+		dest := filepath.Join(dir, filepath.Base(archive))
+		if err := podman.CopyFromContainer(node.ID, archive, dest); err != nil {
+			return checkpoints, err
+		}
+		checkpoints = append(checkpoints, Checkpoint{Container: name, Node: nodeName, Path: dest})
+	}
+	return checkpoints, nil
+}
+
+// checkpointContainer asks the kubelet of nodeName, from the control-plane
+// with the API server's client certificate, to checkpoint a container and
+// returns the path of the archive on the node
+func checkpointContainer(controlPlaneID, nodeName, namespace, pod, container string) (string, error) {
+	url := fmt.Sprintf("https://%s:10250/checkpoint/%s/%s/%s", nodeName, namespace, pod, container)
+	// kubelet serving certificates are self-signed
+	output, err := podman.Exec(controlPlaneID, []string{"curl", "-sS", "--fail-with-body", "--insecure", "-X", "POST",
+		"--cert", kubeletClientCert, "--key", kubeletClientKey, url})
+	if err != nil {
+		return "", fmt.Errorf("failed to checkpoint container %s of pod %s/%s (is features.checkpointRestore enabled?): %w", container, namespace, pod, err)
+	}
+
+	var response struct {
+		Items []string `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &response); err != nil || len(response.Items) == 0 {
+		return "", fmt.Errorf("unexpected checkpoint response from the kubelet: %s", strings.TrimSpace(output))
+	}
+	return response.Items[0], nil
+}
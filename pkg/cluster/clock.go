@@ -0,0 +1,117 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// MaxClockSkew is the node clock offset from the host beyond which kipod
+// resyncs the node. Leases, token and certificate checks start failing at a
+// few seconds of skew.
+const MaxClockSkew = 2 * time.Second
+
+// NodeClock is the clock offset of a node from the host
+type NodeClock struct {
+	Node string
+	ID   string
+	Skew time.Duration
+}
+
+// ClockSkew measures how far the clock of every running node of a cluster is
+// off from the host. Nodes share the host kernel clock on Linux, but not when
+// podman runs them in a VM (podman machine), whose clock stops while the
+// laptop is suspended.
+func ClockSkew(name string) ([]NodeClock, error) {
+	nodes, err := ListNodes(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("cluster '%s' not found", name)
+	}
+
+	var clocks []NodeClock
+	for _, node := range nodes {
+		if node.State != "running" {
+			continue
+		}
+		skew, err := nodeClockSkew(node.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the clock of %s: %w", node.NodeName(), err)
+		}
+		clocks = append(clocks, NodeClock{Node: node.NodeName(), ID: node.ID, Skew: skew})
+	}
+	return clocks, nil
+}
+
+// nodeClockSkew compares the node clock with the midpoint of the host time
+// before and after reading it, which cancels out most of the exec latency
+func nodeClockSkew(nodeID string) (time.Duration, error) {
+	before := time.Now()
+	output, err := podman.Exec(nodeID, []string{"date", "+%s.%N"})
+	after := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected date output %q", strings.TrimSpace(output))
+	}
+	nodeTime := time.Unix(0, int64(seconds*float64(time.Second)))
+	hostTime := before.Add(after.Sub(before) / 2)
+	return nodeTime.Sub(hostTime).Round(time.Millisecond), nil
+}
+
+// SyncTime steps the clock of every node skewed by more than MaxClockSkew to
+// the host time and restarts its kubelet, so node leases and certificate
+// checks recover. It returns the nodes it resynced.
+func SyncTime(name string) ([]NodeClock, error) {
+	clocks, err := ClockSkew(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var synced []NodeClock
+	for _, clock := range clocks {
+		if clock.Skew.Abs() <= MaxClockSkew {
+			continue
+		}
+		if err := stepNodeClock(clock.ID); err != nil {
+			return synced, fmt.Errorf("failed to set the clock of %s: %w", clock.Node, err)
+		}
+		if _, err := podman.Exec(clock.ID, []string{"systemctl", "restart", "kubelet"}); err != nil {
+			return synced, fmt.Errorf("failed to restart kubelet on %s: %w", clock.Node, err)
+		}
+		synced = append(synced, clock)
+	}
+	return synced, nil
+}
+
+// stepNodeClock sets the node clock with chrony when the image has it, and
+// to the host time with date otherwise. Either changes the clock of the
+// kernel running the node, which is the podman machine VM, not the laptop.
+func stepNodeClock(nodeID string) error {
+	if _, err := podman.Exec(nodeID, []string{"chronyc", "-a", "makestep"}); err == nil {
+		return nil
+	}
+	now := time.Now()
+	_, err := podman.Exec(nodeID, []string{"date", "-u", "-s", fmt.Sprintf("@%d.%09d", now.Unix(), now.Nanosecond())})
+	return err
+}
+
+// checkClockSkew resyncs skewed nodes of a cluster that is being started
+// again, e.g. after the host was suspended; failures only warn
+func checkClockSkew(name string) {
+	synced, err := SyncTime(name)
+	for _, clock := range synced {
+		style.Info("Resynced the clock of %s, which was off by %s", clock.Node, clock.Skew)
+	}
+	if err != nil {
+		style.Info("Warning: %v; run kipod sync time -n %s", err, name)
+	}
+}
@@ -0,0 +1,310 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+const (
+	// cloneSnapshotDir is where the source etcd snapshot and its restored data
+	// dir are staged, inside the etcd hostPath of the source control-plane
+	cloneSnapshotDir = "/var/lib/etcd/kipod-clone"
+
+	// cloneConfigMapDir holds configmaps of the new cluster that must survive
+	// the etcd restore because they carry its own endpoints
+	cloneConfigMapDir = "/root/kipod-clone"
+)
+
+// clonePKIFiles are the signing keys copied from the source cluster, so
+// tokens and certificates stored in the restored etcd stay valid. kubeadm
+// reuses them and issues new serving certificates for the new names and IPs.
+var clonePKIFiles = []string{
+	"ca.crt", "ca.key",
+	"sa.key", "sa.pub",
+	"front-proxy-ca.crt", "front-proxy-ca.key",
+	"etcd/ca.crt", "etcd/ca.key",
+}
+
+// cloneSource is the state taken from the source cluster of a clone
+type cloneSource struct {
+	controlPlaneID string
+	dir            string   // host staging directory
+	volumes        []string // volumes copied for the new nodes
+}
+
+// prepareClone checks the source cluster, copies its node volumes for the
+// new nodes and stages its PKI. Runs before any node is created.
+func (c *Cluster) prepareClone() error {
+	src := c.config.CloneFrom
+	if src == c.config.Name {
+		return fmt.Errorf("cannot clone cluster '%s' onto itself", src)
+	}
+
+	controlPlane, err := GetControlPlaneNode(src)
+	if err != nil {
+		return err
+	}
+	if controlPlane.State != "running" {
+		return fmt.Errorf("source cluster '%s' must be running to be cloned", src)
+	}
+	members, err := ListEtcdMembers(src)
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 || c.config.ExternalEtcd {
+		return fmt.Errorf("cloning clusters with external etcd is not supported")
+	}
+
+	dir, err := os.MkdirTemp("", "kipod-clone-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	c.clone = &cloneSource{controlPlaneID: controlPlane.ID, dir: dir}
+
+	style.Step("Copying PKI of cluster %q 🔐", src)
+	for _, file := range clonePKIFiles {
+		dest := filepath.Join(dir, "kubernetes", "pki", file)
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		if err := podman.CopyFromContainer(controlPlane.ID, "/etc/kubernetes/pki/"+file, dest); err != nil {
+			return err
+		}
+	}
+
+	if c.config.StorageType != "volume" {
+		style.Info("Node storage is tmpfs; images will be pulled again by the new cluster")
+		return nil
+	}
+
+	style.Step("Copying node volumes of cluster %q 💾", src)
+	nodes := []string{c.nodeName("control-plane", 0)}
+	for _, pool := range c.config.Pools {
+		for i := 0; i < pool.Count; i++ {
+			nodes = append(nodes, c.nodeName(pool.Name, i))
+		}
+	}
+	for _, node := range nodes {
+		srcNode := src + strings.TrimPrefix(node, c.config.Name)
+		volumes := map[string]string{
+			fmt.Sprintf("kipod-storage-%s", srcNode): fmt.Sprintf("kipod-storage-%s", node),
+		}
+		for _, path := range c.config.ExtraVolumes {
+			volumes[extraVolumeName(srcNode, path)] = extraVolumeName(node, path)
+		}
+		for srcVolume, volume := range volumes {
+			exists, err := podman.VolumeExists(srcVolume)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			if err := podman.CloneVolume(srcVolume, volume, c.nodeVolumeLabels(node)); err != nil {
+				return err
+			}
+			c.clone.volumes = append(c.clone.volumes, volume)
+		}
+	}
+	return nil
+}
+
+// installClonePKI copies the staged source PKI into the new control-plane
+// before kubeadm init
+func (c *Cluster) installClonePKI(controlPlaneID string) error {
+	staged := filepath.Join(c.clone.dir, "kubernetes")
+	if err := podman.CopyToContainer(controlPlaneID, staged+"/.", "/etc/kubernetes"); err != nil {
+		return fmt.Errorf("failed to install cloned PKI: %w", err)
+	}
+	return nil
+}
+
+// restoreClone replaces the etcd data of the new control-plane with a
+// snapshot of the source cluster, then repairs the objects that still refer
+// to the source: its endpoint configmaps and its nodes.
+func (c *Cluster) restoreClone(controlPlaneID string) error {
+	src := c.config.CloneFrom
+	nodeName := c.nodeName("control-plane", 0)
+
+	// Keep the new cluster's own endpoint configmaps
+	if err := c.saveCloneConfigMaps(controlPlaneID); err != nil {
+		return err
+	}
+
+	ip, err := podman.ContainerNetworkIP(controlPlaneID, c.network())
+	if err != nil {
+		return fmt.Errorf("failed to get control-plane IP: %w", err)
+	}
+	ip = strings.TrimSpace(ip)
+
+	// Snapshot the source etcd and restore it for the new member, using the
+	// tools of the source etcd pod
+	style.Step("Snapshotting etcd of cluster %q 📸", src)
+	pods, err := stackedEtcdPods(c.clone.controlPlaneID)
+	if err != nil {
+		return err
+	}
+	snapshot := cloneSnapshotDir + "/snapshot.db"
+	restored := cloneSnapshotDir + "/data"
+	defer podman.Exec(c.clone.controlPlaneID, []string{"rm", "-rf", cloneSnapshotDir})
+
+	if _, err := podman.Exec(c.clone.controlPlaneID, []string{"mkdir", "-p", cloneSnapshotDir}); err != nil {
+		return fmt.Errorf("failed to prepare snapshot directory: %w", err)
+	}
+	if output, err := podman.Exec(c.clone.controlPlaneID, stackedEtcdctl(pods[0], "snapshot", "save", snapshot)); err != nil {
+		return fmt.Errorf("failed to snapshot etcd: %w\nOutput:\n%s", err, output)
+	}
+	peerURL := fmt.Sprintf("https://%s:2380", ip)
+	output, err := podman.Exec(c.clone.controlPlaneID, []string{
+		"kubectl", "-n", "kube-system", "exec", pods[0], "--",
+		"etcdutl", "snapshot", "restore", snapshot,
+		"--data-dir", restored,
+		"--name", nodeName,
+		"--initial-cluster", fmt.Sprintf("%s=%s", nodeName, peerURL),
+		"--initial-advertise-peer-urls", peerURL,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore etcd snapshot: %w\nOutput:\n%s", err, output)
+	}
+	if err := podman.CopyFromContainer(c.clone.controlPlaneID, restored+"/member", filepath.Join(c.clone.dir, "member")); err != nil {
+		return err
+	}
+
+	// Swap the data dir while etcd and the API server are stopped
+	style.Step("Restoring etcd into cluster %q ♻️", c.config.Name)
+	if err := stopStaticPods(controlPlaneID, "etcd", "kube-apiserver"); err != nil {
+		return err
+	}
+	if _, err := podman.Exec(controlPlaneID, []string{"rm", "-rf", "/var/lib/etcd/member"}); err != nil {
+		return fmt.Errorf("failed to remove etcd data: %w", err)
+	}
+	if err := podman.CopyToContainer(controlPlaneID, filepath.Join(c.clone.dir, "member"), "/var/lib/etcd"); err != nil {
+		return err
+	}
+	if err := startStaticPods(controlPlaneID, "etcd", "kube-apiserver"); err != nil {
+		return err
+	}
+
+	// The restored data has no Node for this control-plane; a kubelet
+	// restart registers it again
+	if _, err := podman.Exec(controlPlaneID, []string{"systemctl", "restart", "kubelet"}); err != nil {
+		return fmt.Errorf("failed to restart kubelet: %w", err)
+	}
+	if err := c.waitForAPIServer(controlPlaneID); err != nil {
+		return err
+	}
+	if err := waitForNodeRegistration(controlPlaneID, nodeName); err != nil {
+		return err
+	}
+
+	return c.repairClone(controlPlaneID)
+}
+
+// saveCloneConfigMaps saves the configmaps carrying the new cluster's
+// endpoints, without the metadata that would conflict after the restore
+func (c *Cluster) saveCloneConfigMaps(controlPlaneID string) error {
+	configMaps := []string{"kube-system/kubeadm-config", "kube-public/cluster-info"}
+	if !c.skipsPhase("addon/kube-proxy") {
+		configMaps = append(configMaps, "kube-system/kube-proxy")
+	}
+
+	script := fmt.Sprintf("mkdir -p %s", cloneConfigMapDir)
+	for _, cm := range configMaps {
+		namespace, name, _ := strings.Cut(cm, "/")
+		script += fmt.Sprintf(" && kubectl -n %s get configmap %s -o yaml > %s/%s.yaml", namespace, name, cloneConfigMapDir, name)
+	}
+	script += fmt.Sprintf(" && sed -i '/^  resourceVersion:/d; /^  uid:/d; /^  creationTimestamp:/d' %s/*.yaml", cloneConfigMapDir)
+
+	if output, err := podman.Exec(controlPlaneID, []string{"sh", "-c", script}); err != nil {
+		return fmt.Errorf("failed to save cluster configmaps: %w\nOutput:\n%s", err, output)
+	}
+	return nil
+}
+
+// repairClone puts back the new cluster's configmaps, marks its
+// control-plane and removes the nodes of the source cluster
+func (c *Cluster) repairClone(controlPlaneID string) error {
+	script := fmt.Sprintf("kubectl replace -f %s && rm -rf %s", cloneConfigMapDir, cloneConfigMapDir)
+	if output, err := podman.Exec(controlPlaneID, []string{"sh", "-c", script}); err != nil {
+		return fmt.Errorf("failed to restore cluster configmaps: %w\nOutput:\n%s", err, output)
+	}
+	if !c.skipsPhase("addon/kube-proxy") {
+		if _, err := podman.Exec(controlPlaneID, []string{"kubectl", "-n", "kube-system", "rollout", "restart", "daemonset/kube-proxy"}); err != nil {
+			style.Info("Warning: failed to restart kube-proxy: %v", err)
+		}
+	}
+
+	markArgs := []string{"kubeadm", "init", "phase", "mark-control-plane"}
+	if c.usesKubeadmConfig() {
+		markArgs = append(markArgs, "--config="+kubeadmConfigPath)
+	}
+	if output, err := podman.Exec(controlPlaneID, markArgs); err != nil {
+		return fmt.Errorf("failed to mark control-plane: %w\nOutput:\n%s", err, output)
+	}
+	c.removeControlPlaneTaint(controlPlaneID)
+
+	// Pods bound to the source nodes are garbage collected with them
+	output, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "nodes", "-o", "name"})
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	for _, node := range strings.Fields(output) {
+		if !strings.HasPrefix(node, "node/"+c.config.CloneFrom+"-") {
+			continue
+		}
+		if _, err := podman.Exec(controlPlaneID, []string{"kubectl", "delete", node, "--wait=false"}); err != nil {
+			style.Info("Warning: failed to delete source %s: %v", node, err)
+		}
+	}
+	return nil
+}
+
+// stopStaticPods moves static pod manifests aside and waits until the
+// kubelet has stopped their containers
+func stopStaticPods(containerID string, names ...string) error {
+	for _, name := range names {
+		move := fmt.Sprintf("mv /etc/kubernetes/manifests/%s.yaml /etc/kubernetes/%s.yaml", name, name)
+		if _, err := podman.Exec(containerID, []string{"sh", "-c", move}); err != nil {
+			return fmt.Errorf("failed to stop %s: %w", name, err)
+		}
+	}
+	for _, name := range names {
+		stopped := false
+		for i := 0; i < 60; i++ {
+			output, err := podman.Exec(containerID, []string{"crictl", "ps", "-q", "--name", "^" + name + "$"})
+			if err == nil && strings.TrimSpace(output) == "" {
+				stopped = true
+				break
+			}
+			time.Sleep(2 * time.Second)
+		}
+		if !stopped {
+			return fmt.Errorf("timeout waiting for %s to stop", name)
+		}
+	}
+	return nil
+}
+
+// startStaticPods moves static pod manifests set aside by stopStaticPods back
+func startStaticPods(containerID string, names ...string) error {
+	for _, name := range names {
+		move := fmt.Sprintf("mv /etc/kubernetes/%s.yaml /etc/kubernetes/manifests/%s.yaml", name, name)
+		if _, err := podman.Exec(containerID, []string{"sh", "-c", move}); err != nil {
+			return fmt.Errorf("failed to start %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// cleanupClone removes the host staging directory of a clone
+func (c *Cluster) cleanupClone() {
+	if c.clone != nil {
+		os.RemoveAll(c.clone.dir)
+	}
+}
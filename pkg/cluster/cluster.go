@@ -3,12 +3,15 @@ package cluster
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/events"
 	"github.com/sohankunkerkar/kipod/pkg/podman"
 	"github.com/sohankunkerkar/kipod/pkg/style"
+	"github.com/sohankunkerkar/kipod/pkg/system"
 )
 
 // Config represents cluster configuration
@@ -22,20 +25,142 @@ type Config struct {
 	PodSubnet         string
 	ServiceSubnet     string
 	Rootless          bool
+	// Labels the user tagged the cluster with, recorded on every node
+	Labels map[string]string
+	// Owner is the user creating the cluster, recorded on every node
+	Owner string
+	// Podman network of the nodes (empty is DefaultNetwork) and host port
+	// of the API server (0 is DefaultAPIServerPort)
+	Network       string
+	APIServerPort int
+	// iptables backend of the nodes, nft or legacy; empty keeps the image's
+	IptablesBackend string
 	// Local builds for development
 	CRIOBinary    string
 	CrunBinary    string
 	RuncBinary    string
 	CgroupManager string
 	CRIOConfig    string
+	CRIOProfile   string
 	StorageType   string
 	StorageSize   string
+	StorageDriver string // Empty keeps the node image default
 	WaitDuration  time.Duration
 	Retain        bool
+	// Experimental features configured in both CRI-O and the kubelet
+	EventedPLEG       bool
+	CheckpointRestore bool
+	ImageVolume       bool
+	// Kubelet image garbage collection (0 or empty keeps the kubelet default)
+	ImageGCHighThresholdPercent int
+	ImageGCLowThresholdPercent  int
+	ImageMinimumGCAge           string
+	// Paths backed by a dedicated per-node volume, e.g. /var/lib/kubelet
+	ExtraVolumes []string
+	// Wait for all nodes and kube-system pods to be Ready after provisioning
+	WaitAll bool
+	// Running in CI: shorter default timeouts and node diagnostics on failure
+	CI bool
+	// kubeadm preflight checks to ignore; nil uses DefaultIgnorePreflightErrors
+	IgnorePreflightErrors []string
+	// Run kubeadm preflight checks without ignoring any errors
+	StrictPreflight bool
+	// kubeadm init phases to skip and extra kubeadm init flags
+	SkipPhases    []string
+	ExtraInitArgs []string
 	// Scheduler configuration
 	SchedulerConfigPath string            // Path to KubeSchedulerConfiguration file on host
 	SchedulerExtraArgs  map[string]string // Extra args for kube-scheduler
 	SchedulerExtraVols  []HostPathMount   // Extra volumes for kube-scheduler
+	SchedulerBinary     string            // Local kube-scheduler binary run by the static pod
+	SchedulerImage      string            // Image replacing the kube-scheduler image
+	// Control-plane static pod images by pod name, e.g. kube-apiserver
+	ComponentImages map[string]string
+	// Mirrors replacing registry.k8s.io, for all images or CoreDNS and etcd
+	ImageRepository     string
+	DNSImageRepository  string
+	DNSImageTag         string
+	EtcdImageRepository string
+	EtcdImageTag        string
+	// Registry credentials in auth.json format, installed for CRI-O on
+	// every node and/or as the pull secret of default service accounts
+	RegistryAuth           string
+	RegistryAuthNodes      bool
+	RegistryAuthPullSecret bool
+	// containers-policy.json CRI-O verifies image signatures with, the
+	// public key it refers to and registries.d files by file name
+	SignaturePolicy     string
+	SignatureKey        string
+	SignatureRegistries map[string]string
+	// Optional runtimes
+	WasmRuntime    bool   // Create the crun-wasm RuntimeClass
+	SandboxRuntime string // Experimental sandboxed runtime: kata or gvisor
+	// Inotify limits set inside node containers (0 keeps the image default)
+	InotifyMaxUserWatches   int
+	InotifyMaxUserInstances int
+	// Extra podman options for node containers, keyed by role
+	NodeOptions map[string]NodeOptions
+	// Node container restart policy (default on-failure) and healthcheck
+	RestartPolicy       string
+	DisableHealthcheck  bool
+	HealthcheckInterval string
+	// Worker node pools; defaults to a single "worker" pool of Workers nodes
+	Pools []NodePool
+	// Labels and taints registered with the control-plane node
+	ControlPlaneLabels map[string]string
+	ControlPlaneTaints []Taint
+	// Experimental: run etcd in a dedicated container
+	ExternalEtcd bool
+	EtcdImage    string
+	EtcdReplicas int // 1 or 3 external etcd members
+	// etcd tuning, applied to stacked and external etcd
+	EtcdQuotaBackendBytes       uint64
+	EtcdAutoCompactionMode      string
+	EtcdAutoCompactionRetention string
+	EtcdDataOnVolume            bool
+	EtcdUnsafeNoFsync           bool
+	// CloneFrom is the running cluster whose state a new cluster starts from
+	CloneFrom string
+	// Systemd units and drop-ins installed into nodes before they boot
+	SystemdUnits  []SystemdUnit
+	UnitOverrides []UnitOverride
+	// Entrypoint and command of node containers, empty for the image's
+	NodeEntrypoint []string
+	NodeCommand    []string
+	// Component log levels, e.g. kubelet=4, crio=debug
+	LogLevels map[string]string
+	// cert-manager release of the cert-manager addon; empty disables it
+	CertManagerVersion string
+	// Simulated kwok nodes to register, and the kwok release running them
+	FakeNodes   int
+	KwokVersion string
+	// Helm releases and manifests installed in order once the cluster is Ready
+	HelmCharts          []HelmChart
+	PostCreateManifests []Manifest
+}
+
+// NodePool is a named group of worker nodes sharing labels and taints
+type NodePool struct {
+	Name   string
+	Count  int
+	Labels map[string]string
+	Taints []Taint
+}
+
+// Taint is a node taint registered by kubeadm
+type Taint struct {
+	Key    string
+	Value  string
+	Effect string
+}
+
+// NodeOptions are extra podman run options for node containers
+type NodeOptions struct {
+	Sysctls      map[string]string
+	SecurityOpts []string
+	Devices      []string
+	Ulimits      []string
+	Env          map[string]string
 }
 
 // HostPathMount defines a volume mount for kubeadm components
@@ -51,6 +176,10 @@ type HostPathMount struct {
 type Cluster struct {
 	config  *Config
 	nodeIDs []string
+	timings []PhaseTiming
+	join    *joinConfig
+	etcdPKI *etcdPKI
+	clone   *cloneSource
 }
 
 // NewCluster creates a new cluster instance
@@ -59,6 +188,18 @@ func NewCluster(cfg *Config) (*Cluster, error) {
 		return nil, fmt.Errorf("cluster name cannot be empty")
 	}
 
+	// Plain worker counts become a single "worker" pool
+	if len(cfg.Pools) == 0 && cfg.Workers > 0 {
+		cfg.Pools = []NodePool{{Name: "worker", Count: cfg.Workers}}
+	}
+	if len(cfg.Pools) > 0 {
+		cfg.Workers = 0
+		for _, pool := range cfg.Pools {
+			cfg.Workers += pool.Count
+		}
+		cfg.Nodes = 0
+	}
+
 	// Set defaults
 	if cfg.Nodes == 0 {
 		if cfg.ControlPlanes == 0 && cfg.Workers == 0 {
@@ -105,33 +246,88 @@ func (c *Cluster) Create() (err error) {
 		}
 	}()
 	// Check if node image exists
-	imageExists, err := build.ImageExists(c.config.Image)
+	err = c.timePhase("image check", func() error {
+		imageExists, err := build.ImageExists(c.config.Image)
+		if err != nil {
+			return fmt.Errorf("failed to check if node image exists: %w", err)
+		}
+		if !imageExists {
+			return fmt.Errorf("node image '%s' not found. Please build it first with: kipod build node-image", c.config.Image)
+		}
+
+		style.Step("Ensuring node image (%s) 🖼", c.config.Image)
+
+		return c.checkImageRuntimes()
+	})
 	if err != nil {
-		return fmt.Errorf("failed to check if node image exists: %w", err)
-	}
-	if !imageExists {
-		return fmt.Errorf("node image '%s' not found. Please build it first with: kipod build node-image", c.config.Image)
+		return err
 	}
 
-	style.Step("Ensuring node image (%s) 🖼", c.config.Image)
-
 	// Create shared network
-	networkName := "kipod"
-	exists, err := podman.NetworkExists(networkName)
+	err = c.timePhase("network", func() error {
+		// Fail before any container exists rather than on podman run
+		if err := system.CheckHostPorts(c.publishedPorts()); err != nil {
+			return err
+		}
+
+		return ensureNetwork(c.network())
+	})
 	if err != nil {
-		return fmt.Errorf("failed to check network existence: %w", err)
+		return err
 	}
-	if !exists {
-		style.Step("Preparing network 🌐")
-		if err := podman.CreateNetwork(networkName); err != nil {
-			return fmt.Errorf("failed to create network: %w", err)
+
+	if c.config.CloneFrom != "" {
+		defer c.cleanupClone()
+		if err := c.timePhase("clone prepare", c.prepareClone); err != nil {
+			return err
 		}
 	}
 
 	style.Step("Preparing nodes 📦")
 
+	if c.config.ExternalEtcd {
+		style.Step("Starting external etcd 🗄️ (experimental)")
+		err = c.timePhase("etcd", func() error {
+			pki, err := c.newEtcdPKI()
+			if err != nil {
+				return fmt.Errorf("failed to generate etcd certificates: %w", err)
+			}
+			c.etcdPKI = pki
+
+			ids, err := c.createExternalEtcd(pki)
+			c.nodeIDs = append(c.nodeIDs, ids...)
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if err := waitForEtcd(id); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	// For MVP, create a single control-plane node
-	nodeID, err := c.createNode("control-plane", 0)
+	var nodeID string
+	err = c.timePhase("node create", func() error {
+		nodeID, err = c.createNode("control-plane", "control-plane", 0)
+		if err != nil {
+			return err
+		}
+		if c.clone != nil {
+			if err := c.installClonePKI(nodeID); err != nil {
+				return err
+			}
+		}
+		if c.etcdPKI != nil {
+			return installEtcdClientCerts(nodeID, c.etcdPKI)
+		}
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create control-plane node: %w", err)
 	}
@@ -139,12 +335,23 @@ func (c *Cluster) Create() (err error) {
 
 	// Wait for container to be ready
 	style.Step("Starting control-plane 🕹️")
-	// Initial wait for systemd to start
-	time.Sleep(2 * time.Second)
+	err = c.timePhase("systemd wait", func() error {
+		// Initial wait for systemd to start
+		time.Sleep(2 * time.Second)
 
-	// Verify services are running
-	// Verifying services...
-	if err := c.waitForServices(nodeID); err != nil {
+		// Verify services are running
+		if err := c.waitForServices(nodeID); err != nil {
+			return err
+		}
+		if err := c.configurePauseImage(nodeID); err != nil {
+			return err
+		}
+		if err := c.applyExperimentDropins(nodeID); err != nil {
+			return err
+		}
+		return c.applyLogLevels(nodeID)
+	})
+	if err != nil {
 		return fmt.Errorf("services failed to start: %w", err)
 	}
 
@@ -153,44 +360,81 @@ func (c *Cluster) Create() (err error) {
 		return fmt.Errorf("failed to initialize Kubernetes: %w", err)
 	}
 
+	if c.clone != nil {
+		if err := c.timePhase("clone restore", func() error { return c.restoreClone(nodeID) }); err != nil {
+			return fmt.Errorf("failed to restore cloned cluster: %w", err)
+		}
+	}
+
+	if err := c.timePhase("runtime classes", func() error { return c.createRuntimeClasses(nodeID) }); err != nil {
+		return fmt.Errorf("failed to create runtime classes: %w", err)
+	}
+
+	if c.config.SchedulerBinary != "" || c.config.SchedulerImage != "" {
+		if err := c.timePhase("scheduler override", func() error { return c.overrideScheduler(nodeID) }); err != nil {
+			return fmt.Errorf("failed to replace kube-scheduler: %w", err)
+		}
+	}
+
 	// Warn about HA support
 	if c.config.ControlPlanes > 1 {
-		fmt.Printf("Warning: Multi-control-plane (HA) support is not fully implemented yet. Only the first control-plane will be initialized.\n")
+		style.Info("Warning: Multi-control-plane (HA) support is not fully implemented yet. Only the first control-plane will be initialized.")
 	}
 
-	// Get join command from control-plane
-	// Retrieving join command...
-	joinCmd, err := c.getJoinCommand(nodeID)
+	err = c.timePhase("workers join", func() error {
+		// Create worker nodes pool by pool
+		for _, pool := range c.config.Pools {
+			for i := 0; i < pool.Count; i++ {
+				if err := c.addWorker(nodeID, pool, i); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get join command: %w", err)
+		return err
 	}
 
-	// Create worker nodes
-	for i := 0; i < c.config.Workers; i++ {
-		workerID, err := c.createNode("worker", i)
-		if err != nil {
-			return fmt.Errorf("failed to create worker node %d: %w", i, err)
+	if c.config.RegistryAuthPullSecret {
+		if err := c.timePhase("pull secret", func() error { return c.installPullSecret(nodeID) }); err != nil {
+			return fmt.Errorf("failed to install the image pull secret: %w", err)
 		}
-		c.nodeIDs = append(c.nodeIDs, workerID)
+	}
 
-		style.Step("Waiting for worker-%d to initialize... ⏳", i)
-		time.Sleep(5 * time.Second)
+	if c.config.WaitAll {
+		if err := c.timePhase("wait all", func() error { return c.waitAllReady(nodeID) }); err != nil {
+			return err
+		}
+	}
 
-		if err := c.waitForServices(workerID); err != nil {
-			return fmt.Errorf("worker-%d services failed to start: %w", i, err)
+	if c.config.FakeNodes > 0 {
+		if err := c.timePhase("kwok", func() error { return c.installKwok(nodeID) }); err != nil {
+			return fmt.Errorf("failed to install kwok: %w", err)
 		}
+	}
+
+	if c.config.CertManagerVersion != "" {
+		if err := c.timePhase("cert-manager", func() error { return c.installCertManager(nodeID) }); err != nil {
+			return fmt.Errorf("failed to install cert-manager: %w", err)
+		}
+	}
 
-		style.Step("Joining worker-%d to cluster... 🔗", i)
-		if err := c.joinWorker(workerID, joinCmd); err != nil {
-			return fmt.Errorf("failed to join worker-%d: %w", i, err)
+	if len(c.config.HelmCharts) > 0 {
+		if err := c.timePhase("helm charts", func() error { return c.installHelmCharts(nodeID) }); err != nil {
+			return fmt.Errorf("failed to install helm charts: %w", err)
 		}
+	}
+
+	if len(c.config.PostCreateManifests) > 0 {
+		if err := c.timePhase("post-create manifests", func() error { return c.applyPostCreateManifests(nodeID) }); err != nil {
+			return fmt.Errorf("failed to apply post-create manifests: %w", err)
+		}
+	}
 
-		// Label the worker node
-		workerName := fmt.Sprintf("%s-worker-%d", c.config.Name, i)
-		style.Step("Labeling worker-%d as 'worker'... 🏷️", i)
-		labelCmd := fmt.Sprintf("kubectl label node %s node-role.kubernetes.io/worker=", workerName)
-		if _, err := podman.Exec(nodeID, []string{"sh", "-c", labelCmd}); err != nil {
-			fmt.Printf("  Warning: failed to label worker node %s: %v\n", workerName, err)
+	if c.config.WaitDuration > 0 {
+		if err := c.timePhase("wait", func() error { return c.waitSchedulable(nodeID) }); err != nil {
+			return err
 		}
 	}
 
@@ -198,7 +442,113 @@ func (c *Cluster) Create() (err error) {
 	return nil
 }
 
+// publishedPorts returns the host port mappings of the control-plane nodes,
+// the only nodes publishing ports, so a conflict on any of them is found
+// before the first node is created
+func (c *Cluster) publishedPorts() []string {
+	var ports []string
+	for i := 0; i < c.config.ControlPlanes; i++ {
+		ports = append(ports, c.createContainerOptions(c.nodeName("control-plane", i), "control-plane").Ports...)
+	}
+	return ports
+}
+
+// addWorker creates node i of a worker pool, joins it to the cluster and labels it
+func (c *Cluster) addWorker(controlPlaneID string, pool NodePool, i int) error {
+	workerName := c.nodeName(pool.Name, i)
+	workerID, err := c.createNode("worker", pool.Name, i)
+	if err != nil {
+		return fmt.Errorf("failed to create worker node %s: %w", workerName, err)
+	}
+	c.nodeIDs = append(c.nodeIDs, workerID)
+
+	style.Step("Waiting for %s-%d to initialize... ⏳", pool.Name, i)
+	time.Sleep(5 * time.Second)
+
+	return c.setupWorker(controlPlaneID, workerID, workerName, fmt.Sprintf("%s-%d", pool.Name, i), pool)
+}
+
+// setupWorker configures the services of a booted worker container, joins
+// it to the cluster as nodeName and labels it; display names it in output
+func (c *Cluster) setupWorker(controlPlaneID, workerID, nodeName, display string, pool NodePool) error {
+	if err := c.waitForServices(workerID); err != nil {
+		return fmt.Errorf("%s services failed to start: %w", display, err)
+	}
+	if err := c.configurePauseImage(workerID); err != nil {
+		return fmt.Errorf("%s: %w", display, err)
+	}
+	if err := c.applyExperimentDropins(workerID); err != nil {
+		return fmt.Errorf("%s: %w", display, err)
+	}
+	if err := c.applyLogLevels(workerID); err != nil {
+		return fmt.Errorf("%s: %w", display, err)
+	}
+	if _, err := addHostAlias(workerID); err != nil {
+		style.Info("Warning: %v", err)
+	}
+
+	style.Step("Joining %s to cluster... 🔗", display)
+	if err := c.joinWorker(controlPlaneID, workerID, nodeName, pool); err != nil {
+		return fmt.Errorf("failed to join %s: %w", display, err)
+	}
+
+	// Label the worker node
+	style.Step("Labeling %s as 'worker'... 🏷️", display)
+	labelCmd := fmt.Sprintf("kubectl label node %s node-role.kubernetes.io/worker=", nodeName)
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", labelCmd}); err != nil {
+		style.Info("Warning: failed to label worker node %s: %v", nodeName, err)
+	}
+	return nil
+}
+
+// volumeLabels returns the labels of the named volumes created for the cluster
+func (c *Cluster) volumeLabels() map[string]string {
+	return map[string]string{
+		podman.LabelCluster: c.config.Name,
+		podman.LabelManaged: "true",
+	}
+}
+
+// nodeVolumeLabels returns the labels of the named volumes of a node
+func (c *Cluster) nodeVolumeLabels(nodeName string) map[string]string {
+	labels := c.volumeLabels()
+	labels[podman.LabelNodeName] = nodeName
+	return labels
+}
+
+// nodeName returns the Kubernetes node name and hostname of a pool node
+func (c *Cluster) nodeName(pool string, index int) string {
+	return fmt.Sprintf("%s-%s-%d", c.config.Name, pool, index)
+}
+
+// containerName returns the name of the container of a node, which keeps
+// kipod containers apart from others on the host
+func containerName(nodeName string) string {
+	return podman.ContainerPrefix + nodeName
+}
+
+// nodeLabels returns the labels identifying a node container
+func (c *Cluster) nodeLabels(nodeName, role string) map[string]string {
+	labels := map[string]string{
+		podman.LabelCluster:  c.config.Name,
+		podman.LabelRole:     role,
+		podman.LabelNodeName: nodeName,
+		podman.LabelNetwork:  c.network(),
+	}
+	if c.config.Owner != "" {
+		labels[podman.LabelOwner] = c.config.Owner
+	}
+	for key, value := range c.config.Labels {
+		labels[podman.LabelUserPrefix+key] = value
+	}
+	return labels
+}
+
 func (c *Cluster) cleanupOnFailure() {
+	if c.config.CI {
+		c.printDiagnostics()
+	}
+
 	if c.config.Retain {
 		style.Info("Retaining nodes for debugging due to --retain flag")
 		return
@@ -211,49 +561,80 @@ func (c *Cluster) cleanupOnFailure() {
 			podman.DeleteContainer(nodeID)
 		}
 	}
-}
-
-func (c *Cluster) getJoinCommand(controlPlaneID string) (string, error) {
-	// Generate a new token and print the join command
-	cmd := "kubeadm token create --print-join-command"
-	output, err := podman.Exec(controlPlaneID, []string{"sh", "-c", cmd})
-	if err != nil {
-		return "", fmt.Errorf("failed to generate join command: %w", err)
+	if c.clone != nil {
+		for _, volume := range c.clone.volumes {
+			podman.DeleteVolume(volume)
+		}
 	}
-	return strings.TrimSpace(output), nil
 }
 
-func (c *Cluster) joinWorker(workerID, joinCmd string) error {
-	// Run the join command on the worker
-	// We need to ignore preflight errors similar to init
-	fullCmd := fmt.Sprintf("%s --ignore-preflight-errors=NumCPU,Mem,SystemVerification,FileContent--proc-sys-net-bridge-bridge-nf-call-iptables --v=5", joinCmd)
+func (c *Cluster) createNode(role, pool string, index int) (string, error) {
+	nodeName := c.nodeName(pool, index)
 
-	output, err := podman.Exec(workerID, []string{"sh", "-c", fullCmd})
-	if err != nil {
-		return fmt.Errorf("kubeadm join failed: %w\nOutput:\n%s", err, output)
-	}
-	return nil
+	opts := c.createContainerOptions(nodeName, role)
+	opts.Labels[podman.LabelPool] = pool
+	return c.createNodeContainer(opts, nodeName, role)
 }
 
-func (c *Cluster) createNode(role string, index int) (string, error) {
-	nodeName := fmt.Sprintf("%s-%s-%d", c.config.Name, role, index)
-
-	opts := c.createContainerOptions(nodeName, role)
+// createNodeContainer creates and boots a node container from opts, with
+// the files and local builds of the config installed
+func (c *Cluster) createNodeContainer(opts podman.CreateContainerOptions, nodeName, role string) (string, error) {
+	// Units and storage config must be in place before systemd boots, so
+	// create the node stopped when there is anything to install
+	preBoot := c.hasSystemdFiles() || c.config.StorageDriver != "" || c.config.RegistryAuthNodes || c.config.SignaturePolicy != ""
+	opts.NoStart = preBoot
 
 	containerID, err := podman.CreateContainer(opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
 
+	if preBoot {
+		if err := c.installNodeFiles(containerID); err != nil {
+			podman.DeleteContainer(containerID)
+			return "", err
+		}
+		if err := podman.StartContainer(containerID); err != nil {
+			podman.DeleteContainer(containerID)
+			return "", fmt.Errorf("failed to start node %s: %w", nodeName, err)
+		}
+	}
+
 	// fmt.Printf("  Created node: %s (ID: %s)\n", nodeName, containerID[:12])
 
 	if err := c.installLocalBinaries(containerID); err != nil {
 		return "", err
 	}
 
+	events.Emit(events.Event{Type: events.NodeCreated, Node: nodeName, Role: role})
 	return containerID, nil
 }
 
+// installNodeFiles copies configured files into a node before its first boot
+func (c *Cluster) installNodeFiles(containerID string) error {
+	if c.hasSystemdFiles() {
+		if err := c.installSystemdFiles(containerID); err != nil {
+			return err
+		}
+	}
+	if c.config.StorageDriver != "" {
+		if err := c.installStorageConfig(containerID); err != nil {
+			return err
+		}
+	}
+	if c.config.RegistryAuthNodes {
+		if err := c.installRegistryAuth(containerID); err != nil {
+			return err
+		}
+	}
+	if c.config.SignaturePolicy != "" {
+		if err := c.installSignaturePolicy(containerID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Cluster) createContainerOptions(nodeName, role string) podman.CreateContainerOptions {
 	// Pass KIPOD_CGROUP_MANAGER to the container
 	cgroupMgr := c.config.CgroupManager
@@ -268,19 +649,33 @@ func (c *Cluster) createContainerOptions(nodeName, role string) podman.CreateCon
 	env := []string{}
 	// Always set KIPOD_CGROUP_MANAGER so configure-cgroup-manager.sh knows what to use
 	env = append(env, fmt.Sprintf("KIPOD_CGROUP_MANAGER=%s", cgroupMgr))
+	if c.config.InotifyMaxUserWatches > 0 {
+		env = append(env, fmt.Sprintf("KIPOD_INOTIFY_MAX_USER_WATCHES=%d", c.config.InotifyMaxUserWatches))
+	}
+	if c.config.InotifyMaxUserInstances > 0 {
+		env = append(env, fmt.Sprintf("KIPOD_INOTIFY_MAX_USER_INSTANCES=%d", c.config.InotifyMaxUserInstances))
+	}
+	if args := c.kubeletExtraArgs(); len(args) > 0 {
+		env = append(env, "KIPOD_KUBELET_EXTRA_ARGS="+strings.Join(args, " "))
+	}
+	if c.config.IptablesBackend != "" {
+		env = append(env, "KIPOD_IPTABLES_BACKEND="+c.config.IptablesBackend)
+	}
 
+	// Other nodes and etcd certificates address the node by its hostname
 	opts := podman.CreateContainerOptions{
-		Name:     nodeName,
-		Image:    c.config.Image,
-		Hostname: nodeName,
-		Rootless: c.config.Rootless,
-		Cgroupns: "private",
-		Network:  "kipod",
-		Labels: map[string]string{
-			podman.LabelCluster: c.config.Name,
-			podman.LabelRole:    role,
-		},
-		Env: env,
+		Name:           containerName(nodeName),
+		Image:          c.config.Image,
+		Hostname:       nodeName,
+		Rootless:       c.config.Rootless,
+		Cgroupns:       "private",
+		Network:        c.network(),
+		NetworkAliases: []string{nodeName},
+		Labels:         c.nodeLabels(nodeName, role),
+		Env:            env,
+		VolumeLabels:   c.nodeVolumeLabels(nodeName),
+		Entrypoint:     c.config.NodeEntrypoint,
+		Command:        c.config.NodeCommand,
 	}
 
 	// Configure container storage
@@ -300,6 +695,16 @@ func (c *Cluster) createContainerOptions(nodeName, role string) podman.CreateCon
 		opts.Tmpfs = []string{fmt.Sprintf("/var/lib/containers/storage:rw,size=%s", size)}
 	}
 
+	// Keep stacked etcd data on a volume when requested
+	if role == "control-plane" && c.config.EtcdDataOnVolume && !c.config.ExternalEtcd {
+		opts.Volumes = append(opts.Volumes, fmt.Sprintf("%s:/var/lib/etcd", extraVolumeName(nodeName, "/var/lib/etcd")))
+	}
+
+	// Dedicated volumes keep pod data across node container restarts
+	for _, mountPath := range c.config.ExtraVolumes {
+		opts.Volumes = append(opts.Volumes, fmt.Sprintf("%s:%s:shared", extraVolumeName(nodeName, mountPath), mountPath))
+	}
+
 	// Mount local builds for development
 	if c.config.CRIOBinary != "" {
 		opts.Volumes = append(opts.Volumes, fmt.Sprintf("%s:/usr/local/bin/crio-custom:ro", c.config.CRIOBinary))
@@ -329,14 +734,62 @@ func (c *Cluster) createContainerOptions(nodeName, role string) podman.CreateCon
 		}
 	}
 
+	// Apply user-provided container options for this role
+	if nodeOpts, ok := c.config.NodeOptions[role]; ok {
+		opts.Sysctls = nodeOpts.Sysctls
+		opts.SecurityOpts = append(opts.SecurityOpts, nodeOpts.SecurityOpts...)
+		opts.Devices = append(opts.Devices, nodeOpts.Devices...)
+		opts.Ulimits = append(opts.Ulimits, nodeOpts.Ulimits...)
+		opts.Env = append(opts.Env, nodeEnv(nodeOpts.Env)...)
+	}
+
+	opts.RestartPolicy = c.config.RestartPolicy
+	if opts.RestartPolicy == "" {
+		opts.RestartPolicy = DefaultRestartPolicy
+	}
+	if !c.config.DisableHealthcheck {
+		opts.HealthCmd = nodeHealthCmd
+		opts.HealthInterval = c.config.HealthcheckInterval
+		if opts.HealthInterval == "" {
+			opts.HealthInterval = defaultHealthcheckInterval
+		}
+		opts.HealthStartPeriod = healthcheckStartPeriod
+	}
+
+	// Pass through devices needed by a sandboxed runtime (e.g. /dev/kvm for Kata)
+	opts.Devices = append(opts.Devices, sandboxDevices(c.config.SandboxRuntime)...)
+
 	// Publish API server port for control-plane nodes
 	if role == "control-plane" {
-		opts.Ports = []string{"6443:6443"}
+		opts.Ports = []string{fmt.Sprintf("%d:%d", c.hostAPIServerPort(), apiServerPort)}
+	}
+	if nodeName == c.nodeName("control-plane", 0) {
+		opts.NetworkAliases = append(opts.NetworkAliases, ControlPlaneEndpoint(c.config.Name))
 	}
 
 	return opts
 }
 
+// nodeEnv returns user environment variables as sorted NAME=value pairs, plus
+// KIPOD_NODE_ENV listing their names so the entrypoint can write them to
+// /etc/kipod/node.env for systemd units
+func nodeEnv(vars map[string]string) []string {
+	if len(vars) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		env = append(env, fmt.Sprintf("%s=%s", name, vars[name]))
+	}
+	return append(env, "KIPOD_NODE_ENV="+strings.Join(names, " "))
+}
+
 func (c *Cluster) installLocalBinaries(containerID string) error {
 	// Replace system binaries with local builds
 	if c.config.CRIOBinary != "" {
@@ -415,7 +868,7 @@ func (c *Cluster) waitForServices(containerID string) error {
 func (c *Cluster) initKubernetes(containerID string) error {
 	style.Step("Writing configuration 📜")
 	// fmt.Println("  Running kubeadm init (this may take a few minutes)...")
-	if err := c.runKubeadmInit(containerID); err != nil {
+	if err := c.timePhase("kubeadm init", func() error { return c.runKubeadmInit(containerID) }); err != nil {
 		return err
 	}
 
@@ -429,43 +882,66 @@ chmod 600 /root/.kube/config`
 	}
 
 	// Wait for API server to be ready
-	timeout := c.config.WaitDuration
-	if timeout == 0 {
-		timeout = 5 * time.Minute // Default timeout
+	style.Step("Waiting ≤ %s for control-plane = Ready ⏳", c.waitTimeout())
+	if err := c.timePhase("api server wait", func() error { return c.waitForAPIServer(containerID) }); err != nil {
+		return err
 	}
-	style.Step("Waiting ≤ %s for control-plane = Ready ⏳", timeout)
-	maxRetries := int(timeout.Seconds() / 2)
-	for i := 0; i < maxRetries; i++ {
-		_, err := podman.Exec(containerID, []string{"kubectl", "get", "nodes"})
-		if err == nil {
-			break
+
+	// The bridge CNI config ships in the image; what remains is making
+	// the node schedulable and kube-proxy work rootless
+	return c.timePhase("cni", func() error {
+		c.removeControlPlaneTaint(containerID)
+		c.setupHostAlias(containerID)
+
+		// Without kube-proxy (e.g. replaced by Cilium) there is nothing to patch
+		if c.skipsPhase("addon/kube-proxy") {
+			return nil
 		}
 
-		if i == maxRetries-1 {
-			return fmt.Errorf("timeout waiting for API server")
+		// Patch kube-proxy to skip privileged sysctl operations
+		// This is needed for rootless containers that can't set nf_conntrack_max
+		patchCmd := `kubectl get configmap -n kube-system kube-proxy -o yaml | \
+	sed 's/maxPerCore: null/maxPerCore: 0/; s/conntrackMaxPerCore: null/conntrackMaxPerCore: 0/' | \
+	kubectl apply -f - && \
+	kubectl rollout restart daemonset/kube-proxy -n kube-system`
+		if _, err := podman.Exec(containerID, []string{"sh", "-c", patchCmd}); err != nil {
+			style.Info("Warning: failed to patch kube-proxy: %v", err)
 		}
+		return nil
+	})
+}
 
+// waitForAPIServer waits until kubectl on the control-plane can reach the API server
+func (c *Cluster) waitForAPIServer(controlPlaneID string) error {
+	maxRetries := int(c.waitTimeout().Seconds() / 2)
+	for i := 0; i < maxRetries; i++ {
+		_, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "nodes"})
+		if err == nil {
+			return nil
+		}
 		time.Sleep(2 * time.Second)
 	}
+	return fmt.Errorf("timeout waiting for API server")
+}
 
-	// Remove control-plane taint (for single-node cluster)
-	// fmt.Println("  Configuring single-node cluster...")
+// removeControlPlaneTaint makes the control-plane schedulable (for
+// single-node clusters), unless the user chose its taints explicitly
+func (c *Cluster) removeControlPlaneTaint(controlPlaneID string) {
+	if len(c.config.ControlPlaneTaints) > 0 {
+		return
+	}
 	taintCmd := "kubectl taint nodes --all node-role.kubernetes.io/control-plane- || true"
-	if _, err := podman.Exec(containerID, []string{"sh", "-c", taintCmd}); err != nil {
-		fmt.Printf("  Warning: failed to remove control-plane taint: %v\n", err)
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", taintCmd}); err != nil {
+		style.Info("Warning: failed to remove control-plane taint: %v", err)
 	}
+}
 
-	// Patch kube-proxy to skip privileged sysctl operations
-	// This is needed for rootless containers that can't set nf_conntrack_max
-	// fmt.Println("  Patching kube-proxy for rootless compatibility...")
-	patchCmd := `kubectl get configmap -n kube-system kube-proxy -o yaml | \
-	sed 's/maxPerCore: null/maxPerCore: 0/; s/conntrackMaxPerCore: null/conntrackMaxPerCore: 0/' | \
-	kubectl apply -f - && \
-	kubectl rollout restart daemonset/kube-proxy -n kube-system`
-	if _, err := podman.Exec(containerID, []string{"sh", "-c", patchCmd}); err != nil {
-		fmt.Printf("  Warning: failed to patch kube-proxy: %v\n", err)
+// applyManifest applies a Kubernetes manifest from inside a control-plane node
+func applyManifest(controlPlaneID, manifest string) error {
+	applyCmd := fmt.Sprintf("kubectl apply -f - << 'KIPOD_EOF'\n%s\nKIPOD_EOF", manifest)
+	if output, err := podman.Exec(controlPlaneID, []string{"sh", "-c", applyCmd}); err != nil {
+		return fmt.Errorf("kubectl apply failed: %w\nOutput:\n%s", err, output)
 	}
-
 	return nil
 }
 
@@ -483,17 +959,70 @@ func Delete(name string) error {
 	}
 
 	style.Step("Deleting %d node(s)... 🗑️", len(containers))
+	networks := make(map[string]bool)
+	volumes := make(map[string]bool)
+	ids := make([]string, 0, len(containers))
+	for _, container := range containers {
+		ids = append(ids, container.ID)
+	}
+	details, err := podman.InspectContainers(ids)
+	if err != nil {
+		style.Info("Warning: %v", err)
+		networks[DefaultNetwork] = true
+	}
+	for _, detail := range details {
+		for _, network := range detail.Networks {
+			networks[network] = true
+		}
+		// Volumes of older releases carry no labels; find them by mount
+		for _, volume := range detail.Volumes {
+			if strings.HasPrefix(volume, "kipod-") {
+				volumes[volume] = true
+			}
+		}
+	}
+
+	// An unlabeled shared network of an older release is recognized by its
+	// kipod containers, so look before deleting them
+	var legacy []string
+	if networks[DefaultNetwork] {
+		if ok, err := legacyNetwork(DefaultNetwork); err != nil {
+			style.Info("Warning: %v", err)
+		} else if ok {
+			legacy = append(legacy, DefaultNetwork)
+		}
+	}
+
 	for _, container := range containers {
 		if err := podman.DeleteContainer(container.ID); err != nil {
 			return fmt.Errorf("failed to delete container %s: %w", container.Name, err)
 		}
-		style.Info("Deleted node: %s", container.Name)
+		style.Info("Deleted node: %s", container.NodeName())
+	}
+
+	labeled, err := podman.ListVolumes(map[string]string{podman.LabelCluster: name}, false)
+	if err != nil {
+		style.Info("Warning: %v", err)
+	}
+	for _, volume := range labeled {
+		volumes[volume.Name] = true
+	}
+	for volume := range volumes {
+		if err := podman.DeleteVolume(volume); err != nil {
+			style.Info("Warning: failed to delete volume %s: %v", volume, err)
+		}
+	}
 
-		// Try to delete associated storage volume
-		volName := fmt.Sprintf("kipod-storage-%s", container.Name)
-		// We ignore errors here because the volume might not exist (if using tmpfs)
-		// or might have been deleted already.
-		_ = podman.DeleteVolume(volName)
+	// Remove networks no other cluster uses anymore. Clusters deleted
+	// concurrently check one at a time, so the last one removes them.
+	networksMu.Lock()
+	deleted, err := deleteUnusedNetworks(networks, legacy...)
+	networksMu.Unlock()
+	if err != nil {
+		style.Info("Warning: failed to clean up networks: %v", err)
+	}
+	for _, network := range deleted {
+		style.Info("Deleted unused network: %s", network)
 	}
 
 	return nil
@@ -530,22 +1059,93 @@ func List() ([]string, error) {
 	return clusters, nil
 }
 
-// GetKubeconfig retrieves the kubeconfig for a cluster
-func GetKubeconfig(name string) (string, error) {
+// Labels returns the labels users gave each cluster, read from the
+// io.kipod.label/ labels of its node containers
+func Labels() (map[string]map[string]string, error) {
+	containers, err := podman.ListContainers(map[string]string{
+		podman.LabelCluster: "",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	labels := make(map[string]map[string]string)
+	for _, container := range containers {
+		name := container.Labels[podman.LabelCluster]
+		if name == "" {
+			continue
+		}
+		if labels[name] == nil {
+			labels[name] = make(map[string]string)
+		}
+		for key, value := range userLabels(container) {
+			labels[name][key] = value
+		}
+	}
+	return labels, nil
+}
+
+// userLabels returns the labels a user gave the cluster of a node container
+func userLabels(container podman.Container) map[string]string {
+	labels := make(map[string]string)
+	for key, value := range container.Labels {
+		if key, ok := strings.CutPrefix(key, podman.LabelUserPrefix); ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// ListNodes returns the Kubernetes node containers belonging to a cluster,
+// excluding external etcd members
+func ListNodes(name string) ([]podman.Container, error) {
+	containers, err := podman.ListContainers(map[string]string{
+		podman.LabelCluster: name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster containers: %w", err)
+	}
+
+	var nodes []podman.Container
+	for _, container := range containers {
+		if container.Labels[podman.LabelRole] != RoleEtcd {
+			nodes = append(nodes, container)
+		}
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("cluster '%s' not found", name)
+	}
+
+	return nodes, nil
+}
+
+// GetControlPlaneNode returns the first control-plane node of a cluster
+func GetControlPlaneNode(name string) (podman.Container, error) {
 	containers, err := podman.ListContainers(map[string]string{
 		podman.LabelCluster: name,
 		podman.LabelRole:    "control-plane",
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to list cluster containers: %w", err)
+		return podman.Container{}, fmt.Errorf("failed to list cluster containers: %w", err)
 	}
 
 	if len(containers) == 0 {
-		return "", fmt.Errorf("cluster '%s' not found", name)
+		return podman.Container{}, fmt.Errorf("cluster '%s' not found", name)
+	}
+
+	return containers[0], nil
+}
+
+// GetKubeconfig retrieves the kubeconfig for a cluster
+func GetKubeconfig(name string) (string, error) {
+	node, err := GetControlPlaneNode(name)
+	if err != nil {
+		return "", err
 	}
 
 	// Get kubeconfig from the control-plane node
-	kubeconfig, err := podman.Exec(containers[0].ID, []string{"cat", "/etc/kubernetes/admin.conf"})
+	kubeconfig, err := podman.Exec(node.ID, []string{"cat", "/etc/kubernetes/admin.conf"})
 	if err != nil {
 		return "", fmt.Errorf("failed to retrieve kubeconfig: %w", err)
 	}
@@ -553,52 +1153,59 @@ func GetKubeconfig(name string) (string, error) {
 	return kubeconfig, nil
 }
 
+// kubeadmConfigPath is where the kubeadm init config is written on the control-plane
+const kubeadmConfigPath = "/tmp/kubeadm-config.yaml"
+
+// usesKubeadmConfig reports whether kubeadm init needs a config file, for
+// settings that have no kubeadm init flag
+func (c *Cluster) usesKubeadmConfig() bool {
+	return c.config.SchedulerConfigPath != "" || len(c.config.SchedulerExtraArgs) > 0 || len(c.config.SchedulerExtraVols) > 0 ||
+		len(c.config.ControlPlaneLabels) > 0 || len(c.config.ControlPlaneTaints) > 0 || c.config.ExternalEtcd ||
+		len(c.etcdExtraArgs()) > 0 || len(c.config.ComponentImages) > 0 || c.usesImageRepositories() ||
+		len(c.apiServerFeatureGates()) > 0 || c.kubeletConfiguration() != ""
+}
+
 func (c *Cluster) runKubeadmInit(containerID string) error {
 	// Check if we need to use a kubeadm config file (for scheduler customization)
-	if c.config.SchedulerConfigPath != "" || len(c.config.SchedulerExtraArgs) > 0 || len(c.config.SchedulerExtraVols) > 0 {
+	if c.usesKubeadmConfig() {
 		return c.runKubeadmInitWithConfig(containerID)
 	}
 
 	// Images will be pulled on-demand by kubeadm (optimized - no pre-loading needed)
 	// Initialize Kubernetes using kubeadm
 	// Include localhost and 127.0.0.1 in API server certificate SANs for port-forwarded access
-	initCmd := fmt.Sprintf(`kubeadm init \
-  --pod-network-cidr=%s \
-  --service-cidr=%s \
-  --cri-socket=unix:///var/run/crio/crio.sock \
-  --apiserver-cert-extra-sans=localhost,127.0.0.1 \
-  --ignore-preflight-errors=NumCPU,Mem,SystemVerification,FileContent--proc-sys-net-bridge-bridge-nf-call-iptables \
-  --v=5`, c.config.PodSubnet, c.config.ServiceSubnet)
-
-	output, err := podman.Exec(containerID, []string{"sh", "-c", initCmd})
-	if err != nil {
-		return fmt.Errorf("kubeadm init failed: %w\nOutput:\n%s", err, output)
+	args := []string{
+		"kubeadm", "init",
+		"--pod-network-cidr=" + c.config.PodSubnet,
+		"--service-cidr=" + c.config.ServiceSubnet,
+		"--cri-socket=unix:///var/run/crio/crio.sock",
+		"--apiserver-cert-extra-sans=localhost,127.0.0.1",
+		"--control-plane-endpoint=" + c.controlPlaneEndpoint(),
 	}
-	return nil
+	args = append(args, c.kubeadmInitArgs()...)
+
+	return c.runKubeadm(containerID, c.nodeName("control-plane", 0), args)
 }
 
-// runKubeadmInitWithConfig uses a kubeadm config file to support scheduler customization
+// runKubeadmInitWithConfig uses a kubeadm config file to support scheduler
+// customization, node registration options and external etcd
 func (c *Cluster) runKubeadmInitWithConfig(containerID string) error {
+	if err := c.writeKubeadmPatches(containerID); err != nil {
+		return err
+	}
+
 	// Build the kubeadm config YAML
 	kubeadmConfig := c.generateKubeadmConfig()
 
 	// Write the config to the container
-	writeConfigCmd := fmt.Sprintf("cat > /tmp/kubeadm-config.yaml << 'KUBEADM_EOF'\n%s\nKUBEADM_EOF", kubeadmConfig)
+	writeConfigCmd := fmt.Sprintf("cat > %s << 'KUBEADM_EOF'\n%s\nKUBEADM_EOF", kubeadmConfigPath, kubeadmConfig)
 	if _, err := podman.Exec(containerID, []string{"sh", "-c", writeConfigCmd}); err != nil {
 		return fmt.Errorf("failed to write kubeadm config: %w", err)
 	}
 
 	// Run kubeadm init with the config file
-	initCmd := `kubeadm init \
-  --config=/tmp/kubeadm-config.yaml \
-  --ignore-preflight-errors=NumCPU,Mem,SystemVerification,FileContent--proc-sys-net-bridge-bridge-nf-call-iptables \
-  --v=5`
-
-	output, err := podman.Exec(containerID, []string{"sh", "-c", initCmd})
-	if err != nil {
-		return fmt.Errorf("kubeadm init failed: %w\nOutput:\n%s", err, output)
-	}
-	return nil
+	args := append([]string{"kubeadm", "init", "--config=" + kubeadmConfigPath}, c.kubeadmInitArgs()...)
+	return c.runKubeadm(containerID, c.nodeName("control-plane", 0), args)
 }
 
 // generateKubeadmConfig generates a kubeadm ClusterConfiguration YAML
@@ -608,8 +1215,43 @@ func (c *Cluster) generateKubeadmConfig() string {
 	// ClusterConfiguration
 	sb.WriteString("apiVersion: kubeadm.k8s.io/v1beta3\n")
 	sb.WriteString("kind: ClusterConfiguration\n")
+	sb.WriteString(fmt.Sprintf("controlPlaneEndpoint: %s\n", c.controlPlaneEndpoint()))
 	sb.WriteString(fmt.Sprintf("networking:\n  podSubnet: %s\n  serviceSubnet: %s\n", c.config.PodSubnet, c.config.ServiceSubnet))
 	sb.WriteString("apiServer:\n  certSANs:\n  - localhost\n  - 127.0.0.1\n")
+	if gates := c.apiServerFeatureGates(); len(gates) > 0 {
+		sb.WriteString(fmt.Sprintf("  extraArgs:\n    feature-gates: %s\n", strings.Join(gates, ",")))
+	}
+	if c.config.ImageRepository != "" {
+		sb.WriteString(fmt.Sprintf("imageRepository: %s\n", c.config.ImageRepository))
+	}
+	if c.config.DNSImageRepository != "" || c.config.DNSImageTag != "" {
+		sb.WriteString("dns:\n")
+		writeImageFields(&sb, "  ", c.config.DNSImageRepository, c.config.DNSImageTag)
+	}
+
+	// External etcd endpoints
+	if c.config.ExternalEtcd {
+		sb.WriteString("etcd:\n  external:\n    endpoints:\n")
+		for _, endpoint := range c.etcdEndpoints() {
+			sb.WriteString(fmt.Sprintf("    - %s\n", endpoint))
+		}
+		sb.WriteString(fmt.Sprintf("    caFile: %s\n", apiserverEtcdCAFile))
+		sb.WriteString(fmt.Sprintf("    certFile: %s\n", apiserverEtcdCertFile))
+		sb.WriteString(fmt.Sprintf("    keyFile: %s\n", apiserverEtcdKeyFile))
+	}
+
+	// Stacked etcd image and tuning
+	extraArgs := c.etcdExtraArgs()
+	if !c.config.ExternalEtcd && (len(extraArgs) > 0 || c.config.EtcdImageRepository != "" || c.config.EtcdImageTag != "") {
+		sb.WriteString("etcd:\n  local:\n")
+		writeImageFields(&sb, "    ", c.config.EtcdImageRepository, c.config.EtcdImageTag)
+		if len(extraArgs) > 0 {
+			sb.WriteString("    extraArgs:\n")
+			for _, key := range sortedKeys(extraArgs) {
+				sb.WriteString(fmt.Sprintf("      %s: \"%s\"\n", key, extraArgs[key]))
+			}
+		}
+	}
 
 	// Scheduler configuration
 	if c.config.SchedulerConfigPath != "" || len(c.config.SchedulerExtraArgs) > 0 || len(c.config.SchedulerExtraVols) > 0 {
@@ -661,6 +1303,46 @@ func (c *Cluster) generateKubeadmConfig() string {
 	sb.WriteString("kind: InitConfiguration\n")
 	sb.WriteString("nodeRegistration:\n")
 	sb.WriteString("  criSocket: unix:///var/run/crio/crio.sock\n")
+	writeNodeRegistration(&sb, c.config.ControlPlaneLabels, c.config.ControlPlaneTaints)
+	if len(c.config.ComponentImages) > 0 {
+		sb.WriteString(fmt.Sprintf("patches:\n  directory: %s\n", kubeadmPatchesDir))
+	}
+
+	if kubeletConfig := c.kubeletConfiguration(); kubeletConfig != "" {
+		sb.WriteString("---\n")
+		sb.WriteString(kubeletConfig)
+	}
 
 	return sb.String()
 }
+
+// writeNodeRegistration writes the labels and taints of a kubeadm
+// nodeRegistration block. Setting taints replaces kubeadm's default
+// control-plane taint.
+func writeNodeRegistration(sb *strings.Builder, labels map[string]string, taints []Taint) {
+	if len(labels) > 0 {
+		keys := make([]string, 0, len(labels))
+		for key := range labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", key, labels[key]))
+		}
+		sb.WriteString("  kubeletExtraArgs:\n")
+		sb.WriteString(fmt.Sprintf("    node-labels: \"%s\"\n", strings.Join(pairs, ",")))
+	}
+
+	if len(taints) > 0 {
+		sb.WriteString("  taints:\n")
+		for _, taint := range taints {
+			sb.WriteString(fmt.Sprintf("  - key: %s\n", taint.Key))
+			if taint.Value != "" {
+				sb.WriteString(fmt.Sprintf("    value: %s\n", taint.Value))
+			}
+			sb.WriteString(fmt.Sprintf("    effect: %s\n", taint.Effect))
+		}
+	}
+}
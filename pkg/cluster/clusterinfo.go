@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// ClusterInfoConfigMap in kube-public describes how kipod built the cluster
+	ClusterInfoConfigMap = "kipod-cluster-info"
+
+	// clusterInfoNamespace is readable by every authenticated user, like
+	// the cluster-info ConfigMap kubeadm writes there
+	clusterInfoNamespace = "kube-public"
+)
+
+// ClusterInfo is what RecordClusterInfo publishes in the cluster
+type ClusterInfo struct {
+	KipodVersion string
+	// Config is the effective kipod config of the cluster, as YAML
+	Config string
+}
+
+// RecordClusterInfo writes the ClusterInfoConfigMap and one Event per
+// provisioning phase, involving that ConfigMap, so in-cluster tooling and
+// kubectl users can see how the cluster was built. The API server drops
+// Events after its --event-ttl (one hour by default); the ConfigMap stays.
+func (c *Cluster) RecordClusterInfo(info ClusterInfo) error {
+	controlPlane, err := GetControlPlaneNode(c.config.Name)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	items := []map[string]interface{}{{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      ClusterInfoConfigMap,
+			"namespace": clusterInfoNamespace,
+			"labels":    map[string]string{"app.kubernetes.io/managed-by": "kipod"},
+		},
+		"data": map[string]string{
+			"cluster":       c.config.Name,
+			"kipodVersion":  info.KipodVersion,
+			"provisionedAt": now.Format(time.RFC3339),
+			"config.yaml":   info.Config,
+		},
+	}}
+
+	var total time.Duration
+	for i, timing := range c.timings {
+		total += timing.Duration
+		items = append(items, clusterInfoEvent(i, "PhaseCompleted",
+			fmt.Sprintf("kipod phase %q took %.1fs", timing.Phase, timing.Seconds),
+			timing.Started, timing.Started.Add(timing.Duration)))
+	}
+	items = append(items, clusterInfoEvent(len(c.timings), "Provisioned",
+		fmt.Sprintf("kipod %s provisioned cluster %q in %.1fs", info.KipodVersion, c.config.Name, total.Seconds()),
+		now, now))
+
+	manifest, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode cluster info: %w", err)
+	}
+	return applyManifest(controlPlane.ID, string(manifest))
+}
+
+// clusterInfoEvent returns a Normal Event about the ClusterInfoConfigMap,
+// named like the Events of other components: object name and a unique suffix
+func clusterInfoEvent(index int, reason, message string, first, last time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata": map[string]interface{}{
+			"name":      fmt.Sprintf("%s.%x", ClusterInfoConfigMap, first.UnixNano()+int64(index)),
+			"namespace": clusterInfoNamespace,
+		},
+		"involvedObject": map[string]string{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"name":       ClusterInfoConfigMap,
+			"namespace":  clusterInfoNamespace,
+		},
+		"type":               "Normal",
+		"reason":             reason,
+		"message":            message,
+		"source":             map[string]string{"component": "kipod"},
+		"reportingComponent": "kipod",
+		"reportingInstance":  "kipod",
+		"count":              1,
+		"firstTimestamp":     first.UTC().Format(time.RFC3339),
+		"lastTimestamp":      last.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,193 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// crioUserConf is where the entrypoint installs the user's CRI-O config
+const crioUserConf = "/etc/crio/crio.conf.d/99-user.conf"
+
+// ConfigDiff is the difference between a config file kipod would generate
+// for a node and the one installed on it
+type ConfigDiff struct {
+	// Node is the Kubernetes node name
+	Node string
+	// Path is the file on the node
+	Path string
+	// Missing is set when the file is not installed on the node
+	Missing bool
+	// Lines is the line diff from the generated to the installed file
+	Lines []DiffLine
+}
+
+// DiffLine is a line of a ConfigDiff
+type DiffLine struct {
+	// Op is ' ' for lines in both files, '-' for lines only kipod
+	// generates and '+' for lines only installed on the node
+	Op byte
+	// Text is the line without its newline
+	Text string
+}
+
+// DiffConfigs renders the config files kipod would generate for each node of
+// the cluster of cfg (the kubeadm config, CRI-O drop-ins, storage.conf and
+// the bridge CNI config) and diffs them against the files installed on the
+// running nodes. Only files that differ are returned.
+func DiffConfigs(cfg *Config) ([]ConfigDiff, error) {
+	c, err := NewCluster(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := ListNodes(cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("cluster '%s' not found", cfg.Name)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeName() < nodes[j].NodeName() })
+
+	var diffs []ConfigDiff
+	for _, node := range nodes {
+		if node.State != "running" {
+			style.Info("Warning: skipping %s, which is %s", node.NodeName(), node.State)
+			continue
+		}
+		files, err := c.renderConfigs(node)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range sortedKeys(files) {
+			installed, err := podman.Exec(node.ID, []string{"cat", path})
+			diff := ConfigDiff{Node: node.NodeName(), Path: path, Missing: err != nil}
+			if diff.Missing {
+				installed = ""
+			}
+			if installed == files[path] {
+				continue
+			}
+			diff.Lines = diffLines(splitLines(files[path]), splitLines(installed))
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+// renderConfigs returns the config files kipod writes to a node, by path
+func (c *Cluster) renderConfigs(node podman.Container) (map[string]string, error) {
+	files := make(map[string]string)
+
+	// Only the first control-plane runs kubeadm init; the config is written
+	// through a heredoc, which adds a newline
+	if node.NodeName() == c.nodeName("control-plane", 0) && c.usesKubeadmConfig() {
+		files[kubeadmConfigPath] = c.generateKubeadmConfig() + "\n"
+	}
+
+	if c.config.StorageDriver != "" {
+		files[storageConfPath] = storageConf(c.config.StorageDriver)
+		files[crioStorageConfPath] = fmt.Sprintf("[crio]\n  storage_driver = %q\n", storageDriverName(c.config.StorageDriver))
+	}
+
+	dropins, err := c.crioDropins(node)
+	if err != nil {
+		return nil, err
+	}
+	for path, content := range dropins {
+		files[path] = content
+	}
+	return files, nil
+}
+
+// managedCRIODropins are the CRI-O drop-ins kipod manages on a running node,
+// which take effect on a CRI-O restart
+var managedCRIODropins = []string{crioProfileConf, crioFeaturesConf, crioAuthConf, crioSignatureConf, crioPauseImageConf, crioLogLevelConf, crioUserConf}
+
+// crioDropins returns the managedCRIODropins the config installs on a node, by path
+func (c *Cluster) crioDropins(node podman.Container) (map[string]string, error) {
+	files, err := c.experimentDropins()
+	if err != nil {
+		return nil, err
+	}
+	if level, ok := c.config.LogLevels["crio"]; ok {
+		files[crioLogLevelConf] = fmt.Sprintf("[crio.runtime]\nlog_level = %q\n", level)
+	}
+	if c.config.RegistryAuthNodes {
+		files[crioAuthConf] = registryAuthConf
+	}
+	if c.config.SignaturePolicy != "" {
+		files[crioSignatureConf] = signaturePolicyConf
+	}
+	if c.config.ImageRepository != "" {
+		image, err := pauseImage(node.ID, c.config.ImageRepository)
+		if err != nil {
+			return nil, err
+		}
+		files[crioPauseImageConf] = fmt.Sprintf("[crio.image]\npause_image = %q\n", image)
+	}
+	if c.config.CRIOConfig != "" {
+		data, err := os.ReadFile(c.config.CRIOConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CRI-O config: %w", err)
+		}
+		files[crioUserConf] = string(data)
+	}
+	return files, nil
+}
+
+// splitLines splits a file into lines, without the empty line after the
+// final newline
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns a line diff from a to b based on their longest common
+// subsequence. Config files are small, so the quadratic table is fine.
+func diffLines(a, b []string) []DiffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, DiffLine{Op: ' ', Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{Op: '-', Text: a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: '+', Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, DiffLine{Op: '-', Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, DiffLine{Op: '+', Text: b[j]})
+	}
+	return lines
+}
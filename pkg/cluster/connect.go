@@ -0,0 +1,284 @@
+package cluster
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// bridgeCNIConfig is the CNI config the node image ships
+const bridgeCNIConfig = "/etc/cni/net.d/10-kipod-bridge.conflist"
+
+// ConnectOptions configure how two clusters are connected
+type ConnectOptions struct {
+	// Routes makes pods of the clusters reach each other by their IPs
+	Routes bool
+}
+
+// ConnectNetworkName returns the name of the network shared by two clusters,
+// the same whatever their order
+func ConnectNetworkName(a, b string) string {
+	names := []string{a, b}
+	sort.Strings(names)
+	return fmt.Sprintf("kipod-connect-%s-%s", names[0], names[1])
+}
+
+// Connect attaches the nodes of two clusters to a shared network, so nodes,
+// NodePorts and host-network pods of one cluster reach the other. With
+// Routes, each node hands out pod IPs from its own podCIDR and routes the
+// podCIDRs of all other nodes of both clusters through the shared network.
+// Routes do not survive node restarts; connecting again restores them.
+func Connect(a, b string, opts ConnectOptions) (string, error) {
+	if a == b {
+		return "", fmt.Errorf("cannot connect cluster '%s' to itself", a)
+	}
+	nodesA, err := ListNodes(a)
+	if err != nil {
+		return "", err
+	}
+	nodesB, err := ListNodes(b)
+	if err != nil {
+		return "", err
+	}
+	nodes := append(nodesA, nodesB...)
+
+	network := ConnectNetworkName(a, b)
+	if err := ensureNetwork(network); err != nil {
+		return "", err
+	}
+	attached, err := attachedNetworks(nodes)
+	if err != nil {
+		return "", err
+	}
+	for _, node := range nodes {
+		if containsString(attached[node.ID], network) {
+			continue
+		}
+		style.Step("Connecting %s to %s", node.NodeName(), network)
+		if err := podman.ConnectNetwork(network, node.ID); err != nil {
+			return "", err
+		}
+	}
+
+	if opts.Routes {
+		if err := routePods(network, map[string][]podman.Container{a: nodesA, b: nodesB}); err != nil {
+			return "", err
+		}
+	}
+	return network, nil
+}
+
+// Disconnect removes the routes Connect added between two clusters, detaches
+// their nodes from the shared network and deletes it
+func Disconnect(a, b string) error {
+	network := ConnectNetworkName(a, b)
+	exists, err := podman.NetworkExists(network)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("clusters '%s' and '%s' are not connected", a, b)
+	}
+
+	var nodes []podman.Container
+	podCIDRs := make(map[string]string)
+	for _, name := range []string{a, b} {
+		clusterNodes, err := ListNodes(name)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, clusterNodes...)
+		// Without routes or a reachable API server there is nothing to remove
+		if cidrs, err := nodePodCIDRs(name); err == nil {
+			for node, cidr := range cidrs {
+				podCIDRs[node] = cidr
+			}
+		}
+	}
+
+	attached, err := attachedNetworks(nodes)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.State == "running" {
+			for peer, cidr := range podCIDRs {
+				if peer != node.NodeName() {
+					// The route may be gone with a node restart already
+					_, _ = podman.Exec(node.ID, []string{"ip", "route", "del", cidr})
+				}
+			}
+		}
+		if !containsString(attached[node.ID], network) {
+			continue
+		}
+		if err := podman.DisconnectNetwork(network, node.ID); err != nil {
+			return err
+		}
+	}
+
+	_, err = deleteUnusedNetworks(map[string]bool{network: true})
+	return err
+}
+
+// routePods gives every node a pod range of its own, its podCIDR, instead of
+// the whole pod subnet, and routes the podCIDRs of the other nodes through
+// their IPs on network. Pods that got addresses from the whole subnet are
+// recreated, since their gateway address changes.
+func routePods(network string, clusters map[string][]podman.Container) error {
+	podCIDRs := make(map[string]*net.IPNet)
+	owners := make(map[string]string)
+	names := make([]string, 0, len(clusters))
+	for name := range clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cidrs, err := nodePodCIDRs(name)
+		if err != nil {
+			return err
+		}
+		for node, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return fmt.Errorf("node %s has an invalid podCIDR %q: %w", node, cidr, err)
+			}
+			for other, otherNet := range podCIDRs {
+				if owners[other] != name && (otherNet.Contains(ipNet.IP) || ipNet.Contains(otherNet.IP)) {
+					return fmt.Errorf("pod CIDR %s of %s overlaps %s of %s; create one of the clusters with another networking.podSubnet, e.g. 10.245.0.0/16", cidr, node, otherNet, other)
+				}
+			}
+			podCIDRs[node] = ipNet
+			owners[node] = name
+		}
+	}
+
+	for _, name := range names {
+		controlPlane, err := GetControlPlaneNode(name)
+		if err != nil {
+			return err
+		}
+		for _, node := range clusters[name] {
+			cidr, ok := podCIDRs[node.NodeName()]
+			if !ok {
+				return fmt.Errorf("node %s has no podCIDR; is it registered in cluster '%s'?", node.NodeName(), name)
+			}
+			if err := useNodePodCIDR(controlPlane.ID, node, cidr.String()); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, nodes := range clusters {
+		for _, node := range nodes {
+			for _, peers := range clusters {
+				for _, peer := range peers {
+					if peer.ID == node.ID {
+						continue
+					}
+					peerIP, err := podman.ContainerNetworkIP(peer.ID, network)
+					if err != nil {
+						return err
+					}
+					if peerIP == "" {
+						return fmt.Errorf("node %s has no IP on %s; is it running?", peer.NodeName(), network)
+					}
+					route := []string{"ip", "route", "replace", podCIDRs[peer.NodeName()].String(), "via", peerIP}
+					if _, err := podman.Exec(node.ID, route); err != nil {
+						return fmt.Errorf("failed to route the pods of %s from %s: %w", peer.NodeName(), node.NodeName(), err)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// useNodePodCIDR makes the bridge CNI of a node allocate pod IPs from cidr
+// and recreates the pods that are not on the host network, unless the node
+// already uses it
+func useNodePodCIDR(controlPlaneID string, node podman.Container, cidr string) error {
+	current, err := podman.Exec(node.ID, []string{"sed", "-n", `s/.*"subnet": *"\([^"]*\)".*/\1/p`, bridgeCNIConfig})
+	if err != nil {
+		return fmt.Errorf("failed to read the CNI config of %s: %w", node.NodeName(), err)
+	}
+	if strings.TrimSpace(current) == cidr {
+		return nil
+	}
+
+	style.Step("Assigning pod range %s to %s", cidr, node.NodeName())
+	// forceAddress lets the bridge plugin replace the gateway address of
+	// the whole subnet on cni0 with the one of the new range
+	script := fmt.Sprintf(`sed -i -e 's#"subnet": *"[^"]*"#"subnet": "%s"#' -e '/"forceAddress"/d' -e 's#"isGateway": true,#"isGateway": true,\n      "forceAddress": true,#' %s`, cidr, bridgeCNIConfig)
+	if _, err := podman.Exec(node.ID, []string{"sh", "-c", script}); err != nil {
+		return fmt.Errorf("failed to update the CNI config of %s: %w", node.NodeName(), err)
+	}
+
+	recreate := fmt.Sprintf(`kubectl get pods -A --field-selector spec.nodeName=%s -o jsonpath='{range .items[*]}{.metadata.namespace}{" "}{.metadata.name}{" "}{.spec.hostNetwork}{"\n"}{end}' |
+while read -r namespace pod hostNetwork; do
+  [ "$hostNetwork" = "true" ] || kubectl -n "$namespace" delete pod "$pod" --wait=false
+done`, node.NodeName())
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", recreate}); err != nil {
+		style.Info("Warning: failed to recreate the pods of %s; delete them so they get addresses from %s: %v", node.NodeName(), cidr, err)
+	}
+	return nil
+}
+
+// nodePodCIDRs returns the podCIDR the controller-manager assigned to each
+// node of a cluster, by node name
+func nodePodCIDRs(name string) (map[string]string, error) {
+	controlPlane, err := GetControlPlaneNode(name)
+	if err != nil {
+		return nil, err
+	}
+	output, err := podman.Exec(controlPlane.ID, []string{"kubectl", "get", "nodes", "-o",
+		`jsonpath={range .items[*]}{.metadata.name}{" "}{.spec.podCIDR}{"\n"}{end}`})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the pod CIDRs of cluster '%s': %w", name, err)
+	}
+	cidrs := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			cidrs[fields[0]] = fields[1]
+		}
+	}
+	return cidrs, nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// attachedNetworks returns the networks of each node by container ID,
+// inspecting all of them at once
+func attachedNetworks(nodes []podman.Container) (map[string][]string, error) {
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	details, err := podman.InspectContainers(ids)
+	if err != nil {
+		return nil, err
+	}
+	networks := make(map[string][]string)
+	for _, detail := range details {
+		for _, id := range ids {
+			// podman ps lists short IDs, inspect full ones
+			if strings.HasPrefix(detail.ID, id) {
+				networks[id] = detail.Networks
+			}
+		}
+	}
+	return networks, nil
+}
@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sohankunkerkar/kipod/pkg/crio"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// CRI-O drop-ins of the configured crioProfile and features
+const (
+	crioProfileConf  = crio.CRIODropinPath + "/" + crio.ProfileDropin
+	crioFeaturesConf = crio.CRIODropinPath + "/" + crio.FeaturesDropin
+)
+
+// experimentDropins returns the CRI-O drop-ins the crioProfile and features
+// of the config expand into, by path
+func (c *Cluster) experimentDropins() (map[string]string, error) {
+	files := make(map[string]string)
+	if c.config.CRIOProfile != "" {
+		conf, err := crio.ProfileConfig(c.config.CRIOProfile)
+		if err != nil {
+			return nil, err
+		}
+		files[crioProfileConf] = conf
+	}
+	if conf := crio.FeaturesConfig(c.config.EventedPLEG, c.config.CheckpointRestore); conf != "" {
+		files[crioFeaturesConf] = conf
+	}
+	return files, nil
+}
+
+// applyExperimentDropins installs the drop-ins of the configured CRI-O
+// profile and features on a node and restarts CRI-O once to load them
+func (c *Cluster) applyExperimentDropins(containerID string) error {
+	files, err := c.experimentDropins()
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := writeNodeFile(containerID, path, files[path], 0644); err != nil {
+			return err
+		}
+	}
+	if _, err := podman.Exec(containerID, []string{"systemctl", "restart", "crio"}); err != nil {
+		return fmt.Errorf("failed to restart CRI-O: %w", err)
+	}
+	return waitForCRIO(containerID)
+}
+
+// kubeletFeatureGates returns the kubelet feature gates the configured
+// features need, as name=true
+func (c *Cluster) kubeletFeatureGates() []string {
+	var gates []string
+	if c.config.EventedPLEG {
+		gates = append(gates, "EventedPLEG=true")
+	}
+	if c.config.CheckpointRestore {
+		gates = append(gates, "ContainerCheckpoint=true")
+	}
+	if c.config.ImageVolume {
+		gates = append(gates, "ImageVolume=true")
+	}
+	return gates
+}
+
+// podPidsLimit returns the kubelet podPidsLimit the configured CRI-O profile
+// needs, or 0 to keep the kubelet default
+func (c *Cluster) podPidsLimit() int {
+	if c.config.CRIOProfile == "high-pids" {
+		return crio.HighPidsLimit
+	}
+	return 0
+}
+
+// apiServerFeatureGates returns the API server feature gates the configured
+// features need, as name=true
+func (c *Cluster) apiServerFeatureGates() []string {
+	if c.config.ImageVolume {
+		// Without it the API server drops image volumes from pod specs
+		return []string{"ImageVolume=true"}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package cluster
+
+import "fmt"
+
+const (
+	// apiServerPort is the port the API server listens on in the nodes
+	apiServerPort = 6443
+
+	// DefaultAPIServerPort is the host port the API server is published on
+	// unless the config sets networking.apiServerPort
+	DefaultAPIServerPort = apiServerPort
+)
+
+// ControlPlaneEndpoint returns the stable name of a cluster's API server. It
+// is a network alias of the first control-plane node, so nodes resolve it on
+// the cluster network whatever IP the node gets, and kubeadm puts it in the
+// API server certificate. On the host, kipod kubeconfig alias maps it to the
+// published port.
+func ControlPlaneEndpoint(name string) string {
+	return name + "-control-plane"
+}
+
+// controlPlaneEndpoint returns the host:port kubeadm advertises the API
+// server on, used by kubeconfigs and joining nodes
+func (c *Cluster) controlPlaneEndpoint() string {
+	return fmt.Sprintf("%s:%d", ControlPlaneEndpoint(c.config.Name), apiServerPort)
+}
+
+// hostAPIServerPort returns the host port the API server is published on
+func (c *Cluster) hostAPIServerPort() int {
+	if c.config.APIServerPort != 0 {
+		return c.config.APIServerPort
+	}
+	return DefaultAPIServerPort
+}
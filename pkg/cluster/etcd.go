@@ -0,0 +1,264 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+const (
+	// RoleEtcd is the role label of external etcd containers
+	RoleEtcd = "etcd"
+
+	// DefaultEtcdImage is the etcd image used for external etcd
+	DefaultEtcdImage = "registry.k8s.io/etcd:3.6.4-0"
+
+	// etcdPKIDir holds the member certificates inside etcd containers
+	etcdPKIDir = "/etc/kipod-etcd/pki"
+
+	// Certificates kube-apiserver uses for external etcd, at kubeadm's default paths
+	apiserverEtcdCAFile   = "/etc/kubernetes/pki/etcd/ca.crt"
+	apiserverEtcdCertFile = "/etc/kubernetes/pki/apiserver-etcd-client.crt"
+	apiserverEtcdKeyFile  = "/etc/kubernetes/pki/apiserver-etcd-client.key"
+)
+
+// etcdMemberName returns the hostname of an external etcd member
+func (c *Cluster) etcdMemberName(index int) string {
+	return fmt.Sprintf("%s-%s-%d", c.config.Name, RoleEtcd, index)
+}
+
+// etcdReplicas returns the number of external etcd members
+func (c *Cluster) etcdReplicas() int {
+	if c.config.EtcdReplicas > 0 {
+		return c.config.EtcdReplicas
+	}
+	return 1
+}
+
+// etcdEndpoints returns the client URLs kube-apiserver uses for external etcd
+func (c *Cluster) etcdEndpoints() []string {
+	endpoints := make([]string, 0, c.etcdReplicas())
+	for i := 0; i < c.etcdReplicas(); i++ {
+		endpoints = append(endpoints, fmt.Sprintf("https://%s:2379", c.etcdMemberName(i)))
+	}
+	return endpoints
+}
+
+// etcdInitialCluster returns the --initial-cluster value listing all members
+func (c *Cluster) etcdInitialCluster() string {
+	members := make([]string, 0, c.etcdReplicas())
+	for i := 0; i < c.etcdReplicas(); i++ {
+		name := c.etcdMemberName(i)
+		members = append(members, fmt.Sprintf("%s=https://%s:2380", name, name))
+	}
+	return strings.Join(members, ",")
+}
+
+// etcdExtraArgs returns the etcd flags for the configured quota and
+// compaction settings, without leading dashes
+func (c *Cluster) etcdExtraArgs() map[string]string {
+	args := make(map[string]string)
+	if c.config.EtcdQuotaBackendBytes > 0 {
+		args["quota-backend-bytes"] = strconv.FormatUint(c.config.EtcdQuotaBackendBytes, 10)
+	}
+	if c.config.EtcdAutoCompactionMode != "" {
+		args["auto-compaction-mode"] = c.config.EtcdAutoCompactionMode
+	}
+	if c.config.EtcdAutoCompactionRetention != "" {
+		args["auto-compaction-retention"] = c.config.EtcdAutoCompactionRetention
+	}
+	if c.config.EtcdUnsafeNoFsync {
+		args["unsafe-no-fsync"] = "true"
+	}
+	return args
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// createExternalEtcd runs the etcd members in dedicated containers on the
+// cluster network, secured with the generated PKI. Created containers are
+// recorded for cleanup as they are started.
+func (c *Cluster) createExternalEtcd(pki *etcdPKI) ([]string, error) {
+	image := c.externalEtcdImage()
+
+	var ids []string
+	for i := 0; i < c.etcdReplicas(); i++ {
+		name := c.etcdMemberName(i)
+		opts := podman.CreateContainerOptions{
+			Name:           containerName(name),
+			Image:          image,
+			Hostname:       name,
+			Network:        c.network(),
+			NetworkAliases: []string{name},
+			Systemd:        "false",
+			NoStart:        true,
+			Labels:         c.nodeLabels(name, RoleEtcd),
+			VolumeLabels:   c.nodeVolumeLabels(name),
+			Command: []string{
+				"etcd",
+				"--name", name,
+				"--data-dir", "/var/lib/etcd",
+				"--listen-client-urls", "https://0.0.0.0:2379",
+				"--advertise-client-urls", fmt.Sprintf("https://%s:2379", name),
+				"--listen-peer-urls", "https://0.0.0.0:2380",
+				"--initial-advertise-peer-urls", fmt.Sprintf("https://%s:2380", name),
+				"--initial-cluster", c.etcdInitialCluster(),
+				"--initial-cluster-token", c.config.Name,
+				"--initial-cluster-state", "new",
+				"--cert-file", etcdPKIDir + "/server.crt",
+				"--key-file", etcdPKIDir + "/server.key",
+				"--trusted-ca-file", etcdPKIDir + "/ca.crt",
+				"--client-cert-auth",
+				"--peer-cert-file", etcdPKIDir + "/server.crt",
+				"--peer-key-file", etcdPKIDir + "/server.key",
+				"--peer-trusted-ca-file", etcdPKIDir + "/ca.crt",
+				"--peer-client-cert-auth",
+			},
+		}
+		extraArgs := c.etcdExtraArgs()
+		for _, key := range sortedKeys(extraArgs) {
+			opts.Command = append(opts.Command, fmt.Sprintf("--%s=%s", key, extraArgs[key]))
+		}
+
+		// Keep etcd data off the container overlay, like node storage
+		if c.config.EtcdDataOnVolume {
+			opts.Volumes = []string{fmt.Sprintf("%s:/var/lib/etcd", extraVolumeName(name, "/var/lib/etcd"))}
+		} else if c.config.StorageType == "volume" {
+			opts.Volumes = []string{fmt.Sprintf("kipod-storage-%s:/var/lib/etcd", name)}
+		} else {
+			opts.Tmpfs = []string{"/var/lib/etcd:rw,size=1G"}
+		}
+
+		containerID, err := podman.CreateContainer(opts)
+		if err != nil {
+			return ids, fmt.Errorf("failed to create etcd container %s: %w", name, err)
+		}
+		ids = append(ids, containerID)
+
+		if err := installEtcdMemberCerts(containerID, pki.CA, pki.Members[i]); err != nil {
+			return ids, err
+		}
+		if err := podman.StartContainer(containerID); err != nil {
+			return ids, fmt.Errorf("failed to start etcd member %s: %w", name, err)
+		}
+	}
+	return ids, nil
+}
+
+// installEtcdMemberCerts copies a member's certificates into its stopped container
+func installEtcdMemberCerts(containerID string, ca, member *certKeyPair) error {
+	dir, err := os.MkdirTemp("", "kipod-etcd-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	staged := filepath.Join(dir, "kipod-etcd")
+	err = writePEMFiles(staged, map[string][]byte{
+		"pki/ca.crt":     ca.Cert,
+		"pki/server.crt": member.Cert,
+		"pki/server.key": member.Key,
+	})
+	if err != nil {
+		return err
+	}
+	if err := podman.CopyToContainer(containerID, staged, filepath.Dir(etcdPKIDir)); err != nil {
+		return fmt.Errorf("failed to install etcd certificates: %w", err)
+	}
+	return nil
+}
+
+// installEtcdClientCerts copies the etcd CA and the kube-apiserver client
+// certificate into the control-plane before kubeadm init
+func installEtcdClientCerts(controlPlaneID string, pki *etcdPKI) error {
+	dir, err := os.MkdirTemp("", "kipod-etcd-client-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = writePEMFiles(dir, map[string][]byte{
+		strings.TrimPrefix(apiserverEtcdCAFile, "/etc/kubernetes/"):   pki.CA.Cert,
+		strings.TrimPrefix(apiserverEtcdCertFile, "/etc/kubernetes/"): pki.Client.Cert,
+		strings.TrimPrefix(apiserverEtcdKeyFile, "/etc/kubernetes/"):  pki.Client.Key,
+	})
+	if err != nil {
+		return err
+	}
+	if err := podman.CopyToContainer(controlPlaneID, dir+"/.", "/etc/kubernetes"); err != nil {
+		return fmt.Errorf("failed to install etcd client certificates: %w", err)
+	}
+	return nil
+}
+
+// etcdctl returns an etcdctl command talking to the local member over TLS
+func etcdctl(args ...string) []string {
+	return append([]string{
+		"etcdctl",
+		"--endpoints=https://127.0.0.1:2379",
+		"--cacert=" + etcdPKIDir + "/ca.crt",
+		"--cert=" + etcdPKIDir + "/server.crt",
+		"--key=" + etcdPKIDir + "/server.key",
+	}, args...)
+}
+
+// waitForEtcd waits until the etcd member reports healthy
+func waitForEtcd(containerID string) error {
+	for i := 0; i < 30; i++ {
+		if _, err := podman.Exec(containerID, etcdctl("endpoint", "health")); err == nil {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("timeout waiting for etcd to become healthy")
+}
+
+// ListEtcdMembers returns the external etcd containers of a cluster
+func ListEtcdMembers(name string) ([]podman.Container, error) {
+	containers, err := podman.ListContainers(map[string]string{
+		podman.LabelCluster: name,
+		podman.LabelRole:    RoleEtcd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list etcd containers: %w", err)
+	}
+	return containers, nil
+}
+
+// startEtcd starts stopped external etcd members and waits for them. All
+// members are started first, since a multi-member cluster needs quorum.
+func startEtcd(name string) error {
+	members, err := ListEtcdMembers(name)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.State != "running" {
+			style.Info("Starting stopped etcd member %s", member.NodeName())
+			if err := podman.StartContainer(member.ID); err != nil {
+				return fmt.Errorf("failed to start etcd member %s: %w", member.NodeName(), err)
+			}
+		}
+	}
+	for _, member := range members {
+		if err := waitForEtcd(member.ID); err != nil {
+			return fmt.Errorf("etcd member %s is unhealthy: %w", member.NodeName(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package cluster
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// etcdCertValidity is the lifetime of the generated etcd certificates
+const etcdCertValidity = 10 * 365 * 24 * time.Hour
+
+// certKeyPair is a PEM encoded certificate and private key
+type certKeyPair struct {
+	Cert []byte
+	Key  []byte
+
+	cert   *x509.Certificate
+	signer *ecdsa.PrivateKey
+}
+
+// etcdPKI holds the certificates of an external etcd cluster
+type etcdPKI struct {
+	CA      *certKeyPair
+	Members []*certKeyPair // Server and peer certificate per member
+	Client  *certKeyPair   // kube-apiserver client certificate
+}
+
+// newEtcdPKI generates a CA, a serving/peer certificate for every etcd member
+// and the client certificate kube-apiserver uses to talk to etcd
+func (c *Cluster) newEtcdPKI() (*etcdPKI, error) {
+	ca, err := newCertificate("etcd-ca", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	pki := &etcdPKI{CA: ca}
+
+	for i := 0; i < c.etcdReplicas(); i++ {
+		name := c.etcdMemberName(i)
+		member, err := newCertificate(name, ca, []string{name, "localhost"},
+			[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth})
+		if err != nil {
+			return nil, err
+		}
+		pki.Members = append(pki.Members, member)
+	}
+
+	pki.Client, err = newCertificate("kube-apiserver-etcd-client", ca, nil,
+		[]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	if err != nil {
+		return nil, err
+	}
+	return pki, nil
+}
+
+// newCertificate creates a key pair signed by ca, or a self-signed CA when
+// ca is nil. DNS names also get the loopback address as IP SAN.
+func newCertificate(commonName string, ca *certKeyPair, dnsNames []string, usages []x509.ExtKeyUsage) (*certKeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key for %s: %w", commonName, err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial for %s: %w", commonName, err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(etcdCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  usages,
+		DNSNames:     dnsNames,
+	}
+	if len(dnsNames) > 0 {
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+
+	parent, signer := template, key
+	if ca == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	} else {
+		parent, signer = ca.cert, ca.signer
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate %s: %w", commonName, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", commonName, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key %s: %w", commonName, err)
+	}
+
+	return &certKeyPair{
+		Cert:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:    pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		cert:   cert,
+		signer: key,
+	}, nil
+}
+
+// writePEMFiles writes files relative to dir, keys with owner-only permissions
+func writePEMFiles(dir string, files map[string][]byte) error {
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", name, err)
+		}
+		mode := os.FileMode(0644)
+		if filepath.Ext(name) == ".key" {
+			mode = 0600
+		}
+		if err := os.WriteFile(path, data, mode); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", name, err)
+		}
+	}
+	return nil
+}
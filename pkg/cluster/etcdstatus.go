@@ -0,0 +1,169 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// Certificates kubeadm generates for stacked etcd
+const (
+	stackedEtcdCAFile   = "/etc/kubernetes/pki/etcd/ca.crt"
+	stackedEtcdCertFile = "/etc/kubernetes/pki/etcd/server.crt"
+	stackedEtcdKeyFile  = "/etc/kubernetes/pki/etcd/server.key"
+)
+
+// EtcdMemberStatus is the state of one etcd member as reported by etcdctl
+type EtcdMemberStatus struct {
+	Member      string
+	Version     string
+	DBSize      uint64
+	DBSizeInUse uint64
+	Leader      bool
+	Alarms      []string
+}
+
+// etcdEndpointStatus is one entry of `etcdctl endpoint status -w json`
+type etcdEndpointStatus struct {
+	Status struct {
+		Header struct {
+			MemberID uint64 `json:"member_id"`
+		} `json:"header"`
+		Version     string `json:"version"`
+		DBSize      uint64 `json:"dbSize"`
+		DBSizeInUse uint64 `json:"dbSizeInUse"`
+		Leader      uint64 `json:"leader"`
+	} `json:"Status"`
+}
+
+// EtcdStatus reports the database size and alarms of every etcd member of a
+// cluster, for both stacked and external etcd
+func EtcdStatus(name string) ([]EtcdMemberStatus, error) {
+	members, err := ListEtcdMembers(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []EtcdMemberStatus
+	if len(members) > 0 {
+		for _, member := range members {
+			if member.State != "running" {
+				return nil, fmt.Errorf("etcd member %s is not running", member.NodeName())
+			}
+			status, err := etcdMemberStatus(member.NodeName(), func(args ...string) (string, error) {
+				return podman.Exec(member.ID, etcdctl(args...))
+			})
+			if err != nil {
+				return nil, err
+			}
+			statuses = append(statuses, status)
+		}
+		return statuses, nil
+	}
+
+	// Stacked etcd runs as a static pod on the control-plane
+	controlPlane, err := GetControlPlaneNode(name)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := stackedEtcdPods(controlPlane.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		status, err := etcdMemberStatus(pod, func(args ...string) (string, error) {
+			return podman.Exec(controlPlane.ID, stackedEtcdctl(pod, args...))
+		})
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// stackedEtcdPods returns the names of the etcd static pods of a cluster
+func stackedEtcdPods(controlPlaneID string) ([]string, error) {
+	output, err := podman.Exec(controlPlaneID, []string{
+		"kubectl", "-n", "kube-system", "get", "pods", "-l", "component=etcd", "-o", "name",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list etcd pods: %w", err)
+	}
+	var pods []string
+	for _, pod := range strings.Fields(output) {
+		pods = append(pods, strings.TrimPrefix(pod, "pod/"))
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no etcd pods found")
+	}
+	return pods, nil
+}
+
+// stackedEtcdctl returns a command running etcdctl in a stacked etcd pod
+func stackedEtcdctl(pod string, args ...string) []string {
+	return append([]string{
+		"kubectl", "-n", "kube-system", "exec", pod, "--",
+		"etcdctl",
+		"--endpoints=https://127.0.0.1:2379",
+		"--cacert=" + stackedEtcdCAFile,
+		"--cert=" + stackedEtcdCertFile,
+		"--key=" + stackedEtcdKeyFile,
+	}, args...)
+}
+
+// etcdMemberStatus queries one member through run, which executes etcdctl
+// with the given arguments against that member
+func etcdMemberStatus(member string, run func(args ...string) (string, error)) (EtcdMemberStatus, error) {
+	status := EtcdMemberStatus{Member: member}
+
+	output, err := run("endpoint", "status", "-w", "json")
+	if err != nil {
+		return status, fmt.Errorf("failed to get status of etcd member %s: %w", member, err)
+	}
+	var endpoints []etcdEndpointStatus
+	if err := json.Unmarshal([]byte(output), &endpoints); err != nil || len(endpoints) == 0 {
+		return status, fmt.Errorf("failed to parse status of etcd member %s: %s", member, strings.TrimSpace(output))
+	}
+	endpoint := endpoints[0].Status
+	status.Version = endpoint.Version
+	status.DBSize = endpoint.DBSize
+	status.DBSizeInUse = endpoint.DBSizeInUse
+	status.Leader = endpoint.Leader == endpoint.Header.MemberID
+
+	output, err = run("alarm", "list")
+	if err != nil {
+		return status, fmt.Errorf("failed to list alarms of etcd member %s: %w", member, err)
+	}
+	status.Alarms = parseEtcdAlarms(output, endpoint.Header.MemberID)
+	return status, nil
+}
+
+// parseEtcdAlarms returns the alarms raised for memberID from `etcdctl alarm
+// list` output, which has lines like "memberID:123 alarm:NOSPACE"
+func parseEtcdAlarms(output string, memberID uint64) []string {
+	var alarms []string
+	for _, line := range strings.Split(output, "\n") {
+		var id, alarm string
+		for _, field := range strings.Fields(line) {
+			key, value, _ := strings.Cut(field, ":")
+			switch key {
+			case "memberID":
+				id = value
+			case "alarm":
+				alarm = value
+			}
+		}
+		if alarm == "" {
+			continue
+		}
+		if parsed, err := strconv.ParseUint(id, 10, 64); err == nil && parsed != memberID {
+			continue
+		}
+		alarms = append(alarms, alarm)
+	}
+	return alarms
+}
@@ -0,0 +1,401 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/crio"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// externalJoinConfig is where the join config is written on external machines
+const externalJoinConfig = "/tmp/kipod-join.yaml"
+
+// externalKubeletUnit runs the kubelet installed on an external machine
+const externalKubeletUnit = `[Unit]
+Description=kubelet: The Kubernetes Node Agent
+Documentation=https://kubernetes.io/docs/
+Wants=network-online.target crio.service
+After=network-online.target crio.service
+
+[Service]
+ExecStart=/usr/local/bin/kubelet
+Restart=always
+StartLimitInterval=0
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// externalKubeletDropin passes the flags kubeadm writes to the kubelet
+const externalKubeletDropin = `[Service]
+Environment="KUBELET_KUBECONFIG_ARGS=--bootstrap-kubeconfig=/etc/kubernetes/bootstrap-kubelet.conf --kubeconfig=/etc/kubernetes/kubelet.conf"
+Environment="KUBELET_CONFIG_ARGS=--config=/var/lib/kubelet/config.yaml"
+EnvironmentFile=-/var/lib/kubelet/kubeadm-flags.env
+EnvironmentFile=-/etc/sysconfig/kubelet
+ExecStart=
+ExecStart=/usr/local/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_CONFIG_ARGS $KUBELET_KUBEADM_ARGS $KUBELET_EXTRA_ARGS
+`
+
+// externalKubeletArgs are the kubelet flags of external machines, which use
+// systemd cgroups like CRI-O does by default outside of node containers
+const externalKubeletArgs = "KUBELET_EXTRA_ARGS=--container-runtime-endpoint=unix:///var/run/crio/crio.sock --cgroup-driver=systemd\n"
+
+// ExternalOptions configure joining a machine reached over SSH
+type ExternalOptions struct {
+	// SSH is the ssh destination, e.g. user@host
+	SSH string
+	// Identity is an ssh private key file
+	Identity string
+	// NodeName is the Kubernetes node name (default: the machine hostname)
+	NodeName string
+	// APIServerAddress is the address the machine reaches the host on
+	// (default: the client address of the ssh connection)
+	APIServerAddress string
+	// KubernetesVersion and CRIOVersion default to the versions the
+	// control-plane runs
+	KubernetesVersion string
+	CRIOVersion       string
+}
+
+// sshTarget runs scripts as root on a machine over ssh
+type sshTarget struct {
+	dest     string
+	identity string
+}
+
+// run runs a shell script as root, through sudo unless the ssh user is root
+func (t sshTarget) run(script string) (string, error) {
+	return t.ssh(`if [ "$(id -u)" -eq 0 ]; then exec sh -s; else exec sudo -n sh -s; fi`, "set -e\n"+script)
+}
+
+// query runs a command as the ssh user, whose environment sudo would reset
+func (t sshTarget) query(command string) (string, error) {
+	return t.ssh(command, "")
+}
+
+// ssh runs a remote command with stdin
+func (t sshTarget) ssh(command, stdin string) (string, error) {
+	args := []string{"-o", "BatchMode=yes"}
+	if t.identity != "" {
+		args = append(args, "-i", t.identity)
+	}
+	args = append(args, t.dest, command)
+
+	cmd := exec.Command("ssh", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ssh %s failed: %w\nStderr: %s", t.dest, err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// JoinExternal installs CRI-O, the kubelet and kubeadm on a machine over
+// SSH and joins it to a running cluster as a worker, for a node with a real
+// kernel next to the node containers (experimental). The machine must run
+// systemd, have curl, iptables and conntrack installed, and reach the API
+// server port published on the host. Traffic to the control-plane address
+// is redirected to the host with a NAT rule that does not survive a reboot.
+func JoinExternal(cfg *Config, opts ExternalOptions) error {
+	c, err := NewCluster(cfg)
+	if err != nil {
+		return err
+	}
+	// The machine reaches the advertised port on the host
+	if port := c.hostAPIServerPort(); port != apiServerPort {
+		return fmt.Errorf("external nodes need the API server published on host port %d, but cluster '%s' uses %d", apiServerPort, cfg.Name, port)
+	}
+	controlPlane, err := GetControlPlaneNode(cfg.Name)
+	if err != nil {
+		return err
+	}
+	target := sshTarget{dest: opts.SSH, identity: opts.Identity}
+
+	style.Step("Inspecting %s 🔍", opts.SSH)
+	facts, err := target.query(`echo "$(uname -m) $(hostname) ${SSH_CONNECTION%% *}"; command -v curl systemctl iptables >/dev/null`)
+	if err != nil {
+		return fmt.Errorf("%s is not reachable or lacks curl, systemd or iptables: %w", opts.SSH, err)
+	}
+	fields := strings.Fields(facts)
+	if len(fields) < 2 {
+		return fmt.Errorf("unexpected output from %s: %q", opts.SSH, facts)
+	}
+	arch, ok := map[string]string{"x86_64": "amd64", "aarch64": "arm64"}[fields[0]]
+	if !ok {
+		return fmt.Errorf("unsupported architecture %s on %s", fields[0], opts.SSH)
+	}
+	nodeName := opts.NodeName
+	if nodeName == "" {
+		nodeName = strings.ToLower(fields[1])
+	}
+	if !nodeNameRegexp.MatchString(nodeName) {
+		return fmt.Errorf("invalid node name %q, must be a lowercase DNS label; set one explicitly", nodeName)
+	}
+	hostAddress := opts.APIServerAddress
+	if hostAddress == "" && len(fields) > 2 {
+		hostAddress = fields[2]
+	}
+	if hostAddress == "" {
+		return fmt.Errorf("cannot tell the address %s reaches this host on; set it explicitly", opts.SSH)
+	}
+
+	k8sVersion, crioVersion, err := controlPlaneVersions(controlPlane.ID, opts)
+	if err != nil {
+		return err
+	}
+
+	style.Step("Installing CRI-O %s and Kubernetes %s on %s 📦", crioVersion, k8sVersion, opts.SSH)
+	if err := c.installExternal(target, controlPlane, arch, k8sVersion, crioVersion); err != nil {
+		return err
+	}
+
+	style.Step("Routing %s to the API server on %s 🔀", opts.SSH, hostAddress)
+	if err := routeExternalAPIServer(target, controlPlane.ID, hostAddress); err != nil {
+		return err
+	}
+
+	style.Step("Joining %s to cluster... 🔗", nodeName)
+	j, err := c.joinConfigFor(controlPlane.ID)
+	if err != nil {
+		return err
+	}
+	joinConfig := generateJoinConfig(j, nodeName, NodePool{Name: nodeName}, c.ignorePreflightErrors())
+	script := writeFileScript(externalJoinConfig, joinConfig) +
+		fmt.Sprintf("kubeadm join --config=%s\nrm -f %s\n", externalJoinConfig, externalJoinConfig)
+	output, err := target.run(script)
+	logPath := writeKubeadmLog(cfg.Name, nodeName, "kubeadm join --config="+externalJoinConfig, output, err)
+	if err != nil {
+		return kubeadmError("join", err, output, logPath)
+	}
+	if err := waitForNodeRegistration(controlPlane.ID, nodeName); err != nil {
+		return err
+	}
+
+	labelCmd := fmt.Sprintf("kubectl label node %s node-role.kubernetes.io/worker=", nodeName)
+	if _, err := podman.Exec(controlPlane.ID, []string{"sh", "-c", labelCmd}); err != nil {
+		style.Info("Warning: failed to label worker node %s: %v", nodeName, err)
+	}
+	return nil
+}
+
+// controlPlaneVersions returns the Kubernetes and CRI-O releases to install,
+// those of the control-plane unless set in opts
+func controlPlaneVersions(controlPlaneID string, opts ExternalOptions) (string, string, error) {
+	k8sVersion := strings.TrimPrefix(opts.KubernetesVersion, "v")
+	if k8sVersion == "" {
+		output, err := podman.Exec(controlPlaneID, []string{"kubeadm", "version", "-o", "short"})
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read the Kubernetes version: %w", err)
+		}
+		k8sVersion = strings.TrimPrefix(strings.TrimSpace(output), "v")
+	}
+
+	crioVersion := strings.TrimPrefix(opts.CRIOVersion, "v")
+	if crioVersion == "" {
+		output, err := podman.Exec(controlPlaneID, []string{"crio", "version"})
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read the CRI-O version: %w", err)
+		}
+		for _, line := range strings.Split(output, "\n") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(line), "Version:"); ok {
+				crioVersion = strings.TrimSpace(value)
+				break
+			}
+		}
+		// Builds from a branch have no release bundle
+		if crioVersion == "" || strings.Contains(crioVersion, "-") {
+			return "", "", fmt.Errorf("the control-plane runs CRI-O %q, which has no release bundle; set the CRI-O version explicitly", crioVersion)
+		}
+	}
+	return k8sVersion, crioVersion, nil
+}
+
+// installExternal installs the CRI-O release bundle, kubeadm and the kubelet
+// on an external machine with the cluster's CRI-O drop-ins and bridge CNI
+func (c *Cluster) installExternal(target sshTarget, controlPlane podman.Container, arch, k8sVersion, crioVersion string) error {
+	var sb strings.Builder
+	sb.WriteString("modprobe -a overlay br_netfilter\n")
+	for _, cmd := range crio.ConfigureSysctlCommands() {
+		sb.WriteString(shellJoin(cmd) + "\n")
+	}
+
+	bundle, err := externalArtifact("cri-o", crioVersion, arch)
+	if err != nil {
+		return err
+	}
+	sb.WriteString("rm -rf /tmp/kipod-cri-o && mkdir -p /tmp/kipod-cri-o\n")
+	sb.WriteString(bundle.FetchCommand("/tmp/kipod-cri-o/cri-o.tar.gz") + "\n")
+	sb.WriteString("tar -xzf /tmp/kipod-cri-o/cri-o.tar.gz -C /tmp/kipod-cri-o\n")
+	sb.WriteString("(cd /tmp/kipod-cri-o/cri-o && ./install)\nrm -rf /tmp/kipod-cri-o\n")
+
+	for _, name := range []string{"kubeadm", "kubelet"} {
+		artifact, err := externalArtifact(name, k8sVersion, arch)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(artifact.FetchCommand("/usr/local/bin/"+name) + "\n")
+		sb.WriteString("chmod 0755 /usr/local/bin/" + name + "\n")
+	}
+
+	files := map[string]string{
+		"/etc/systemd/system/kubelet.service":                   externalKubeletUnit,
+		"/etc/systemd/system/kubelet.service.d/10-kubeadm.conf": externalKubeletDropin,
+		"/etc/sysconfig/kubelet":                                externalKubeletArgs,
+		bridgeCNIConfig:                                         bridgeConflist(c.config.PodSubnet),
+	}
+	dropins, err := c.crioDropins(controlPlane)
+	if err != nil {
+		return err
+	}
+	for path, content := range dropins {
+		files[path] = content
+	}
+	for path, content := range c.signatureFiles() {
+		files[path] = content
+	}
+	for _, file := range sortedKeys(files) {
+		sb.WriteString(writeFileScript(file, files[file]))
+	}
+	if c.config.RegistryAuthNodes {
+		// Keep the credentials readable by root only from the start
+		sb.WriteString(fmt.Sprintf("(umask 077\nrm -f %s\n%s)\n", crioAuthFile, writeFileScript(crioAuthFile, c.config.RegistryAuth)))
+	}
+	sb.WriteString("systemctl daemon-reload\nsystemctl enable crio kubelet\nsystemctl restart crio\n")
+
+	if output, err := target.run(sb.String()); err != nil {
+		return fmt.Errorf("failed to install node components: %w\nOutput:\n%s", err, output)
+	}
+	return nil
+}
+
+// externalArtifact looks up an artifact to install on an external machine
+// and makes sure it will be verified there: a signed artifact's signature is
+// verified on this host, which also yields the checksum the machine checks
+// its download against, and an unpinned one is checked against the checksum
+// its source publishes. An artifact that stays unverified refuses the join.
+func externalArtifact(name, version, arch string) (build.Artifact, error) {
+	artifact, err := build.LookupArtifact(name, version, arch)
+	if err != nil {
+		return artifact, err
+	}
+	if artifact.Signature != "" {
+		style.Info("Verifying the signature of %s %s (%s)...", name, version, arch)
+		if err := artifact.VerifySignature(); err != nil {
+			return artifact, err
+		}
+	}
+	if err := artifact.ResolveChecksum(); err != nil {
+		return artifact, err
+	}
+	if !artifact.Verified() {
+		return artifact, fmt.Errorf("refusing to join with an unverified artifact: no sha256 pinned or published for %s %s (%s)", name, version, arch)
+	}
+	return artifact, nil
+}
+
+// routeExternalAPIServer makes an external machine reach the API server
+// under the address the cluster advertises, by resolving the control-plane
+// hostname or redirecting its IP to the port published on the host
+func routeExternalAPIServer(target sshTarget, controlPlaneID, hostAddress string) error {
+	output, err := podman.Exec(controlPlaneID, []string{
+		"kubectl", "-n", "kube-public", "get", "configmap", "cluster-info",
+		"-o", "jsonpath={.data.kubeconfig}",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read cluster-info: %w", err)
+	}
+	var server string
+	for _, line := range strings.Split(output, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "server:"); ok {
+			server = strings.TrimSpace(value)
+		}
+	}
+	endpoint, err := url.Parse(server)
+	if err != nil || endpoint.Hostname() == "" {
+		return fmt.Errorf("unexpected API server address %q in cluster-info", server)
+	}
+
+	host, port := endpoint.Hostname(), endpoint.Port()
+	if port == "" {
+		port = "443"
+	}
+	var script string
+	if net.ParseIP(host) == nil {
+		script = fmt.Sprintf("grep -q ' %[2]s$' /etc/hosts || echo '%[1]s %[2]s' >> /etc/hosts\n", hostAddress, host)
+	} else {
+		rule := fmt.Sprintf("OUTPUT -p tcp -d %s --dport %s -j DNAT --to-destination %s:%s", host, port, hostAddress, port)
+		script = fmt.Sprintf("iptables -t nat -C %[1]s 2>/dev/null || iptables -t nat -A %[1]s\n", rule)
+	}
+	if _, err := target.run(script); err != nil {
+		return fmt.Errorf("failed to route the API server: %w", err)
+	}
+	return nil
+}
+
+// writeFileScript returns a shell snippet writing content to path
+func writeFileScript(file, content string) string {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return fmt.Sprintf("mkdir -p %s\ncat > %s << 'KIPOD_EOF'\n%sKIPOD_EOF\n", path.Dir(file), file, content)
+}
+
+// shellJoin quotes a command for sh
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// bridgeConflist returns the bridge CNI config of the node image with the
+// pod subnet of the cluster, for external machines that lack it
+func bridgeConflist(podSubnet string) string {
+	return fmt.Sprintf(`{
+  "cniVersion": "1.0.0",
+  "name": "kipod-bridge",
+  "plugins": [
+    {
+      "type": "bridge",
+      "bridge": "cni0",
+      "isGateway": true,
+      "ipMasq": true,
+      "hairpinMode": true,
+      "ipam": {
+        "type": "host-local",
+        "routes": [
+          { "dst": "0.0.0.0/0" }
+        ],
+        "ranges": [
+          [{ "subnet": "%s" }]
+        ]
+      }
+    },
+    {
+      "type": "portmap",
+      "capabilities": {
+        "portMappings": true
+      }
+    },
+    {
+      "type": "firewall"
+    },
+    {
+      "type": "tuning"
+    }
+  ]
+}
+`, podSubnet)
+}
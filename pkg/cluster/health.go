@@ -0,0 +1,17 @@
+package cluster
+
+// DefaultRestartPolicy restarts node containers that exit with an error,
+// e.g. when systemd in the node crashes or the OOM killer hits it
+const DefaultRestartPolicy = "on-failure"
+
+const (
+	// nodeHealthCmd reports a node healthy once systemd finished booting and
+	// CRI-O answers; "degraded" only means some unit failed
+	nodeHealthCmd = `state=$(systemctl is-system-running); { [ "$state" = running ] || [ "$state" = degraded ]; } && crictl info >/dev/null`
+
+	defaultHealthcheckInterval = "30s"
+
+	// healthcheckStartPeriod covers the node boot, during which failed
+	// checks do not count
+	healthcheckStartPeriod = "2m"
+)
@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// HelmVersion is the Helm release installed on the control-plane to install
+// helmCharts
+const HelmVersion = "3.19.0"
+
+// HelmChart is a Helm release installed after the cluster is Ready
+type HelmChart struct {
+	Name      string
+	Namespace string
+	Repo      string
+	Chart     string
+	Version   string
+	// Values files, applied in order
+	Values []string
+}
+
+// installHelmCharts installs helm on the control-plane node when the image
+// does not ship it, then installs or upgrades every configured release,
+// waiting for its resources to be ready
+func (c *Cluster) installHelmCharts(controlPlaneID string) error {
+	remaining := remainingUntil(time.Now().Add(c.waitTimeout()))
+
+	if err := ensureHelm(controlPlaneID); err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "kipod-helm-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, chart := range c.config.HelmCharts {
+		style.Step("Installing %s (%s) ⎈", chart.Name, chart.Chart)
+
+		args := []string{
+			"helm", "upgrade", "--install", chart.Name, chart.Chart,
+			"--namespace", chart.Namespace, "--create-namespace", "--wait",
+		}
+		if chart.Repo != "" {
+			args = append(args, "--repo", chart.Repo)
+		}
+		if chart.Version != "" {
+			args = append(args, "--version", chart.Version)
+		}
+		var valuesFiles []string
+		for i, values := range chart.Values {
+			name := fmt.Sprintf("%s-%s-%d.yaml", chart.Namespace, chart.Name, i)
+			src := filepath.Join(tmpDir, name)
+			if err := os.WriteFile(src, []byte(values), 0600); err != nil {
+				return fmt.Errorf("failed to write values of %s: %w", chart.Name, err)
+			}
+			dest := "/tmp/kipod-values-" + name
+			if err := podman.CopyToContainer(controlPlaneID, src, dest); err != nil {
+				return err
+			}
+			valuesFiles = append(valuesFiles, dest)
+			args = append(args, "--values", dest)
+		}
+
+		args = append(args, "--timeout", remaining())
+		_, err := podman.Exec(controlPlaneID, args)
+		if len(valuesFiles) > 0 {
+			_, _ = podman.Exec(controlPlaneID, append([]string{"rm", "-f"}, valuesFiles...))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to install %s: %w", chart.Name, err)
+		}
+	}
+	return nil
+}
+
+// ensureHelm downloads helm to /usr/local/bin on the node unless it is
+// present. The tarball is verified against the sha256 pinned in the artifact
+// manifest; an unpinned release is downloaded with a warning, or refused when
+// build.RequireVerifiedEnv is set.
+func ensureHelm(controlPlaneID string) error {
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", "command -v helm"}); err == nil {
+		return nil
+	}
+
+	helm, err := build.LookupArtifact("helm", HelmVersion, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	if err := helm.Check(); err != nil {
+		return err
+	}
+	install := helm.FetchCommand("/tmp/helm.tar.gz") +
+		fmt.Sprintf(" && tar -C /usr/local/bin -xzf /tmp/helm.tar.gz --strip-components=1 linux-%s/helm && rm -f /tmp/helm.tar.gz", runtime.GOARCH)
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", install}); err != nil {
+		return fmt.Errorf("failed to install helm %s: %w", HelmVersion, err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// HostAliasName resolves to the host in nodes and, through CoreDNS, in pods
+const HostAliasName = "host.kipod.internal"
+
+// hostGatewayIP returns the address podman gives the host inside a node
+func hostGatewayIP(nodeID string) (string, error) {
+	output, err := podman.Exec(nodeID, []string{"getent", "ahostsv4", hostGatewayName})
+	fields := strings.Fields(output)
+	if err != nil || len(fields) == 0 {
+		return "", fmt.Errorf("nodes cannot resolve %s to reach the host (podman 4.1 or newer adds it)", hostGatewayName)
+	}
+	return fields[0], nil
+}
+
+// addHostAlias maps HostAliasName to the host in the /etc/hosts of a node.
+// Node images also do this in their entrypoint, which keeps the entry across
+// restarts, when podman rewrites /etc/hosts.
+func addHostAlias(nodeID string) (string, error) {
+	hostIP, err := hostGatewayIP(nodeID)
+	if err != nil {
+		return "", err
+	}
+	script := fmt.Sprintf("grep -q ' %[2]s$' /etc/hosts || echo '%[1]s %[2]s' >> /etc/hosts", hostIP, HostAliasName)
+	if _, err := podman.Exec(nodeID, []string{"sh", "-c", script}); err != nil {
+		return "", fmt.Errorf("failed to add %s to /etc/hosts: %w", HostAliasName, err)
+	}
+	return hostIP, nil
+}
+
+// addCoreDNSHostAlias makes CoreDNS answer HostAliasName with hostIP, so pods
+// resolve it like nodes do. The kubeadm Corefile reloads itself on change.
+func addCoreDNSHostAlias(controlPlaneID, hostIP string) error {
+	corefile, err := podman.Exec(controlPlaneID, []string{
+		"kubectl", "-n", "kube-system", "get", "configmap", "coredns", "-o", "jsonpath={.data.Corefile}",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read the CoreDNS config: %w", err)
+	}
+	if strings.Contains(corefile, HostAliasName) {
+		return nil
+	}
+
+	// Answer the alias in the root server block, before forwarding upstream
+	server := ".:53 {\n"
+	if !strings.Contains(corefile, server) {
+		return fmt.Errorf("unexpected CoreDNS config without a %q server block", strings.TrimSpace(server))
+	}
+	hosts := fmt.Sprintf("    hosts {\n       %s %s\n       fallthrough\n    }\n", hostIP, HostAliasName)
+	corefile = strings.Replace(corefile, server, server+hosts, 1)
+
+	patch, err := json.Marshal(map[string]map[string]string{"data": {"Corefile": corefile}})
+	if err != nil {
+		return err
+	}
+	if _, err := podman.Exec(controlPlaneID, []string{
+		"kubectl", "-n", "kube-system", "patch", "configmap", "coredns", "--type", "merge", "-p", string(patch),
+	}); err != nil {
+		return fmt.Errorf("failed to update the CoreDNS config: %w", err)
+	}
+	return nil
+}
+
+// setupHostAlias resolves HostAliasName on the control-plane node and in
+// pods; failures only warn since the cluster works without it
+func (c *Cluster) setupHostAlias(controlPlaneID string) {
+	hostIP, err := addHostAlias(controlPlaneID)
+	if err != nil {
+		style.Info("Warning: %v", err)
+		return
+	}
+	if c.skipsPhase("addon/coredns") {
+		return
+	}
+	if err := addCoreDNSHostAlias(controlPlaneID, hostIP); err != nil {
+		style.Info("Warning: pods cannot resolve %s: %v", HostAliasName, err)
+	}
+}
@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// kubeletExtraArgs returns the kubelet flags of the config that the node
+// entrypoint appends to the kubelet arguments on every boot
+func (c *Cluster) kubeletExtraArgs() []string {
+	var args []string
+	// The flag adds to the gates the entrypoint sets
+	if gates := c.kubeletFeatureGates(); len(gates) > 0 {
+		args = append(args, "--feature-gates="+strings.Join(gates, ","))
+	}
+	return args
+}
+
+// kubeletConfiguration returns the KubeletConfiguration document of the
+// kubeadm config, for settings whose kubelet flags are deprecated or
+// missing, or "" if there are none. kubeadm uploads it to the cluster, so
+// joining nodes get it too.
+func (c *Cluster) kubeletConfiguration() string {
+	var sb strings.Builder
+	if c.config.ImageGCHighThresholdPercent > 0 {
+		sb.WriteString(fmt.Sprintf("imageGCHighThresholdPercent: %d\n", c.config.ImageGCHighThresholdPercent))
+	}
+	if c.config.ImageGCLowThresholdPercent > 0 {
+		sb.WriteString(fmt.Sprintf("imageGCLowThresholdPercent: %d\n", c.config.ImageGCLowThresholdPercent))
+	}
+	if c.config.ImageMinimumGCAge != "" {
+		sb.WriteString(fmt.Sprintf("imageMinimumGCAge: %s\n", c.config.ImageMinimumGCAge))
+	}
+	if limit := c.podPidsLimit(); limit > 0 {
+		sb.WriteString(fmt.Sprintf("podPidsLimit: %d\n", limit))
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\n" + sb.String()
+}
+
+// ImagePrune is the result of pruning the images of a node
+type ImagePrune struct {
+	// Removed are the images crictl deleted
+	Removed []string
+	// Freed is the image storage released, in bytes
+	Freed uint64
+}
+
+// PruneImages removes the images no container uses from a running node with
+// crictl rmi --prune, releasing tmpfs-backed storage between iterations
+// without waiting for the kubelet's garbage collection
+func PruneImages(containerID string) (ImagePrune, error) {
+	var result ImagePrune
+
+	before, err := NodeStorageUsage(containerID)
+	if err != nil {
+		return result, err
+	}
+	output, err := podman.Exec(containerID, []string{"crictl", "rmi", "--prune"})
+	if err != nil {
+		return result, fmt.Errorf("crictl rmi --prune failed: %w", err)
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if image, ok := strings.CutPrefix(strings.TrimSpace(line), "Deleted: "); ok {
+			result.Removed = append(result.Removed, image)
+		}
+	}
+
+	after, err := NodeStorageUsage(containerID)
+	if err != nil {
+		return result, err
+	}
+	if before.Used > after.Used {
+		result.Freed = before.Used - after.Used
+	}
+	return result, nil
+}
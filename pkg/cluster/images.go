@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// kubeadmPatchesDir holds the kubeadm patches kipod writes on the control-plane
+const kubeadmPatchesDir = "/etc/kubernetes/kipod-patches"
+
+// writeKubeadmPatches writes a strategic merge patch per overridden static pod
+// image. kubeadm has no per-component image settings for the control-plane
+// besides the shared repository, but applies these patches to the manifests
+// it generates.
+func (c *Cluster) writeKubeadmPatches(containerID string) error {
+	if len(c.config.ComponentImages) == 0 {
+		return nil
+	}
+
+	var script string
+	for _, pod := range sortedKeys(c.config.ComponentImages) {
+		image := c.config.ComponentImages[pod]
+		style.Info("Using %s image %s", pod, image)
+		patch := fmt.Sprintf("spec:\n  containers:\n  - name: %s\n    image: %s\n", pod, image)
+		script += fmt.Sprintf("cat > %s/%s+strategic.yaml << 'KIPOD_EOF'\n%sKIPOD_EOF\n", kubeadmPatchesDir, pod, patch)
+	}
+	script = fmt.Sprintf("mkdir -p %s\n", kubeadmPatchesDir) + script
+	if _, err := podman.Exec(containerID, []string{"sh", "-c", script}); err != nil {
+		return fmt.Errorf("failed to write kubeadm patches: %w", err)
+	}
+	return nil
+}
+
+// crioPauseImageConf is the CRI-O drop-in pointing the pause image at the
+// configured image repository
+const crioPauseImageConf = "/etc/crio/crio.conf.d/98-kipod-pause-image.conf"
+
+// usesImageRepositories reports whether images are pulled from mirrors of
+// registry.k8s.io
+func (c *Cluster) usesImageRepositories() bool {
+	return c.config.ImageRepository != "" || c.config.DNSImageRepository != "" || c.config.DNSImageTag != "" ||
+		c.config.EtcdImageRepository != "" || c.config.EtcdImageTag != ""
+}
+
+// writeImageFields writes the imageRepository and imageTag of a kubeadm
+// image block
+func writeImageFields(sb *strings.Builder, indent, repository, tag string) {
+	if repository != "" {
+		sb.WriteString(fmt.Sprintf("%simageRepository: %s\n", indent, repository))
+	}
+	if tag != "" {
+		sb.WriteString(fmt.Sprintf("%simageTag: %s\n", indent, tag))
+	}
+}
+
+// configurePauseImage makes CRI-O pull the pause image from the configured
+// image repository, which kubeadm does not manage. The tag is the one the
+// node's kubeadm release expects.
+func (c *Cluster) configurePauseImage(containerID string) error {
+	if c.config.ImageRepository == "" {
+		return nil
+	}
+
+	image, err := pauseImage(containerID, c.config.ImageRepository)
+	if err != nil {
+		return err
+	}
+
+	conf := fmt.Sprintf("[crio.image]\npause_image = %q\n", image)
+	cmd := fmt.Sprintf("cat > %s << 'KIPOD_EOF'\n%sKIPOD_EOF", crioPauseImageConf, conf)
+	if _, err := podman.Exec(containerID, []string{"sh", "-c", cmd}); err != nil {
+		return fmt.Errorf("failed to set the pause image: %w", err)
+	}
+	if _, err := podman.Exec(containerID, []string{"systemctl", "restart", "crio"}); err != nil {
+		return fmt.Errorf("failed to restart CRI-O: %w", err)
+	}
+	return waitForCRIO(containerID)
+}
+
+// pauseImage returns the pause image the kubeadm release of a node expects,
+// from repository or kubeadm's default one
+func pauseImage(containerID, repository string) (string, error) {
+	list := `kubeadm config images list --kubernetes-version "$(kubeadm version -o short)"`
+	if repository != "" {
+		list += " --image-repository " + repository
+	}
+	output, err := podman.Exec(containerID, []string{"sh", "-c", list + " | grep '/pause:'"})
+	if err != nil {
+		return "", fmt.Errorf("failed to determine the pause image: %w", err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// externalEtcdImage returns the image of external etcd members
+func (c *Cluster) externalEtcdImage() string {
+	if c.config.EtcdImage != "" {
+		return c.config.EtcdImage
+	}
+	repository, tag, _ := strings.Cut(DefaultEtcdImage, ":")
+	repository = path.Dir(repository)
+	if c.config.ImageRepository != "" {
+		repository = c.config.ImageRepository
+	}
+	if c.config.EtcdImageRepository != "" {
+		repository = c.config.EtcdImageRepository
+	}
+	if c.config.EtcdImageTag != "" {
+		tag = c.config.EtcdImageTag
+	}
+	return fmt.Sprintf("%s/etcd:%s", repository, tag)
+}
@@ -0,0 +1,218 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+const (
+	// joinTokenTTL is the lifetime of bootstrap tokens created for joins
+	joinTokenTTL = 30 * time.Minute
+
+	// joinTokenRefreshMargin renews a token this long before it expires, so a
+	// slow node never starts a join with a token about to lapse
+	joinTokenRefreshMargin = 5 * time.Minute
+
+	// maxJoinAttempts is how often a worker join is tried before giving up
+	maxJoinAttempts = 3
+
+	// nodeRegistrationTimeout bounds the wait for a joined node to appear in the API
+	nodeRegistrationTimeout = 2 * time.Minute
+)
+
+var (
+	tokenPattern  = regexp.MustCompile(`^[a-z0-9]{6}\.[a-z0-9]{16}$`)
+	caHashPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// joinConfig holds the bootstrap token discovery details for worker joins
+type joinConfig struct {
+	Endpoint     string
+	Token        string
+	CACertHashes []string
+	Created      time.Time
+}
+
+// expired reports whether the token should be renewed before the next join
+func (j *joinConfig) expired() bool {
+	return time.Since(j.Created) > joinTokenTTL-joinTokenRefreshMargin
+}
+
+// parseJoinCommand extracts the join details from the output of
+// `kubeadm token create --print-join-command`, skipping any warning lines
+func parseJoinCommand(output string) (*joinConfig, error) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "kubeadm" || fields[1] != "join" {
+			continue
+		}
+
+		j := &joinConfig{Endpoint: fields[2]}
+		for i := 3; i < len(fields)-1; i++ {
+			switch fields[i] {
+			case "--token":
+				j.Token = fields[i+1]
+			case "--discovery-token-ca-cert-hash":
+				j.CACertHashes = append(j.CACertHashes, fields[i+1])
+			}
+		}
+
+		if !tokenPattern.MatchString(j.Token) {
+			return nil, fmt.Errorf("invalid bootstrap token in join command: %q", j.Token)
+		}
+		if len(j.CACertHashes) == 0 {
+			return nil, fmt.Errorf("join command has no CA certificate hash")
+		}
+		for _, hash := range j.CACertHashes {
+			if !caHashPattern.MatchString(hash) {
+				return nil, fmt.Errorf("invalid CA certificate hash in join command: %q", hash)
+			}
+		}
+		return j, nil
+	}
+	return nil, fmt.Errorf("no kubeadm join command found in output:\n%s", output)
+}
+
+// caCertHash computes the kubeadm discovery hash of the cluster CA, i.e. the
+// SHA-256 of its DER-encoded Subject Public Key Info
+func caCertHash(controlPlaneID string) (string, error) {
+	data, err := podman.Exec(controlPlaneID, []string{"cat", "/etc/kubernetes/pki/ca.crt"})
+	if err != nil {
+		return "", fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return "", fmt.Errorf("cluster CA is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse cluster CA: %w", err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// joinConfigFor returns valid join details, creating a new token when none
+// exists yet or the current one is close to expiring
+func (c *Cluster) joinConfigFor(controlPlaneID string) (*joinConfig, error) {
+	if c.join != nil && !c.join.expired() {
+		return c.join, nil
+	}
+
+	cmd := fmt.Sprintf("kubeadm token create --print-join-command --ttl %s", joinTokenTTL)
+	output, err := podman.Exec(controlPlaneID, []string{"sh", "-c", cmd})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate join token: %w", err)
+	}
+	j, err := parseJoinCommand(output)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure workers will pin the CA this control-plane actually serves
+	expected, err := caCertHash(controlPlaneID)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, hash := range j.CACertHashes {
+		if hash == expected {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("join command CA hash %v does not match cluster CA %s", j.CACertHashes, expected)
+	}
+
+	j.Created = time.Now()
+	c.join = j
+	return j, nil
+}
+
+// generateJoinConfig generates a kubeadm JoinConfiguration YAML
+func generateJoinConfig(j *joinConfig, nodeName string, pool NodePool, ignorePreflightErrors []string) string {
+	var sb strings.Builder
+
+	sb.WriteString("apiVersion: kubeadm.k8s.io/v1beta3\n")
+	sb.WriteString("kind: JoinConfiguration\n")
+	sb.WriteString("discovery:\n")
+	sb.WriteString("  bootstrapToken:\n")
+	sb.WriteString(fmt.Sprintf("    apiServerEndpoint: %s\n", j.Endpoint))
+	sb.WriteString(fmt.Sprintf("    token: %s\n", j.Token))
+	sb.WriteString("    caCertHashes:\n")
+	for _, hash := range j.CACertHashes {
+		sb.WriteString(fmt.Sprintf("    - %s\n", hash))
+	}
+	sb.WriteString("nodeRegistration:\n")
+	sb.WriteString(fmt.Sprintf("  name: %s\n", nodeName))
+	sb.WriteString("  criSocket: unix:///var/run/crio/crio.sock\n")
+	if len(ignorePreflightErrors) > 0 {
+		sb.WriteString("  ignorePreflightErrors:\n")
+		for _, check := range ignorePreflightErrors {
+			sb.WriteString(fmt.Sprintf("  - %s\n", check))
+		}
+	}
+	writeNodeRegistration(&sb, pool.Labels, pool.Taints)
+
+	return sb.String()
+}
+
+// joinWorker joins a worker with retries and waits until it is registered
+func (c *Cluster) joinWorker(controlPlaneID, workerID, nodeName string, pool NodePool) error {
+	var lastErr error
+	backoff := 5 * time.Second
+
+	for attempt := 1; attempt <= maxJoinAttempts; attempt++ {
+		if attempt > 1 {
+			style.Info("Join of %s failed, retrying in %s (attempt %d/%d)", nodeName, backoff, attempt, maxJoinAttempts)
+			time.Sleep(backoff)
+			backoff *= 2
+
+			// Undo the partial join so kubeadm preflight passes again
+			if _, err := podman.Exec(workerID, []string{"kubeadm", "reset", "-f"}); err != nil {
+				style.Info("Warning: kubeadm reset on %s failed: %v", nodeName, err)
+			}
+		}
+
+		j, err := c.joinConfigFor(controlPlaneID)
+		if err != nil {
+			return err
+		}
+
+		writeConfigCmd := fmt.Sprintf("cat > /tmp/kubeadm-join.yaml << 'KUBEADM_EOF'\n%s\nKUBEADM_EOF", generateJoinConfig(j, nodeName, pool, c.ignorePreflightErrors()))
+		if _, err := podman.Exec(workerID, []string{"sh", "-c", writeConfigCmd}); err != nil {
+			return fmt.Errorf("failed to write join config: %w", err)
+		}
+
+		if err := c.runKubeadm(workerID, nodeName, []string{"kubeadm", "join", "--config=/tmp/kubeadm-join.yaml", "--v=5"}); err != nil {
+			lastErr = err
+			continue
+		}
+
+		return waitForNodeRegistration(controlPlaneID, nodeName)
+	}
+
+	return lastErr
+}
+
+// waitForNodeRegistration waits until the node object exists in the API server
+func waitForNodeRegistration(controlPlaneID, nodeName string) error {
+	deadline := time.Now().Add(nodeRegistrationTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "node", nodeName}); err == nil {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("node %s joined but did not register with the API server within %s", nodeName, nodeRegistrationTimeout)
+}
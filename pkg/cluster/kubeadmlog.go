@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/paths"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+const (
+	// keepKubeadmLogs is how many earlier kubeadm logs of a node are kept
+	// next to the latest, as <node>-kubeadm.log.1 (newest) and up
+	keepKubeadmLogs = 3
+
+	// kubeadmErrorLines is how much of the kubeadm output error messages
+	// include when the full output is in a log
+	kubeadmErrorLines = 30
+)
+
+// kubeadmLogPath returns where the output of the last kubeadm init or join
+// on a node is kept: <cache>/clusters/<cluster>/<node>-kubeadm.log
+func kubeadmLogPath(clusterName, nodeName string) string {
+	return filepath.Join(paths.CacheDir(), "clusters", clusterName, nodeName+"-kubeadm.log")
+}
+
+// runKubeadm runs kubeadm on a node and keeps its stdout and stderr in the
+// kubeadm log of the node. Errors end with the tail of the output and the
+// path of the log.
+func (c *Cluster) runKubeadm(containerID, nodeName string, args []string) error {
+	var output bytes.Buffer
+	err := podman.ExecStream(containerID, args, &output, &output)
+	logPath := writeKubeadmLog(c.config.Name, nodeName, strings.Join(args, " "), output.String(), err)
+	if err != nil {
+		return kubeadmError(args[1], err, output.String(), logPath)
+	}
+	return nil
+}
+
+// writeKubeadmLog rotates the kubeadm logs of a node and writes the output
+// of a kubeadm run as the latest, with secrets redacted and readable by the
+// user only. It returns the path of the log, or "" when
+// it cannot be written; the log only helps debugging, so that is not an error.
+func writeKubeadmLog(clusterName, nodeName, command, output string, runErr error) string {
+	path := kubeadmLogPath(clusterName, nodeName)
+	if !filepath.IsAbs(path) {
+		return ""
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return ""
+	}
+	// Tighten directories created by earlier versions
+	if err := os.Chmod(dir, 0700); err != nil {
+		return ""
+	}
+	for i := keepKubeadmLogs; i > 0; i-- {
+		older := fmt.Sprintf("%s.%d", path, i)
+		newer := path
+		if i > 1 {
+			newer = fmt.Sprintf("%s.%d", path, i-1)
+		}
+		os.Rename(newer, older)
+	}
+
+	status := "succeeded"
+	if runErr != nil {
+		status = fmt.Sprintf("failed: %v", runErr)
+	}
+	content := fmt.Sprintf("# %s on %s at %s\n%s# %s\n", command, nodeName, time.Now().Format(time.RFC3339), output, status)
+	if err := os.WriteFile(path, []byte(podman.RedactSecrets(content)), 0600); err != nil {
+		return ""
+	}
+	return path
+}
+
+// kubeadmError formats a failed kubeadm run, with the last lines of its
+// output when the full output is in logPath
+func kubeadmError(subcommand string, err error, output, logPath string) error {
+	output = podman.RedactSecrets(output)
+	if logPath == "" {
+		return fmt.Errorf("kubeadm %s failed: %w\nOutput:\n%s", subcommand, err, output)
+	}
+	if strings.TrimSpace(output) == "" {
+		return fmt.Errorf("kubeadm %s failed: %w\nFull output: %s", subcommand, err, logPath)
+	}
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > kubeadmErrorLines {
+		lines = lines[len(lines)-kubeadmErrorLines:]
+	}
+	return fmt.Errorf("kubeadm %s failed: %w\nOutput (last %d lines):\n%s\nFull output: %s", subcommand, err, len(lines), strings.Join(lines, "\n"), logPath)
+}
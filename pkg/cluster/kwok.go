@@ -0,0 +1,183 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+const (
+	// DefaultKwokVersion is the kwok release the kwok addon installs
+	DefaultKwokVersion = "0.6.1"
+
+	// FakeNodeLabel marks the simulated nodes kipod registers
+	FakeNodeLabel = "kipod.io/fake-node"
+
+	// fakeNodeTaint keeps workloads off simulated nodes unless they tolerate it
+	fakeNodeTaint = "kwok.x-k8s.io/node"
+
+	// fakeNodeBatch bounds the nodes applied at once, to keep the manifest
+	// below the size limit of a single exec argument
+	fakeNodeBatch = 100
+)
+
+// installKwok deploys the kwok controller with its fast stages, which make
+// fake nodes Ready and pods on them Running right away, then registers the
+// configured number of fake nodes
+func (c *Cluster) installKwok(controlPlaneID string) error {
+	style.Step("Installing kwok %s with %d fake node(s) 🪄", c.config.KwokVersion, c.config.FakeNodes)
+	if err := deployKwok(controlPlaneID, c.config.KwokVersion, remainingUntil(time.Now().Add(c.waitTimeout()))); err != nil {
+		return err
+	}
+	return scaleFakeNodes(controlPlaneID, c.config.Name, c.config.FakeNodes)
+}
+
+// ScaleFakeNodes registers or removes simulated nodes until the cluster has
+// count of them, deploying kwok first when the cluster has no kwok controller
+func ScaleFakeNodes(name string, count int, version string) error {
+	controlPlane, err := GetControlPlaneNode(name)
+	if err != nil {
+		return err
+	}
+	if _, err := podman.Exec(controlPlane.ID, []string{"kubectl", "-n", "kube-system", "get", "deployment", "kwok-controller"}); err != nil {
+		style.Step("Installing kwok %s 🪄", version)
+		if err := deployKwok(controlPlane.ID, version, remainingUntil(time.Now().Add(defaultWaitTimeout))); err != nil {
+			return err
+		}
+	}
+	return scaleFakeNodes(controlPlane.ID, name, count)
+}
+
+// deployKwok downloads and applies the kwok controller and stage manifests,
+// verified against the sha256 pinned in the artifact manifest
+func deployKwok(controlPlaneID, version string, remaining func() string) error {
+	// Check both before applying either, so a release refused as unpinned
+	// changes nothing
+	var artifacts []build.Artifact
+	for _, name := range []string{"kwok", "kwok-stage-fast"} {
+		artifact, err := build.LookupArtifact(name, version, runtime.GOARCH)
+		if err != nil {
+			return err
+		}
+		if err := artifact.Check(); err != nil {
+			return err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+
+	for _, artifact := range artifacts {
+		name := artifact.Name
+		path := "/tmp/kipod-" + name + ".yaml"
+		if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", artifact.FetchCommand(path)}); err != nil {
+			return fmt.Errorf("failed to download %s %s: %w", name, version, err)
+		}
+		err := applyManifestFile(controlPlaneID, path, remaining)
+		_, _ = podman.Exec(controlPlaneID, []string{"rm", "-f", path})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// scaleFakeNodes registers the fake nodes <cluster>-fake-0 to count-1 and
+// deletes fake nodes beyond them
+func scaleFakeNodes(controlPlaneID, name string, count int) error {
+	output, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "nodes", "-l", FakeNodeLabel + "=true", "-o", "name"})
+	if err != nil {
+		return fmt.Errorf("failed to list fake nodes: %w", err)
+	}
+	existing := make(map[string]bool)
+	for _, node := range strings.Fields(output) {
+		existing[strings.TrimPrefix(node, "node/")] = true
+	}
+
+	wanted := make(map[string]bool)
+	var missing []string
+	for i := 0; i < count; i++ {
+		nodeName := fmt.Sprintf("%s-fake-%d", name, i)
+		wanted[nodeName] = true
+		if !existing[nodeName] {
+			missing = append(missing, nodeName)
+		}
+	}
+	for start := 0; start < len(missing); start += fakeNodeBatch {
+		end := min(start+fakeNodeBatch, len(missing))
+		if err := applyFakeNodes(controlPlaneID, missing[start:end]); err != nil {
+			return err
+		}
+	}
+
+	var extra []string
+	for node := range existing {
+		if !wanted[node] {
+			extra = append(extra, node)
+		}
+	}
+	sort.Strings(extra)
+	for start := 0; start < len(extra); start += fakeNodeBatch {
+		end := min(start+fakeNodeBatch, len(extra))
+		args := append([]string{"kubectl", "delete", "node", "--wait=false"}, extra[start:end]...)
+		if _, err := podman.Exec(controlPlaneID, args); err != nil {
+			return fmt.Errorf("failed to delete fake nodes: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyFakeNodes registers Node objects kwok takes over, tainted so only
+// pods tolerating kwok.x-k8s.io/node=fake:NoSchedule land on them
+func applyFakeNodes(controlPlaneID string, names []string) error {
+	resources := map[string]string{"cpu": "32", "memory": "256Gi", "pods": "110"}
+	items := make([]map[string]interface{}, 0, len(names))
+	for _, nodeName := range names {
+		items = append(items, map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Node",
+			"metadata": map[string]interface{}{
+				"name":        nodeName,
+				"annotations": map[string]string{"kwok.x-k8s.io/node": "fake", "node.alpha.kubernetes.io/ttl": "0"},
+				"labels": map[string]string{
+					FakeNodeLabel:            "true",
+					"type":                   "kwok",
+					"kubernetes.io/arch":     runtime.GOARCH,
+					"kubernetes.io/os":       "linux",
+					"kubernetes.io/hostname": nodeName,
+				},
+			},
+			"spec": map[string]interface{}{
+				"taints": []map[string]string{{"key": fakeNodeTaint, "value": "fake", "effect": "NoSchedule"}},
+			},
+			// Nodes may set their status on create
+			"status": map[string]interface{}{
+				"allocatable": resources,
+				"capacity":    resources,
+				"nodeInfo": map[string]string{
+					"architecture":    runtime.GOARCH,
+					"kubeletVersion":  "fake",
+					"operatingSystem": "linux",
+				},
+			},
+		})
+	}
+
+	manifest, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode fake nodes: %w", err)
+	}
+	if err := applyManifest(controlPlaneID, string(manifest)); err != nil {
+		return fmt.Errorf("failed to register fake nodes: %w", err)
+	}
+	return nil
+}
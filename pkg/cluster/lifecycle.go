@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// startTimeout bounds the wait for the API server of a started cluster
+const startTimeout = 3 * time.Minute
+
+// networksMu serializes the cleanup of networks shared by clusters
+var networksMu sync.Mutex
+
+// Stop stops the containers of a cluster, workers first and external etcd
+// last, keeping their storage so Start brings the cluster back
+func Stop(name string) error {
+	containers, err := podman.ListContainers(map[string]string{podman.LabelCluster: name})
+	if err != nil {
+		return fmt.Errorf("failed to list cluster containers: %w", err)
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("cluster '%s' not found", name)
+	}
+
+	sortByStartOrder(containers)
+	for i := len(containers) - 1; i >= 0; i-- {
+		container := containers[i]
+		if container.State != "running" {
+			continue
+		}
+		if err := podman.StopContainer(container.ID); err != nil {
+			return fmt.Errorf("failed to stop node %s: %w", container.Name, err)
+		}
+		style.Info("Stopped node: %s", container.Name)
+	}
+	return nil
+}
+
+// Start starts the stopped containers of a cluster, external etcd first and
+// workers last, waits for the API server to answer and resyncs node clocks
+// that fell behind while the cluster was stopped. When podman gave the
+// control-plane a new IP, the control-plane and the kubelets are moved to it
+// as Repair does.
+func Start(name string) error {
+	if err := startEtcd(name); err != nil {
+		return err
+	}
+	nodes, err := ListNodes(name)
+	if err != nil {
+		return err
+	}
+
+	sortByStartOrder(nodes)
+	var controlPlane *podman.Container
+	for i, node := range nodes {
+		if node.Labels[podman.LabelRole] == "control-plane" && controlPlane == nil {
+			controlPlane = &nodes[i]
+		}
+		if node.State == "running" {
+			continue
+		}
+		if err := podman.StartContainer(node.ID); err != nil {
+			return fmt.Errorf("failed to start node %s: %w", node.NodeName(), err)
+		}
+		style.Info("Started node: %s", node.NodeName())
+	}
+	if controlPlane == nil {
+		return fmt.Errorf("cluster '%s' has no control-plane node", name)
+	}
+
+	oldIP, newIP, err := controlPlaneIPs(*controlPlane)
+	if err != nil {
+		return err
+	}
+	moved := oldIP != newIP
+	c := &Cluster{config: &Config{Name: name}}
+	if moved {
+		if err := c.waitForServices(controlPlane.ID); err != nil {
+			return fmt.Errorf("node %s is unhealthy: %w", controlPlane.NodeName(), err)
+		}
+		style.Step("Moving the control-plane from %s to %s 🔧", oldIP, newIP)
+		if err := moveControlPlane(controlPlane.ID, oldIP, newIP); err != nil {
+			return err
+		}
+	}
+
+	if err := waitForAPIServerTimeout(controlPlane.ID, startTimeout); err != nil {
+		return fmt.Errorf("cluster '%s' did not come back: %w", name, err)
+	}
+
+	if moved {
+		if err := moveEndpoints(controlPlane.ID, oldIP, newIP); err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			if node.ID == controlPlane.ID {
+				continue
+			}
+			if err := c.waitForServices(node.ID); err != nil {
+				return fmt.Errorf("node %s is unhealthy: %w", node.NodeName(), err)
+			}
+			if err := restartKubelet(node, oldIP, newIP); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Clocks of podman machine VMs stop while the host sleeps
+	checkClockSkew(name)
+	return nil
+}
+
+// sortByStartOrder orders containers as they must start: etcd members,
+// control-planes, then workers, each by name
+func sortByStartOrder(containers []podman.Container) {
+	rank := func(c podman.Container) int {
+		switch c.Labels[podman.LabelRole] {
+		case RoleEtcd:
+			return 0
+		case "control-plane":
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		if ri, rj := rank(containers[i]), rank(containers[j]); ri != rj {
+			return ri < rj
+		}
+		return containers[i].Name < containers[j].Name
+	})
+}
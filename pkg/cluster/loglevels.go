@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// crioLogLevelConf is the CRI-O drop-in holding the configured log level
+const crioLogLevelConf = "/etc/crio/crio.conf.d/99-kipod-log-level.conf"
+
+// applyLogLevels sets the configured component log levels on a running node
+// and restarts the affected services. It is idempotent, so nodes can be
+// reconfigured any time.
+func (c *Cluster) applyLogLevels(containerID string) error {
+	if level, ok := c.config.LogLevels["crio"]; ok {
+		conf := fmt.Sprintf("[crio.runtime]\nlog_level = %q\n", level)
+		cmd := fmt.Sprintf("cat > %s << 'KIPOD_EOF'\n%sKIPOD_EOF", crioLogLevelConf, conf)
+		if _, err := podman.Exec(containerID, []string{"sh", "-c", cmd}); err != nil {
+			return fmt.Errorf("failed to set CRI-O log level: %w", err)
+		}
+		if _, err := podman.Exec(containerID, []string{"systemctl", "restart", "crio"}); err != nil {
+			return fmt.Errorf("failed to restart CRI-O: %w", err)
+		}
+		if err := waitForCRIO(containerID); err != nil {
+			return err
+		}
+	}
+
+	if level, ok := c.config.LogLevels["kubelet"]; ok {
+		return setKubeletVerbosity(containerID, level)
+	}
+
+	return nil
+}
+
+// kubeletSysconfig holds the kubelet flags the entrypoint writes on boot
+const kubeletSysconfig = "/etc/sysconfig/kubelet"
+
+// kubeletVerbosityFile keeps the kubelet log level across restarts: the
+// entrypoint adds it as --v when it rewrites kubeletSysconfig
+const kubeletVerbosityFile = "/etc/kipod/kubelet-verbosity"
+
+// kubeletVerbosity returns the --v flag set on the kubelet of a node, or ""
+func kubeletVerbosity(containerID string) (string, error) {
+	cmd := fmt.Sprintf(`sed -n -E 's/^KUBELET_EXTRA_ARGS=.* --v=([0-9]+).*/\1/p' %s`, kubeletSysconfig)
+	output, err := podman.Exec(containerID, []string{"sh", "-c", cmd})
+	if err != nil {
+		return "", fmt.Errorf("failed to read kubelet flags: %w", err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// setKubeletVerbosity replaces the --v flag of the kubelet, or removes it
+// when level is empty, and restarts the kubelet. The level is kept in
+// kubeletVerbosityFile so it survives the node restarting.
+func setKubeletVerbosity(containerID, level string) error {
+	// Replace any previous verbosity instead of appending another one
+	script := `s/ --v=[0-9]+//g`
+	persist := "rm -f " + kubeletVerbosityFile
+	if level != "" {
+		script += fmt.Sprintf("; s/$/ --v=%s/", level)
+		persist = fmt.Sprintf("mkdir -p /etc/kipod && echo %s > %s", level, kubeletVerbosityFile)
+	}
+	cmd := fmt.Sprintf(`sed -i -E '/^KUBELET_EXTRA_ARGS=/ { %s }' %s && %s`, script, kubeletSysconfig, persist)
+	if _, err := podman.Exec(containerID, []string{"sh", "-c", cmd}); err != nil {
+		return fmt.Errorf("failed to set kubelet log level: %w", err)
+	}
+	// kubelet may still be waiting for kubeadm; restarting it is harmless
+	if _, err := podman.Exec(containerID, []string{"systemctl", "restart", "kubelet"}); err != nil {
+		return fmt.Errorf("failed to restart kubelet: %w", err)
+	}
+	return nil
+}
+
+// waitForCRIO waits until CRI-O answers on its socket again after a restart
+func waitForCRIO(containerID string) error {
+	for i := 0; i < 30; i++ {
+		if _, err := podman.Exec(containerID, []string{"crictl", "info"}); err == nil {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	logs, _ := podman.Exec(containerID, []string{"journalctl", "-u", "crio", "-n", "50", "--no-pager"})
+	return fmt.Errorf("CRI-O did not come back after restart. Logs:\n%s", logs)
+}
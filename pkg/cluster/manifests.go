@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// manifestFieldManager owns the fields of server-side applied manifests
+const manifestFieldManager = "kipod"
+
+// Manifest is a Kubernetes manifest applied after the cluster is Ready
+type Manifest struct {
+	// Source is the path or URL the manifest was read from, for messages
+	Source  string
+	Content string
+}
+
+// rolloutKinds are the workload kinds whose rollout is awaited after apply
+var rolloutKinds = map[string]bool{
+	"Deployment":  true,
+	"DaemonSet":   true,
+	"StatefulSet": true,
+}
+
+// applyPostCreateManifests waits for the nodes to be Ready, then applies the
+// post-create manifests in order with server-side apply. Each manifest's CRDs
+// must be established and its workloads rolled out before the next one is
+// applied, so later manifests can rely on earlier ones.
+func (c *Cluster) applyPostCreateManifests(controlPlaneID string) error {
+	timeout := c.waitTimeout()
+	remaining := remainingUntil(time.Now().Add(timeout))
+
+	if _, err := podman.Exec(controlPlaneID, []string{"kubectl", "wait", "--for=condition=Ready", "nodes", "--all", "--timeout=" + remaining()}); err != nil {
+		return fmt.Errorf("nodes not Ready within %s: %w", timeout, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "kipod-manifests-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for i, manifest := range c.config.PostCreateManifests {
+		style.Step("Applying %s 📜", manifest.Source)
+
+		name := fmt.Sprintf("%02d.yaml", i)
+		src := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(src, []byte(manifest.Content), 0600); err != nil {
+			return fmt.Errorf("failed to write manifest %s: %w", manifest.Source, err)
+		}
+		dest := "/tmp/kipod-manifest-" + name
+		if err := podman.CopyToContainer(controlPlaneID, src, dest); err != nil {
+			return err
+		}
+
+		err := applyManifestFile(controlPlaneID, dest, remaining)
+		_, _ = podman.Exec(controlPlaneID, []string{"rm", "-f", dest})
+		if err != nil {
+			return fmt.Errorf("%s: %w", manifest.Source, err)
+		}
+	}
+	return nil
+}
+
+// remainingUntil returns a function formatting the time left until deadline
+// as a kubectl --timeout value, at least one second
+func remainingUntil(deadline time.Time) func() string {
+	return func() string {
+		left := time.Until(deadline).Round(time.Second)
+		if left <= 0 {
+			left = time.Second
+		}
+		return left.String()
+	}
+}
+
+// applyManifestFile server-side applies a manifest file on the control-plane
+// node and waits for it with waitForManifest
+func applyManifestFile(controlPlaneID, path string, remaining func() string) error {
+	if _, err := podman.Exec(controlPlaneID, []string{
+		"kubectl", "apply", "--server-side", "--field-manager=" + manifestFieldManager, "-f", path,
+	}); err != nil {
+		return fmt.Errorf("failed to apply: %w", err)
+	}
+	if err := waitForManifest(controlPlaneID, path, remaining); err != nil {
+		return fmt.Errorf("not ready in time: %w", err)
+	}
+	return nil
+}
+
+// waitForManifest waits until the CRDs of an applied manifest are established
+// and its Deployments, DaemonSets and StatefulSets are rolled out
+func waitForManifest(controlPlaneID, path string, remaining func() string) error {
+	output, err := podman.Exec(controlPlaneID, []string{
+		"kubectl", "get", "-f", path, "--no-headers",
+		"-o", "custom-columns=KIND:.kind,NAMESPACE:.metadata.namespace,NAME:.metadata.name",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list applied objects: %w", err)
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		kind, namespace, name := fields[0], fields[1], fields[2]
+
+		var args []string
+		switch {
+		case kind == "CustomResourceDefinition":
+			args = []string{"kubectl", "wait", "--for=condition=Established", "crd/" + name}
+		case rolloutKinds[kind]:
+			args = []string{"kubectl", "rollout", "status", strings.ToLower(kind) + "/" + name, "-n", namespace}
+		default:
+			continue
+		}
+		if _, err := podman.Exec(controlPlaneID, append(args, "--timeout="+remaining())); err != nil {
+			return fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+	}
+	return nil
+}
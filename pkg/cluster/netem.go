@@ -0,0 +1,232 @@
+package cluster
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+const (
+	// netemRulesPath records the impairments of a node, one per line:
+	// device, peer IP, peer name and the netem parameters
+	netemRulesPath = "/etc/kipod/netem"
+
+	// netemFirstBand is the first prio band used for impaired peers; bands
+	// 1-3 carry the other traffic like the default pfifo_fast qdisc does
+	netemFirstBand = 4
+
+	// netemMaxBands is the most bands a prio qdisc supports
+	netemMaxBands = 16
+)
+
+// netemPriomap maps packet priorities to bands 1-3 (0-2), as pfifo_fast does
+const netemPriomap = "1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1"
+
+var netemRateRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(bit|kbit|mbit|gbit|bps|kbps|mbps|gbps)$`)
+
+// NetemOptions describe how traffic between two nodes is impaired
+type NetemOptions struct {
+	Latency time.Duration
+	Jitter  time.Duration
+	// Loss is the percentage of packets dropped
+	Loss float64
+	// Rate limits bandwidth, in tc units like 10mbit
+	Rate string
+}
+
+// Validate checks that the options impair something and are in range
+func (o NetemOptions) Validate() error {
+	if o.Latency < 0 || o.Jitter < 0 {
+		return fmt.Errorf("latency and jitter cannot be negative")
+	}
+	if o.Jitter > 0 && o.Latency == 0 {
+		return fmt.Errorf("jitter requires a latency")
+	}
+	if o.Loss < 0 || o.Loss > 100 {
+		return fmt.Errorf("loss must be a percentage between 0 and 100, got: %g", o.Loss)
+	}
+	if o.Rate != "" && !netemRateRegexp.MatchString(o.Rate) {
+		return fmt.Errorf("rate must be a tc rate like 10mbit or 500kbit, got: %s", o.Rate)
+	}
+	if o.Latency == 0 && o.Loss == 0 && o.Rate == "" {
+		return fmt.Errorf("set at least one of latency, loss or rate")
+	}
+	return nil
+}
+
+// args returns the tc netem parameters
+func (o NetemOptions) args() []string {
+	var args []string
+	if o.Latency > 0 {
+		args = append(args, "delay", fmt.Sprintf("%dus", o.Latency.Microseconds()))
+		if o.Jitter > 0 {
+			args = append(args, fmt.Sprintf("%dus", o.Jitter.Microseconds()))
+		}
+	}
+	if o.Loss > 0 {
+		args = append(args, "loss", strconv.FormatFloat(o.Loss, 'f', -1, 64)+"%")
+	}
+	if o.Rate != "" {
+		args = append(args, "rate", o.Rate)
+	}
+	return args
+}
+
+// NetemRule is the impairment of the traffic a node sends to a peer
+type NetemRule struct {
+	Device string
+	PeerIP string
+	Peer   string
+	Netem  string // tc netem parameters
+}
+
+// SetNetem impairs the traffic between two nodes in both directions,
+// replacing any earlier impairment between them
+func SetNetem(a, b podman.Container, opts NetemOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	for _, side := range [][2]podman.Container{{a, b}, {b, a}} {
+		node, peer := side[0], side[1]
+		peerIP, err := nodeIP(peer)
+		if err != nil {
+			return err
+		}
+		device, err := routeDevice(node, peerIP)
+		if err != nil {
+			return err
+		}
+		rules, err := NetemRules(node)
+		if err != nil {
+			return err
+		}
+		rules = removeNetemRules(rules, peer.NodeName())
+		rules = append(rules, NetemRule{Device: device, PeerIP: peerIP, Peer: peer.NodeName(), Netem: strings.Join(opts.args(), " ")})
+		if err := applyNetemRules(node, rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearNetem removes the impairments of a node towards peer, or all of them
+// when peer is empty, and of the peer towards the node
+func ClearNetem(node podman.Container, peer *podman.Container) error {
+	if peer == nil {
+		return applyNetemRules(node, nil)
+	}
+	rules, err := NetemRules(node)
+	if err != nil {
+		return err
+	}
+	if err := applyNetemRules(node, removeNetemRules(rules, peer.NodeName())); err != nil {
+		return err
+	}
+	peerRules, err := NetemRules(*peer)
+	if err != nil {
+		return err
+	}
+	return applyNetemRules(*peer, removeNetemRules(peerRules, node.NodeName()))
+}
+
+// NetemRules returns the impairments recorded in a node
+func NetemRules(node podman.Container) ([]NetemRule, error) {
+	output, err := podman.Exec(node.ID, []string{"sh", "-c", "cat " + netemRulesPath + " 2>/dev/null || true"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the netem rules of %s: %w", node.NodeName(), err)
+	}
+	var rules []NetemRule
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		rules = append(rules, NetemRule{Device: fields[0], PeerIP: fields[1], Peer: fields[2], Netem: strings.Join(fields[3:], " ")})
+	}
+	return rules, nil
+}
+
+// removeNetemRules returns rules without those towards peer
+func removeNetemRules(rules []NetemRule, peer string) []NetemRule {
+	var kept []NetemRule
+	for _, rule := range rules {
+		if rule.Peer != peer {
+			kept = append(kept, rule)
+		}
+	}
+	return kept
+}
+
+// applyNetemRules rebuilds the qdiscs of a node from rules: on each device a
+// prio qdisc whose extra bands hold one netem qdisc per peer, selected by a
+// destination filter. Devices that had rules before are reset.
+func applyNetemRules(node podman.Container, rules []NetemRule) error {
+	previous, err := NetemRules(node)
+	if err != nil {
+		return err
+	}
+
+	byDevice := make(map[string][]NetemRule)
+	for _, rule := range rules {
+		byDevice[rule.Device] = append(byDevice[rule.Device], rule)
+	}
+	devices := make(map[string]bool)
+	for _, rule := range append(previous, rules...) {
+		devices[rule.Device] = true
+	}
+	names := make([]string, 0, len(devices))
+	for device := range devices {
+		names = append(names, device)
+	}
+	sort.Strings(names)
+
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	for _, device := range names {
+		fmt.Fprintf(&script, "tc qdisc del dev %s root 2>/dev/null || true\n", device)
+		deviceRules := byDevice[device]
+		if len(deviceRules) == 0 {
+			continue
+		}
+		if netemFirstBand+len(deviceRules)-1 > netemMaxBands {
+			return fmt.Errorf("node %s can impair traffic to at most %d peers per interface", node.NodeName(), netemMaxBands-netemFirstBand+1)
+		}
+		fmt.Fprintf(&script, "tc qdisc add dev %s root handle 1: prio bands %d priomap %s\n", device, netemMaxBands, netemPriomap)
+		for i, rule := range deviceRules {
+			band := netemFirstBand + i
+			fmt.Fprintf(&script, "tc qdisc add dev %s parent 1:%x handle %x0: netem %s\n", device, band, band, rule.Netem)
+			fmt.Fprintf(&script, "tc filter add dev %s parent 1:0 protocol ip prio 1 u32 match ip dst %s/32 flowid 1:%x\n", device, rule.PeerIP, band)
+		}
+	}
+
+	var lines []string
+	for _, rule := range rules {
+		lines = append(lines, fmt.Sprintf("%s %s %s %s", rule.Device, rule.PeerIP, rule.Peer, rule.Netem))
+	}
+	fmt.Fprintf(&script, "mkdir -p /etc/kipod && cat > %s << 'KIPOD_EOF'\n%s\nKIPOD_EOF\n", netemRulesPath, strings.Join(lines, "\n"))
+
+	if output, err := podman.Exec(node.ID, []string{"sh", "-c", script.String()}); err != nil {
+		return fmt.Errorf("failed to configure tc on %s (the host kernel needs the sch_netem module): %w\nOutput:\n%s", node.NodeName(), err, output)
+	}
+	return nil
+}
+
+// routeDevice returns the interface a node reaches ip through
+func routeDevice(node podman.Container, ip string) (string, error) {
+	output, err := podman.Exec(node.ID, []string{"ip", "-o", "route", "get", ip})
+	if err != nil {
+		return "", fmt.Errorf("failed to find the route from %s to %s: %w", node.NodeName(), ip, err)
+	}
+	fields := strings.Fields(output)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "dev" {
+			return fields[i+1], nil
+		}
+	}
+	return "", fmt.Errorf("no route from %s to %s", node.NodeName(), ip)
+}
@@ -0,0 +1,121 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// DefaultNetwork is the podman network shared by cluster nodes
+const DefaultNetwork = "kipod"
+
+// network returns the podman network the nodes of the cluster are attached to
+func (c *Cluster) network() string {
+	if c.config.Network != "" {
+		return c.config.Network
+	}
+	return DefaultNetwork
+}
+
+// nodeNetwork returns the podman network a node was created on, which
+// nodes of older releases do not record: they are on DefaultNetwork
+func nodeNetwork(node podman.Container) string {
+	if network := node.Labels[podman.LabelNetwork]; network != "" {
+		return network
+	}
+	return DefaultNetwork
+}
+
+// ensureNetwork creates a kipod-managed network unless it exists
+func ensureNetwork(name string) error {
+	exists, err := podman.NetworkExists(name)
+	if err != nil {
+		return fmt.Errorf("failed to check network existence: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	style.Step("Preparing network 🌐")
+	if err := podman.CreateNetwork(name, map[string]string{podman.LabelManaged: "true"}); err != nil {
+		return fmt.Errorf("failed to create network: %w", err)
+	}
+	return nil
+}
+
+// managedNetworks returns the networks kipod created, which it labels
+func managedNetworks() ([]string, error) {
+	return podman.ListNetworks(map[string]string{podman.LabelManaged: "true"})
+}
+
+// legacyNetwork reports whether network is the shared network of a release
+// that did not label it: an unlabeled DefaultNetwork only kipod containers
+// are attached to. A network the user created is never legacy. It must be
+// checked while the containers of the cluster still exist.
+func legacyNetwork(network string) (bool, error) {
+	if network != DefaultNetwork {
+		return false, nil
+	}
+	managed, err := managedNetworks()
+	if err != nil {
+		return false, err
+	}
+	if containsString(managed, network) {
+		return false, nil
+	}
+	users, err := podman.NetworkContainers(network)
+	if err != nil || len(users) == 0 {
+		return false, err
+	}
+	containers, err := podman.ListContainers(map[string]string{podman.LabelCluster: ""})
+	if err != nil {
+		return false, err
+	}
+	kipod := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		kipod[container.ID] = true
+	}
+	for _, user := range users {
+		if !kipod[user] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// deleteUnusedNetworks deletes the kipod-managed networks among candidates
+// (all of them when candidates is nil), and the legacy networks found by
+// legacyNetwork, that no container of any cluster is attached to, and
+// returns their names
+func deleteUnusedNetworks(candidates map[string]bool, legacy ...string) ([]string, error) {
+	networks, err := managedNetworks()
+	if err != nil {
+		return nil, err
+	}
+	networks = append(networks, legacy...)
+
+	var deleted []string
+	for _, network := range networks {
+		if candidates != nil && !candidates[network] {
+			continue
+		}
+		users, err := podman.NetworkContainers(network)
+		if err != nil {
+			return deleted, err
+		}
+		if len(users) > 0 {
+			continue
+		}
+		if err := podman.DeleteNetwork(network); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, network)
+	}
+	return deleted, nil
+}
+
+// PruneNetworks deletes kipod-managed networks no container uses and
+// returns their names
+func PruneNetworks() ([]string, error) {
+	return deleteUnusedNetworks(nil)
+}
@@ -0,0 +1,126 @@
+package cluster
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// nodeNameRegexp matches node names usable as hostnames and Kubernetes names
+var nodeNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// CreateNodeOptions customize a node created outside the pools of a cluster
+type CreateNodeOptions struct {
+	// Name names the node <cluster>-<name>, its hostname and Kubernetes
+	// node name, like the nodes of pools
+	Name string
+	// Image replaces the node image of the cluster
+	Image string
+	// Volumes are extra podman --volume values
+	Volumes []string
+}
+
+// JoinOptions configure how a node container is joined to a cluster
+type JoinOptions struct {
+	// NodeName is the Kubernetes node name (default: the container hostname)
+	NodeName string
+	// Labels and Taints are set when the node registers
+	Labels map[string]string
+	Taints []Taint
+}
+
+// CreateNode creates a worker node container for a running cluster with the
+// options of the cluster's nodes, without joining it, so it can be
+// customized before kubeadm join runs. It returns the container name.
+func CreateNode(cfg *Config, opts CreateNodeOptions) (string, error) {
+	c, err := NewCluster(cfg)
+	if err != nil {
+		return "", err
+	}
+	// Prefixed with the cluster, so clusters can have nodes of the same name
+	nodeName := fmt.Sprintf("%s-%s", cfg.Name, opts.Name)
+	if opts.Name == "" || !nodeNameRegexp.MatchString(nodeName) {
+		return "", fmt.Errorf("invalid node name %q, %s must be a lowercase DNS label", opts.Name, nodeName)
+	}
+	if _, err := GetControlPlaneNode(cfg.Name); err != nil {
+		return "", err
+	}
+
+	containerOpts := c.createContainerOptions(nodeName, "worker")
+	if opts.Image != "" {
+		containerOpts.Image = opts.Image
+	}
+	containerOpts.Volumes = append(containerOpts.Volumes, opts.Volumes...)
+	if _, err := c.createNodeContainer(containerOpts, nodeName, "worker"); err != nil {
+		return "", fmt.Errorf("failed to create node %s: %w", nodeName, err)
+	}
+	return containerName(nodeName), nil
+}
+
+// JoinNode sets up CRI-O on a node container, whether created by CreateNode
+// or by the user from a kipod node image, and joins it to a running cluster
+// as a worker with a fresh bootstrap token. Containers not on the cluster
+// network are attached to it first.
+func JoinNode(cfg *Config, container string, opts JoinOptions) error {
+	c, err := NewCluster(cfg)
+	if err != nil {
+		return err
+	}
+	controlPlane, err := GetControlPlaneNode(cfg.Name)
+	if err != nil {
+		return err
+	}
+
+	details, err := podman.InspectContainers([]string{container})
+	if err != nil {
+		return fmt.Errorf("container %s not found: %w", container, err)
+	}
+	if len(details) == 0 {
+		return fmt.Errorf("container %s not found", container)
+	}
+	id := details[0].ID
+
+	if network := c.network(); !containsString(details[0].Networks, network) {
+		style.Step("Connecting %s to %s", container, network)
+		if err := podman.ConnectNetwork(network, id); err != nil {
+			return err
+		}
+	}
+
+	nodeName := opts.NodeName
+	if nodeName == "" {
+		hostname, err := podman.Exec(id, []string{"hostname"})
+		if err != nil {
+			return fmt.Errorf("failed to read the hostname of %s: %w", container, err)
+		}
+		nodeName = strings.TrimSpace(hostname)
+	}
+	if !nodeNameRegexp.MatchString(nodeName) {
+		return fmt.Errorf("invalid node name %q, must be a lowercase DNS label; set one explicitly", nodeName)
+	}
+
+	if !c.isClusterNode(id) {
+		style.Info("Warning: %s has no %s=%s label, so kipod commands such as delete cluster do not manage it", container, podman.LabelCluster, cfg.Name)
+	}
+
+	pool := NodePool{Name: nodeName, Labels: opts.Labels, Taints: opts.Taints}
+	return c.setupWorker(controlPlane.ID, id, nodeName, nodeName, pool)
+}
+
+// isClusterNode reports whether the container with the full ID id is
+// labelled as a node of the cluster
+func (c *Cluster) isClusterNode(id string) bool {
+	nodes, err := ListNodes(c.config.Name)
+	if err != nil {
+		return false
+	}
+	for _, node := range nodes {
+		if strings.HasPrefix(id, node.ID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// CurrentOwner returns who the clusters created now belong to:
+// $KIPOD_OWNER, for teams sharing one account or podman socket, else the
+// login name of the user
+func CurrentOwner() string {
+	if owner := os.Getenv("KIPOD_OWNER"); owner != "" {
+		return owner
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Owners returns the user who created each cluster, from the labels of its
+// node containers. Clusters created before kipod recorded owners are
+// missing.
+func Owners() (map[string]string, error) {
+	containers, err := podman.ListContainers(map[string]string{
+		podman.LabelCluster: "",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	owners := make(map[string]string)
+	for _, container := range containers {
+		name, owner := container.Labels[podman.LabelCluster], container.Labels[podman.LabelOwner]
+		if name != "" && owner != "" {
+			owners[name] = owner
+		}
+	}
+	return owners, nil
+}
+
+// CheckOwner refuses to operate on a cluster another user created, unless
+// force is set. Clusters without a recorded owner belong to everyone.
+func CheckOwner(name string, force bool) error {
+	return CheckOwnerFlag(name, force, "--force")
+}
+
+// CheckOwnerFlag is CheckOwner for commands that take another flag than
+// --force to operate on clusters of other users
+func CheckOwnerFlag(name string, force bool, flag string) error {
+	if force {
+		return nil
+	}
+	owners, err := Owners()
+	if err != nil {
+		return err
+	}
+	if owner := owners[name]; owner != "" && owner != CurrentOwner() {
+		return fmt.Errorf("cluster %q belongs to %s; pass %s to operate on it anyway", name, owner, flag)
+	}
+	return nil
+}
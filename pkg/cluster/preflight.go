@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"strings"
+)
+
+// DefaultIgnorePreflightErrors are the kubeadm preflight checks that fail
+// inside node containers without indicating a real problem
+var DefaultIgnorePreflightErrors = []string{
+	"NumCPU",
+	"Mem",
+	"SystemVerification",
+	"FileContent--proc-sys-net-bridge-bridge-nf-call-iptables",
+}
+
+// ignorePreflightErrors returns the preflight checks kubeadm init and join ignore
+func (c *Cluster) ignorePreflightErrors() []string {
+	if c.config.StrictPreflight {
+		return nil
+	}
+	if c.config.IgnorePreflightErrors != nil {
+		return c.config.IgnorePreflightErrors
+	}
+	return DefaultIgnorePreflightErrors
+}
+
+// kubeadmInitArgs returns the flags added to every kubeadm init: ignored
+// preflight checks, skipped phases and user supplied extra arguments
+func (c *Cluster) kubeadmInitArgs() []string {
+	var args []string
+	if ignore := c.ignorePreflightErrors(); len(ignore) > 0 {
+		args = append(args, "--ignore-preflight-errors="+strings.Join(ignore, ","))
+	}
+	if len(c.config.SkipPhases) > 0 {
+		args = append(args, "--skip-phases="+strings.Join(c.config.SkipPhases, ","))
+	}
+	args = append(args, c.config.ExtraInitArgs...)
+	return append(args, "--v=5")
+}
+
+// skipsPhase reports whether kubeadm init skips a phase, either directly or
+// through its parent phase
+func (c *Cluster) skipsPhase(phase string) bool {
+	for _, skipped := range c.config.SkipPhases {
+		if skipped == phase || strings.HasPrefix(phase, skipped+"/") || skipped == strings.Split(phase, "/")[0]+"/all" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+const (
+	// crioAuthFile holds the registry credentials on nodes
+	crioAuthFile = "/etc/crio/kipod-auth.json"
+
+	// crioAuthConf makes CRI-O pull every image with crioAuthFile
+	crioAuthConf = "/etc/crio/crio.conf.d/97-kipod-registry-auth.conf"
+
+	// pullSecretName is the image pull secret kipod creates in each namespace
+	pullSecretName = "kipod-registry-auth"
+)
+
+// registryAuthConf is the content of crioAuthConf
+var registryAuthConf = fmt.Sprintf("[crio.image]\nglobal_auth_file = %q\n", crioAuthFile)
+
+// installRegistryAuth copies the registry credentials and the drop-in
+// pointing CRI-O at them into a node that has not been started yet
+func (c *Cluster) installRegistryAuth(containerID string) error {
+	if err := writeNodeFile(containerID, crioAuthFile, c.config.RegistryAuth, 0600); err != nil {
+		return err
+	}
+	return writeNodeFile(containerID, crioAuthConf, registryAuthConf, 0644)
+}
+
+// syncRegistryAuth brings the registry credentials of a running node in line
+// with the config. CRI-O reads the file on every pull, so only changes to
+// crioAuthConf need a restart.
+func (c *Cluster) syncRegistryAuth(containerID string) error {
+	installed, err := podman.Exec(containerID, []string{"cat", crioAuthFile})
+	exists := err == nil
+	switch {
+	case c.config.RegistryAuthNodes && (!exists || installed != c.config.RegistryAuth):
+		return writeNodeFile(containerID, crioAuthFile, c.config.RegistryAuth, 0600)
+	case !c.config.RegistryAuthNodes && exists:
+		if _, err := podman.Exec(containerID, []string{"rm", "-f", crioAuthFile}); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", crioAuthFile, err)
+		}
+	}
+	return nil
+}
+
+// installPullSecret creates or updates the pull secret in every namespace
+// and adds it to the imagePullSecrets of the namespace's default service
+// account, keeping the secrets already listed there. Namespaces created
+// later are not covered; kipod apply adds them.
+func (c *Cluster) installPullSecret(controlPlaneID string) error {
+	style.Step("Adding image pull secret %s to default service accounts 🔑", pullSecretName)
+
+	const authPath = "/tmp/kipod-registry-auth.json"
+	if err := writeNodeFile(controlPlaneID, authPath, c.config.RegistryAuth, 0600); err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`set -e
+trap 'rm -f %[1]s' EXIT
+for ns in $(kubectl get namespaces -o jsonpath='{.items[*].metadata.name}'); do
+  kubectl create secret docker-registry %[2]s -n "$ns" --from-file=.dockerconfigjson=%[1]s --dry-run=client -o yaml | kubectl apply -f - >/dev/null
+  # The service account controller may not have created it in a new namespace yet
+  secrets=$(kubectl get serviceaccount default -n "$ns" -o jsonpath='{.imagePullSecrets[*].name}' 2>/dev/null) || { echo "no default service account in $ns" >&2; continue; }
+  case " $secrets " in
+  *" %[2]s "*) ;;
+  "  ") kubectl patch serviceaccount default -n "$ns" -p '{"imagePullSecrets":[{"name":"%[2]s"}]}' >/dev/null ;;
+  *) kubectl patch serviceaccount default -n "$ns" --type=json -p '[{"op":"add","path":"/imagePullSecrets/-","value":{"name":"%[2]s"}}]' >/dev/null ;;
+  esac
+done`, authPath, pullSecretName)
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", script}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// reloadTarget describes where a component binary lives inside a node and
+// which systemd service needs to be restarted to pick up a new build
+type reloadTarget struct {
+	Path    string
+	Service string
+}
+
+// reloadTargets maps component names to their location inside the node image.
+// OCI runtimes have no service of their own; new containers pick them up directly.
+var reloadTargets = map[string]reloadTarget{
+	"crio":    {Path: "/usr/local/bin/crio", Service: "crio"},
+	"crun":    {Path: "/usr/bin/crun"},
+	"runc":    {Path: "/usr/bin/runc"},
+	"kubelet": {Path: "/usr/bin/kubelet", Service: "kubelet"},
+}
+
+// nodeServices are the services reported after a reload
+var nodeServices = []string{"crio", "kubelet"}
+
+// ReloadComponents returns the component names that can be reloaded
+func ReloadComponents() []string {
+	components := make([]string, 0, len(reloadTargets))
+	for name := range reloadTargets {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+	return components
+}
+
+// ValidateReloadComponent checks that a component can be reloaded
+func ValidateReloadComponent(component string) error {
+	if _, ok := reloadTargets[component]; !ok {
+		return fmt.Errorf("unknown component %q (supported: %s)", component, strings.Join(ReloadComponents(), ", "))
+	}
+	return nil
+}
+
+// ReloadBinaries copies locally built binaries into every node of a cluster
+// and restarts the services that run them
+func ReloadBinaries(name string, binaries map[string]string) error {
+	nodes, err := ListNodes(name)
+	if err != nil {
+		return err
+	}
+
+	components := make([]string, 0, len(binaries))
+	for component := range binaries {
+		if err := ValidateReloadComponent(component); err != nil {
+			return err
+		}
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, node := range nodes {
+		for _, component := range components {
+			target := reloadTargets[component]
+			style.Info("Reloading %s on %s...", component, node.NodeName())
+
+			// Stop the service first so the running binary can be replaced
+			if target.Service != "" {
+				if _, err := podman.Exec(node.ID, []string{"systemctl", "stop", target.Service}); err != nil {
+					return fmt.Errorf("failed to stop %s on %s: %w", target.Service, node.NodeName(), err)
+				}
+			}
+
+			if err := podman.CopyToContainer(node.ID, binaries[component], target.Path); err != nil {
+				return fmt.Errorf("failed to install %s on %s: %w", component, node.NodeName(), err)
+			}
+			if _, err := podman.Exec(node.ID, []string{"chmod", "0755", target.Path}); err != nil {
+				return fmt.Errorf("failed to make %s executable on %s: %w", component, node.NodeName(), err)
+			}
+
+			if target.Service != "" {
+				if _, err := podman.Exec(node.ID, []string{"systemctl", "start", target.Service}); err != nil {
+					logs, _ := podman.Exec(node.ID, []string{"journalctl", "-u", target.Service, "-n", "50", "--no-pager"})
+					return fmt.Errorf("failed to start %s on %s: %w\nLogs:\n%s", target.Service, node.NodeName(), err, logs)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// NodeServiceStatus holds the systemd state of the kipod services on a node
+type NodeServiceStatus struct {
+	Node     string
+	Services map[string]string
+}
+
+// GetServiceStatus reports the state of CRI-O and kubelet on every node
+func GetServiceStatus(name string) ([]NodeServiceStatus, error) {
+	nodes, err := ListNodes(name)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]NodeServiceStatus, 0, len(nodes))
+	for _, node := range nodes {
+		status := NodeServiceStatus{
+			Node:     node.NodeName(),
+			Services: make(map[string]string),
+		}
+		for _, service := range nodeServices {
+			// is-active exits non-zero for any unit that is not running
+			state := "active"
+			if _, err := podman.Exec(node.ID, []string{"systemctl", "is-active", service}); err != nil {
+				state = "inactive"
+			}
+			status.Services[service] = state
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Node < statuses[j].Node
+	})
+
+	return statuses, nil
+}
@@ -0,0 +1,252 @@
+package cluster
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// endpointConfigMaps carry the control-plane address for in-cluster clients
+var endpointConfigMaps = []string{"kube-system/kube-proxy", "kube-public/cluster-info", "kube-system/kubeadm-config"}
+
+// Repair brings a cluster back to health after its node containers were
+// restarted, e.g. by a host reboot or the OOM killer, without recreating it.
+// It starts stopped nodes and, when podman gave the control-plane a new IP,
+// moves the static pods, serving certificates, kubeconfigs and endpoint
+// configmaps to it. It then restarts every kubelet, so nodes report their
+// current IPs, and verifies that each node reaches the API server.
+func Repair(name string) error {
+	c := &Cluster{config: &Config{Name: name}}
+
+	nodes, err := ListNodes(name)
+	if err != nil {
+		return err
+	}
+	var controlPlane *podman.Container
+	for i := range nodes {
+		if nodes[i].Labels[podman.LabelRole] == "control-plane" {
+			controlPlane = &nodes[i]
+			break
+		}
+	}
+	if controlPlane == nil {
+		return fmt.Errorf("cluster '%s' has no control-plane node", name)
+	}
+
+	// External etcd must be serving before the API server can come back
+	if err := startEtcd(name); err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if node.State != "running" {
+			style.Step("Starting stopped node %s", node.NodeName())
+			if err := podman.StartContainer(node.ID); err != nil {
+				return fmt.Errorf("failed to start node %s: %w", node.NodeName(), err)
+			}
+		}
+		if err := c.waitForServices(node.ID); err != nil {
+			return fmt.Errorf("node %s is unhealthy: %w", node.NodeName(), err)
+		}
+		if _, err := addHostAlias(node.ID); err != nil {
+			style.Info("Warning: %v", err)
+		}
+	}
+
+	oldIP, newIP, err := controlPlaneIPs(*controlPlane)
+	if err != nil {
+		return err
+	}
+	moved := oldIP != newIP
+	if moved {
+		style.Step("Moving the control-plane from %s to %s 🔧", oldIP, newIP)
+		if err := moveControlPlane(controlPlane.ID, oldIP, newIP); err != nil {
+			return err
+		}
+	} else if _, err := podman.Exec(controlPlane.ID, []string{"systemctl", "restart", "kubelet"}); err != nil {
+		return fmt.Errorf("failed to restart kubelet on %s: %w", controlPlane.NodeName(), err)
+	}
+
+	style.Step("Waiting ≤ %s for the API server ⏳", defaultWaitTimeout)
+	if err := waitForAPIServerTimeout(controlPlane.ID, defaultWaitTimeout); err != nil {
+		return fmt.Errorf("API server on %s did not come back: %w", controlPlane.NodeName(), err)
+	}
+
+	if moved {
+		if err := moveEndpoints(controlPlane.ID, oldIP, newIP); err != nil {
+			return err
+		}
+	}
+
+	for _, node := range nodes {
+		if node.ID == controlPlane.ID {
+			continue
+		}
+		style.Step("Restarting kubelet on %s", node.NodeName())
+		if err := restartKubelet(node, oldIP, newIP); err != nil {
+			return err
+		}
+	}
+
+	// Every node must reach the API server through the endpoint its kubelet uses
+	for _, node := range nodes {
+		if _, err := podman.Exec(node.ID, []string{"kubectl", "--kubeconfig=/etc/kubernetes/kubelet.conf", "get", "--raw", "/readyz"}); err != nil {
+			return fmt.Errorf("node %s cannot reach the API server: %w", node.NodeName(), err)
+		}
+	}
+
+	style.Step("Waiting ≤ %s for nodes = Ready ⏳", defaultWaitTimeout)
+	if output, err := podman.Exec(controlPlane.ID, []string{
+		"kubectl", "wait", "--for=condition=Ready", "nodes", "--all", fmt.Sprintf("--timeout=%s", defaultWaitTimeout),
+	}); err != nil {
+		return fmt.Errorf("nodes not Ready: %w\nOutput:\n%s", err, output)
+	}
+	return nil
+}
+
+// controlPlaneIPs returns the address the API server was set up to
+// advertise and the current IP of the control-plane container on the
+// cluster network
+func controlPlaneIPs(controlPlane podman.Container) (string, string, error) {
+	output, err := podman.Exec(controlPlane.ID, []string{"sed", "-n", "s/.*--advertise-address=//p", "/etc/kubernetes/manifests/kube-apiserver.yaml"})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the API server manifest: %w", err)
+	}
+	oldIP := strings.TrimSpace(output)
+	newIP, err := podman.ContainerNetworkIP(controlPlane.ID, nodeNetwork(controlPlane))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get control-plane IP: %w", err)
+	}
+	newIP = strings.TrimSpace(newIP)
+	if oldIP == "" {
+		// Nothing to compare with; treat the node as unmoved
+		return newIP, newIP, nil
+	}
+	return oldIP, newIP, nil
+}
+
+// moveEndpoints points the in-cluster clients of a control-plane that moved
+// from oldIP to newIP at it, once its API server answers again
+func moveEndpoints(controlPlaneID, oldIP, newIP string) error {
+	if err := updateEndpointConfigMaps(controlPlaneID, oldIP, newIP); err != nil {
+		return err
+	}
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c",
+		"kubectl -n kube-system get daemonset kube-proxy >/dev/null 2>&1 || exit 0; kubectl -n kube-system rollout restart daemonset/kube-proxy"}); err != nil {
+		style.Info("Warning: failed to restart kube-proxy: %v", err)
+	}
+	return nil
+}
+
+// restartKubelet restarts the kubelet of a worker, first pointing its
+// kubeconfig at newIP when the control-plane moved from oldIP
+func restartKubelet(node podman.Container, oldIP, newIP string) error {
+	if oldIP != newIP {
+		if _, err := podman.Exec(node.ID, []string{"sh", "-c", replaceIPScript(oldIP, newIP, "/etc/kubernetes/kubelet.conf")}); err != nil {
+			return fmt.Errorf("failed to update the kubelet kubeconfig of %s: %w", node.NodeName(), err)
+		}
+	}
+	if _, err := podman.Exec(node.ID, []string{"systemctl", "restart", "kubelet"}); err != nil {
+		return fmt.Errorf("failed to restart kubelet on %s: %w", node.NodeName(), err)
+	}
+	return nil
+}
+
+// moveControlPlane rewrites the static pod manifests and kubeconfigs of the
+// control-plane for its new IP, issues serving certificates valid for it and
+// restarts kubelet and the control-plane pods
+func moveControlPlane(controlPlaneID, oldIP, newIP string) error {
+	files := "/etc/kubernetes/manifests/*.yaml /etc/kubernetes/*.conf /root/.kube/config"
+	if _, err := podman.Exec(controlPlaneID, []string{"sh", "-c", replaceIPScript(oldIP, newIP, files)}); err != nil {
+		return fmt.Errorf("failed to update control-plane manifests: %w", err)
+	}
+
+	if err := regenerateServingCerts(controlPlaneID, oldIP, newIP); err != nil {
+		return err
+	}
+
+	if _, err := podman.Exec(controlPlaneID, []string{"systemctl", "restart", "kubelet"}); err != nil {
+		return fmt.Errorf("failed to restart kubelet: %w", err)
+	}
+	return restartControlPlanePods(controlPlaneID)
+}
+
+// regenerateServingCerts issues a new API server certificate with the SANs
+// of the current one, the old IP replaced by the new one, and new etcd
+// serving and peer certificates when etcd is stacked. The old files are
+// kept with a .stale suffix.
+func regenerateServingCerts(controlPlaneID, oldIP, newIP string) error {
+	certPEM, err := podman.Exec(controlPlaneID, []string{"cat", "/etc/kubernetes/pki/apiserver.crt"})
+	if err != nil {
+		return fmt.Errorf("failed to read the API server certificate: %w", err)
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return fmt.Errorf("API server certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse the API server certificate: %w", err)
+	}
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		if ip.String() != oldIP {
+			sans = append(sans, ip.String())
+		}
+	}
+
+	// Without a kubernetes version kubeadm would look up the latest release online
+	script := fmt.Sprintf(`set -e
+version=$(kubeadm version -o short)
+cd /etc/kubernetes/pki
+mv apiserver.crt apiserver.crt.stale && mv apiserver.key apiserver.key.stale
+kubeadm init phase certs apiserver --kubernetes-version="$version" --apiserver-advertise-address=%s --apiserver-cert-extra-sans=%s
+if [ -f /etc/kubernetes/manifests/etcd.yaml ]; then
+  for cert in server peer; do
+    mv etcd/$cert.crt etcd/$cert.crt.stale && mv etcd/$cert.key etcd/$cert.key.stale
+  done
+  kubeadm init phase certs etcd-server --kubernetes-version="$version"
+  kubeadm init phase certs etcd-peer --kubernetes-version="$version"
+fi`, newIP, strings.Join(sans, ","))
+	if output, err := podman.Exec(controlPlaneID, []string{"sh", "-c", script}); err != nil {
+		return fmt.Errorf("failed to issue serving certificates for %s: %w\nOutput:\n%s", newIP, err, output)
+	}
+	return nil
+}
+
+// updateEndpointConfigMaps replaces the old control-plane IP in the
+// configmaps kube-proxy, kubeadm and bootstrapping kubelets read
+func updateEndpointConfigMaps(controlPlaneID, oldIP, newIP string) error {
+	pattern := ipPattern(oldIP)
+	for _, cm := range endpointConfigMaps {
+		namespace, name, _ := strings.Cut(cm, "/")
+		manifest, err := podman.Exec(controlPlaneID, []string{"kubectl", "-n", namespace, "get", "configmap", name, "-o", "yaml"})
+		if err != nil {
+			// kube-proxy may be skipped
+			continue
+		}
+		if !pattern.MatchString(manifest) {
+			continue
+		}
+		replaceCmd := fmt.Sprintf("kubectl replace -f - << 'KIPOD_EOF'\n%s\nKIPOD_EOF", pattern.ReplaceAllString(manifest, newIP))
+		if output, err := podman.Exec(controlPlaneID, []string{"sh", "-c", replaceCmd}); err != nil {
+			return fmt.Errorf("failed to update configmap %s: %w\nOutput:\n%s", cm, err, output)
+		}
+	}
+	return nil
+}
+
+// ipPattern matches an IP address but not a longer one containing it
+func ipPattern(ip string) *regexp.Regexp {
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(ip) + `\b`)
+}
+
+// replaceIPScript returns a shell command replacing oldIP with newIP in files
+func replaceIPScript(oldIP, newIP, files string) string {
+	return fmt.Sprintf(`sed -i 's/\b%s\b/%s/g' %s`, strings.ReplaceAll(oldIP, ".", `\.`), newIP, files)
+}
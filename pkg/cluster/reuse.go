@@ -0,0 +1,136 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// Exists reports whether any node container belongs to the named cluster
+func Exists(name string) (bool, error) {
+	containers, err := podman.ListContainers(map[string]string{
+		podman.LabelCluster: name,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list cluster containers: %w", err)
+	}
+	return len(containers) > 0, nil
+}
+
+// Reuse adopts an existing cluster with the same name: it starts stopped
+// nodes, verifies their services and the API server, and creates any
+// workers missing from the configuration. Existing nodes are never removed.
+func (c *Cluster) Reuse() (err error) {
+	defer func() {
+		// Only workers created by this call are cleaned up
+		if err != nil {
+			c.cleanupOnFailure()
+		}
+	}()
+
+	nodes, err := ListNodes(c.config.Name)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]podman.Container)
+	for _, node := range nodes {
+		byName[node.NodeName()] = node
+	}
+
+	controlPlaneName := fmt.Sprintf("%s-control-plane-0", c.config.Name)
+	controlPlane, ok := byName[controlPlaneName]
+	if !ok {
+		return fmt.Errorf("cluster '%s' exists but has no %s node; delete it with: kipod delete cluster --name %s", c.config.Name, controlPlaneName, c.config.Name)
+	}
+
+	style.Step("Reusing existing cluster %q ♻️", c.config.Name)
+
+	// External etcd must be serving before the API server can come back
+	if err := c.timePhase("etcd", func() error { return startEtcd(c.config.Name) }); err != nil {
+		return err
+	}
+
+	// Make sure every existing node is up before reconciling
+	err = c.timePhase("systemd wait", func() error {
+		for _, node := range nodes {
+			if node.State != "running" {
+				style.Info("Starting stopped node %s", node.NodeName())
+				if err := podman.StartContainer(node.ID); err != nil {
+					return fmt.Errorf("failed to start node %s: %w", node.NodeName(), err)
+				}
+			}
+			if err := c.waitForServices(node.ID); err != nil {
+				return fmt.Errorf("node %s is unhealthy: %w", node.NodeName(), err)
+			}
+			if err := c.applyLogLevels(node.ID); err != nil {
+				return fmt.Errorf("node %s: %w", node.NodeName(), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Clocks of podman machine VMs stop while the host sleeps
+	checkClockSkew(c.config.Name)
+
+	err = c.timePhase("api server wait", func() error {
+		if _, err := podman.Exec(controlPlane.ID, []string{"kubectl", "get", "nodes"}); err != nil {
+			return fmt.Errorf("API server on %s is not responding: %w", controlPlaneName, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Find pool nodes missing from the cluster
+	type poolNode struct {
+		pool  NodePool
+		index int
+	}
+	var missing []poolNode
+	desired := 1
+	for _, pool := range c.config.Pools {
+		for i := 0; i < pool.Count; i++ {
+			desired++
+			if _, ok := byName[c.nodeName(pool.Name, i)]; !ok {
+				missing = append(missing, poolNode{pool, i})
+			}
+		}
+	}
+	if extra := len(nodes) - (desired - len(missing)); extra > 0 {
+		style.Info("Warning: cluster has %d node(s) not in the configuration; they are left untouched", extra)
+	}
+
+	if len(missing) > 0 {
+		err = c.timePhase("workers join", func() error {
+			for _, node := range missing {
+				if err := c.addWorker(controlPlane.ID, node.pool, node.index); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.config.WaitAll {
+		if err := c.timePhase("wait all", func() error { return c.waitAllReady(controlPlane.ID) }); err != nil {
+			return err
+		}
+	}
+	if c.config.WaitDuration > 0 {
+		if err := c.timePhase("wait", func() error { return c.waitSchedulable(controlPlane.ID) }); err != nil {
+			return err
+		}
+	}
+
+	style.Success("Ready")
+	return nil
+}
@@ -0,0 +1,174 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/crio"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// SmokeTestImage is the image used for the post-change smoke pod
+const SmokeTestImage = "docker.io/library/busybox:latest"
+
+// SetDefaultRuntime switches CRI-O's default OCI runtime on every node of a
+// running cluster and restarts CRI-O to apply it
+func SetDefaultRuntime(name, runtime string) error {
+	dropin, err := crio.GenerateDefaultRuntimeConfig(runtime)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := ListNodes(name)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if _, err := podman.Exec(node.ID, crio.WriteDropinCommand(crio.DefaultRuntimeDropin, dropin)); err != nil {
+			return fmt.Errorf("failed to write runtime config on %s: %w", node.NodeName(), err)
+		}
+
+		style.Info("Restarting CRI-O on %s...", node.NodeName())
+		if err := restartCRIO(node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restartCRIO restarts CRI-O on a node and waits until it answers CRI requests again
+func restartCRIO(node podman.Container) error {
+	if _, err := podman.Exec(node.ID, crio.RestartCommand()); err != nil {
+		logs, _ := podman.Exec(node.ID, []string{"journalctl", "-u", "crio", "-n", "50", "--no-pager"})
+		return fmt.Errorf("failed to restart CRI-O on %s: %w\nLogs:\n%s", node.NodeName(), err, logs)
+	}
+
+	maxRetries := 30
+	for i := 0; i < maxRetries; i++ {
+		if _, err := podman.Exec(node.ID, []string{"crictl", "info"}); err == nil {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	logs, _ := podman.Exec(node.ID, []string{"journalctl", "-u", "crio", "-n", "50", "--no-pager"})
+	return fmt.Errorf("CRI-O on %s did not become ready after restart. Logs:\n%s", node.NodeName(), logs)
+}
+
+// RunSmokePod runs a short-lived pod to completion to verify that the
+// cluster can still start containers, and removes it afterwards
+func RunSmokePod(name string, timeout time.Duration) error {
+	node, err := GetControlPlaneNode(name)
+	if err != nil {
+		return err
+	}
+
+	podName := fmt.Sprintf("kipod-smoke-%d", time.Now().Unix())
+	defer func() {
+		_, _ = podman.Exec(node.ID, []string{"kubectl", "delete", "pod", podName, "--ignore-not-found", "--wait=false"})
+	}()
+
+	runCmd := []string{
+		"kubectl", "run", podName,
+		"--image=" + SmokeTestImage,
+		"--restart=Never",
+		"--command", "--", "sh", "-c", "echo kipod smoke test ok",
+	}
+	if _, err := podman.Exec(node.ID, runCmd); err != nil {
+		return fmt.Errorf("failed to create smoke pod: %w", err)
+	}
+
+	waitCmd := []string{
+		"kubectl", "wait", "pod", podName,
+		"--for=jsonpath={.status.phase}=Succeeded",
+		fmt.Sprintf("--timeout=%s", timeout),
+	}
+	if _, err := podman.Exec(node.ID, waitCmd); err != nil {
+		describe, _ := podman.Exec(node.ID, []string{"kubectl", "describe", "pod", podName})
+		return fmt.Errorf("smoke pod did not succeed: %w\n%s", err, strings.TrimSpace(describe))
+	}
+
+	return nil
+}
+
+// runtimeClass describes an optional runtime handler exposed as a RuntimeClass
+type runtimeClass struct {
+	Name       string
+	Handler    string
+	ImageLabel string // node image label proving the runtime is installed
+	LabelValue string
+}
+
+// optionalRuntimeClasses returns the RuntimeClasses requested in the cluster config
+func (c *Cluster) optionalRuntimeClasses() []runtimeClass {
+	var classes []runtimeClass
+	if c.config.WasmRuntime {
+		classes = append(classes, runtimeClass{Name: "crun-wasm", Handler: "crun-wasm", ImageLabel: build.LabelWasm, LabelValue: "true"})
+	}
+	switch c.config.SandboxRuntime {
+	case "kata":
+		classes = append(classes, runtimeClass{Name: "kata", Handler: "kata", ImageLabel: build.LabelSandboxRuntime, LabelValue: "kata"})
+	case "gvisor":
+		classes = append(classes, runtimeClass{Name: "gvisor", Handler: "runsc", ImageLabel: build.LabelSandboxRuntime, LabelValue: "gvisor"})
+	}
+	return classes
+}
+
+// checkImageRuntimes verifies that the node image was built with every
+// optional runtime the cluster config asks for
+func (c *Cluster) checkImageRuntimes() error {
+	classes := c.optionalRuntimeClasses()
+	if len(classes) == 0 {
+		return nil
+	}
+
+	labels, err := build.GetImageLabels(c.config.Image)
+	if err != nil {
+		return err
+	}
+
+	for _, rc := range classes {
+		if labels[rc.ImageLabel] != rc.LabelValue {
+			return fmt.Errorf("node image '%s' does not include the %s runtime. Rebuild it with: kipod build node-image --rebuild --config <config>", c.config.Image, rc.Handler)
+		}
+	}
+	return nil
+}
+
+// createRuntimeClasses registers a RuntimeClass for every optional runtime
+func (c *Cluster) createRuntimeClasses(controlPlaneID string) error {
+	for _, rc := range c.optionalRuntimeClasses() {
+		style.Step("Creating RuntimeClass %s 🧩", rc.Name)
+		manifest := fmt.Sprintf(`apiVersion: node.k8s.io/v1
+kind: RuntimeClass
+metadata:
+  name: %s
+handler: %s
+`, rc.Name, rc.Handler)
+		if err := applyManifest(controlPlaneID, manifest); err != nil {
+			return fmt.Errorf("failed to create RuntimeClass %s: %w", rc.Name, err)
+		}
+	}
+	return nil
+}
+
+// sandboxDevices returns the host devices a sandboxed runtime needs inside the node
+func sandboxDevices(runtime string) []string {
+	if runtime != "kata" {
+		return nil
+	}
+
+	var devices []string
+	for _, dev := range []string{"/dev/kvm", "/dev/vhost-vsock", "/dev/vhost-net"} {
+		if _, err := os.Stat(dev); err == nil {
+			devices = append(devices, dev)
+		}
+	}
+	return devices
+}
@@ -0,0 +1,208 @@
+package cluster
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+const (
+	// schedulerManifest is the kube-scheduler static pod kubeadm writes
+	schedulerManifest = "/etc/kubernetes/manifests/kube-scheduler.yaml"
+
+	// schedulerManifestOrig keeps the manifest kubeadm wrote, outside the
+	// manifests directory so the kubelet does not run it
+	schedulerManifestOrig = "/etc/kubernetes/kube-scheduler.yaml.kubeadm"
+
+	// customSchedulerPath is where a local kube-scheduler binary is
+	// installed on control-plane nodes
+	customSchedulerPath = "/opt/kipod/bin/kube-scheduler"
+
+	// customSchedulerVolume mounts it over the binary of the scheduler image
+	customSchedulerVolume = "kipod-kube-scheduler"
+
+	// schedulerRestartTimeout bounds the wait for the new scheduler container
+	schedulerRestartTimeout = 2 * time.Minute
+)
+
+// SchedulerOverride replaces what the kube-scheduler static pod runs. An
+// empty override restores the scheduler kubeadm set up.
+type SchedulerOverride struct {
+	// Binary is a local, statically linked kube-scheduler binary
+	Binary string
+	// Image is a kube-scheduler image
+	Image string
+}
+
+// overrideScheduler applies the configured scheduler binary or image to the
+// first control-plane once kubeadm has set it up
+func (c *Cluster) overrideScheduler(controlPlaneID string) error {
+	override := SchedulerOverride{Binary: c.config.SchedulerBinary, Image: c.config.SchedulerImage}
+	if override.Binary != "" {
+		style.Step("Installing kube-scheduler from %s 📅", override.Binary)
+	} else {
+		style.Step("Running kube-scheduler image %s 📅", override.Image)
+	}
+	return applySchedulerOverride(controlPlaneID, override)
+}
+
+// OverrideScheduler replaces the kube-scheduler binary or image on every
+// control-plane of a cluster, restarts it and waits for the new container.
+// Applying the same binary again restarts the scheduler with the new build.
+func OverrideScheduler(name string, override SchedulerOverride) error {
+	if override.Binary != "" && override.Image != "" {
+		return fmt.Errorf("a scheduler binary and image cannot be combined")
+	}
+	controlPlanes, err := runningControlPlanes(name)
+	if err != nil {
+		return err
+	}
+	for _, node := range controlPlanes {
+		style.Step("Replacing kube-scheduler on %s", node.NodeName())
+		if err := applySchedulerOverride(node.ID, override); err != nil {
+			return fmt.Errorf("%s: %w", node.NodeName(), err)
+		}
+	}
+	return nil
+}
+
+// SchedulerLogsCommand returns the command following the logs of the
+// running kube-scheduler container of a control-plane node
+func SchedulerLogsCommand() []string {
+	return []string{"sh", "-c", "exec crictl logs -f $(crictl ps --name '^kube-scheduler$' --state running -q | head -n 1)"}
+}
+
+// applySchedulerOverride rewrites the scheduler manifest of a node from the
+// one kubeadm wrote and waits for the kubelet to run the new scheduler
+func applySchedulerOverride(nodeID string, override SchedulerOverride) error {
+	previous, _ := podman.Exec(nodeID, []string{"sh", "-c", "crictl ps --name '^kube-scheduler$' --state running -q | head -n 1"})
+	previous = strings.TrimSpace(previous)
+
+	if override.Binary != "" {
+		// Replace the file rather than write into it: the running scheduler
+		// keeps the old one mounted, its successor mounts the new one
+		staging := customSchedulerPath + ".new"
+		if _, err := podman.Exec(nodeID, []string{"mkdir", "-p", path.Dir(customSchedulerPath)}); err != nil {
+			return fmt.Errorf("failed to create the scheduler binary directory: %w", err)
+		}
+		if err := podman.CopyToContainer(nodeID, override.Binary, staging); err != nil {
+			return err
+		}
+		if _, err := podman.Exec(nodeID, []string{"sh", "-c", fmt.Sprintf("chmod 0755 %[1]s && mv -f %[1]s %[2]s", staging, customSchedulerPath)}); err != nil {
+			return fmt.Errorf("failed to install the scheduler binary: %w", err)
+		}
+	}
+
+	if _, err := podman.Exec(nodeID, []string{"sh", "-c", fmt.Sprintf("[ -f %[2]s ] || cp %[1]s %[2]s", schedulerManifest, schedulerManifestOrig)}); err != nil {
+		return fmt.Errorf("failed to back up the scheduler manifest: %w", err)
+	}
+	original, err := podman.Exec(nodeID, []string{"cat", schedulerManifestOrig})
+	if err != nil {
+		return fmt.Errorf("failed to read the scheduler manifest: %w", err)
+	}
+	current, err := podman.Exec(nodeID, []string{"cat", schedulerManifest})
+	if err != nil {
+		return fmt.Errorf("failed to read the scheduler manifest: %w", err)
+	}
+	manifest, err := schedulerManifestFor(original, override)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(manifest) == strings.TrimSpace(current) {
+		// Same manifest, e.g. a rebuilt binary: the kubelet would not notice
+		if previous != "" {
+			if _, err := podman.Exec(nodeID, []string{"crictl", "stop", previous}); err != nil {
+				return fmt.Errorf("failed to restart kube-scheduler: %w", err)
+			}
+		}
+	} else {
+		// Write next to the manifest and rename, so the kubelet never reads
+		// a partial file
+		staging := "/etc/kubernetes/kube-scheduler.yaml.new"
+		write := fmt.Sprintf("cat > %s << 'KIPOD_EOF'\n%s\nKIPOD_EOF\nmv -f %s %s", staging, strings.TrimRight(manifest, "\n"), staging, schedulerManifest)
+		if _, err := podman.Exec(nodeID, []string{"sh", "-c", write}); err != nil {
+			return fmt.Errorf("failed to write the scheduler manifest: %w", err)
+		}
+	}
+	if override == (SchedulerOverride{}) {
+		_, _ = podman.Exec(nodeID, []string{"rm", "-f", schedulerManifestOrig, customSchedulerPath})
+	}
+
+	return waitForSchedulerRestart(nodeID, previous)
+}
+
+// schedulerManifestFor returns the kubeadm scheduler manifest with the
+// override applied
+func schedulerManifestFor(original string, override SchedulerOverride) (string, error) {
+	if override == (SchedulerOverride{}) {
+		return original, nil
+	}
+
+	var pod map[string]interface{}
+	if err := yaml.Unmarshal([]byte(original), &pod); err != nil {
+		return "", fmt.Errorf("failed to parse the scheduler manifest: %w", err)
+	}
+	spec, _ := pod["spec"].(map[string]interface{})
+	containers, _ := spec["containers"].([]interface{})
+	if len(containers) == 0 {
+		return "", fmt.Errorf("scheduler manifest has no container")
+	}
+	container, _ := containers[0].(map[string]interface{})
+	if container == nil {
+		return "", fmt.Errorf("scheduler manifest has an invalid container")
+	}
+
+	if override.Image != "" {
+		container["image"] = override.Image
+		// A locally loaded image has no registry to pull from
+		container["imagePullPolicy"] = "IfNotPresent"
+	}
+	if override.Binary != "" {
+		mounts, _ := container["volumeMounts"].([]interface{})
+		container["volumeMounts"] = append(mounts, map[string]interface{}{
+			"name":      customSchedulerVolume,
+			"mountPath": "/usr/local/bin/kube-scheduler",
+			"readOnly":  true,
+		})
+		volumes, _ := spec["volumes"].([]interface{})
+		spec["volumes"] = append(volumes, map[string]interface{}{
+			"name":     customSchedulerVolume,
+			"hostPath": map[string]interface{}{"path": customSchedulerPath, "type": "File"},
+		})
+	}
+
+	data, err := yaml.Marshal(pod)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the scheduler manifest: %w", err)
+	}
+	return string(data), nil
+}
+
+// waitForSchedulerRestart waits until a kube-scheduler container other than
+// previous is running and has stayed up for a few seconds
+func waitForSchedulerRestart(nodeID, previous string) error {
+	deadline := time.Now().Add(schedulerRestartTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(2 * time.Second)
+		output, _ := podman.Exec(nodeID, []string{"sh", "-c", "crictl ps --name '^kube-scheduler$' --state running -q | head -n 1"})
+		id := strings.TrimSpace(output)
+		if id == "" || id == previous {
+			continue
+		}
+		// A scheduler that cannot start exits right away
+		time.Sleep(3 * time.Second)
+		if _, err := podman.Exec(nodeID, []string{"sh", "-c", fmt.Sprintf("crictl ps --state running -q | grep -q '^%s'", id)}); err == nil {
+			return nil
+		}
+	}
+
+	logs, _ := podman.Exec(nodeID, []string{"sh", "-c", "crictl logs --tail 30 $(crictl ps -a --name '^kube-scheduler$' -q | head -n 1) 2>&1"})
+	return fmt.Errorf("kube-scheduler did not come up within %s\nLogs:\n%s", schedulerRestartTimeout, logs)
+}
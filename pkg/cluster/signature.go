@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+const (
+	// signaturePolicyFile is the containers-policy.json CRI-O verifies
+	// images with. The policy of the node image stays in place for podman.
+	signaturePolicyFile = "/etc/crio/kipod-policy.json"
+
+	// signatureKeyFile is where the public key of the signature policy is
+	// installed on nodes
+	signatureKeyFile = "/etc/crio/kipod-sigstore.pub"
+
+	// crioSignatureConf points CRI-O at signaturePolicyFile
+	crioSignatureConf = "/etc/crio/crio.conf.d/96-kipod-signature-policy.conf"
+
+	// registriesDir holds the registries.d files; kipod owns the ones
+	// prefixed with registriesFilePrefix
+	registriesDir        = "/etc/containers/registries.d"
+	registriesFilePrefix = "kipod-"
+)
+
+// signaturePolicyConf is the content of crioSignatureConf
+var signaturePolicyConf = fmt.Sprintf("[crio.image]\nsignature_policy = %q\n", signaturePolicyFile)
+
+// TestSignaturePolicy returns a containers-policy.json requiring images
+// under scopes to have a sigstore signature made with the key installed at
+// signatureKeyFile, and accepting all other images so the cluster's own
+// images keep working, with the registries.d config that makes CRI-O look
+// up sigstore signatures for scopes
+func TestSignaturePolicy(scopes []string) (policy, registries string) {
+	signed := []map[string]interface{}{{
+		"type":           "sigstoreSigned",
+		"keyPath":        signatureKeyFile,
+		"signedIdentity": map[string]string{"type": "matchRepository"},
+	}}
+	docker := make(map[string]interface{})
+	for _, scope := range scopes {
+		docker[scope] = signed
+	}
+	doc := map[string]interface{}{
+		"default":    []map[string]string{{"type": "insecureAcceptAnything"}},
+		"transports": map[string]interface{}{"docker": docker},
+	}
+	data, _ := json.MarshalIndent(doc, "", "  ")
+
+	var sb strings.Builder
+	sb.WriteString("# Managed by kipod: signaturePolicy.requireSigned\ndocker:\n")
+	for _, scope := range scopes {
+		sb.WriteString(fmt.Sprintf("  %s:\n    use-sigstore-attachments: true\n", scope))
+	}
+	return string(data) + "\n", sb.String()
+}
+
+// signatureFiles returns the files of the signature policy installed on
+// nodes besides crioSignatureConf, by path
+func (c *Cluster) signatureFiles() map[string]string {
+	files := make(map[string]string)
+	if c.config.SignaturePolicy == "" {
+		return files
+	}
+	files[signaturePolicyFile] = c.config.SignaturePolicy
+	if c.config.SignatureKey != "" {
+		files[signatureKeyFile] = c.config.SignatureKey
+	}
+	for name, content := range c.config.SignatureRegistries {
+		files[path.Join(registriesDir, registriesFilePrefix+name)] = content
+	}
+	return files
+}
+
+// installSignaturePolicy copies the signature policy and the drop-in
+// pointing CRI-O at it into a node that has not been started yet
+func (c *Cluster) installSignaturePolicy(containerID string) error {
+	files := c.signatureFiles()
+	for _, file := range sortedKeys(files) {
+		if err := writeNodeFile(containerID, file, files[file], 0644); err != nil {
+			return err
+		}
+	}
+	return writeNodeFile(containerID, crioSignatureConf, signaturePolicyConf, 0644)
+}
+
+// syncSignaturePolicy brings the signature policy files of a running node in
+// line with the config, removing the ones kipod installed before and no
+// longer wants. CRI-O reads them on every pull, so only changes to
+// crioSignatureConf need a restart.
+func (c *Cluster) syncSignaturePolicy(containerID string) error {
+	want := c.signatureFiles()
+
+	installed := []string{signaturePolicyFile, signatureKeyFile}
+	output, err := podman.Exec(containerID, []string{"sh", "-c", fmt.Sprintf("ls -1 %s/%s*.yaml 2>/dev/null || true", registriesDir, registriesFilePrefix)})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", registriesDir, err)
+	}
+	installed = append(installed, strings.Fields(output)...)
+	sort.Strings(installed)
+	for _, file := range installed {
+		if _, ok := want[file]; ok {
+			continue
+		}
+		if _, err := podman.Exec(containerID, []string{"rm", "-f", file}); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+
+	for _, file := range sortedKeys(want) {
+		current, err := podman.Exec(containerID, []string{"cat", file})
+		if err == nil && current == want[file] {
+			continue
+		}
+		if err := writeNodeFile(containerID, file, want[file], 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
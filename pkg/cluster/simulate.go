@@ -0,0 +1,213 @@
+package cluster
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/system"
+)
+
+const (
+	// simulatePort is the port the simulated node serves inside, the one
+	// the API server is published on
+	simulatePort = 6443
+
+	// simulateReply is what the simulated node answers on simulatePort
+	simulateReply = "kipod-simulate"
+)
+
+// simulateUnits serve simulateReply on simulatePort through systemd socket
+// activation, which needs nothing but systemd in the node image
+var simulateUnits = map[string]string{
+	"kipod-simulate.socket":   fmt.Sprintf("[Socket]\nListenStream=%d\nAccept=yes\n", simulatePort),
+	"kipod-simulate@.service": fmt.Sprintf("[Service]\nExecStart=/bin/sh -c 'echo %s'\nStandardOutput=socket\n", simulateReply),
+}
+
+// SimulateNode boots a throwaway control-plane container with the options
+// the cluster would be created with (privileged, systemd, private cgroup
+// namespace, tmpfs storage) on the cluster network, and checks that
+// systemd boots and a port published like the API server's answers from
+// the host. It gives a definitive answer before a full cluster create.
+func SimulateNode(cfg *Config) ([]system.ValidationResult, error) {
+	c, err := NewCluster(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := build.ImageExists(cfg.Image)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return simulationResults(system.ValidationResult{
+			Name:    "Node Container",
+			Message: fmt.Sprintf("Image %s not found. Build it with: kipod build node-image", cfg.Image),
+			Fatal:   true,
+		}), nil
+	}
+
+	network := c.network()
+	networkExisted, err := podman.NetworkExists(network)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureNetwork(network); err != nil {
+		return nil, err
+	}
+	if !networkExisted {
+		defer deleteUnusedNetworks(map[string]bool{network: true})
+	}
+
+	hostPort, err := system.FreeHostPort()
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("kipod-simulate-%d", time.Now().Unix())
+	opts := c.simulateContainerOptions(name, hostPort)
+
+	containerID, err := podman.CreateContainer(opts)
+	if err != nil {
+		return simulationResults(system.ValidationResult{
+			Name:    "Node Container",
+			Message: fmt.Sprintf("Failed to start a node container: %v", err),
+			Fatal:   true,
+		}), nil
+	}
+	defer podman.DeleteContainer(containerID)
+
+	results := []system.ValidationResult{{
+		Name:    "Node Container",
+		Passed:  true,
+		Message: fmt.Sprintf("Started %s with the options of a %s control-plane", name, cfg.Name),
+	}}
+
+	boot := simulateBoot(containerID)
+	results = append(results, boot)
+	if !boot.Passed {
+		return simulationResults(results...), nil
+	}
+	results = append(results, simulatePortPublish(containerID, hostPort))
+	return simulationResults(results...), nil
+}
+
+// simulateContainerOptions returns the options of the first control-plane
+// for a throwaway container: it carries no cluster label, so kipod does not
+// list it as a cluster, uses tmpfs storage rather than named volumes, and
+// publishes simulatePort on hostPort
+func (c *Cluster) simulateContainerOptions(name string, hostPort int) podman.CreateContainerOptions {
+	opts := c.createContainerOptions(c.nodeName("control-plane", 0), "control-plane")
+	opts.Name = name
+	opts.Hostname = name
+	opts.NetworkAliases = nil
+	opts.Labels = map[string]string{podman.LabelManaged: "true"}
+	opts.VolumeLabels = nil
+	opts.RestartPolicy = ""
+	opts.HealthCmd = ""
+	opts.Ports = []string{fmt.Sprintf("%d:%d", hostPort, simulatePort)}
+
+	// Keep bind mounts, e.g. local binaries; named volumes would outlive it
+	var volumes []string
+	for _, volume := range opts.Volumes {
+		if strings.HasPrefix(volume, "/") {
+			volumes = append(volumes, volume)
+		}
+	}
+	opts.Volumes = volumes
+	if len(opts.Tmpfs) == 0 {
+		opts.Tmpfs = []string{"/var/lib/containers/storage:rw,size=1G"}
+	}
+	return opts
+}
+
+// simulateBoot waits for systemd in the container to finish booting
+func simulateBoot(containerID string) system.ValidationResult {
+	var status string
+	for i := 0; i < 30; i++ {
+		output, _ := podman.Exec(containerID, []string{"systemctl", "is-system-running"})
+		status = strings.TrimSpace(output)
+		if status == "running" || status == "degraded" {
+			return system.ValidationResult{
+				Name:    "Systemd Boot",
+				Passed:  true,
+				Message: fmt.Sprintf("systemd is %s", status),
+			}
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	logs, _ := podman.Exec(containerID, []string{"journalctl", "-b", "-p", "err", "-n", "20", "--no-pager"})
+	return system.ValidationResult{
+		Name:    "Systemd Boot",
+		Message: fmt.Sprintf("systemd did not finish booting (state: %q). Recent errors:\n%s", status, strings.TrimSpace(logs)),
+		Fatal:   true,
+	}
+}
+
+// simulatePortPublish serves a reply on simulatePort in the container and
+// reads it through the published host port
+func simulatePortPublish(containerID string, hostPort int) system.ValidationResult {
+	var script strings.Builder
+	for _, unit := range []string{"kipod-simulate.socket", "kipod-simulate@.service"} {
+		script.WriteString(fmt.Sprintf("cat > /etc/systemd/system/%s << 'KIPOD_EOF'\n%sKIPOD_EOF\n", unit, simulateUnits[unit]))
+	}
+	script.WriteString("systemctl daemon-reload && systemctl start kipod-simulate.socket")
+	if output, err := podman.Exec(containerID, []string{"sh", "-c", script.String()}); err != nil {
+		return system.ValidationResult{
+			Name:    "Port Publish",
+			Message: fmt.Sprintf("Could not listen on port %d in the node: %v\n%s", simulatePort, err, output),
+			Fatal:   true,
+		}
+	}
+
+	address := net.JoinHostPort("127.0.0.1", fmt.Sprint(hostPort))
+	var lastErr error
+	for i := 0; i < 10; i++ {
+		reply, err := readReply(address)
+		if err == nil && reply == simulateReply {
+			return system.ValidationResult{
+				Name:    "Port Publish",
+				Passed:  true,
+				Message: fmt.Sprintf("Host port %d reaches port %d in the node", hostPort, simulatePort),
+			}
+		}
+		lastErr = err
+		if err == nil {
+			lastErr = fmt.Errorf("unexpected reply %q", reply)
+		}
+		time.Sleep(time.Second)
+	}
+	return system.ValidationResult{
+		Name:    "Port Publish",
+		Message: fmt.Sprintf("Host port %d does not reach port %d in the node (%v); the API server would be unreachable. Check the rootless network backend (pasta or slirp4netns) and rootlessport", hostPort, simulatePort, lastErr),
+		Fatal:   true,
+	}
+}
+
+// readReply connects to address and reads one line
+func readReply(address string) (string, error) {
+	conn, err := net.DialTimeout("tcp", address, 2*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func simulationResults(results ...system.ValidationResult) []system.ValidationResult {
+	for i := range results {
+		results[i].Category = "Node Simulation"
+	}
+	return results
+}
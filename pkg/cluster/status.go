@@ -0,0 +1,170 @@
+package cluster
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// Cluster states reported by GetClusterStatus
+const (
+	StateRunning  = "running"
+	StateStopped  = "stopped"
+	StateDegraded = "degraded"
+)
+
+// API server health reported by GetClusterStatus
+const (
+	APIServerOK          = "ok"
+	APIServerNotReady    = "not-ready"
+	APIServerUnreachable = "unreachable"
+)
+
+// apiServerProbeTimeout bounds the readiness probe of the API server
+const apiServerProbeTimeout = 3 * time.Second
+
+// ClusterStatus is the health of a cluster at a glance
+type ClusterStatus struct {
+	Name string `json:"name"`
+	// Labels are the labels the cluster was created with
+	Labels map[string]string `json:"labels,omitempty"`
+	// Owner is the user who created the cluster, if recorded
+	Owner string `json:"owner,omitempty"`
+	// State is running when every node container runs, stopped when none
+	// does, and degraded otherwise or when a node fails its healthcheck
+	State string `json:"state"`
+	// Nodes and NodesRunning count node containers
+	Nodes        int `json:"nodes"`
+	NodesRunning int `json:"nodesRunning"`
+	// NodesRegistered and NodesReady count Kubernetes nodes, including
+	// kwok fake nodes
+	NodesRegistered int `json:"nodesRegistered"`
+	NodesReady      int `json:"nodesReady"`
+	// APIServer is the answer of /readyz on the port published on the host:
+	// ok, not-ready or unreachable
+	APIServer string `json:"apiServer"`
+	// KubernetesVersion is the kubelet version of the nodes, several
+	// comma-separated during an upgrade; empty if the API server did not
+	// answer
+	KubernetesVersion string    `json:"kubernetesVersion,omitempty"`
+	Created           time.Time `json:"created"`
+	// Error is why the nodes could not be queried, if they could not
+	Error string `json:"error,omitempty"`
+}
+
+// GetClusterStatus reports the health of a cluster: the state of its node
+// containers, the Kubernetes nodes Ready, whether the API server published
+// on hostPort answers, the Kubernetes version and when the cluster was
+// created
+func GetClusterStatus(name string, hostPort int) (ClusterStatus, error) {
+	nodes, err := ListNodes(name)
+	if err != nil {
+		return ClusterStatus{}, err
+	}
+
+	status := ClusterStatus{Name: name, Nodes: len(nodes), APIServer: APIServerUnreachable}
+	healthy := true
+	var controlPlane *podman.Container
+	for i, node := range nodes {
+		if len(status.Labels) == 0 {
+			status.Labels = userLabels(node)
+		}
+		if status.Owner == "" {
+			status.Owner = node.Labels[podman.LabelOwner]
+		}
+		if status.Created.IsZero() || (!node.Created.IsZero() && node.Created.Before(status.Created)) {
+			status.Created = node.Created
+		}
+		if node.State != "running" {
+			continue
+		}
+		status.NodesRunning++
+		if node.Health == "unhealthy" {
+			healthy = false
+		}
+		if controlPlane == nil && node.Labels[podman.LabelRole] == "control-plane" {
+			controlPlane = &nodes[i]
+		}
+	}
+	switch {
+	case status.NodesRunning == 0:
+		status.State = StateStopped
+		return status, nil
+	case status.NodesRunning < status.Nodes || !healthy:
+		status.State = StateDegraded
+	default:
+		status.State = StateRunning
+	}
+
+	status.APIServer = probeAPIServer(hostPort)
+	if controlPlane == nil {
+		status.Error = "no control-plane node is running"
+		return status, nil
+	}
+	if err := nodeReadiness(controlPlane.ID, &status); err != nil {
+		status.Error = err.Error()
+	}
+	return status, nil
+}
+
+// nodeReadiness counts the registered and Ready Kubernetes nodes and
+// collects their kubelet versions
+func nodeReadiness(controlPlaneID string, status *ClusterStatus) error {
+	output, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "nodes", "--request-timeout=5s", "-o",
+		`jsonpath={range .items[*]}{.status.nodeInfo.kubeletVersion}{" "}{.status.conditions[?(@.type=="Ready")].status}{"\n"}{end}`})
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	versions := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		status.NodesRegistered++
+		// Fake kwok nodes may report a placeholder version
+		if strings.HasPrefix(fields[0], "v") {
+			versions[fields[0]] = true
+		}
+		if len(fields) == 2 && fields[1] == "True" {
+			status.NodesReady++
+		}
+	}
+
+	list := make([]string, 0, len(versions))
+	for version := range versions {
+		list = append(list, version)
+	}
+	sort.Strings(list)
+	status.KubernetesVersion = strings.Join(list, ",")
+	return nil
+}
+
+// probeAPIServer asks the API server published on hostPort whether it is
+// ready; /readyz is readable without credentials
+func probeAPIServer(hostPort int) string {
+	client := &http.Client{
+		Timeout: apiServerProbeTimeout,
+		Transport: &http.Transport{
+			// Only reachability matters here, not the cluster's identity
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	resp, err := client.Get("https://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort)) + "/readyz")
+	if err != nil {
+		return APIServerUnreachable
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return APIServerNotReady
+	}
+	return APIServerOK
+}
@@ -0,0 +1,124 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+const (
+	// storageConfPath is the containers/storage config read by CRI-O
+	storageConfPath = "/etc/containers/storage.conf"
+
+	// crioStorageConfPath pins the driver in CRI-O, overriding 00-kipod.conf
+	crioStorageConfPath = "/etc/crio/crio.conf.d/05-kipod-storage.conf"
+
+	// imageStorageDir is the graphroot of CRI-O, a tmpfs or volume mount
+	imageStorageDir = "/var/lib/containers/storage"
+)
+
+// StorageUsage is the usage of the image storage of a node
+type StorageUsage struct {
+	// Filesystem is the filesystem type, e.g. tmpfs
+	Filesystem string
+	// Size is the tmpfs size, or for volumes the size of the host
+	// filesystem holding the volume, in bytes
+	Size uint64
+	// Used is in bytes
+	Used uint64
+}
+
+// NodeStorageUsage returns the usage of the image storage of a running node
+func NodeStorageUsage(containerID string) (StorageUsage, error) {
+	cmd := fmt.Sprintf("df -B1 --output=fstype,size,used %s | tail -n 1", imageStorageDir)
+	output, err := podman.Exec(containerID, []string{"sh", "-c", cmd})
+	if err != nil {
+		return StorageUsage{}, fmt.Errorf("failed to measure image storage: %w", err)
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 3 {
+		return StorageUsage{}, fmt.Errorf("unexpected df output %q", strings.TrimSpace(output))
+	}
+	usage := StorageUsage{Filesystem: fields[0]}
+	if usage.Size, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return StorageUsage{}, fmt.Errorf("unexpected df output %q", strings.TrimSpace(output))
+	}
+	if usage.Used, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+		return StorageUsage{}, fmt.Errorf("unexpected df output %q", strings.TrimSpace(output))
+	}
+	return usage, nil
+}
+
+// storageConf returns the storage.conf for a storage driver
+func storageConf(driver string) string {
+	conf := fmt.Sprintf(`[storage]
+  driver = %q
+  runroot = "/run/containers/storage"
+  graphroot = "/var/lib/containers/storage"
+`, storageDriverName(driver))
+
+	switch driver {
+	case "fuse-overlayfs":
+		conf += `
+[storage.options.overlay]
+  mount_program = "/usr/bin/fuse-overlayfs"
+  ignore_chown_errors = "true"
+`
+	case "overlay":
+		conf += `
+[storage.options.overlay]
+  ignore_chown_errors = "true"
+`
+	case "vfs":
+		conf += `
+[storage.options.vfs]
+  ignore_chown_errors = "true"
+`
+	}
+	return conf
+}
+
+// storageDriverName maps a kipod storage driver to the containers/storage
+// driver; fuse-overlayfs is the overlay driver with a mount program
+func storageDriverName(driver string) string {
+	if driver == "fuse-overlayfs" {
+		return "overlay"
+	}
+	return driver
+}
+
+// installStorageConfig writes the storage configuration for the configured
+// driver into a node that has not been started yet
+func (c *Cluster) installStorageConfig(containerID string) error {
+	dir, err := os.MkdirTemp("", "kipod-storage-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		storageConfPath:     storageConf(c.config.StorageDriver),
+		crioStorageConfPath: fmt.Sprintf("[crio]\n  storage_driver = %q\n", storageDriverName(c.config.StorageDriver)),
+	}
+	for dest, content := range files {
+		src := filepath.Join(dir, filepath.Base(dest))
+		if err := os.WriteFile(src, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", dest, err)
+		}
+		if err := podman.CopyToContainer(containerID, src, dest); err != nil {
+			return fmt.Errorf("failed to configure storage driver: %w", err)
+		}
+	}
+	return nil
+}
+
+// extraVolumeName returns the podman volume backing mountPath on a node,
+// e.g. kipod-data-kipod-worker-0-var-lib-kubelet
+func extraVolumeName(nodeName, mountPath string) string {
+	slug := strings.ReplaceAll(strings.Trim(mountPath, "/"), "/", "-")
+	return fmt.Sprintf("kipod-data-%s-%s", nodeName, slug)
+}
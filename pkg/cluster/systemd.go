@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+const (
+	// systemdUnitDir is where extra units and drop-ins are installed in nodes
+	systemdUnitDir = "/etc/systemd/system"
+
+	// defaultDropInName is the drop-in file name used when none is configured
+	defaultDropInName = "90-kipod-override.conf"
+)
+
+// SystemdUnit is an extra unit file installed into every node
+type SystemdUnit struct {
+	Name    string
+	Content string
+	Enable  bool // Start the unit at boot via multi-user.target
+}
+
+// UnitOverride is a drop-in for a unit shipped in the node image
+type UnitOverride struct {
+	Unit    string
+	Name    string // Drop-in file name, defaults to 90-kipod-override.conf
+	Content string
+}
+
+// hasSystemdFiles reports whether any units or drop-ins are configured
+func (c *Cluster) hasSystemdFiles() bool {
+	return len(c.config.SystemdUnits) > 0 || len(c.config.UnitOverrides) > 0
+}
+
+// stageSystemdFiles lays out the configured units, drop-ins and enablement
+// symlinks in dir, mirroring /etc/systemd/system
+func (c *Cluster) stageSystemdFiles(dir string) error {
+	for _, unit := range c.config.SystemdUnits {
+		if err := os.WriteFile(filepath.Join(dir, unit.Name), []byte(unit.Content), 0644); err != nil {
+			return fmt.Errorf("failed to stage unit %s: %w", unit.Name, err)
+		}
+		if !unit.Enable {
+			continue
+		}
+		wantsDir := filepath.Join(dir, "multi-user.target.wants")
+		if err := os.MkdirAll(wantsDir, 0755); err != nil {
+			return fmt.Errorf("failed to stage unit %s: %w", unit.Name, err)
+		}
+		if err := os.Symlink(filepath.Join(systemdUnitDir, unit.Name), filepath.Join(wantsDir, unit.Name)); err != nil {
+			return fmt.Errorf("failed to enable unit %s: %w", unit.Name, err)
+		}
+	}
+
+	for _, override := range c.config.UnitOverrides {
+		name := override.Name
+		if name == "" {
+			name = defaultDropInName
+		}
+		dropInDir := filepath.Join(dir, override.Unit+".d")
+		if err := os.MkdirAll(dropInDir, 0755); err != nil {
+			return fmt.Errorf("failed to stage drop-in for %s: %w", override.Unit, err)
+		}
+		if err := os.WriteFile(filepath.Join(dropInDir, name), []byte(override.Content), 0644); err != nil {
+			return fmt.Errorf("failed to stage drop-in for %s: %w", override.Unit, err)
+		}
+	}
+
+	return nil
+}
+
+// installSystemdFiles copies the configured units and drop-ins into a node
+// that has not been started yet, so systemd picks them up on first boot
+func (c *Cluster) installSystemdFiles(containerID string) error {
+	dir, err := os.MkdirTemp("", "kipod-systemd-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := c.stageSystemdFiles(dir); err != nil {
+		return err
+	}
+
+	// Copying the directory contents merges them into the existing tree
+	if err := podman.CopyToContainer(containerID, dir+"/.", systemdUnitDir); err != nil {
+		return fmt.Errorf("failed to install systemd units: %w", err)
+	}
+	return nil
+}
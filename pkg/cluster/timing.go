@@ -0,0 +1,62 @@
+package cluster
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/events"
+	"github.com/sohankunkerkar/kipod/pkg/tracing"
+)
+
+// PhaseTiming records how long one phase of cluster creation took
+type PhaseTiming struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"durationNanoseconds"`
+	Seconds  float64       `json:"seconds"`
+
+	// Started is when the phase first ran
+	Started time.Time `json:"-"`
+}
+
+// timePhase runs fn and records its duration under the given phase name.
+// Repeated phases (e.g. one per worker) accumulate into a single entry, but
+// are traced as one span each.
+func (c *Cluster) timePhase(phase string, fn func() error) error {
+	events.Emit(events.Event{Type: events.PhaseStart, Cluster: c.config.Name, Phase: phase})
+	span := tracing.Start(phase)
+	span.SetAttribute("kipod.cluster", c.config.Name)
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+	span.End(err)
+
+	end := events.Event{Type: events.PhaseEnd, Cluster: c.config.Name, Phase: phase, Seconds: elapsed.Seconds()}
+	if err != nil {
+		end.Error = err.Error()
+		var waitErr *WaitError
+		if errors.As(err, &waitErr) {
+			end.Reason = waitErr.Reason
+		}
+	}
+	events.Emit(end)
+
+	for i := range c.timings {
+		if c.timings[i].Phase == phase {
+			c.timings[i].Duration += elapsed
+			c.timings[i].Seconds = c.timings[i].Duration.Seconds()
+			return err
+		}
+	}
+	c.timings = append(c.timings, PhaseTiming{
+		Phase:    phase,
+		Duration: elapsed,
+		Seconds:  elapsed.Seconds(),
+		Started:  start,
+	})
+	return err
+}
+
+// Timings returns the recorded phase timings in the order the phases first ran
+func (c *Cluster) Timings() []PhaseTiming {
+	return c.timings
+}
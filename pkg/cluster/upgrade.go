@@ -0,0 +1,303 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+// kubeletManagedLabels are node labels the kubelet sets itself on registration
+var kubeletManagedLabels = map[string]bool{
+	"kubernetes.io/hostname":         true,
+	"kubernetes.io/os":               true,
+	"kubernetes.io/arch":             true,
+	"beta.kubernetes.io/os":          true,
+	"beta.kubernetes.io/arch":        true,
+	"node-role.kubernetes.io/worker": true,
+}
+
+// UpgradeOptions configure a rolling replacement of worker nodes
+type UpgradeOptions struct {
+	// Image is the node image of the new workers
+	Image string
+	// Nodes limits the upgrade to these node names (default: all workers)
+	Nodes []string
+	// DrainTimeout bounds the eviction of the pods of each node
+	DrainTimeout time.Duration
+	// Force evicts pods no controller manages, which are lost
+	Force bool
+}
+
+// nodeObject is the part of a Kubernetes Node object kept across a replacement
+type nodeObject struct {
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Taints []Taint `json:"taints"`
+	} `json:"spec"`
+}
+
+// UpgradeNodes replaces worker nodes one at a time with nodes of another
+// image, leaving the control-plane alone: a node of the new image joins the
+// pool of each old node under the lowest free index of the pool, with the
+// pool's settings and the labels and taints of the old node object, and
+// once it is Ready the old node is drained and deleted with its container
+// and volumes.
+func UpgradeNodes(cfg *Config, opts UpgradeOptions) error {
+	c, err := NewCluster(cfg)
+	if err != nil {
+		return err
+	}
+	controlPlane, err := GetControlPlaneNode(cfg.Name)
+	if err != nil {
+		return err
+	}
+	if err := checkKubeletSkew(controlPlane.ID, opts.Image); err != nil {
+		return err
+	}
+
+	workers, err := upgradeTargets(cfg.Name, opts.Nodes)
+	if err != nil {
+		return err
+	}
+	for i, worker := range workers {
+		style.Step("Upgrading %s (%d/%d) to %s ⬆️", worker.NodeName(), i+1, len(workers), opts.Image)
+		if err := c.replaceWorker(controlPlane.ID, worker, opts); err != nil {
+			return fmt.Errorf("failed to upgrade %s: %w", worker.NodeName(), err)
+		}
+	}
+	return nil
+}
+
+// upgradeTargets returns the worker nodes to replace, in start order
+func upgradeTargets(name string, nodeNames []string) ([]podman.Container, error) {
+	nodes, err := ListNodes(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("cluster '%s' not found", name)
+	}
+	sortByStartOrder(nodes)
+
+	var workers []podman.Container
+	for _, node := range nodes {
+		if node.Labels[podman.LabelRole] != "worker" {
+			continue
+		}
+		if len(nodeNames) > 0 && !containsString(nodeNames, node.NodeName()) {
+			continue
+		}
+		if node.State != "running" {
+			return nil, fmt.Errorf("node %s is %s; start the cluster first with: kipod start cluster --name %s", node.NodeName(), node.State, name)
+		}
+		workers = append(workers, node)
+	}
+	for _, nodeName := range nodeNames {
+		found := false
+		for _, worker := range workers {
+			found = found || worker.NodeName() == nodeName
+		}
+		if !found {
+			return nil, fmt.Errorf("worker node %q not found in cluster '%s'", nodeName, name)
+		}
+	}
+	if len(workers) == 0 {
+		return nil, fmt.Errorf("cluster '%s' has no worker nodes", name)
+	}
+	return workers, nil
+}
+
+// replaceWorker joins a node of the new image to the pool of a worker node,
+// then drains and deletes the old node, so the pool is never a node short
+func (c *Cluster) replaceWorker(controlPlaneID string, worker podman.Container, opts UpgradeOptions) error {
+	oldName := worker.NodeName()
+	node, err := getNodeObject(controlPlaneID, oldName)
+	if err != nil {
+		return err
+	}
+	labels, taints := preservedNodeSettings(node)
+
+	// The old node's taints include the pool's and any added since
+	pool := c.workerPool(worker.Labels[podman.LabelPool])
+	pool.Taints = taints
+	nodeName, err := c.freeNodeName(pool.Name)
+	if err != nil {
+		return err
+	}
+
+	containerOpts := c.createContainerOptions(nodeName, "worker")
+	containerOpts.Image = opts.Image
+	containerOpts.Labels[podman.LabelPool] = pool.Name
+	workerID, err := c.createNodeContainer(containerOpts, nodeName, "worker")
+	if err != nil {
+		return err
+	}
+
+	// Taints are registered by kubeadm so nothing is scheduled before they
+	// are in place; labels the kubelet may not set itself are added after
+	if err := c.setupWorker(controlPlaneID, workerID, nodeName, nodeName, pool); err != nil {
+		return fmt.Errorf("%w (%s is left in place)", err, oldName)
+	}
+	if len(labels) > 0 {
+		args := []string{"kubectl", "label", "node", nodeName, "--overwrite"}
+		for _, key := range sortedKeys(labels) {
+			args = append(args, fmt.Sprintf("%s=%s", key, labels[key]))
+		}
+		if _, err := podman.Exec(controlPlaneID, args); err != nil {
+			return fmt.Errorf("failed to restore node labels on %s: %w", nodeName, err)
+		}
+	}
+
+	wait := []string{"kubectl", "wait", "--for=condition=Ready", "node/" + nodeName, fmt.Sprintf("--timeout=%s", defaultWaitTimeout)}
+	if _, err := podman.Exec(controlPlaneID, wait); err != nil {
+		return fmt.Errorf("node %s did not become Ready (%s is left in place): %w", nodeName, oldName, err)
+	}
+	style.Info("Joined %s, replacing %s", nodeName, oldName)
+
+	drain := []string{"kubectl", "drain", oldName, "--ignore-daemonsets", "--delete-emptydir-data",
+		fmt.Sprintf("--timeout=%s", opts.DrainTimeout)}
+	if opts.Force {
+		drain = append(drain, "--force")
+	}
+	if output, err := podman.Exec(controlPlaneID, drain); err != nil {
+		return fmt.Errorf("failed to drain the node (the node stays cordoned; uncordon it with kubectl uncordon %s): %w\nOutput:\n%s", oldName, err, output)
+	}
+	if _, err := podman.Exec(controlPlaneID, []string{"kubectl", "delete", "node", oldName}); err != nil {
+		return fmt.Errorf("failed to delete the node object: %w", err)
+	}
+
+	// Remove the container with its image storage and data volumes
+	if err := podman.DeleteContainer(worker.ID); err != nil {
+		return fmt.Errorf("failed to delete container %s: %w", worker.Name, err)
+	}
+	volumes, err := podman.ListVolumes(c.nodeVolumeLabels(oldName), false)
+	if err != nil {
+		style.Info("Warning: %v", err)
+	}
+	for _, volume := range volumes {
+		if err := podman.DeleteVolume(volume.Name); err != nil {
+			style.Info("Warning: failed to delete volume %s: %v", volume.Name, err)
+		}
+	}
+	return nil
+}
+
+// workerPool returns the configured pool a worker belongs to, or the
+// default worker pool for nodes added outside of any pool
+func (c *Cluster) workerPool(name string) NodePool {
+	if name == "" {
+		name = "worker"
+	}
+	for _, pool := range c.config.Pools {
+		if pool.Name == name {
+			return pool
+		}
+	}
+	return NodePool{Name: name}
+}
+
+// freeNodeName returns the name of the lowest index of a pool that no node
+// of the cluster has
+func (c *Cluster) freeNodeName(pool string) (string, error) {
+	nodes, err := ListNodes(c.config.Name)
+	if err != nil {
+		return "", err
+	}
+	taken := make(map[string]bool)
+	for _, node := range nodes {
+		taken[node.NodeName()] = true
+	}
+	for i := 0; ; i++ {
+		if name := c.nodeName(pool, i); !taken[name] {
+			return name, nil
+		}
+	}
+}
+
+// getNodeObject reads the labels and taints of a Kubernetes node
+func getNodeObject(controlPlaneID, nodeName string) (nodeObject, error) {
+	var node nodeObject
+	output, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "node", nodeName, "-o", "json"})
+	if err != nil {
+		return node, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+	if err := json.Unmarshal([]byte(output), &node); err != nil {
+		return node, fmt.Errorf("failed to parse node %s: %w", nodeName, err)
+	}
+	return node, nil
+}
+
+// preservedNodeSettings returns the labels and taints of a node that a
+// replacement should keep, without those the kubelet and node controllers
+// manage, such as the unschedulable taint of a cordoned node
+func preservedNodeSettings(node nodeObject) (map[string]string, []Taint) {
+	labels := make(map[string]string)
+	for key, value := range node.Metadata.Labels {
+		if !kubeletManagedLabels[key] {
+			labels[key] = value
+		}
+	}
+	var taints []Taint
+	for _, taint := range node.Spec.Taints {
+		if strings.HasPrefix(taint.Key, "node.kubernetes.io/") || strings.HasPrefix(taint.Key, "node.cloudprovider.kubernetes.io/") {
+			continue
+		}
+		taints = append(taints, taint)
+	}
+	return labels, taints
+}
+
+// checkKubeletSkew rejects node images that are not kipod node images for
+// this host, or whose kubelet is newer than the API server, which the
+// Kubernetes version skew policy does not allow
+func checkKubeletSkew(controlPlaneID, image string) error {
+	if err := build.ValidateNodeImageArchive(image); err != nil {
+		return err
+	}
+	labels, err := build.GetImageLabels(image)
+	if err != nil {
+		return err
+	}
+	output, err := podman.Exec(controlPlaneID, []string{"kubeadm", "version", "-o", "short"})
+	if err != nil {
+		return fmt.Errorf("failed to read the control-plane version: %w", err)
+	}
+
+	kubelet, server := labels[build.LabelKubernetesVersion], strings.TrimSpace(output)
+	kubeletMinor, err := kubernetesMinor(kubelet)
+	if err != nil {
+		return fmt.Errorf("image %s: %w", image, err)
+	}
+	serverMinor, err := kubernetesMinor(server)
+	if err != nil {
+		return fmt.Errorf("control-plane: %w", err)
+	}
+	if kubeletMinor > serverMinor {
+		return fmt.Errorf("image %s has Kubernetes %s, newer than the control-plane's %s; the kubelet may not be newer than the API server", image, kubelet, server)
+	}
+	if serverMinor-kubeletMinor > 3 {
+		style.Info("Warning: Kubernetes %s of %s is more than three minor releases older than the control-plane's %s, which is not supported", kubelet, image, server)
+	}
+	return nil
+}
+
+// kubernetesMinor returns the minor release of a version like v1.34.2
+func kubernetesMinor(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("invalid Kubernetes version %q", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid Kubernetes version %q", version)
+	}
+	return minor, nil
+}
@@ -0,0 +1,197 @@
+package cluster
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/kubeconfig"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// DefaultUserRole is the ClusterRole bound to generated users by default
+const DefaultUserRole = "view"
+
+// csrTimeout bounds the wait for kube-controller-manager to sign a user CSR
+const csrTimeout = time.Minute
+
+// UserOptions describe an RBAC-limited user of a cluster
+type UserOptions struct {
+	User   string
+	Groups []string
+	// ClusterRole is bound to the user in every namespace of Namespaces, or
+	// cluster-wide when Namespaces is empty
+	ClusterRole string
+	Namespaces  []string
+}
+
+// userCSRManifest asks the API server's client signer for a user certificate
+const userCSRManifest = `apiVersion: certificates.k8s.io/v1
+kind: CertificateSigningRequest
+metadata:
+  name: %s
+  labels:
+    app.kubernetes.io/managed-by: kipod
+spec:
+  request: %s
+  signerName: kubernetes.io/kube-apiserver-client
+  usages:
+  - client auth`
+
+// userRoleBindingManifest binds a ClusterRole to a user in one namespace
+const userRoleBindingManifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: %[2]s
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+  labels:
+    app.kubernetes.io/managed-by: kipod
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: %[3]s
+subjects:
+- apiGroup: rbac.authorization.k8s.io
+  kind: User
+  name: %[4]q`
+
+// userClusterRoleBindingManifest binds a ClusterRole to a user cluster-wide
+const userClusterRoleBindingManifest = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: %[1]s
+  labels:
+    app.kubernetes.io/managed-by: kipod
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: %[2]s
+subjects:
+- apiGroup: rbac.authorization.k8s.io
+  kind: User
+  name: %[3]q`
+
+// invalidObjectNameChars are replaced when a user name becomes an object name
+var invalidObjectNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// UserKubeconfig creates a client certificate for opts.User signed by the
+// cluster CA through a CertificateSigningRequest, binds opts.ClusterRole to
+// the user and returns a kubeconfig authenticating as that user. The
+// kubeconfig addresses the API server like the admin kubeconfig does.
+func UserKubeconfig(name string, opts UserOptions) (string, error) {
+	if opts.User == "" {
+		return "", fmt.Errorf("a user name is required")
+	}
+	if opts.ClusterRole == "" {
+		opts.ClusterRole = DefaultUserRole
+	}
+
+	controlPlane, err := GetControlPlaneNode(name)
+	if err != nil {
+		return "", err
+	}
+
+	objectName := "kipod-user-" + strings.Trim(invalidObjectNameChars.ReplaceAllString(strings.ToLower(opts.User), "-"), "-.")
+	certPEM, keyPEM, err := signUserCertificate(controlPlane.ID, objectName, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := bindUserRole(controlPlane.ID, objectName, opts); err != nil {
+		return "", err
+	}
+
+	admin, err := GetKubeconfig(name)
+	if err != nil {
+		return "", err
+	}
+	namespace := ""
+	if len(opts.Namespaces) > 0 {
+		namespace = opts.Namespaces[0]
+	}
+	cfg, err := kubeconfig.ForUser([]byte(admin), name, opts.User, certPEM, keyPEM, namespace)
+	if err != nil {
+		return "", err
+	}
+	data, err := cfg.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// signUserCertificate generates a key for the user, has the cluster sign a
+// CSR for it and returns the PEM certificate and key. The user name becomes
+// the certificate CN and the groups its organizations, which is how the API
+// server maps client certificates to users.
+func signUserCertificate(controlPlaneID, csrName string, opts UserOptions) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key for %s: %w", opts.User, err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: opts.User, Organization: opts.Groups},
+	}, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate request for %s: %w", opts.User, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode key for %s: %w", opts.User, err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+
+	// A CSR cannot be updated once approved, so any previous one is replaced
+	if _, err := podman.Exec(controlPlaneID, []string{"kubectl", "delete", "csr", csrName, "--ignore-not-found"}); err != nil {
+		return nil, nil, fmt.Errorf("failed to delete the previous CSR %s: %w", csrName, err)
+	}
+	if err := applyManifest(controlPlaneID, fmt.Sprintf(userCSRManifest, csrName, base64.StdEncoding.EncodeToString(csrPEM))); err != nil {
+		return nil, nil, err
+	}
+	if _, err := podman.Exec(controlPlaneID, []string{"kubectl", "certificate", "approve", csrName}); err != nil {
+		return nil, nil, fmt.Errorf("failed to approve CSR %s: %w", csrName, err)
+	}
+
+	deadline := time.Now().Add(csrTimeout)
+	for time.Now().Before(deadline) {
+		output, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "csr", csrName, "-o", "jsonpath={.status.certificate}"})
+		if err == nil && strings.TrimSpace(output) != "" {
+			certPEM, err := base64.StdEncoding.DecodeString(strings.TrimSpace(output))
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to decode the certificate of CSR %s: %w", csrName, err)
+			}
+			return certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+		}
+		time.Sleep(time.Second)
+	}
+	return nil, nil, fmt.Errorf("CSR %s was not signed within %s", csrName, csrTimeout)
+}
+
+// bindUserRole binds opts.ClusterRole to the user in each of opts.Namespaces,
+// creating missing namespaces, or cluster-wide without namespaces
+func bindUserRole(controlPlaneID, bindingName string, opts UserOptions) error {
+	var manifests []string
+	if len(opts.Namespaces) == 0 {
+		manifests = append(manifests, fmt.Sprintf(userClusterRoleBindingManifest, bindingName, opts.ClusterRole, opts.User))
+	}
+	for _, namespace := range opts.Namespaces {
+		manifests = append(manifests, fmt.Sprintf(userRoleBindingManifest, bindingName, namespace, opts.ClusterRole, opts.User))
+	}
+	if err := applyManifest(controlPlaneID, strings.Join(manifests, "\n---\n")); err != nil {
+		return fmt.Errorf("failed to bind role %s to %s: %w", opts.ClusterRole, opts.User, err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package cluster
+
+import (
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// OrphanedVolumes returns the kipod volumes no container uses, including
+// the unlabeled storage and data volumes of older releases
+func OrphanedVolumes() ([]podman.Volume, error) {
+	volumes, err := podman.ListVolumes(nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphaned []podman.Volume
+	for _, volume := range volumes {
+		if volume.Labels[podman.LabelManaged] == "true" ||
+			strings.HasPrefix(volume.Name, "kipod-storage-") ||
+			strings.HasPrefix(volume.Name, "kipod-data-") {
+			orphaned = append(orphaned, volume)
+		}
+	}
+	return orphaned, nil
+}
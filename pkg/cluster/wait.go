@@ -0,0 +1,251 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+	"github.com/sohankunkerkar/kipod/pkg/style"
+)
+
+const (
+	// defaultWaitTimeout bounds the API server and --wait-all waits
+	defaultWaitTimeout = 5 * time.Minute
+
+	// ciWaitTimeout is the default in CI, where a stuck cluster should fail fast
+	ciWaitTimeout = 3 * time.Minute
+
+	// waitProbePod is the pod --wait schedules to check workloads can run
+	waitProbePod = "kipod-wait-probe"
+)
+
+// Reasons of a WaitError, stable for scripts to match on
+const (
+	WaitReasonWorkersNotJoined   = "WorkersNotJoined"
+	WaitReasonNodesNotReady      = "NodesNotReady"
+	WaitReasonDaemonSetsNotReady = "DaemonSetsNotReady"
+	WaitReasonProbeUnschedulable = "ProbeUnschedulable"
+	WaitReasonProbeNotRunning    = "ProbeNotRunning"
+)
+
+// WaitError is returned when the cluster does not become able to run
+// workloads within the wait duration
+type WaitError struct {
+	// Reason is one of the WaitReason constants
+	Reason string
+	// Message details what was not ready
+	Message string
+}
+
+func (e *WaitError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Message)
+}
+
+// waitTimeout returns the configured wait duration or the default
+func (c *Cluster) waitTimeout() time.Duration {
+	if c.config.WaitDuration > 0 {
+		return c.config.WaitDuration
+	}
+	if c.config.CI {
+		return ciWaitTimeout
+	}
+	return defaultWaitTimeout
+}
+
+// waitAllReady waits until every node and every kube-system pod is Ready
+func (c *Cluster) waitAllReady(controlPlaneID string) error {
+	timeout := c.waitTimeout()
+	style.Step("Waiting ≤ %s for all nodes and system pods = Ready ⏳", timeout)
+
+	deadline := time.Now().Add(timeout)
+	waits := []struct {
+		what string
+		args []string
+	}{
+		{"nodes", []string{"kubectl", "wait", "--for=condition=Ready", "nodes", "--all"}},
+		{"kube-system pods", []string{"kubectl", "wait", "--for=condition=Ready", "pods", "--all", "-n", "kube-system"}},
+	}
+	for _, wait := range waits {
+		remaining := time.Until(deadline).Round(time.Second)
+		if remaining <= 0 {
+			remaining = time.Second
+		}
+		args := append(wait.args, fmt.Sprintf("--timeout=%s", remaining))
+		if _, err := podman.Exec(controlPlaneID, args); err != nil {
+			status, _ := podman.Exec(controlPlaneID, []string{"kubectl", "get", "nodes,pods", "-A", "-o", "wide"})
+			return fmt.Errorf("%s not Ready within %s: %w\nCluster status:\n%s", wait.what, timeout, err, status)
+		}
+	}
+	return nil
+}
+
+// printDiagnostics prints the recent crio and kubelet logs of the nodes
+// created so far, so a failed CI run explains itself before cleanup
+func (c *Cluster) printDiagnostics() {
+	for _, nodeID := range c.nodeIDs {
+		logs, err := podman.Exec(nodeID, []string{"journalctl", "-u", "crio", "-u", "kubelet", "-n", "40", "--no-pager"})
+		if err != nil {
+			continue
+		}
+		// Node hostnames are their node names
+		name, _ := podman.Exec(nodeID, []string{"hostname"})
+		style.Header("--- Recent crio/kubelet logs of %s ---", strings.TrimSpace(name))
+		style.Header("%s", strings.TrimRight(logs, "\n"))
+	}
+}
+
+// waitSchedulable waits until every configured node has joined and is Ready,
+// all DaemonSets (kube-proxy, a CNI) are rolled out, and a probe pod reaches
+// Running, so workloads can be deployed right after create
+func (c *Cluster) waitSchedulable(controlPlaneID string) error {
+	timeout := c.waitTimeout()
+	style.Step("Waiting ≤ %s for the cluster to run workloads ⏳", timeout)
+	deadline := time.Now().Add(timeout)
+
+	expected := []string{c.nodeName("control-plane", 0)}
+	for _, pool := range c.config.Pools {
+		for i := 0; i < pool.Count; i++ {
+			expected = append(expected, c.nodeName(pool.Name, i))
+		}
+	}
+	if err := waitUntil(deadline, func() *WaitError { return nodesReady(controlPlaneID, expected) }); err != nil {
+		return err
+	}
+	if err := waitUntil(deadline, func() *WaitError { return daemonSetsReady(controlPlaneID) }); err != nil {
+		return err
+	}
+	return runWaitProbe(controlPlaneID, c.config.ImageRepository, deadline)
+}
+
+// waitUntil polls check until it passes, returning its last failure once
+// the deadline passes
+func waitUntil(deadline time.Time, check func() *WaitError) error {
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// nodesReady checks that the expected nodes are registered and all nodes
+// are Ready
+func nodesReady(controlPlaneID string, expected []string) *WaitError {
+	output, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "nodes", "-o",
+		`jsonpath={range .items[*]}{.metadata.name}{" "}{.status.conditions[?(@.type=="Ready")].status}{"\n"}{end}`})
+	if err != nil {
+		return &WaitError{Reason: WaitReasonNodesNotReady, Message: fmt.Sprintf("failed to list nodes: %v", err)}
+	}
+	ready := make(map[string]bool)
+	var notReady []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		ready[fields[0]] = len(fields) == 2 && fields[1] == "True"
+		if !ready[fields[0]] {
+			notReady = append(notReady, fields[0])
+		}
+	}
+
+	var missing []string
+	for _, name := range expected {
+		if _, ok := ready[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return &WaitError{Reason: WaitReasonWorkersNotJoined, Message: "nodes not registered: " + strings.Join(missing, ", ")}
+	}
+	if len(notReady) > 0 {
+		sort.Strings(notReady)
+		return &WaitError{Reason: WaitReasonNodesNotReady, Message: "nodes not Ready: " + strings.Join(notReady, ", ")}
+	}
+	return nil
+}
+
+// daemonSetsReady checks that every DaemonSet has its pods updated and Ready
+func daemonSetsReady(controlPlaneID string) *WaitError {
+	output, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "daemonsets", "-A", "-o",
+		`jsonpath={range .items[*]}{.metadata.namespace}/{.metadata.name}{" "}{.status.desiredNumberScheduled}{" "}{.status.updatedNumberScheduled}{" "}{.status.numberReady}{"\n"}{end}`})
+	if err != nil {
+		return &WaitError{Reason: WaitReasonDaemonSetsNotReady, Message: fmt.Sprintf("failed to list DaemonSets: %v", err)}
+	}
+	var pending []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// Counts are omitted while zero
+		for len(fields) < 4 {
+			fields = append(fields, "0")
+		}
+		if fields[1] != fields[2] || fields[1] != fields[3] {
+			pending = append(pending, fmt.Sprintf("%s (%s/%s ready)", fields[0], fields[3], fields[1]))
+		}
+	}
+	if len(pending) > 0 {
+		return &WaitError{Reason: WaitReasonDaemonSetsNotReady, Message: "DaemonSets not rolled out: " + strings.Join(pending, ", ")}
+	}
+	return nil
+}
+
+// runWaitProbe schedules a pause pod and waits for it to run, then deletes it
+func runWaitProbe(controlPlaneID, repository string, deadline time.Time) error {
+	// The pause image is on every node already, or pulled from the mirror
+	image, err := pauseImage(controlPlaneID, repository)
+	if err != nil {
+		return err
+	}
+	manifest := fmt.Sprintf(`apiVersion: v1
+kind: Pod
+metadata:
+  name: %s
+  namespace: default
+  labels:
+    app.kubernetes.io/managed-by: kipod
+spec:
+  restartPolicy: Never
+  terminationGracePeriodSeconds: 0
+  automountServiceAccountToken: false
+  containers:
+  - name: pause
+    image: %s
+    imagePullPolicy: IfNotPresent`, waitProbePod, image)
+	defer func() {
+		_, _ = podman.Exec(controlPlaneID, []string{"kubectl", "delete", "pod", "-n", "default", waitProbePod, "--wait=false", "--ignore-not-found"})
+	}()
+
+	return waitUntil(deadline, func() *WaitError {
+		// The default ServiceAccount may not exist yet, so keep applying
+		if err := applyManifest(controlPlaneID, manifest); err != nil {
+			return &WaitError{Reason: WaitReasonProbeUnschedulable, Message: fmt.Sprintf("failed to create the probe pod: %v", err)}
+		}
+		output, err := podman.Exec(controlPlaneID, []string{"kubectl", "get", "pod", "-n", "default", waitProbePod, "-o",
+			`jsonpath={.status.phase}|{.spec.nodeName}|{.status.conditions[?(@.type=="PodScheduled")].message}|{.status.containerStatuses[0].state.waiting.reason}`})
+		if err != nil {
+			return &WaitError{Reason: WaitReasonProbeUnschedulable, Message: fmt.Sprintf("failed to get the probe pod: %v", err)}
+		}
+		status := strings.SplitN(strings.TrimSpace(output), "|", 4)
+		for len(status) < 4 {
+			status = append(status, "")
+		}
+		phase, node, scheduling, waiting := status[0], status[1], status[2], status[3]
+		switch {
+		case phase == "Running":
+			return nil
+		case node == "":
+			return &WaitError{Reason: WaitReasonProbeUnschedulable, Message: "probe pod not scheduled: " + scheduling}
+		default:
+			return &WaitError{Reason: WaitReasonProbeNotRunning, Message: fmt.Sprintf("probe pod on %s is %s %s", node, phase, waiting)}
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package cluster
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// hostGatewayName is the name podman resolves to the host in containers
+const hostGatewayName = "host.containers.internal"
+
+// Files of a webhook serving certificate directory, named like the
+// controller-runtime defaults
+const (
+	webhookCAFile   = "ca.crt"
+	webhookCertFile = "tls.crt"
+	webhookKeyFile  = "tls.key"
+)
+
+// WebhookOptions describe a webhook server running on the host
+type WebhookOptions struct {
+	Name      string
+	Namespace string
+	// HostPort is the port the webhook server listens on, on the host
+	HostPort int
+	// CertDir receives the CA and serving certificate; existing ones are reused
+	CertDir string
+}
+
+// WebhookEndpoint is a Service routing to a webhook server on the host
+type WebhookEndpoint struct {
+	Service  string
+	HostIP   string
+	CertDir  string
+	CABundle string // base64 encoded, for clientConfig.caBundle
+}
+
+// webhookManifest is a selector-less Service whose EndpointSlice points at the host
+const webhookManifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: %[2]s
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+  labels:
+    app.kubernetes.io/managed-by: kipod
+spec:
+  ports:
+  - name: https
+    port: 443
+    targetPort: %[3]d
+---
+apiVersion: discovery.k8s.io/v1
+kind: EndpointSlice
+metadata:
+  name: %[1]s-host
+  namespace: %[2]s
+  labels:
+    kubernetes.io/service-name: %[1]s
+    endpointslice.kubernetes.io/managed-by: kipod
+addressType: IPv4
+ports:
+- name: https
+  port: %[3]d
+  protocol: TCP
+endpoints:
+- addresses:
+  - %[4]s`
+
+// DevWebhook creates a Service in the cluster that routes to a webhook
+// server on the host, and a serving certificate for it in opts.CertDir
+func DevWebhook(name string, opts WebhookOptions) (*WebhookEndpoint, error) {
+	controlPlane, err := GetControlPlaneNode(name)
+	if err != nil {
+		return nil, err
+	}
+
+	hostIP, err := hostGatewayIP(controlPlane.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	caPEM, err := webhookCertificates(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := fmt.Sprintf(webhookManifest, opts.Name, opts.Namespace, opts.HostPort, hostIP)
+	if err := applyManifest(controlPlane.ID, manifest); err != nil {
+		return nil, err
+	}
+
+	return &WebhookEndpoint{
+		Service:  fmt.Sprintf("%s.%s.svc", opts.Name, opts.Namespace),
+		HostIP:   hostIP,
+		CertDir:  opts.CertDir,
+		CABundle: base64.StdEncoding.EncodeToString(caPEM),
+	}, nil
+}
+
+// DeleteDevWebhook removes the Service created by DevWebhook
+func DeleteDevWebhook(name string, opts WebhookOptions) error {
+	controlPlane, err := GetControlPlaneNode(name)
+	if err != nil {
+		return err
+	}
+	if _, err := podman.Exec(controlPlane.ID, []string{
+		"kubectl", "delete", "-n", opts.Namespace, "--ignore-not-found",
+		"service/" + opts.Name, "endpointslice/" + opts.Name + "-host",
+	}); err != nil {
+		return fmt.Errorf("failed to delete webhook service %s: %w", opts.Name, err)
+	}
+	return nil
+}
+
+// webhookCertificates returns the CA of the serving certificate in
+// opts.CertDir, generating both unless the directory already holds a key pair
+// for the Service, so a running webhook server keeps working
+func webhookCertificates(opts WebhookOptions) ([]byte, error) {
+	service := fmt.Sprintf("%s.%s.svc", opts.Name, opts.Namespace)
+	if caPEM, ok := existingWebhookCertificates(opts.CertDir, service); ok {
+		return caPEM, nil
+	}
+
+	ca, err := newCertificate(opts.Name+"-webhook-ca", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	serving, err := newCertificate(service, ca,
+		[]string{opts.Name, opts.Name + "." + opts.Namespace, service, service + ".cluster.local", "localhost"},
+		[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writePEMFiles(opts.CertDir, map[string][]byte{
+		webhookCAFile:   ca.Cert,
+		webhookCertFile: serving.Cert,
+		webhookKeyFile:  serving.Key,
+	}); err != nil {
+		return nil, err
+	}
+	return ca.Cert, nil
+}
+
+// existingWebhookCertificates returns the CA in certDir when it also holds a
+// key and a certificate valid for service
+func existingWebhookCertificates(certDir, service string) ([]byte, bool) {
+	caPEM, err := os.ReadFile(filepath.Join(certDir, webhookCAFile))
+	if err != nil {
+		return nil, false
+	}
+	if _, err := os.Stat(filepath.Join(certDir, webhookKeyFile)); err != nil {
+		return nil, false
+	}
+	certPEM, err := os.ReadFile(filepath.Join(certDir, webhookCertFile))
+	if err != nil {
+		return nil, false
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || cert.VerifyHostname(service) != nil || time.Now().After(cert.NotAfter) {
+		return nil, false
+	}
+	return caPEM, true
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MaxFakeNodes bounds the simulated nodes of the kwok addon
+const MaxFakeNodes = 5000
+
+var addonVersionRegexp = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// AddonsConfig enables components kipod installs after the cluster is Ready
+type AddonsConfig struct {
+	// CertManager deploys cert-manager with a self-signed CA ClusterIssuer
+	CertManager CertManagerAddon `yaml:"certManager,omitempty" json:"certManager,omitempty"`
+
+	// Kwok registers simulated nodes, for scale testing without node containers
+	Kwok KwokAddon `yaml:"kwok,omitempty" json:"kwok,omitempty"`
+}
+
+// CertManagerAddon configures the cert-manager addon
+type CertManagerAddon struct {
+	// Enabled installs cert-manager and the kipod-ca ClusterIssuer
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Version of cert-manager, e.g. "1.16.2" (default: the release kipod pins)
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+
+	// TrustOnHost offers to add the CA to the host trust store after create
+	TrustOnHost bool `yaml:"trustOnHost,omitempty" json:"trustOnHost,omitempty"`
+}
+
+// KwokAddon configures simulated nodes run by kwok
+type KwokAddon struct {
+	// Nodes is the number of fake nodes to register; 0 disables the addon
+	Nodes int `yaml:"nodes,omitempty" json:"nodes,omitempty"`
+
+	// Version of kwok, e.g. "0.6.1" (default: the release kipod pins)
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+}
+
+func (a AddonsConfig) validate() error {
+	certManager := a.CertManager
+	if certManager.Version != "" && !addonVersionRegexp.MatchString(certManager.Version) {
+		return fmt.Errorf("addons.certManager.version must be a release like 1.16.2, got: %s", certManager.Version)
+	}
+	if !certManager.Enabled && (certManager.Version != "" || certManager.TrustOnHost) {
+		return fmt.Errorf("addons.certManager options require addons.certManager.enabled")
+	}
+
+	kwok := a.Kwok
+	if kwok.Nodes < 0 || kwok.Nodes > MaxFakeNodes {
+		return fmt.Errorf("addons.kwok.nodes must be between 0 and %d, got: %d", MaxFakeNodes, kwok.Nodes)
+	}
+	if kwok.Version != "" && !addonVersionRegexp.MatchString(kwok.Version) {
+		return fmt.Errorf("addons.kwok.version must be a release like 0.6.1, got: %s", kwok.Version)
+	}
+	if kwok.Nodes == 0 && kwok.Version != "" {
+		return fmt.Errorf("addons.kwok.version requires addons.kwok.nodes")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldChange is a config field whose value differs between two configs
+type FieldChange struct {
+	// Path is the dotted config path, e.g. networking.podSubnet
+	Path string
+	// Old and New summarize the values, empty when unset
+	Old string
+	New string
+}
+
+// Changes returns the fields that differ from old to new, in config order.
+// Lists are compared as a whole; maps have a field per key.
+func Changes(old, new *ClusterConfig) []FieldChange {
+	var changes []FieldChange
+	diffValues(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &changes)
+	return changes
+}
+
+// diffValues appends the leaves that differ between a and b, which have the
+// same type. It walks the config like collectValues.
+func diffValues(a, b reflect.Value, path string, changes *[]FieldChange) {
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*changes = append(*changes, FieldChange{Path: path, Old: summarizeValue(a), New: summarizeValue(b)})
+			}
+			return
+		}
+		diffValues(a.Elem(), b.Elem(), path, changes)
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			switch {
+			case name == "-", path == "" && (name == "apiVersion" || name == "kind"):
+			case options == "inline":
+				diffValues(a.Field(i), b.Field(i), path, changes)
+			default:
+				if name == "" {
+					name = strings.ToLower(field.Name)
+				}
+				diffValues(a.Field(i), b.Field(i), joinPath(path, name), changes)
+			}
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, key := range append(a.MapKeys(), b.MapKeys()...) {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			av, bv := a.MapIndex(keys[name]), b.MapIndex(keys[name])
+			if av.IsValid() && bv.IsValid() && reflect.DeepEqual(av.Interface(), bv.Interface()) {
+				continue
+			}
+			*changes = append(*changes, FieldChange{Path: joinPath(path, name), Old: summarizeValue(av), New: summarizeValue(bv)})
+		}
+	case reflect.Slice, reflect.Array:
+		if a.Len() == 0 && b.Len() == 0 {
+			return
+		}
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changes = append(*changes, FieldChange{Path: path, Old: summarizeValue(a), New: summarizeValue(b)})
+		}
+	default:
+		if a.Interface() != b.Interface() {
+			*changes = append(*changes, FieldChange{Path: path, Old: summarizeValue(a), New: summarizeValue(b)})
+		}
+	}
+}
+
+// summarizeValue formats a config value the way Fields does, or "" when
+// it is unset
+func summarizeValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	var fields []ResolvedField
+	collectValues(v, "", &fields)
+	values := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Path == "" {
+			values = append(values, field.Value)
+		} else {
+			values = append(values, field.Path+"="+field.Value)
+		}
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,54 @@
+package config
+
+import "fmt"
+
+// FeaturesConfig turns on experimental features that need CRI-O and the
+// kubelet configured together
+type FeaturesConfig struct {
+	// EventedPLEG makes the kubelet learn of container changes from CRI-O
+	// events instead of relisting pods: CRI-O's enable_pod_events and the
+	// kubelet's EventedPLEG feature gate. crioProfile evented-pleg implies it.
+	EventedPLEG bool `yaml:"eventedPLEG,omitempty" json:"eventedPLEG,omitempty"`
+
+	// CheckpointRestore allows checkpointing running containers with CRIU
+	// through the kubelet API: CRI-O's enable_criu_support and the kubelet's
+	// ContainerCheckpoint feature gate
+	CheckpointRestore bool `yaml:"checkpointRestore,omitempty" json:"checkpointRestore,omitempty"`
+
+	// ImageVolume allows pods to mount OCI images and artifacts as volumes
+	// (volumes[].image): the ImageVolume feature gate of the API server and
+	// the kubelet. crioProfile image-volume implies it.
+	ImageVolume bool `yaml:"imageVolume,omitempty" json:"imageVolume,omitempty"`
+}
+
+// featureGatesSince maps the feature gates of features to the Kubernetes
+// minor version that introduced them
+var featureGatesSince = map[string]int{
+	"EventedPLEG":         26,
+	"ContainerCheckpoint": 25,
+	"ImageVolume":         31,
+}
+
+// validateFeatures checks the requested Kubernetes version has the feature
+// gates of the enabled features
+func (c *ClusterConfig) validateFeatures() error {
+	if c.Versions.Kubernetes == "" {
+		return nil
+	}
+	minor, err := extractMinorVersion(c.Versions.Kubernetes)
+	if err != nil {
+		return nil
+	}
+
+	enabled := map[string]bool{
+		"EventedPLEG":         c.Features.EventedPLEG || c.CRIOProfile == "evented-pleg",
+		"ContainerCheckpoint": c.Features.CheckpointRestore,
+		"ImageVolume":         c.Features.ImageVolume || c.CRIOProfile == "image-volume",
+	}
+	for _, gate := range []string{"EventedPLEG", "ContainerCheckpoint", "ImageVolume"} {
+		if enabled[gate] && minor < featureGatesSince[gate] {
+			return fmt.Errorf("features: the %s feature gate requires Kubernetes 1.%d or later, got %s", gate, featureGatesSince[gate], c.Versions.Kubernetes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// helmReleaseNameRegexp matches the release names Helm accepts
+var helmReleaseNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// HelmChart is a Helm chart installed after the cluster is created
+type HelmChart struct {
+	// Name is the release name (default: the chart name)
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Namespace to install the release into, created if missing (default: default)
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Repo is the chart repository URL; leave empty for oci:// chart references
+	Repo string `yaml:"repo,omitempty" json:"repo,omitempty"`
+
+	// Chart is the chart name in Repo, or an oci:// reference
+	Chart string `yaml:"chart" json:"chart"`
+
+	// Version of the chart (default: latest)
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+
+	// Values are inline chart values, applied on top of ValuesFile
+	Values map[string]interface{} `yaml:"values,omitempty" json:"values,omitempty"`
+
+	// ValuesFile is a path to a values file on the host
+	ValuesFile string `yaml:"valuesFile,omitempty" json:"valuesFile,omitempty"`
+}
+
+// ReleaseName returns the release name, defaulting to the chart name
+func (h HelmChart) ReleaseName() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return path.Base(h.Chart)
+}
+
+// validateHelmCharts checks that every chart can be located and that release
+// names are unique per namespace
+func (c *ClusterConfig) validateHelmCharts() error {
+	seen := map[string]bool{}
+	for i, chart := range c.HelmCharts {
+		field := fmt.Sprintf("helmCharts[%d]", i)
+		if chart.Chart == "" {
+			return fmt.Errorf("%s: chart is required", field)
+		}
+		isOCI := strings.HasPrefix(chart.Chart, "oci://")
+		if chart.Repo == "" && !isOCI {
+			return fmt.Errorf("%s: repo is required unless chart is an oci:// reference", field)
+		}
+		if chart.Repo != "" && isOCI {
+			return fmt.Errorf("%s: repo cannot be used with an oci:// chart", field)
+		}
+		if err := validateSourceURL(field+".repo", chart.Repo); err != nil {
+			return err
+		}
+
+		name := chart.ReleaseName()
+		if !helmReleaseNameRegexp.MatchString(name) || len(name) > 53 {
+			return fmt.Errorf("%s: invalid release name %q", field, name)
+		}
+		key := chart.Namespace + "/" + name
+		if seen[key] {
+			return fmt.Errorf("%s: duplicate release %s", field, name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
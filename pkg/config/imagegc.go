@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kubelet defaults of the image garbage collection thresholds
+const (
+	defaultImageGCHighThresholdPercent = 85
+	defaultImageGCLowThresholdPercent  = 80
+)
+
+// ImageGCConfig sets when the kubelet garbage collects unused images, which
+// matters with tmpfs storage, where images fill up memory
+type ImageGCConfig struct {
+	// HighThresholdPercent is the image storage usage that triggers garbage
+	// collection (kubelet default 85; 100 disables it)
+	HighThresholdPercent int `yaml:"highThresholdPercent,omitempty" json:"highThresholdPercent,omitempty"`
+
+	// LowThresholdPercent is the usage garbage collection frees space down
+	// to (kubelet default 80)
+	LowThresholdPercent int `yaml:"lowThresholdPercent,omitempty" json:"lowThresholdPercent,omitempty"`
+
+	// MinimumAge is how long an unused image is kept, e.g. "30s" (kubelet
+	// default 2m)
+	MinimumAge string `yaml:"minimumAge,omitempty" json:"minimumAge,omitempty"`
+}
+
+func (g ImageGCConfig) validate() error {
+	for field, value := range map[string]int{
+		"imageGC.highThresholdPercent": g.HighThresholdPercent,
+		"imageGC.lowThresholdPercent":  g.LowThresholdPercent,
+	} {
+		if value < 0 || value > 100 {
+			return fmt.Errorf("%s must be between 0 and 100, got: %d", field, value)
+		}
+	}
+
+	high, low := g.HighThresholdPercent, g.LowThresholdPercent
+	if high == 0 {
+		high = defaultImageGCHighThresholdPercent
+	}
+	if low == 0 {
+		low = defaultImageGCLowThresholdPercent
+	}
+	if low >= high {
+		return fmt.Errorf("imageGC.lowThresholdPercent (%d) must be below imageGC.highThresholdPercent (%d, kubelet default %d)", low, high, defaultImageGCHighThresholdPercent)
+	}
+
+	if g.MinimumAge != "" {
+		if d, err := time.ParseDuration(g.MinimumAge); err != nil || d < 0 {
+			return fmt.Errorf("imageGC.minimumAge must be a duration like 30s, got: %s", g.MinimumAge)
+		}
+	}
+	return nil
+}
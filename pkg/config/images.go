@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// imageRefRegexp matches image references such as
+// registry.example.com:5000/kube-apiserver:v1.31.0-dev or repo@sha256:<digest>
+var imageRefRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/:-]*(@sha256:[a-f0-9]{64})?$`)
+
+// imageRepositoryRegexp matches image repository prefixes such as
+// mirror.example.com:5000/k8s
+var imageRepositoryRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9._:/-]*[a-z0-9]$`)
+
+// imageTagRegexp matches image tags
+var imageTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+
+// DNSConfig configures the CoreDNS image kubeadm deploys
+type DNSConfig struct {
+	// ImageRepository replaces the repository of the CoreDNS image, which
+	// is then <imageRepository>/coredns
+	ImageRepository string `yaml:"imageRepository,omitempty" json:"imageRepository,omitempty"`
+
+	// ImageTag replaces the CoreDNS image tag kubeadm picks
+	ImageTag string `yaml:"imageTag,omitempty" json:"imageTag,omitempty"`
+}
+
+// ComponentImagesConfig replaces the images of the control-plane static pods
+// kubeadm sets up, e.g. to test patched Kubernetes builds
+type ComponentImagesConfig struct {
+	// APIServer is the kube-apiserver image
+	APIServer string `yaml:"apiServer,omitempty" json:"apiServer,omitempty"`
+
+	// ControllerManager is the kube-controller-manager image
+	ControllerManager string `yaml:"controllerManager,omitempty" json:"controllerManager,omitempty"`
+
+	// Scheduler is the kube-scheduler image
+	Scheduler string `yaml:"scheduler,omitempty" json:"scheduler,omitempty"`
+
+	// Etcd is the image of stacked etcd
+	Etcd string `yaml:"etcd,omitempty" json:"etcd,omitempty"`
+}
+
+// StaticPodImages returns the configured images by static pod name
+func (i ComponentImagesConfig) StaticPodImages() map[string]string {
+	images := make(map[string]string)
+	for pod, image := range map[string]string{
+		"kube-apiserver":          i.APIServer,
+		"kube-controller-manager": i.ControllerManager,
+		"kube-scheduler":          i.Scheduler,
+		"etcd":                    i.Etcd,
+	} {
+		if image != "" {
+			images[pod] = image
+		}
+	}
+	return images
+}
+
+func (c *ClusterConfig) validateComponentImages() error {
+	for field, image := range map[string]string{
+		"apiServer":         c.ComponentImages.APIServer,
+		"controllerManager": c.ComponentImages.ControllerManager,
+		"scheduler":         c.ComponentImages.Scheduler,
+		"etcd":              c.ComponentImages.Etcd,
+	} {
+		if image != "" && !imageRefRegexp.MatchString(image) {
+			return fmt.Errorf("componentImages.%s must be an image reference, got: %q", field, image)
+		}
+	}
+	if c.ComponentImages.Scheduler != "" && (c.Scheduler.Image != "" || c.LocalBuilds.KubeSchedulerBinary != "") {
+		return fmt.Errorf("componentImages.scheduler cannot be combined with scheduler.image or localBuilds.kubeSchedulerBinary")
+	}
+	if c.ComponentImages.Etcd != "" && c.Etcd.External {
+		return fmt.Errorf("componentImages.etcd applies to stacked etcd; use etcd.image with etcd.external")
+	}
+	return nil
+}
+
+// validateImageRepositories checks the mirrors replacing registry.k8s.io
+func (c *ClusterConfig) validateImageRepositories() error {
+	for field, repository := range map[string]string{
+		"imageRepository":      c.ImageRepository,
+		"dns.imageRepository":  c.DNS.ImageRepository,
+		"etcd.imageRepository": c.Etcd.ImageRepository,
+	} {
+		if repository != "" && !imageRepositoryRegexp.MatchString(repository) {
+			return fmt.Errorf("%s must be an image repository like mirror.example.com/k8s, got: %q", field, repository)
+		}
+	}
+	for field, tag := range map[string]string{
+		"dns.imageTag":  c.DNS.ImageTag,
+		"etcd.imageTag": c.Etcd.ImageTag,
+	} {
+		if tag != "" && !imageTagRegexp.MatchString(tag) {
+			return fmt.Errorf("%s must be an image tag, got: %q", field, tag)
+		}
+	}
+
+	if c.Etcd.ImageRepository != "" || c.Etcd.ImageTag != "" {
+		if c.Etcd.Image != "" {
+			return fmt.Errorf("etcd.image cannot be combined with etcd.imageRepository or etcd.imageTag")
+		}
+		if c.ComponentImages.Etcd != "" {
+			return fmt.Errorf("componentImages.etcd cannot be combined with etcd.imageRepository or etcd.imageTag")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KubeadmConfig controls how kipod runs kubeadm init
+type KubeadmConfig struct {
+	// SkipPhases are kubeadm init phases to skip, e.g. addon/kube-proxy when
+	// a CNI such as Cilium replaces it
+	SkipPhases []string `yaml:"skipPhases,omitempty" json:"skipPhases,omitempty"`
+
+	// ExtraInitArgs are extra flags for kubeadm init. With a kubeadm config
+	// file only the flags kubeadm allows next to --config may be used.
+	ExtraInitArgs []string `yaml:"extraInitArgs,omitempty" json:"extraInitArgs,omitempty"`
+}
+
+// kubeadmInitPhases maps kubeadm init phases to the Kubernetes minor version
+// that introduced them (0 for phases available in every supported release)
+var kubeadmInitPhases = map[string]int{
+	"preflight":                        0,
+	"certs":                            0,
+	"certs/all":                        0,
+	"certs/ca":                         0,
+	"certs/apiserver":                  0,
+	"certs/apiserver-kubelet-client":   0,
+	"certs/front-proxy-ca":             0,
+	"certs/front-proxy-client":         0,
+	"certs/etcd-ca":                    0,
+	"certs/etcd-server":                0,
+	"certs/etcd-peer":                  0,
+	"certs/etcd-healthcheck-client":    0,
+	"certs/apiserver-etcd-client":      0,
+	"certs/sa":                         0,
+	"kubeconfig":                       0,
+	"kubeconfig/all":                   0,
+	"kubeconfig/admin":                 0,
+	"kubeconfig/super-admin":           29,
+	"kubeconfig/kubelet":               0,
+	"kubeconfig/controller-manager":    0,
+	"kubeconfig/scheduler":             0,
+	"etcd":                             0,
+	"etcd/local":                       0,
+	"control-plane":                    0,
+	"control-plane/all":                0,
+	"control-plane/apiserver":          0,
+	"control-plane/controller-manager": 0,
+	"control-plane/scheduler":          0,
+	"kubelet-start":                    0,
+	"wait-control-plane":               0,
+	"upload-config":                    0,
+	"upload-config/all":                0,
+	"upload-config/kubeadm":            0,
+	"upload-config/kubelet":            0,
+	"upload-certs":                     0,
+	"mark-control-plane":               0,
+	"bootstrap-token":                  0,
+	"kubelet-finalize":                 0,
+	"kubelet-finalize/all":             0,
+	"addon":                            0,
+	"addon/all":                        0,
+	"addon/coredns":                    0,
+	"addon/kube-proxy":                 0,
+	"show-join-command":                29,
+}
+
+// requiredKubeadmPhases produce the admin kubeconfig kipod reads after init
+var requiredKubeadmPhases = map[string]bool{
+	"kubeconfig":       true,
+	"kubeconfig/all":   true,
+	"kubeconfig/admin": true,
+}
+
+// validateKubeadm checks skipPhases against the phases of the requested
+// Kubernetes version
+func (c *ClusterConfig) validateKubeadm() error {
+	minor := -1
+	if c.Versions.Kubernetes != "" {
+		if m, err := extractMinorVersion(c.Versions.Kubernetes); err == nil {
+			minor = m
+		}
+	}
+
+	for _, phase := range c.Kubeadm.SkipPhases {
+		since, ok := kubeadmInitPhases[phase]
+		if !ok {
+			return fmt.Errorf("unknown kubeadm init phase %q (supported: %s)", phase, strings.Join(kubeadmPhaseNames(), ", "))
+		}
+		if minor >= 0 && minor < since {
+			return fmt.Errorf("kubeadm init phase %q requires Kubernetes 1.%d or later, got %s", phase, since, c.Versions.Kubernetes)
+		}
+		if requiredKubeadmPhases[phase] {
+			return fmt.Errorf("kubeadm init phase %q cannot be skipped: kipod needs the admin kubeconfig", phase)
+		}
+		if phase == "bootstrap-token" && c.WorkerCount() > 0 {
+			return fmt.Errorf("kubeadm init phase %q cannot be skipped in clusters with workers: they join with a bootstrap token", phase)
+		}
+	}
+
+	for _, arg := range c.Kubeadm.ExtraInitArgs {
+		if !strings.HasPrefix(arg, "--") {
+			return fmt.Errorf("invalid kubeadm extraInitArgs entry %q: expected --flag or --flag=value", arg)
+		}
+		if strings.HasPrefix(arg, "--skip-phases") {
+			return fmt.Errorf("use kubeadm.skipPhases instead of --skip-phases in extraInitArgs")
+		}
+	}
+
+	return nil
+}
+
+// kubeadmPhaseNames returns the known kubeadm init phases in sorted order
+func kubeadmPhaseNames() []string {
+	names := make([]string, 0, len(kubeadmInitPhases))
+	for name := range kubeadmInitPhases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
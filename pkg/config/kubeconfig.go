@@ -0,0 +1,21 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KubeconfigConfig controls the kubeconfigs kipod writes on the host
+type KubeconfigConfig struct {
+	// ContextName names the context, cluster and user entries of the
+	// cluster, e.g. to follow team conventions next to kind or minikube
+	// contexts (default kipod-<name>)
+	ContextName string `yaml:"contextName,omitempty" json:"contextName,omitempty"`
+}
+
+func (k KubeconfigConfig) validate() error {
+	if strings.ContainsAny(k.ContextName, " \t\r\n") {
+		return fmt.Errorf("kubeconfig.contextName %q must not contain whitespace", k.ContextName)
+	}
+	return nil
+}
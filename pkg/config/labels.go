@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// validateClusterLabels checks cluster labels follow the Kubernetes label
+// syntax, so selectors written for kubectl read the same
+func validateClusterLabels(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := validateLabelKey(key); err != nil {
+			return fmt.Errorf("labels: %w", err)
+		}
+		if !labelValueRegexp.MatchString(labels[key]) {
+			return fmt.Errorf("labels: invalid value %q of %s", labels[key], key)
+		}
+	}
+	return nil
+}
+
+// ParseLabel parses a key=value cluster label
+func ParseLabel(s string) (string, string, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid label %q, expected key=value", s)
+	}
+	if err := validateClusterLabels(map[string]string{key: value}); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// requirement is one term of a label selector
+type requirement struct {
+	key   string
+	value string
+	op    string // "=", "!=", "exists" or "!exists"
+}
+
+// Selector selects clusters by their labels
+type Selector []requirement
+
+// ParseSelector parses a comma-separated label selector in the equality
+// syntax of kubectl: key=value, key==value, key!=value, key and !key. Empty
+// terms are rejected, so a selector such as "," cannot match every cluster.
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("invalid selector %q: empty term", s)
+		}
+
+		var req requirement
+		switch {
+		case strings.Contains(term, "!="):
+			req.key, req.value, _ = strings.Cut(term, "!=")
+			req.op = "!="
+		case strings.Contains(term, "=="):
+			req.key, req.value, _ = strings.Cut(term, "==")
+			req.op = "="
+		case strings.Contains(term, "="):
+			req.key, req.value, _ = strings.Cut(term, "=")
+			req.op = "="
+		case strings.HasPrefix(term, "!"):
+			req.key = strings.TrimPrefix(term, "!")
+			req.op = "!exists"
+		default:
+			req.key = term
+			req.op = "exists"
+		}
+		req.key, req.value = strings.TrimSpace(req.key), strings.TrimSpace(req.value)
+		if err := validateLabelKey(req.key); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		if !labelValueRegexp.MatchString(req.value) {
+			return nil, fmt.Errorf("invalid selector %q: invalid value %q", s, req.value)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// Matches reports whether labels satisfy every term of the selector
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.key]
+		switch req.op {
+		case "=":
+			if !ok || value != req.value {
+				return false
+			}
+		case "!=":
+			if ok && value == req.value {
+				return false
+			}
+		case "exists":
+			if !ok {
+				return false
+			}
+		case "!exists":
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
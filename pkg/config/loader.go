@@ -1,7 +1,10 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -13,11 +16,18 @@ func LoadFromFile(path string) (*ClusterConfig, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	return parse(data)
+}
 
+// parse loads a ClusterConfig from YAML, normalizes and validates it
+func parse(data []byte) (*ClusterConfig, error) {
 	var cfg ClusterConfig
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if err := checkKnownFields(data); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
 
 	// Apply defaults and normalize
 	cfg.Normalize()
@@ -30,6 +40,55 @@ func LoadFromFile(path string) (*ClusterConfig, error) {
 	return &cfg, nil
 }
 
+// LoadAllFromFile loads the ClusterConfigs of a YAML file holding several
+// clusters, as documents separated by --- or as a list. Each config is
+// normalized and validated on its own.
+func LoadAllFromFile(path string) ([]*ClusterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var nodes []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		if root := doc.Content[0]; root.Kind == yaml.SequenceNode {
+			nodes = append(nodes, root.Content...)
+		} else {
+			nodes = append(nodes, root)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no cluster configs found in %s", path)
+	}
+
+	configs := make([]*ClusterConfig, 0, len(nodes))
+	for i, node := range nodes {
+		var cfg ClusterConfig
+		if err := node.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("cluster %d (line %d): failed to parse config: %w", i+1, node.Line, err)
+		}
+		if err := checkKnownNodeFields(node); err != nil {
+			return nil, fmt.Errorf("cluster %d (line %d): invalid config: %w", i+1, node.Line, err)
+		}
+		cfg.Normalize()
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("cluster %d (line %d): invalid configuration: %w", i+1, node.Line, err)
+		}
+		configs = append(configs, &cfg)
+	}
+	return configs, nil
+}
+
 // SaveToFile saves a ClusterConfig to a YAML file
 func SaveToFile(cfg *ClusterConfig, path string) error {
 	data, err := yaml.Marshal(cfg)
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// crioLogLevels are the log levels accepted by CRI-O's log_level option
+var crioLogLevels = []string{"fatal", "panic", "error", "warn", "info", "debug", "trace"}
+
+// ParseComponentLogLevels parses a comma separated list of component=level
+// pairs, e.g. "kubelet=4,crio=debug"
+func ParseComponentLogLevels(s string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, level, ok := strings.Cut(pair, "=")
+		if !ok || component == "" || level == "" {
+			return nil, fmt.Errorf("invalid log level %q, expected component=level", pair)
+		}
+		levels[component] = level
+	}
+	if err := validateLogLevels(levels); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// validateLogLevels checks the component log levels: kubelet takes a klog
+// verbosity (0-10), crio one of its log_level names
+func validateLogLevels(levels map[string]string) error {
+	components := make([]string, 0, len(levels))
+	for component := range levels {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		level := levels[component]
+		switch component {
+		case "kubelet":
+			v, err := strconv.Atoi(level)
+			if err != nil || v < 0 || v > 10 {
+				return fmt.Errorf("invalid kubelet log level %q (must be a verbosity between 0 and 10)", level)
+			}
+		case "crio":
+			valid := false
+			for _, l := range crioLogLevels {
+				if level == l {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return fmt.Errorf("invalid crio log level %q (must be one of %s)", level, strings.Join(crioLogLevels, ", "))
+			}
+		default:
+			return fmt.Errorf("unknown log level component %q (supported: crio, kubelet)", component)
+		}
+	}
+	return nil
+}
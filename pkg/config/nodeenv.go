@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedNodeEnv are variables kipod or systemd set in node containers
+var reservedNodeEnv = map[string]bool{
+	"_CRIO_ROOTLESS": true,
+	"container":      true,
+}
+
+// NodeEnvConfig defines environment variables for all node containers and per
+// node role. Role-specific variables override global ones of the same name.
+type NodeEnvConfig struct {
+	// Global variables are set in every node
+	Global map[string]string `yaml:"global,omitempty" json:"global,omitempty"`
+
+	// ControlPlane variables are set in control-plane nodes only
+	ControlPlane map[string]string `yaml:"controlPlane,omitempty" json:"controlPlane,omitempty"`
+
+	// Worker variables are set in worker nodes only
+	Worker map[string]string `yaml:"worker,omitempty" json:"worker,omitempty"`
+}
+
+// ForRole returns the merged variables for a node role ("control-plane" or "worker")
+func (e NodeEnvConfig) ForRole(role string) map[string]string {
+	roleEnv := e.Worker
+	if role == RoleControlPlane {
+		roleEnv = e.ControlPlane
+	}
+
+	merged := make(map[string]string)
+	for k, v := range e.Global {
+		merged[k] = v
+	}
+	for k, v := range roleEnv {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (e NodeEnvConfig) validate() error {
+	for field, env := range map[string]map[string]string{
+		"nodeEnv.global":       e.Global,
+		"nodeEnv.controlPlane": e.ControlPlane,
+		"nodeEnv.worker":       e.Worker,
+	} {
+		if err := validateNodeEnv(env); err != nil {
+			return fmt.Errorf("invalid %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// validateNodeEnv rejects malformed names, values that cannot be written to a
+// systemd environment file and variables kipod sets itself
+func validateNodeEnv(env map[string]string) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !envNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+		if reservedNodeEnv[name] || strings.HasPrefix(name, "KIPOD_") {
+			return fmt.Errorf("%s is reserved for kipod", name)
+		}
+		if strings.ContainsAny(env[name], "\n\r\x00") {
+			return fmt.Errorf("value of %s contains a newline or NUL", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// restartPolicyRegexp matches the podman --restart policies
+var restartPolicyRegexp = regexp.MustCompile(`^(no|always|unless-stopped|on-failure(:[1-9][0-9]*)?)$`)
+
+// NodeHealthConfig controls how podman restarts and checks node containers
+type NodeHealthConfig struct {
+	// RestartPolicy is the podman --restart policy of node containers: "no",
+	// "on-failure" (default), "on-failure:N", "always" or "unless-stopped"
+	RestartPolicy string `yaml:"restartPolicy,omitempty" json:"restartPolicy,omitempty"`
+
+	// Healthcheck configures the podman healthcheck of node containers
+	Healthcheck HealthcheckConfig `yaml:"healthcheck,omitempty" json:"healthcheck,omitempty"`
+}
+
+// HealthcheckConfig configures the node container healthcheck, which checks
+// that systemd is up and CRI-O answers
+type HealthcheckConfig struct {
+	// Disabled creates node containers without a healthcheck
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// Interval between checks, e.g. "30s" (default)
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+}
+
+func (h NodeHealthConfig) validate() error {
+	if h.RestartPolicy != "" && !restartPolicyRegexp.MatchString(h.RestartPolicy) {
+		return fmt.Errorf("nodeHealth.restartPolicy must be 'no', 'on-failure', 'on-failure:N', 'always' or 'unless-stopped', got: %s", h.RestartPolicy)
+	}
+	if h.Healthcheck.Interval != "" {
+		if h.Healthcheck.Disabled {
+			return fmt.Errorf("nodeHealth.healthcheck.interval is set but the healthcheck is disabled")
+		}
+		if d, err := time.ParseDuration(h.Healthcheck.Interval); err != nil || d < time.Second {
+			return fmt.Errorf("nodeHealth.healthcheck.interval must be a duration of at least 1s, got: %s", h.Healthcheck.Interval)
+		}
+	}
+	return nil
+}
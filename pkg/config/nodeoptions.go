@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var sysctlKeyRegexp = regexp.MustCompile(`^[a-z0-9_]+(\.[a-zA-Z0-9_*-]+)+$`)
+
+// validSecurityOpts are the --security-opt keys podman accepts
+var validSecurityOpts = map[string]bool{
+	"apparmor":          true,
+	"label":             true,
+	"mask":              true,
+	"no-new-privileges": true,
+	"proc-opts":         true,
+	"seccomp":           true,
+	"unmask":            true,
+}
+
+// validUlimits are the resource names podman accepts for --ulimit
+var validUlimits = map[string]bool{
+	"core": true, "cpu": true, "data": true, "fsize": true, "locks": true,
+	"memlock": true, "msgqueue": true, "nice": true, "nofile": true, "nproc": true,
+	"rss": true, "rtprio": true, "rttime": true, "sigpending": true, "stack": true,
+}
+
+// validate checks that container options are well-formed before they reach podman
+func (o ContainerOptions) validate() error {
+	for key, value := range o.Sysctls {
+		if !sysctlKeyRegexp.MatchString(key) {
+			return fmt.Errorf("invalid sysctl name %q", key)
+		}
+		if value == "" {
+			return fmt.Errorf("sysctl %q has an empty value", key)
+		}
+	}
+
+	for _, opt := range o.SecurityOpts {
+		key, _, _ := strings.Cut(opt, "=")
+		if !validSecurityOpts[key] {
+			return fmt.Errorf("unsupported security option %q", opt)
+		}
+	}
+
+	for _, dev := range o.Devices {
+		parts := strings.Split(dev, ":")
+		if len(parts) > 3 || !strings.HasPrefix(parts[0], "/dev/") {
+			return fmt.Errorf("invalid device %q, expected /dev/<src>[:<dst>[:<perms>]]", dev)
+		}
+		if len(parts) == 3 && strings.Trim(parts[2], "rwm") != "" {
+			return fmt.Errorf("invalid device permissions %q in %q, expected a combination of r, w, m", parts[2], dev)
+		}
+	}
+
+	for _, ulimit := range o.Ulimits {
+		name, limits, ok := strings.Cut(ulimit, "=")
+		if !ok || !validUlimits[name] {
+			return fmt.Errorf("invalid ulimit %q, expected <name>=<soft>[:<hard>]", ulimit)
+		}
+		for _, limit := range strings.Split(limits, ":") {
+			if _, err := strconv.ParseInt(limit, 10, 64); err != nil && limit != "unlimited" {
+				return fmt.Errorf("invalid ulimit value %q in %q", limit, ulimit)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// RoleControlPlane is the role of control-plane nodes
+	RoleControlPlane = "control-plane"
+
+	// RoleWorker is the role of worker nodes
+	RoleWorker = "worker"
+)
+
+var (
+	poolNameRegexp    = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+	labelPrefixRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]{0,251}[a-z0-9])?$`)
+	labelNameRegexp   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.-]{0,61}[A-Za-z0-9])?$`)
+	labelValueRegexp  = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9_.-]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+// validTaintEffects are the taint effects Kubernetes accepts
+var validTaintEffects = map[string]bool{
+	"NoSchedule":       true,
+	"PreferNoSchedule": true,
+	"NoExecute":        true,
+}
+
+// Taint is a parsed node taint
+type Taint struct {
+	Key    string
+	Value  string
+	Effect string
+}
+
+// ParseTaint parses a taint in kubectl format: key[=value]:Effect
+func ParseTaint(s string) (Taint, error) {
+	spec, effect, ok := strings.Cut(s, ":")
+	if !ok {
+		return Taint{}, fmt.Errorf("invalid taint %q, expected key[=value]:Effect", s)
+	}
+	if !validTaintEffects[effect] {
+		return Taint{}, fmt.Errorf("invalid taint effect %q in %q, expected NoSchedule, PreferNoSchedule or NoExecute", effect, s)
+	}
+
+	key, value, _ := strings.Cut(spec, "=")
+	if err := validateLabelKey(key); err != nil {
+		return Taint{}, fmt.Errorf("invalid taint %q: %w", s, err)
+	}
+	if !labelValueRegexp.MatchString(value) {
+		return Taint{}, fmt.Errorf("invalid taint value %q in %q", value, s)
+	}
+
+	return Taint{Key: key, Value: value, Effect: effect}, nil
+}
+
+// validatePools checks node pool names, roles, labels and taints
+func (c *ClusterConfig) validatePools() error {
+	seen := map[string]bool{}
+	controlPlanePools := 0
+
+	for _, pool := range c.Nodes.Pools {
+		if !poolNameRegexp.MatchString(pool.Name) {
+			return fmt.Errorf("invalid node pool name %q, must be a lowercase DNS label of at most 32 characters", pool.Name)
+		}
+		if seen[pool.Name] {
+			return fmt.Errorf("duplicate node pool name %q", pool.Name)
+		}
+		seen[pool.Name] = true
+
+		switch pool.Role {
+		case RoleControlPlane:
+			// Control-plane nodes keep their usual names
+			if pool.Name != RoleControlPlane {
+				return fmt.Errorf("node pool %q: control-plane pools must be named %q", pool.Name, RoleControlPlane)
+			}
+			controlPlanePools++
+		case RoleWorker, "":
+			if pool.Name == RoleControlPlane || pool.Name == "etcd" {
+				return fmt.Errorf("node pool name %q is reserved", pool.Name)
+			}
+			if pool.Name == RoleWorker && c.Nodes.Workers > 0 {
+				return fmt.Errorf("node pool %q conflicts with nodes.workers; set the worker count in the pool instead", pool.Name)
+			}
+		default:
+			return fmt.Errorf("node pool %q: role must be %q or %q, got: %s", pool.Name, RoleControlPlane, RoleWorker, pool.Role)
+		}
+
+		if pool.Count < 1 {
+			return fmt.Errorf("node pool %q: count must be at least 1", pool.Name)
+		}
+
+		for key, value := range pool.Labels {
+			if err := validateLabelKey(key); err != nil {
+				return fmt.Errorf("node pool %q: %w", pool.Name, err)
+			}
+			if !labelValueRegexp.MatchString(value) {
+				return fmt.Errorf("node pool %q: invalid label value %q for %q", pool.Name, value, key)
+			}
+			// The NodeRestriction admission plugin rejects these when set by the kubelet
+			if prefix, _, ok := strings.Cut(key, "/"); ok && isRestrictedLabelDomain(prefix) {
+				return fmt.Errorf("node pool %q: label %q uses a namespace the kubelet may not set on its own node", pool.Name, key)
+			}
+		}
+
+		for _, taint := range pool.Taints {
+			if _, err := ParseTaint(taint); err != nil {
+				return fmt.Errorf("node pool %q: %w", pool.Name, err)
+			}
+		}
+	}
+
+	if controlPlanePools > 0 && c.Nodes.ControlPlanes > 0 {
+		return fmt.Errorf("node pool %q conflicts with nodes.controlPlanes; set the control-plane count in the pool instead", RoleControlPlane)
+	}
+
+	return nil
+}
+
+// validateLabelKey checks a label or taint key of the form [prefix/]name
+func validateLabelKey(key string) error {
+	prefix, name, ok := strings.Cut(key, "/")
+	if !ok {
+		name, prefix = prefix, ""
+	}
+	if prefix != "" && !labelPrefixRegexp.MatchString(prefix) {
+		return fmt.Errorf("invalid key prefix %q in %q", prefix, key)
+	}
+	if !labelNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	return nil
+}
+
+// isRestrictedLabelDomain reports whether the kubelet is barred from setting
+// labels in this domain, see the NodeRestriction admission plugin
+func isRestrictedLabelDomain(domain string) bool {
+	for _, allowed := range []string{"kubelet.kubernetes.io", "node.kubernetes.io"} {
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return false
+		}
+	}
+	return domain == "kubernetes.io" || strings.HasSuffix(domain, ".kubernetes.io") ||
+		domain == "k8s.io" || strings.HasSuffix(domain, ".k8s.io")
+}
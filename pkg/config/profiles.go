@@ -0,0 +1,49 @@
+package config
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profileFS holds the commented example configs generated by init config
+//
+//go:embed profiles/*.yaml
+var profileFS embed.FS
+
+// Profiles returns the names of the built-in config profiles
+func Profiles() []string {
+	entries, err := profileFS.ReadDir("profiles")
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the commented config of a built-in profile
+func Profile(name string) ([]byte, error) {
+	data, err := profileFS.ReadFile("profiles/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(Profiles(), ", "))
+	}
+	return data, nil
+}
+
+// LoadProfile loads the config of a built-in profile
+func LoadProfile(name string) (*ClusterConfig, error) {
+	data, err := Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import "fmt"
+
+// RegistryAuthConfig installs credentials for private registries cluster-wide
+type RegistryAuthConfig struct {
+	// AuthFile is a containers auth.json or Docker config.json on the host,
+	// e.g. the file podman login writes, with inline credentials
+	AuthFile string `yaml:"authFile,omitempty" json:"authFile,omitempty"`
+
+	// Nodes makes CRI-O on every node pull all images with AuthFile
+	// (default unless ImagePullSecret is set)
+	Nodes bool `yaml:"nodes,omitempty" json:"nodes,omitempty"`
+
+	// ImagePullSecret creates a kipod-registry-auth pull secret in every
+	// namespace and adds it to the namespace's default service account
+	ImagePullSecret bool `yaml:"imagePullSecret,omitempty" json:"imagePullSecret,omitempty"`
+}
+
+func (r RegistryAuthConfig) validate() error {
+	if r.AuthFile == "" && (r.Nodes || r.ImagePullSecret) {
+		return fmt.Errorf("registryAuth options require registryAuth.authFile")
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source is where the effective value of a config field came from. Flags
+// take precedence over environment variables, which take precedence over
+// the config file or profile, which take precedence over defaults.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceConfig  Source = "config"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// ResolvedField is the effective value of a config field and its origin
+type ResolvedField struct {
+	// Path is the dotted config path, e.g. versions.kubernetes
+	Path  string
+	Value string
+	// Source and Origin, e.g. SourceFlag and "--name" or SourceConfig and
+	// the config file path
+	Source Source
+	Origin string
+}
+
+// Resolver loads a config file, a profile or the defaults and applies the
+// environment variables and flags of a command on top, recording which
+// source set each field
+type Resolver struct {
+	// Config is the effective config
+	Config *ClusterConfig
+
+	origin    string
+	fileSet   map[string]bool
+	overrides map[string]ResolvedField
+}
+
+// NewResolver loads configFile, the built-in profile or, when both are
+// empty, the defaults
+func NewResolver(configFile, profile string) (*Resolver, error) {
+	r := &Resolver{fileSet: make(map[string]bool), overrides: make(map[string]ResolvedField)}
+
+	var data []byte
+	var err error
+	switch {
+	case configFile != "" && profile != "":
+		return nil, fmt.Errorf("--profile and --config cannot be combined; write the profile out with kipod init config --profile %s and edit it instead", profile)
+	case configFile != "":
+		if r.Config, err = LoadFromFile(configFile); err != nil {
+			return nil, err
+		}
+		if data, err = os.ReadFile(configFile); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		r.origin = configFile
+	case profile != "":
+		if r.Config, err = LoadProfile(profile); err != nil {
+			return nil, err
+		}
+		if data, err = Profile(profile); err != nil {
+			return nil, err
+		}
+		r.origin = "profile " + profile
+	default:
+		r.Config = DefaultConfig()
+		return r, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(doc.Content) > 0 {
+		collectSetFields(doc.Content[0], "", r.fileSet)
+	}
+	return r, nil
+}
+
+// Env sets a field from an environment variable, when it is set
+func (r *Resolver) Env(path, variable string, set func(value string) error) error {
+	value := os.Getenv(variable)
+	if value == "" {
+		return nil
+	}
+	if err := set(value); err != nil {
+		return fmt.Errorf("invalid %s: %w", variable, err)
+	}
+	r.overrides[path] = ResolvedField{Path: path, Source: SourceEnv, Origin: variable}
+	return nil
+}
+
+// Flag sets a field from a flag, when it was given
+func (r *Resolver) Flag(path, flag string, given bool, set func() error) error {
+	if !given {
+		return nil
+	}
+	if err := set(); err != nil {
+		return fmt.Errorf("invalid --%s: %w", flag, err)
+	}
+	r.overrides[path] = ResolvedField{Path: path, Source: SourceFlag, Origin: "--" + flag}
+	return nil
+}
+
+// Fields returns every non-empty field of the effective config in config
+// file order, with the source of its value
+func (r *Resolver) Fields() []ResolvedField {
+	var fields []ResolvedField
+	collectValues(reflect.ValueOf(r.Config).Elem(), "", &fields)
+
+	for i, field := range fields {
+		if override, ok := r.overrideFor(field.Path); ok {
+			fields[i].Source, fields[i].Origin = override.Source, override.Origin
+			continue
+		}
+		fields[i].Source = SourceDefault
+		for path := field.Path; path != ""; path = parentPath(path) {
+			if r.fileSet[path] {
+				fields[i].Source, fields[i].Origin = SourceConfig, r.origin
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// overrideFor returns the flag or environment variable that set a field or
+// one of its parents
+func (r *Resolver) overrideFor(path string) (ResolvedField, bool) {
+	for ; path != ""; path = parentPath(path) {
+		if override, ok := r.overrides[path]; ok {
+			return override, true
+		}
+	}
+	return ResolvedField{}, false
+}
+
+// collectSetFields records the paths of the values a YAML config sets
+func collectSetFields(node *yaml.Node, path string, set map[string]bool) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		if path != "" {
+			set[path] = true
+		}
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		collectSetFields(node.Content[i+1], joinPath(path, node.Content[i].Value), set)
+	}
+}
+
+// collectValues appends the non-empty leaves of a config value. Lists are
+// one field; maps of scalars have a field per key.
+func collectValues(v reflect.Value, path string, fields *[]ResolvedField) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			collectValues(v.Elem(), path, fields)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			switch {
+			case name == "-", path == "" && (name == "apiVersion" || name == "kind"):
+			case options == "inline":
+				collectValues(v.Field(i), path, fields)
+			default:
+				if name == "" {
+					name = strings.ToLower(field.Name)
+				}
+				collectValues(v.Field(i), joinPath(path, name), fields)
+			}
+		}
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(key.Interface()))
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectValues(v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())), joinPath(path, key), fields)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return
+		}
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i)
+			if k := item.Kind(); k == reflect.Struct || k == reflect.Map || k == reflect.Slice {
+				items = nil
+				break
+			}
+			items = append(items, fmt.Sprint(item.Interface()))
+		}
+		value := strings.Join(items, ",")
+		if items == nil {
+			value = fmt.Sprintf("%d item(s)", v.Len())
+		}
+		*fields = append(*fields, ResolvedField{Path: path, Value: value})
+	default:
+		if !v.IsZero() {
+			*fields = append(*fields, ResolvedField{Path: path, Value: fmt.Sprint(v.Interface())})
+		}
+	}
+}
+
+// parentPath returns the config path one level up, or ""
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SignaturePolicyConfig installs a containers-policy.json and sigstore
+// registries.d configuration on every node, so CRI-O verifies the
+// signatures of the images it pulls
+type SignaturePolicyConfig struct {
+	// PolicyFile is a containers-policy.json on the host
+	PolicyFile string `yaml:"policyFile,omitempty" json:"policyFile,omitempty"`
+
+	// RequireSigned generates a test policy instead of PolicyFile: images
+	// under these registry or repository scopes, e.g. quay.io/myorg, must
+	// have a sigstore signature made with PublicKey, and all others are
+	// accepted
+	RequireSigned []string `yaml:"requireSigned,omitempty" json:"requireSigned,omitempty"`
+
+	// PublicKey is a cosign public key on the host, installed at
+	// /etc/crio/kipod-sigstore.pub for the policy to refer to
+	PublicKey string `yaml:"publicKey,omitempty" json:"publicKey,omitempty"`
+
+	// RegistriesDir is a directory of registries.d YAML files on the host,
+	// installed next to the ones in the node image
+	RegistriesDir string `yaml:"registriesDir,omitempty" json:"registriesDir,omitempty"`
+}
+
+// Enabled reports whether a policy is installed on nodes
+func (s SignaturePolicyConfig) Enabled() bool {
+	return s.PolicyFile != "" || len(s.RequireSigned) > 0
+}
+
+func (s SignaturePolicyConfig) validate() error {
+	switch {
+	case s.PolicyFile != "" && len(s.RequireSigned) > 0:
+		return fmt.Errorf("signaturePolicy.policyFile and signaturePolicy.requireSigned cannot be combined")
+	case len(s.RequireSigned) > 0 && s.PublicKey == "":
+		return fmt.Errorf("signaturePolicy.requireSigned requires signaturePolicy.publicKey")
+	case !s.Enabled() && (s.PublicKey != "" || s.RegistriesDir != ""):
+		return fmt.Errorf("signaturePolicy options require signaturePolicy.policyFile or signaturePolicy.requireSigned")
+	}
+	for _, scope := range s.RequireSigned {
+		if scope == "" || strings.Contains(scope, "://") || strings.ContainsAny(scope, " @") {
+			return fmt.Errorf("signaturePolicy.requireSigned: invalid scope %q, want a registry or repository such as quay.io/myorg", scope)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// CRI-O install methods for sources.crio.type
+const (
+	CRIOSourceGit     = "git"
+	CRIOSourcePackage = "package"
+	CRIOSourceBundle  = "bundle"
+)
+
+// SourcesConfig overrides where the node image build gets components from
+type SourcesConfig struct {
+	// CRIO selects how CRI-O is installed
+	CRIO CRIOSource `yaml:"crio,omitempty" json:"crio,omitempty"`
+
+	// Kubernetes overrides the Kubernetes RPM repository
+	Kubernetes PackageSource `yaml:"kubernetes,omitempty" json:"kubernetes,omitempty"`
+}
+
+// CRIOSource selects how CRI-O is installed in the node image
+type CRIOSource struct {
+	// Type is "git" (build from source, default), "package" (RPM from the
+	// pkgs.k8s.io repository) or "bundle" (static binary bundle from the
+	// GitHub release)
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	// URL overrides the git repository, RPM repository base URL or bundle
+	// download URL
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// SHA256 is the expected checksum of the bundle
+	SHA256 string `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+}
+
+// PackageSource is an RPM repository
+type PackageSource struct {
+	// URL is the repository base URL
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+}
+
+var (
+	crioVersionRegexp = regexp.MustCompile(`^v?\d+\.\d+(\.\d+)?$`)
+	sha256Regexp      = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// IsExactVersion reports whether version names a patch release, e.g. 1.34.2
+// rather than 1.34
+func IsExactVersion(version string) bool {
+	match := crioVersionRegexp.FindStringSubmatch(version)
+	return match != nil && match[1] != ""
+}
+
+// ValidateSources checks versions.crio and the sources block. It is separate
+// from Validate so build flags overriding the config can be checked too.
+func (c *ClusterConfig) ValidateSources() error {
+	if c.Versions.CRIO != "" && !crioVersionRegexp.MatchString(c.Versions.CRIO) {
+		return fmt.Errorf("invalid CRI-O version %q (expected a minor version like 1.34 or a release like 1.34.2)", c.Versions.CRIO)
+	}
+
+	crio := c.Sources.CRIO
+	switch crio.Type {
+	case "", CRIOSourceGit, CRIOSourcePackage:
+		if crio.SHA256 != "" {
+			return fmt.Errorf("sources.crio.sha256 is only supported with type %q", CRIOSourceBundle)
+		}
+	case CRIOSourceBundle:
+		if crio.URL == "" && !IsExactVersion(c.Versions.CRIO) {
+			return fmt.Errorf("sources.crio type %q needs an exact versions.crio (e.g. 1.34.2) or a url", CRIOSourceBundle)
+		}
+		if crio.SHA256 != "" && !sha256Regexp.MatchString(crio.SHA256) {
+			return fmt.Errorf("sources.crio.sha256 must be 64 lowercase hex characters, got: %q", crio.SHA256)
+		}
+	default:
+		return fmt.Errorf("sources.crio.type must be 'git', 'package' or 'bundle', got: %s", crio.Type)
+	}
+
+	if err := validateSourceURL("sources.crio.url", crio.URL); err != nil {
+		return err
+	}
+	return validateSourceURL("sources.kubernetes.url", c.Sources.Kubernetes.URL)
+}
+
+func validateSourceURL(field, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if u, err := url.Parse(raw); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute URL, got: %q", field, raw)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkKnownFields reports every key of a YAML config that ClusterConfig has
+// no field for, with its line and the closest known field, so typos such as
+// controlPlane for controlPlanes fail instead of being ignored
+func checkKnownFields(data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return checkKnownNodeFields(doc.Content[0])
+}
+
+// checkKnownNodeFields is checkKnownFields for a config already parsed into
+// a YAML node, such as one entry of a list of configs
+func checkKnownNodeFields(node *yaml.Node) error {
+	var problems []string
+	checkNode(node, reflect.TypeOf(ClusterConfig{}), "", &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("unknown fields:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// checkNode checks the keys of node against the fields of t, recursing into
+// nested structs, slices and maps
+func checkNode(node *yaml.Node, t reflect.Type, path string, problems *[]string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				*problems = append(*problems, unknownFieldMessage(key, path, fields))
+				continue
+			}
+			checkNode(value, field, joinPath(path, key.Value), problems)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			checkNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkNode(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), problems)
+		}
+	}
+}
+
+// yamlFields returns the types of the fields of a struct by YAML key,
+// including the fields of inlined structs
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if options == "inline" || strings.Contains(options, ",inline") {
+			for key, inlined := range yamlFields(field.Type) {
+				fields[key] = inlined
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// unknownFieldMessage describes an unknown key, suggesting the known field
+// it most likely misspells
+func unknownFieldMessage(key *yaml.Node, path string, fields map[string]reflect.Type) string {
+	where := "the config"
+	if path != "" {
+		where = path
+	}
+	msg := fmt.Sprintf("line %d: unknown field %q in %s", key.Line, key.Value, where)
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if suggestion := closestField(key.Value, names); suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %s?)", suggestion)
+	}
+	return msg
+}
+
+// closestField returns the name closest to key by edit distance, ignoring
+// case, or "" when none is close enough to be a typo
+func closestField(key string, names []string) string {
+	best, bestDistance := "", -1
+	for _, name := range names {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(name))
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(2, len(key)/3) {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// joinPath appends a key to a dotted config path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
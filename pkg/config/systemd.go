@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// SystemdUnit is an extra systemd unit installed into every node
+type SystemdUnit struct {
+	// Name of the unit file, e.g. "debug-dump.service"
+	Name string `yaml:"name" json:"name"`
+
+	// Path to the unit file on the host (mutually exclusive with Content)
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Content is the inline unit file content
+	Content string `yaml:"content,omitempty" json:"content,omitempty"`
+
+	// Enable starts the unit at boot (WantedBy=multi-user.target)
+	Enable bool `yaml:"enable,omitempty" json:"enable,omitempty"`
+}
+
+// UnitOverride is a drop-in for an existing systemd unit in the node image
+type UnitOverride struct {
+	// Unit to override, e.g. "kubelet.service"
+	Unit string `yaml:"unit" json:"unit"`
+
+	// Name of the drop-in file (default "90-kipod-override.conf")
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Path to the drop-in file on the host (mutually exclusive with Content)
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Content is the inline drop-in content
+	Content string `yaml:"content,omitempty" json:"content,omitempty"`
+}
+
+// validUnitSuffixes are the systemd unit types that may be installed
+var validUnitSuffixes = []string{".service", ".socket", ".timer", ".path", ".mount", ".target"}
+
+func validateUnitName(name string) error {
+	if name == "" || name != filepath.Base(name) {
+		return fmt.Errorf("invalid unit name %q", name)
+	}
+	for _, suffix := range validUnitSuffixes {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unit %q must end with one of %s", name, strings.Join(validUnitSuffixes, ", "))
+}
+
+func validateSource(what, path, content string) error {
+	if (path == "") == (content == "") {
+		return fmt.Errorf("%s: exactly one of path or content must be set", what)
+	}
+	return nil
+}
+
+// validateSystemd checks extraSystemdUnits, unitOverrides, nodeEntrypoint
+// and nodeCommand
+func (c *ClusterConfig) validateSystemd() error {
+	for field, args := range map[string][]string{"nodeEntrypoint": c.NodeEntrypoint, "nodeCommand": c.NodeCommand} {
+		for _, arg := range args {
+			if strings.TrimSpace(arg) == "" {
+				return fmt.Errorf("invalid %s: empty argument", field)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, unit := range c.ExtraSystemdUnits {
+		if err := validateUnitName(unit.Name); err != nil {
+			return fmt.Errorf("invalid extraSystemdUnits: %w", err)
+		}
+		if seen[unit.Name] {
+			return fmt.Errorf("invalid extraSystemdUnits: duplicate unit %q", unit.Name)
+		}
+		seen[unit.Name] = true
+		if err := validateSource(unit.Name, unit.Path, unit.Content); err != nil {
+			return fmt.Errorf("invalid extraSystemdUnits: %w", err)
+		}
+	}
+
+	for _, override := range c.UnitOverrides {
+		if err := validateUnitName(override.Unit); err != nil {
+			return fmt.Errorf("invalid unitOverrides: %w", err)
+		}
+		if override.Name != "" && (override.Name != filepath.Base(override.Name) || !strings.HasSuffix(override.Name, ".conf")) {
+			return fmt.Errorf("invalid unitOverrides: drop-in name %q must be a file name ending in .conf", override.Name)
+		}
+		if err := validateSource(override.Unit, override.Path, override.Content); err != nil {
+			return fmt.Errorf("invalid unitOverrides: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -2,10 +2,21 @@ package config
 
 import (
 	"fmt"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/crio"
 )
 
+// preflightCheckRegexp matches kubeadm preflight check names, e.g.
+// FileContent--proc-sys-net-bridge-bridge-nf-call-iptables or "all"
+var preflightCheckRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// networkNameRegexp matches the network names podman accepts
+var networkNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
 // ClusterConfig represents the configuration for a kipod cluster
 type ClusterConfig struct {
 	// APIVersion is the config API version
@@ -17,15 +28,28 @@ type ClusterConfig struct {
 	// Name is the cluster name
 	Name string `yaml:"name,omitempty" json:"name,omitempty"`
 
+	// Labels tag the cluster for selection, e.g. team: payments
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
 	// Nodes configuration
 	Nodes NodesConfig `yaml:"nodes,omitempty" json:"nodes,omitempty"`
 
 	// Image is the base image to use for nodes
 	Image string `yaml:"image,omitempty" json:"image,omitempty"`
 
+	// BaseImage is the image the node image is built on (default Fedora)
+	BaseImage string `yaml:"baseImage,omitempty" json:"baseImage,omitempty"`
+
+	// BaseDistro is the distro of BaseImage: "fedora", "centos-stream" or
+	// "ubuntu". Detected from the image name when empty.
+	BaseDistro string `yaml:"baseDistro,omitempty" json:"baseDistro,omitempty"`
+
 	// Versions specifies component versions
 	Versions VersionsConfig `yaml:"versions,omitempty" json:"versions,omitempty"`
 
+	// Sources overrides where the node image build downloads components from
+	Sources SourcesConfig `yaml:"sources,omitempty" json:"sources,omitempty"`
+
 	// LocalBuilds specifies paths to local development builds
 	LocalBuilds LocalBuildsConfig `yaml:"localBuilds,omitempty" json:"localBuilds,omitempty"`
 
@@ -38,12 +62,97 @@ type ClusterConfig struct {
 	// CRIOConfig is path to a CRI-O config file to inject into /etc/crio/crio.conf.d/99-user.conf
 	CRIOConfig string `yaml:"crioConfig,omitempty" json:"crioConfig,omitempty"`
 
+	// CRIOProfile selects a CRI-O config shipped with kipod for a common
+	// experiment: evented-pleg, high-pids, debug-logging or image-volume
+	CRIOProfile string `yaml:"crioProfile,omitempty" json:"crioProfile,omitempty"`
+
+	// Features turns on experimental CRI-O and kubelet features together
+	Features FeaturesConfig `yaml:"features,omitempty" json:"features,omitempty"`
+
 	// Storage configuration
 	Storage StorageConfig `yaml:"storage,omitempty" json:"storage,omitempty"`
 
+	// ImageGC sets the kubelet image garbage collection thresholds
+	ImageGC ImageGCConfig `yaml:"imageGC,omitempty" json:"imageGC,omitempty"`
+
+	// NodeStorage configures per-node data volumes
+	NodeStorage NodeStorageConfig `yaml:"nodeStorage,omitempty" json:"nodeStorage,omitempty"`
+
+	// ImageRepository replaces registry.k8s.io for the images kubeadm and
+	// CRI-O pull, e.g. an internal mirror
+	ImageRepository string `yaml:"imageRepository,omitempty" json:"imageRepository,omitempty"`
+
+	// RegistryAuth installs pull credentials for private registries
+	RegistryAuth RegistryAuthConfig `yaml:"registryAuth,omitempty" json:"registryAuth,omitempty"`
+
+	// SignaturePolicy makes CRI-O verify image signatures
+	SignaturePolicy SignaturePolicyConfig `yaml:"signaturePolicy,omitempty" json:"signaturePolicy,omitempty"`
+
+	// DNS configures the CoreDNS image
+	DNS DNSConfig `yaml:"dns,omitempty" json:"dns,omitempty"`
+
+	// ComponentImages replaces the images of control-plane components
+	ComponentImages ComponentImagesConfig `yaml:"componentImages,omitempty" json:"componentImages,omitempty"`
+
 	// Scheduler configuration for kube-scheduler customization
 	Scheduler SchedulerConfig `yaml:"scheduler,omitempty" json:"scheduler,omitempty"`
 
+	// Runtimes enables optional OCI runtimes in the node image and cluster
+	Runtimes RuntimesConfig `yaml:"runtimes,omitempty" json:"runtimes,omitempty"`
+
+	// Inotify limits applied inside node containers
+	Inotify InotifyConfig `yaml:"inotify,omitempty" json:"inotify,omitempty"`
+
+	// NodeOptions passes extra podman options to node containers
+	NodeOptions NodeOptionsConfig `yaml:"nodeOptions,omitempty" json:"nodeOptions,omitempty"`
+
+	// NodeEnv sets environment variables in node containers
+	NodeEnv NodeEnvConfig `yaml:"nodeEnv,omitempty" json:"nodeEnv,omitempty"`
+
+	// NodeHealth sets the restart policy and healthcheck of node containers
+	NodeHealth NodeHealthConfig `yaml:"nodeHealth,omitempty" json:"nodeHealth,omitempty"`
+
+	// Kubeconfig controls the kubeconfigs written on the host
+	Kubeconfig KubeconfigConfig `yaml:"kubeconfig,omitempty" json:"kubeconfig,omitempty"`
+
+	// PostCreateManifests are local paths or http(s) URLs of manifests applied
+	// in order once the cluster is Ready
+	PostCreateManifests []string `yaml:"postCreateManifests,omitempty" json:"postCreateManifests,omitempty"`
+
+	// Addons are components kipod installs once the cluster is Ready
+	Addons AddonsConfig `yaml:"addons,omitempty" json:"addons,omitempty"`
+
+	// HelmCharts are installed in order once the cluster is Ready, after
+	// Addons and before PostCreateManifests
+	HelmCharts []HelmChart `yaml:"helmCharts,omitempty" json:"helmCharts,omitempty"`
+
+	// Etcd configures the etcd topology
+	Etcd EtcdConfig `yaml:"etcd,omitempty" json:"etcd,omitempty"`
+
+	// ExtraSystemdUnits are systemd units installed into every node before boot
+	ExtraSystemdUnits []SystemdUnit `yaml:"extraSystemdUnits,omitempty" json:"extraSystemdUnits,omitempty"`
+
+	// UnitOverrides are drop-ins for units in the node image, e.g. kubelet.service
+	UnitOverrides []UnitOverride `yaml:"unitOverrides,omitempty" json:"unitOverrides,omitempty"`
+
+	// NodeEntrypoint replaces the entrypoint of the node image, which
+	// prepares the node and then runs NodeCommand
+	NodeEntrypoint []string `yaml:"nodeEntrypoint,omitempty" json:"nodeEntrypoint,omitempty"`
+
+	// NodeCommand replaces the command of the node image, /sbin/init
+	NodeCommand []string `yaml:"nodeCommand,omitempty" json:"nodeCommand,omitempty"`
+
+	// Kubeadm controls kubeadm init phases and flags
+	Kubeadm KubeadmConfig `yaml:"kubeadm,omitempty" json:"kubeadm,omitempty"`
+
+	// IgnorePreflightErrors lists kubeadm preflight checks to ignore on init
+	// and join (default: NumCPU, Mem, SystemVerification and the
+	// bridge-nf-call-iptables check, which fail inside node containers)
+	IgnorePreflightErrors []string `yaml:"ignorePreflightErrors,omitempty" json:"ignorePreflightErrors,omitempty"`
+
+	// ComponentLogLevels sets node component log levels, e.g. {kubelet: "4", crio: debug}
+	ComponentLogLevels map[string]string `yaml:"componentLogLevels,omitempty" json:"componentLogLevels,omitempty"`
+
 	// Deprecated fields (kept for backward compatibility)
 	// CRIOVersion is deprecated, use Versions.CRIO instead
 	CRIOVersion string `yaml:"crioVersion,omitempty" json:"crioVersion,omitempty"`
@@ -65,6 +174,27 @@ type NodesConfig struct {
 
 	// Deprecated: Total is deprecated, use ControlPlanes + Workers
 	Total int `yaml:"total,omitempty" json:"total,omitempty"`
+
+	// Pools are additional named groups of nodes with their own labels and taints
+	Pools []NodePool `yaml:"pools,omitempty" json:"pools,omitempty"`
+}
+
+// NodePool is a named group of nodes sharing a role, labels and taints
+type NodePool struct {
+	// Name of the pool, used in node names (<cluster>-<pool>-<index>)
+	Name string `yaml:"name" json:"name"`
+
+	// Role is "control-plane" or "worker" (default)
+	Role string `yaml:"role,omitempty" json:"role,omitempty"`
+
+	// Count is the number of nodes in the pool
+	Count int `yaml:"count" json:"count"`
+
+	// Labels are applied by the kubelet when the node registers
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
+	// Taints in kubectl format, e.g. "dedicated=gpu:NoSchedule"
+	Taints []string `yaml:"taints,omitempty" json:"taints,omitempty"`
 }
 
 // VersionsConfig specifies component versions to install
@@ -72,7 +202,8 @@ type VersionsConfig struct {
 	// Kubernetes version (e.g., "1.34.2")
 	Kubernetes string `yaml:"kubernetes,omitempty" json:"kubernetes,omitempty"`
 
-	// CRIO version (e.g., "1.34" - minor version only)
+	// CRIO version, a minor version (e.g., "1.34", the tip of its release
+	// branch) or an exact release (e.g., "1.34.2")
 	CRIO string `yaml:"crio,omitempty" json:"crio,omitempty"`
 
 	// Crun version (e.g., "1.25")
@@ -97,6 +228,10 @@ type LocalBuildsConfig struct {
 
 	// RuncBinary path to local runc binary
 	RuncBinary string `yaml:"runcBinary,omitempty" json:"runcBinary,omitempty"`
+
+	// KubeSchedulerBinary path to a local, statically linked kube-scheduler
+	// binary run by the kube-scheduler static pod
+	KubeSchedulerBinary string `yaml:"kubeSchedulerBinary,omitempty" json:"kubeSchedulerBinary,omitempty"`
 }
 
 // NetworkingConfig defines cluster networking
@@ -109,6 +244,18 @@ type NetworkingConfig struct {
 
 	// DNSdomain is the cluster DNS domain
 	DNSDomain string `yaml:"dnsDomain,omitempty" json:"dnsDomain,omitempty"`
+
+	// Network is the podman network the nodes are attached to (default
+	// kipod, shared by all clusters)
+	Network string `yaml:"network,omitempty" json:"network,omitempty"`
+
+	// APIServerPort is the host port the API server is published on
+	// (default 6443)
+	APIServerPort int `yaml:"apiServerPort,omitempty" json:"apiServerPort,omitempty"`
+
+	// IptablesBackend is the iptables backend of the nodes: auto (default,
+	// the backend of the host), nft or legacy
+	IptablesBackend string `yaml:"iptablesBackend,omitempty" json:"iptablesBackend,omitempty"`
 }
 
 // StorageConfig defines container storage configuration
@@ -118,6 +265,23 @@ type StorageConfig struct {
 
 	// Size of storage (e.g. "10G") - primarily for tmpfs
 	Size string `yaml:"size,omitempty" json:"size,omitempty"`
+
+	// Driver is the CRI-O storage driver: "fuse-overlayfs" (image default),
+	// "overlay" (native kernel overlay, tmpfs only) or "vfs"
+	Driver string `yaml:"driver,omitempty" json:"driver,omitempty"`
+}
+
+// NodeStorageConfig defines per-node data volumes
+type NodeStorageConfig struct {
+	// ExtraVolumes are paths in each node backed by a dedicated podman volume,
+	// so pod data survives node container restarts
+	ExtraVolumes []NodeVolume `yaml:"extraVolumes,omitempty" json:"extraVolumes,omitempty"`
+}
+
+// NodeVolume is a path in the node backed by a podman volume
+type NodeVolume struct {
+	// MountPath is the absolute path in the node, e.g. /var/lib/kubelet
+	MountPath string `yaml:"mountPath" json:"mountPath"`
 }
 
 // SchedulerConfig defines kube-scheduler configuration
@@ -132,6 +296,118 @@ type SchedulerConfig struct {
 
 	// ExtraVolumes are additional volumes to mount into the kube-scheduler pod
 	ExtraVolumes []HostPathMount `yaml:"extraVolumes,omitempty" json:"extraVolumes,omitempty"`
+
+	// Image replaces the image of the kube-scheduler static pod, e.g. a
+	// scheduler built with out-of-tree plugins
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+}
+
+// RuntimesConfig enables optional OCI runtimes beyond the default crun/runc
+type RuntimesConfig struct {
+	// Wasm installs crun with WasmEdge support in the node image and
+	// creates a "crun-wasm" RuntimeClass after the cluster is initialized
+	Wasm bool `yaml:"wasm,omitempty" json:"wasm,omitempty"`
+
+	// Sandboxed installs an experimental sandboxed runtime: "kata" or "gvisor"
+	// Kata requires /dev/kvm (nested virtualization when the host is a VM)
+	Sandboxed string `yaml:"sandboxed,omitempty" json:"sandboxed,omitempty"`
+}
+
+// EtcdConfig defines the etcd topology
+type EtcdConfig struct {
+	// External runs etcd in a dedicated container instead of as a static pod
+	// on the control-plane (experimental)
+	External bool `yaml:"external,omitempty" json:"external,omitempty"`
+
+	// Image is the etcd image used for external etcd
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// ImageRepository replaces the repository of the etcd image, which is
+	// then <imageRepository>/etcd
+	ImageRepository string `yaml:"imageRepository,omitempty" json:"imageRepository,omitempty"`
+
+	// ImageTag replaces the etcd image tag
+	ImageTag string `yaml:"imageTag,omitempty" json:"imageTag,omitempty"`
+
+	// Replicas is the number of external etcd members: 1 (default) or 3
+	Replicas int `yaml:"replicas,omitempty" json:"replicas,omitempty"`
+
+	// QuotaBackendBytes is the etcd space quota, e.g. "8G" (etcd default 2G)
+	QuotaBackendBytes string `yaml:"quotaBackendBytes,omitempty" json:"quotaBackendBytes,omitempty"`
+
+	// AutoCompactionMode is "periodic" or "revision"
+	AutoCompactionMode string `yaml:"autoCompactionMode,omitempty" json:"autoCompactionMode,omitempty"`
+
+	// AutoCompactionRetention is a duration like "1h" (periodic) or a
+	// revision count like "1000" (revision)
+	AutoCompactionRetention string `yaml:"autoCompactionRetention,omitempty" json:"autoCompactionRetention,omitempty"`
+
+	// DataOnVolume keeps the etcd data dir on a podman volume instead of the
+	// control-plane container's filesystem or tmpfs
+	DataOnVolume bool `yaml:"dataOnVolume,omitempty" json:"dataOnVolume,omitempty"`
+
+	// UnsafeNoFsync skips fsync on writes, which speeds up etcd but loses
+	// data on a node crash; for throwaway clusters
+	UnsafeNoFsync bool `yaml:"unsafeNoFsync,omitempty" json:"unsafeNoFsync,omitempty"`
+}
+
+// InotifyConfig defines the inotify limits set inside node containers
+type InotifyConfig struct {
+	// MaxUserWatches sets fs.inotify.max_user_watches (default 524288)
+	MaxUserWatches int `yaml:"maxUserWatches,omitempty" json:"maxUserWatches,omitempty"`
+
+	// MaxUserInstances sets fs.inotify.max_user_instances (default 512)
+	MaxUserInstances int `yaml:"maxUserInstances,omitempty" json:"maxUserInstances,omitempty"`
+}
+
+// NodeOptionsConfig defines node container options for all nodes and per node role
+// Role-specific options are merged on top of the options applied to all nodes
+type NodeOptionsConfig struct {
+	// ContainerOptions are applied to every node
+	ContainerOptions `yaml:",inline"`
+
+	// ControlPlane options are applied to control-plane nodes only
+	ControlPlane ContainerOptions `yaml:"controlPlane,omitempty" json:"controlPlane,omitempty"`
+
+	// Worker options are applied to worker nodes only
+	Worker ContainerOptions `yaml:"worker,omitempty" json:"worker,omitempty"`
+}
+
+// ContainerOptions are podman run options passed through to node containers
+type ContainerOptions struct {
+	// Sysctls to set in the node container (e.g. {"net.core.somaxconn": "1024"})
+	Sysctls map[string]string `yaml:"sysctls,omitempty" json:"sysctls,omitempty"`
+
+	// SecurityOpts are podman --security-opt values (e.g. "seccomp=unconfined")
+	SecurityOpts []string `yaml:"securityOpts,omitempty" json:"securityOpts,omitempty"`
+
+	// Devices are host devices to pass through (e.g. "/dev/fuse" or "/dev/kvm:/dev/kvm:rw")
+	Devices []string `yaml:"devices,omitempty" json:"devices,omitempty"`
+
+	// Ulimits are podman --ulimit values (e.g. "nofile=1048576:1048576")
+	Ulimits []string `yaml:"ulimits,omitempty" json:"ulimits,omitempty"`
+}
+
+// ForRole returns the merged options for a node role ("control-plane" or "worker")
+func (n NodeOptionsConfig) ForRole(role string) ContainerOptions {
+	roleOpts := n.Worker
+	if role == "control-plane" {
+		roleOpts = n.ControlPlane
+	}
+
+	merged := ContainerOptions{
+		Sysctls:      make(map[string]string),
+		SecurityOpts: append(append([]string{}, n.SecurityOpts...), roleOpts.SecurityOpts...),
+		Devices:      append(append([]string{}, n.Devices...), roleOpts.Devices...),
+		Ulimits:      append(append([]string{}, n.Ulimits...), roleOpts.Ulimits...),
+	}
+	for k, v := range n.Sysctls {
+		merged.Sysctls[k] = v
+	}
+	for k, v := range roleOpts.Sysctls {
+		merged.Sysctls[k] = v
+	}
+	return merged
 }
 
 // HostPathMount defines a volume mount from host to container
@@ -176,6 +452,10 @@ func DefaultConfig() *ClusterConfig {
 			DNSDomain:     "cluster.local",
 		},
 		CgroupManager: "cgroupfs", // Default to cgroupfs for rootless
+		Inotify: InotifyConfig{
+			MaxUserWatches:   524288,
+			MaxUserInstances: 512,
+		},
 	}
 }
 
@@ -232,20 +512,43 @@ func (c *ClusterConfig) Normalize() {
 	}
 
 	// Set node defaults
-	if c.Nodes.ControlPlanes == 0 && c.Nodes.Workers == 0 && c.Nodes.Total == 0 {
-		c.Nodes.ControlPlanes = 1
+	for i := range c.Nodes.Pools {
+		if c.Nodes.Pools[i].Role == "" {
+			c.Nodes.Pools[i].Role = RoleWorker
+		}
+	}
+	if c.RegistryAuth.AuthFile != "" && !c.RegistryAuth.ImagePullSecret {
+		c.RegistryAuth.Nodes = true
+	}
+	for i := range c.HelmCharts {
+		if c.HelmCharts[i].Namespace == "" {
+			c.HelmCharts[i].Namespace = "default"
+		}
 	}
 	// Handle deprecated Total field
 	if c.Nodes.Total > 0 && c.Nodes.ControlPlanes == 0 && c.Nodes.Workers == 0 {
 		c.Nodes.ControlPlanes = 1
 		c.Nodes.Workers = c.Nodes.Total - 1
 	}
+	// One control-plane unless the count or a pool configures them, also
+	// when only workers are given
+	if c.ControlPlaneCount() == 0 {
+		c.Nodes.ControlPlanes = 1
+	}
 
 	// Set cgroup manager default
 	if c.CgroupManager == "" {
 		c.CgroupManager = "cgroupfs"
 	}
 
+	// Set inotify defaults
+	if c.Inotify.MaxUserWatches == 0 {
+		c.Inotify.MaxUserWatches = 524288
+	}
+	if c.Inotify.MaxUserInstances == 0 {
+		c.Inotify.MaxUserInstances = 512
+	}
+
 	// Set storage defaults
 	if c.Storage.Type == "" {
 		c.Storage.Type = "tmpfs"
@@ -264,8 +567,27 @@ func (c *ClusterConfig) Validate() error {
 	if c.Nodes.Workers < 0 {
 		return fmt.Errorf("worker node count cannot be negative")
 	}
-	if c.Nodes.ControlPlanes == 0 && c.Nodes.Workers == 0 {
-		return fmt.Errorf("cluster must have at least one node")
+	if c.ControlPlaneCount() == 0 {
+		return fmt.Errorf("cluster must have at least one control-plane node")
+	}
+	if err := c.validatePools(); err != nil {
+		return err
+	}
+	if err := validateClusterLabels(c.Labels); err != nil {
+		return err
+	}
+
+	// Validate networking
+	if c.Networking.Network != "" && !networkNameRegexp.MatchString(c.Networking.Network) {
+		return fmt.Errorf("networking.network must be a podman network name (letters, digits, '_', '.', '-'), got: %q", c.Networking.Network)
+	}
+	if c.Networking.APIServerPort < 0 || c.Networking.APIServerPort > 65535 {
+		return fmt.Errorf("networking.apiServerPort must be a port between 1 and 65535, got: %d", c.Networking.APIServerPort)
+	}
+	switch c.Networking.IptablesBackend {
+	case "", "auto", "nft", "legacy":
+	default:
+		return fmt.Errorf("networking.iptablesBackend must be auto, nft or legacy, got: %q", c.Networking.IptablesBackend)
 	}
 
 	// Validate cgroup manager
@@ -273,6 +595,157 @@ func (c *ClusterConfig) Validate() error {
 		return fmt.Errorf("cgroup manager must be 'cgroupfs' or 'systemd', got: %s", c.CgroupManager)
 	}
 
+	if c.CRIOProfile != "" {
+		if _, err := crio.ProfileConfig(c.CRIOProfile); err != nil {
+			return fmt.Errorf("crioProfile: %w", err)
+		}
+	}
+
+	// Validate storage type and driver
+	if c.Storage.Type != "tmpfs" && c.Storage.Type != "volume" {
+		return fmt.Errorf("storage type must be 'tmpfs' or 'volume', got: %s", c.Storage.Type)
+	}
+	switch c.Storage.Driver {
+	case "", "fuse-overlayfs", "vfs":
+	case "overlay":
+		// Native overlay is only supported on the tmpfs graphroot kipod sets up
+		if c.Storage.Type != "tmpfs" {
+			return fmt.Errorf("storage driver 'overlay' requires storage type 'tmpfs'; use 'fuse-overlayfs' or 'vfs' with volume storage")
+		}
+	default:
+		return fmt.Errorf("storage driver must be 'overlay', 'fuse-overlayfs' or 'vfs', got: %s", c.Storage.Driver)
+	}
+
+	// Validate node data volumes
+	seenVolumes := map[string]bool{}
+	for _, vol := range c.NodeStorage.ExtraVolumes {
+		if !path.IsAbs(vol.MountPath) || path.Clean(vol.MountPath) != vol.MountPath || vol.MountPath == "/" {
+			return fmt.Errorf("nodeStorage.extraVolumes: mountPath must be a clean absolute path, got: %q", vol.MountPath)
+		}
+		if strings.HasPrefix(vol.MountPath+"/", "/var/lib/containers/storage/") {
+			return fmt.Errorf("nodeStorage.extraVolumes: %s is managed by storage.type", vol.MountPath)
+		}
+		if seenVolumes[vol.MountPath] {
+			return fmt.Errorf("nodeStorage.extraVolumes: duplicate mountPath %s", vol.MountPath)
+		}
+		seenVolumes[vol.MountPath] = true
+	}
+
+	// Validate sandboxed runtime
+	if c.Runtimes.Sandboxed != "" && c.Runtimes.Sandboxed != "kata" && c.Runtimes.Sandboxed != "gvisor" {
+		return fmt.Errorf("sandboxed runtime must be 'kata' or 'gvisor', got: %s", c.Runtimes.Sandboxed)
+	}
+
+	// Validate inotify limits
+	if c.Inotify.MaxUserWatches < 0 || c.Inotify.MaxUserInstances < 0 {
+		return fmt.Errorf("inotify limits cannot be negative")
+	}
+
+	// Validate node container options
+	if err := c.NodeOptions.ContainerOptions.validate(); err != nil {
+		return fmt.Errorf("invalid nodeOptions: %w", err)
+	}
+	if err := c.NodeOptions.ControlPlane.validate(); err != nil {
+		return fmt.Errorf("invalid nodeOptions.controlPlane: %w", err)
+	}
+	if err := c.NodeOptions.Worker.validate(); err != nil {
+		return fmt.Errorf("invalid nodeOptions.worker: %w", err)
+	}
+	if err := c.NodeEnv.validate(); err != nil {
+		return err
+	}
+	if err := c.NodeHealth.validate(); err != nil {
+		return err
+	}
+	if err := c.Kubeconfig.validate(); err != nil {
+		return err
+	}
+
+	// Validate external etcd
+	if c.Etcd.Replicas != 0 {
+		if !c.Etcd.External {
+			return fmt.Errorf("etcd.replicas requires etcd.external")
+		}
+		if c.Etcd.Replicas != 1 && c.Etcd.Replicas != 3 {
+			return fmt.Errorf("etcd.replicas must be 1 or 3, got: %d", c.Etcd.Replicas)
+		}
+	}
+
+	switch c.Etcd.AutoCompactionMode {
+	case "", "periodic", "revision":
+	default:
+		return fmt.Errorf("etcd.autoCompactionMode must be 'periodic' or 'revision', got: %s", c.Etcd.AutoCompactionMode)
+	}
+	if c.Etcd.AutoCompactionRetention != "" && c.Etcd.AutoCompactionMode == "revision" {
+		if _, err := strconv.ParseUint(c.Etcd.AutoCompactionRetention, 10, 64); err != nil {
+			return fmt.Errorf("etcd.autoCompactionRetention must be a revision count in revision mode, got: %s", c.Etcd.AutoCompactionRetention)
+		}
+	}
+
+	if err := c.validateFeatures(); err != nil {
+		return err
+	}
+	if err := c.validateKubeadm(); err != nil {
+		return fmt.Errorf("invalid kubeadm config: %w", err)
+	}
+
+	for _, check := range c.IgnorePreflightErrors {
+		if !preflightCheckRegexp.MatchString(check) {
+			return fmt.Errorf("invalid ignorePreflightErrors entry %q", check)
+		}
+	}
+
+	if err := validateLogLevels(c.ComponentLogLevels); err != nil {
+		return fmt.Errorf("invalid componentLogLevels: %w", err)
+	}
+
+	// Validate systemd units and overrides
+	if err := c.validateSystemd(); err != nil {
+		return err
+	}
+
+	if err := c.ValidateSources(); err != nil {
+		return err
+	}
+
+	if c.LocalBuilds.KubeSchedulerBinary != "" && c.Scheduler.Image != "" {
+		return fmt.Errorf("localBuilds.kubeSchedulerBinary and scheduler.image cannot be combined")
+	}
+	if err := c.validateComponentImages(); err != nil {
+		return err
+	}
+	if err := c.validateImageRepositories(); err != nil {
+		return err
+	}
+	if err := c.RegistryAuth.validate(); err != nil {
+		return err
+	}
+	if err := c.SignaturePolicy.validate(); err != nil {
+		return err
+	}
+	if err := c.ImageGC.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Addons.validate(); err != nil {
+		return err
+	}
+
+	if err := c.validateHelmCharts(); err != nil {
+		return err
+	}
+
+	for _, manifest := range c.PostCreateManifests {
+		if manifest == "" {
+			return fmt.Errorf("postCreateManifests: empty entry")
+		}
+		if IsManifestURL(manifest) {
+			if err := validateSourceURL("postCreateManifests", manifest); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Validate version compatibility (CRI-O follows Kubernetes n-2 policy)
 	if err := validateVersionCompatibility(c.Versions.Kubernetes, c.Versions.CRIO); err != nil {
 		return fmt.Errorf("version compatibility check failed: %w", err)
@@ -333,9 +806,30 @@ func extractMinorVersion(version string) (int, error) {
 	return minor, nil
 }
 
-// TotalNodes returns the total number of nodes
+// TotalNodes returns the total number of nodes, including node pools
 func (c *ClusterConfig) TotalNodes() int {
-	return c.Nodes.ControlPlanes + c.Nodes.Workers
+	return c.ControlPlaneCount() + c.WorkerCount()
+}
+
+// ControlPlaneCount returns the number of control-plane nodes, including pools
+func (c *ClusterConfig) ControlPlaneCount() int {
+	return c.Nodes.ControlPlanes + c.PoolNodes(RoleControlPlane)
+}
+
+// WorkerCount returns the number of worker nodes, including pools
+func (c *ClusterConfig) WorkerCount() int {
+	return c.Nodes.Workers + c.PoolNodes(RoleWorker)
+}
+
+// PoolNodes returns the number of pool nodes with the given role
+func (c *ClusterConfig) PoolNodes(role string) int {
+	total := 0
+	for _, pool := range c.Nodes.Pools {
+		if pool.Role == role || (pool.Role == "" && role == RoleWorker) {
+			total += pool.Count
+		}
+	}
+	return total
 }
 
 // HasLocalBuilds returns true if any local builds are configured
@@ -343,5 +837,12 @@ func (c *ClusterConfig) HasLocalBuilds() bool {
 	return c.LocalBuilds.CRIOBinary != "" ||
 		c.LocalBuilds.CRIOSourceDir != "" ||
 		c.LocalBuilds.CrunBinary != "" ||
-		c.LocalBuilds.RuncBinary != ""
+		c.LocalBuilds.RuncBinary != "" ||
+		c.LocalBuilds.KubeSchedulerBinary != ""
+}
+
+// IsManifestURL reports whether a postCreateManifests entry is a URL rather
+// than a local path
+func IsManifestURL(manifest string) bool {
+	return strings.HasPrefix(manifest, "http://") || strings.HasPrefix(manifest, "https://")
 }
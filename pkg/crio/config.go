@@ -2,7 +2,10 @@ package crio
 
 import (
 	"fmt"
+	"runtime"
 	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
 )
 
 const (
@@ -112,8 +115,19 @@ echo "CRI-O installed successfully"
 `, version, version)
 }
 
-// SetupCommands returns commands to configure CRI-O in a container
-func SetupCommands() [][]string {
+// CNIPluginsVersion is the CNI plugins release installed by SetupCommands
+const CNIPluginsVersion = "1.3.0"
+
+// SetupCommands returns commands to configure CRI-O in a container. The CNI
+// plugins are verified against the checksum pinned in the artifact manifest.
+func SetupCommands() ([][]string, error) {
+	plugins, err := build.LookupArtifact("cni-plugins", CNIPluginsVersion, runtime.GOARCH)
+	if err != nil {
+		return nil, err
+	}
+	if err := plugins.Check(); err != nil {
+		return nil, err
+	}
 	return [][]string{
 		// Create config directories
 		{"mkdir", "-p", CRIODropinPath},
@@ -121,8 +135,8 @@ func SetupCommands() [][]string {
 		{"mkdir", "-p", "/opt/cni/bin"},
 
 		// Install CNI plugins
-		{"sh", "-c", "curl -L https://github.com/containernetworking/plugins/releases/download/v1.3.0/cni-plugins-linux-amd64-v1.3.0.tgz | tar -C /opt/cni/bin -xz"},
-	}
+		{"sh", "-c", plugins.FetchCommand("/tmp/cni-plugins.tgz") + " && tar -C /opt/cni/bin -xzf /tmp/cni-plugins.tgz && rm -f /tmp/cni-plugins.tgz"},
+	}, nil
 }
 
 // WriteConfigCommand returns the command to write CRI-O config
@@ -248,3 +262,37 @@ net.ipv4.ip_forward                 = 1
 		{"sysctl", "--system"},
 	}
 }
+
+// SupportedRuntimes lists the OCI runtimes shipped in the kipod node image
+var SupportedRuntimes = map[string]string{
+	"crun": "/usr/bin/crun",
+	"runc": "/usr/bin/runc",
+}
+
+// DefaultRuntimeDropin is the drop-in used to override CRI-O's default runtime.
+// It sorts after the image defaults but before 99-user.conf so user config still wins.
+const DefaultRuntimeDropin = "50-default-runtime.conf"
+
+// GenerateDefaultRuntimeConfig generates a drop-in that switches CRI-O's default runtime
+func GenerateDefaultRuntimeConfig(runtime string) (string, error) {
+	path, ok := SupportedRuntimes[runtime]
+	if !ok {
+		return "", fmt.Errorf("unsupported runtime %q, must be one of: crun, runc", runtime)
+	}
+
+	return fmt.Sprintf(`# Managed by kipod configure default-runtime
+[crio.runtime]
+  default_runtime = "%s"
+
+[crio.runtime.runtimes.%s]
+  runtime_path = "%s"
+`, runtime, runtime, path), nil
+}
+
+// WriteDropinCommand returns the command to write a named CRI-O drop-in
+func WriteDropinCommand(name, config string) []string {
+	return []string{
+		"sh", "-c",
+		fmt.Sprintf("cat > %s/%s << 'EOF'\n%s\nEOF", CRIODropinPath, name, config),
+	}
+}
@@ -0,0 +1,24 @@
+package crio
+
+import "strings"
+
+// FeaturesDropin is the drop-in holding the CRI-O side of experimental
+// features kipod turns on together with their kubelet feature gates
+const FeaturesDropin = "81-kipod-features.conf"
+
+// FeaturesConfig returns the drop-in enabling CRI-O's pod events, for the
+// kubelet's Evented PLEG, and its CRIU support, for checkpoint/restore, or
+// "" when neither is enabled
+func FeaturesConfig(podEvents, criu bool) string {
+	var settings []string
+	if podEvents {
+		settings = append(settings, "enable_pod_events = true")
+	}
+	if criu {
+		settings = append(settings, "enable_criu_support = true")
+	}
+	if len(settings) == 0 {
+		return ""
+	}
+	return "# Managed by kipod: features\n[crio.runtime]\n" + strings.Join(settings, "\n") + "\n"
+}
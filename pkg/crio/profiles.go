@@ -0,0 +1,60 @@
+package crio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProfileDropin is the drop-in a CRI-O profile expands into. It sorts after
+// the image defaults but before the drop-ins kipod writes for explicit
+// settings, such as the CRI-O log level, and 99-user.conf, so those win.
+const ProfileDropin = "80-kipod-profile.conf"
+
+// HighPidsLimit is the kubelet podPidsLimit the high-pids profile sets
+const HighPidsLimit = 32768
+
+// profiles are the CRI-O configs of common experiments, selected with the
+// crioProfile config field
+var profiles = map[string]string{
+	// CRI-O emits container events the kubelet's Evented PLEG subscribes to
+	// instead of relisting every pod each second; kipod turns on the
+	// EventedPLEG feature gate for this profile
+	"evented-pleg": `[crio.runtime]
+enable_pod_events = true
+`,
+	// Raise the pids limit of pods for fork-heavy workloads. CRI-O applies
+	// the limit the kubelet passes, so kipod sets the kubelet's podPidsLimit
+	// to HighPidsLimit for this profile and the drop-in has nothing to set.
+	"high-pids": `# The kubelet's podPidsLimit is 32768; CRI-O applies it to every pod
+`,
+	// Debug logs of every CRI request, in the journal of the crio unit
+	"debug-logging": `[crio.runtime]
+log_level = "debug"
+`,
+	// CRI-O mounts the image volumes of pods itself once kipod turns on the
+	// ImageVolume feature gates for this profile; also bind mount the
+	// VOLUMEs images declare rather than creating empty directories
+	"image-volume": `[crio.image]
+image_volumes = "bind"
+`,
+}
+
+// Profiles returns the names of the CRI-O profiles, sorted
+func Profiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileConfig returns the drop-in of a CRI-O profile
+func ProfileConfig(name string) (string, error) {
+	config, ok := profiles[name]
+	if !ok {
+		return "", fmt.Errorf("unknown CRI-O profile %q, must be one of: %s", name, strings.Join(Profiles(), ", "))
+	}
+	return fmt.Sprintf("# Managed by kipod: crioProfile %s\n%s", name, config), nil
+}
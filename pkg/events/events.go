@@ -0,0 +1,72 @@
+// Package events emits machine-readable progress events as JSON lines, so
+// wrapper scripts and IDE plugins can render their own UI on top of kipod
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	PhaseStart  = "phase_start"
+	PhaseEnd    = "phase_end"
+	NodeCreated = "node_created"
+	Step        = "step"
+	Info        = "info"
+	Warning     = "warning"
+	Success     = "success"
+)
+
+// Event is a single progress event
+type Event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Cluster string    `json:"cluster,omitempty"`
+	Phase   string    `json:"phase,omitempty"`
+	Node    string    `json:"node,omitempty"`
+	Role    string    `json:"role,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Seconds float64   `json:"seconds,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	// Reason is a stable identifier of why a phase failed, when known
+	Reason string `json:"reason,omitempty"`
+}
+
+var (
+	mu  sync.Mutex
+	out io.Writer
+)
+
+// Enable starts writing events to w; a nil writer disables events
+func Enable(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = w
+}
+
+// Enabled reports whether events are being written
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return out != nil
+}
+
+// Emit writes an event as a single JSON line if events are enabled
+func Emit(e Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	out.Write(append(data, '\n'))
+}
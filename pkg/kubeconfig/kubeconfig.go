@@ -0,0 +1,348 @@
+// Package kubeconfig merges kipod cluster credentials into kubeconfig files
+// and points them at the API server address reachable from the host.
+// The types mirror the clientcmd v1 layout; entry bodies are kept as generic
+// maps so fields kipod does not know about (exec auth, extensions, ...) in a
+// user's existing kubeconfig survive a merge. They stand in for the
+// k8s.io/client-go/tools/clientcmd/api types until client-go is vendored;
+// Config keeps the same field names so the switch stays mechanical.
+package kubeconfig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sohankunkerkar/kipod/pkg/paths"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a kubeconfig file
+type Config struct {
+	APIVersion     string                 `yaml:"apiVersion"`
+	Kind           string                 `yaml:"kind"`
+	Clusters       []NamedCluster         `yaml:"clusters"`
+	Contexts       []NamedContext         `yaml:"contexts"`
+	CurrentContext string                 `yaml:"current-context"`
+	AuthInfos      []NamedAuthInfo        `yaml:"users"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+// NamedCluster is a named cluster entry
+type NamedCluster struct {
+	Name    string                 `yaml:"name"`
+	Cluster map[string]interface{} `yaml:"cluster"`
+}
+
+// NamedAuthInfo is a named user entry
+type NamedAuthInfo struct {
+	Name     string                 `yaml:"name"`
+	AuthInfo map[string]interface{} `yaml:"user"`
+}
+
+// NamedContext is a named context entry
+type NamedContext struct {
+	Name    string  `yaml:"name"`
+	Context Context `yaml:"context"`
+}
+
+// Context ties a cluster to a user
+type Context struct {
+	Cluster   string                 `yaml:"cluster"`
+	AuthInfo  string                 `yaml:"user"`
+	Namespace string                 `yaml:"namespace,omitempty"`
+	Extra     map[string]interface{} `yaml:",inline"`
+}
+
+// ContextName returns the default context, cluster and user name of a kipod
+// cluster
+func ContextName(clusterName string) string {
+	return "kipod-" + clusterName
+}
+
+// DefaultPath returns the kubeconfig kubectl uses by default: the first
+// entry of $KUBECONFIG, or $HOME/.kube/config
+func DefaultPath() string {
+	for _, path := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if path != "" {
+			return path
+		}
+	}
+	return filepath.Join(paths.KubeDir(), "config")
+}
+
+// Parse parses kubeconfig data
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+	if cfg.APIVersion == "" {
+		cfg.APIVersion = "v1"
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = "Config"
+	}
+	return cfg, nil
+}
+
+// Load reads a kubeconfig file, returning an empty config if it does not exist
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Parse(nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig %s: %w", path, err)
+	}
+	return Parse(data)
+}
+
+// Marshal encodes the kubeconfig as YAML
+func (c *Config) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, fmt.Errorf("failed to marshal kubeconfig: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Write saves the kubeconfig to path with owner-only permissions
+func (c *Config) Write(path string) error {
+	data, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create kubeconfig directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig %s: %w", path, err)
+	}
+	return nil
+}
+
+// ForCluster converts a kubeadm admin kubeconfig into one whose cluster,
+// user and context are all named name, so several clusters can live in the
+// same file
+func ForCluster(data []byte, name string) (*Config, error) {
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	cluster, user := cfg.current()
+	if cluster == nil || user == nil {
+		return nil, fmt.Errorf("kubeconfig for %s has no cluster or user entry", name)
+	}
+
+	return &Config{
+		APIVersion:     cfg.APIVersion,
+		Kind:           cfg.Kind,
+		Clusters:       []NamedCluster{{Name: name, Cluster: cluster.Cluster}},
+		AuthInfos:      []NamedAuthInfo{{Name: name, AuthInfo: user.AuthInfo}},
+		Contexts:       []NamedContext{{Name: name, Context: Context{Cluster: name, AuthInfo: name}}},
+		CurrentContext: name,
+	}, nil
+}
+
+// UserContextName returns the context and user name of an extra user of a
+// kipod cluster
+func UserContextName(clusterName, user string) string {
+	return user + "@" + ContextName(clusterName)
+}
+
+// ForUser builds a kubeconfig for the cluster of a kubeadm admin kubeconfig
+// that authenticates as another user with a PEM client certificate and key.
+// The context defaults to namespace when it is set.
+func ForUser(data []byte, clusterName, user string, cert, key []byte, namespace string) (*Config, error) {
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	cluster, _ := cfg.current()
+	if cluster == nil {
+		return nil, fmt.Errorf("kubeconfig for cluster '%s' has no cluster entry", clusterName)
+	}
+
+	clusterEntry := ContextName(clusterName)
+	name := UserContextName(clusterName, user)
+	return &Config{
+		APIVersion: cfg.APIVersion,
+		Kind:       cfg.Kind,
+		Clusters:   []NamedCluster{{Name: clusterEntry, Cluster: cluster.Cluster}},
+		AuthInfos: []NamedAuthInfo{{Name: name, AuthInfo: map[string]interface{}{
+			"client-certificate-data": base64.StdEncoding.EncodeToString(cert),
+			"client-key-data":         base64.StdEncoding.EncodeToString(key),
+		}}},
+		Contexts:       []NamedContext{{Name: name, Context: Context{Cluster: clusterEntry, AuthInfo: name, Namespace: namespace}}},
+		CurrentContext: name,
+	}, nil
+}
+
+// current returns the cluster and user entries of the current context, or
+// the first entries of a file without a current context. Entries it does
+// not find are nil.
+func (c *Config) current() (*NamedCluster, *NamedAuthInfo) {
+	var clusterName, userName string
+	for _, context := range c.Contexts {
+		if context.Name == c.CurrentContext {
+			clusterName, userName = context.Context.Cluster, context.Context.AuthInfo
+		}
+	}
+
+	var cluster *NamedCluster
+	for i := range c.Clusters {
+		if c.Clusters[i].Name == clusterName || (clusterName == "" && i == 0) {
+			cluster = &c.Clusters[i]
+			break
+		}
+	}
+	var user *NamedAuthInfo
+	for i := range c.AuthInfos {
+		if c.AuthInfos[i].Name == userName || (userName == "" && i == 0) {
+			user = &c.AuthInfos[i]
+			break
+		}
+	}
+	return cluster, user
+}
+
+// Server returns the API server URL of the cluster of the current context
+func (c *Config) Server() string {
+	cluster, _ := c.current()
+	if cluster == nil {
+		return ""
+	}
+	server, _ := cluster.Cluster["server"].(string)
+	return server
+}
+
+// SetServerHost replaces the host and port of the API server URL of every
+// cluster entry, keeping its scheme, and its port when port is 0
+func (c *Config) SetServerHost(host string, port int) error {
+	for _, cluster := range c.Clusters {
+		server, _ := cluster.Cluster["server"].(string)
+		endpoint, err := url.Parse(server)
+		if err != nil || endpoint.Host == "" {
+			return fmt.Errorf("cluster %q has an invalid server %q", cluster.Name, server)
+		}
+		if port != 0 {
+			endpoint.Host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if port := endpoint.Port(); port != "" {
+			endpoint.Host = net.JoinHostPort(host, port)
+		} else {
+			endpoint.Host = host
+		}
+		cluster.Cluster["server"] = endpoint.String()
+	}
+	return nil
+}
+
+// Merge adds the entries of other, replacing entries with the same name
+func (c *Config) Merge(other *Config) {
+	for _, cluster := range other.Clusters {
+		c.Clusters = setCluster(c.Clusters, cluster)
+	}
+	for _, user := range other.AuthInfos {
+		c.AuthInfos = setAuthInfo(c.AuthInfos, user)
+	}
+	for _, context := range other.Contexts {
+		c.Contexts = setContext(c.Contexts, context)
+	}
+}
+
+// HasContext reports whether a context with the given name exists
+func (c *Config) HasContext(name string) bool {
+	for _, context := range c.Contexts {
+		if context.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UseContext makes the named context current
+func (c *Config) UseContext(name string) error {
+	if !c.HasContext(name) {
+		return fmt.Errorf("context %q not found", name)
+	}
+	c.CurrentContext = name
+	return nil
+}
+
+// RemoveCluster removes the cluster, user and context entries named name
+// and reports whether anything was removed
+func (c *Config) RemoveCluster(name string) bool {
+	removed := false
+
+	clusters := c.Clusters[:0]
+	for _, cluster := range c.Clusters {
+		if cluster.Name == name {
+			removed = true
+			continue
+		}
+		clusters = append(clusters, cluster)
+	}
+	c.Clusters = clusters
+
+	users := c.AuthInfos[:0]
+	for _, user := range c.AuthInfos {
+		if user.Name == name {
+			removed = true
+			continue
+		}
+		users = append(users, user)
+	}
+	c.AuthInfos = users
+
+	contexts := c.Contexts[:0]
+	for _, context := range c.Contexts {
+		if context.Name == name {
+			removed = true
+			continue
+		}
+		contexts = append(contexts, context)
+	}
+	c.Contexts = contexts
+
+	if c.CurrentContext == name {
+		c.CurrentContext = ""
+	}
+	return removed
+}
+
+func setCluster(list []NamedCluster, entry NamedCluster) []NamedCluster {
+	for i := range list {
+		if list[i].Name == entry.Name {
+			list[i] = entry
+			return list
+		}
+	}
+	return append(list, entry)
+}
+
+func setAuthInfo(list []NamedAuthInfo, entry NamedAuthInfo) []NamedAuthInfo {
+	for i := range list {
+		if list[i].Name == entry.Name {
+			list[i] = entry
+			return list
+		}
+	}
+	return append(list, entry)
+}
+
+func setContext(list []NamedContext, entry NamedContext) []NamedContext {
+	for i := range list {
+		if list[i].Name == entry.Name {
+			list[i] = entry
+			return list
+		}
+	}
+	return append(list, entry)
+}
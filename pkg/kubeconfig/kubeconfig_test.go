@@ -0,0 +1,468 @@
+package kubeconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// adminKubeconfig is a kubeadm admin.conf as written on the control-plane
+const adminKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: kubernetes
+  cluster:
+    certificate-authority-data: Q0E=
+    server: https://10.88.0.2:6443
+contexts:
+- name: kubernetes-admin@kubernetes
+  context:
+    cluster: kubernetes
+    user: kubernetes-admin
+current-context: kubernetes-admin@kubernetes
+users:
+- name: kubernetes-admin
+  user:
+    client-certificate-data: Q0VSVA==
+    client-key-data: S0VZ
+`
+
+// multiKubeconfig is a user's kubeconfig with several clusters, an exec
+// user and fields kipod does not know about
+const multiKubeconfig = `apiVersion: v1
+kind: Config
+preferences:
+  colors: true
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com
+- name: staging
+  cluster:
+    server: https://[fd00::10]:8443
+    extensions:
+    - name: client.authentication.k8s.io/exec
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: sso
+    namespace: web
+- name: staging
+  context:
+    cluster: staging
+    user: sso
+current-context: staging
+users:
+- name: sso
+  user:
+    exec:
+      command: sso-login
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           string
+		wantClusters   []string
+		wantCurrent    string
+		wantServer     string
+		wantAPIVersion string
+		wantErr        bool
+	}{
+		{
+			name:           "empty",
+			data:           "",
+			wantAPIVersion: "v1",
+		},
+		{
+			name:           "kubeadm admin",
+			data:           adminKubeconfig,
+			wantClusters:   []string{"kubernetes"},
+			wantCurrent:    "kubernetes-admin@kubernetes",
+			wantServer:     "https://10.88.0.2:6443",
+			wantAPIVersion: "v1",
+		},
+		{
+			name:           "multi-cluster uses the current context",
+			data:           multiKubeconfig,
+			wantClusters:   []string{"prod", "staging"},
+			wantCurrent:    "staging",
+			wantServer:     "https://[fd00::10]:8443",
+			wantAPIVersion: "v1",
+		},
+		{
+			name:    "invalid",
+			data:    "clusters: {",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := clusterNames(cfg); !reflect.DeepEqual(got, tt.wantClusters) {
+				t.Errorf("clusters = %v, want %v", got, tt.wantClusters)
+			}
+			if cfg.CurrentContext != tt.wantCurrent {
+				t.Errorf("current context = %q, want %q", cfg.CurrentContext, tt.wantCurrent)
+			}
+			if got := cfg.Server(); got != tt.wantServer {
+				t.Errorf("server = %q, want %q", got, tt.wantServer)
+			}
+			if cfg.APIVersion != tt.wantAPIVersion || cfg.Kind != "Config" {
+				t.Errorf("apiVersion/kind = %s/%s, want %s/Config", cfg.APIVersion, cfg.Kind, tt.wantAPIVersion)
+			}
+		})
+	}
+}
+
+func TestParseKeepsUnknownFields(t *testing.T) {
+	cfg, err := Parse([]byte(multiKubeconfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := cfg.Marshal()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"preferences:", "colors: true", "extensions:", "command: sso-login", "namespace: web"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("marshaled kubeconfig lost %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestForCluster(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantServer string
+		wantUser   string
+		wantErr    bool
+	}{
+		{
+			name:       "kubeadm admin",
+			data:       adminKubeconfig,
+			wantServer: "https://10.88.0.2:6443",
+			wantUser:   "client-key-data",
+		},
+		{
+			name:       "current context of several",
+			data:       multiKubeconfig,
+			wantServer: "https://[fd00::10]:8443",
+			wantUser:   "exec",
+		},
+		{
+			name: "no current context uses the first entries",
+			data: `clusters:
+- name: a
+  cluster:
+    server: https://127.0.0.1:40123
+users:
+- name: a
+  user:
+    token: abc
+`,
+			wantServer: "https://127.0.0.1:40123",
+			wantUser:   "token",
+		},
+		{
+			name:    "no user",
+			data:    "clusters:\n- name: a\n  cluster:\n    server: https://127.0.0.1:6443\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ForCluster([]byte(tt.data), "kipod-dev")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(cfg.Clusters) != 1 || len(cfg.AuthInfos) != 1 || len(cfg.Contexts) != 1 {
+				t.Fatalf("want one cluster, user and context, got %d, %d, %d", len(cfg.Clusters), len(cfg.AuthInfos), len(cfg.Contexts))
+			}
+			if cfg.Clusters[0].Name != "kipod-dev" || cfg.AuthInfos[0].Name != "kipod-dev" || cfg.CurrentContext != "kipod-dev" {
+				t.Errorf("entries are not named kipod-dev: %+v", cfg)
+			}
+			if want := (Context{Cluster: "kipod-dev", AuthInfo: "kipod-dev"}); !reflect.DeepEqual(cfg.Contexts[0].Context, want) {
+				t.Errorf("context = %+v, want %+v", cfg.Contexts[0].Context, want)
+			}
+			if got := cfg.Server(); got != tt.wantServer {
+				t.Errorf("server = %q, want %q", got, tt.wantServer)
+			}
+			if _, ok := cfg.AuthInfos[0].AuthInfo[tt.wantUser]; !ok {
+				t.Errorf("user %v has no %s", cfg.AuthInfos[0].AuthInfo, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestSetServerHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		host    string
+		port    int
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "published port",
+			server: "https://10.88.0.2:6443",
+			host:   "127.0.0.1",
+			port:   40123,
+			want:   "https://127.0.0.1:40123",
+		},
+		{
+			name:   "keeps a non-6443 port",
+			server: "https://10.88.0.2:8443",
+			host:   "127.0.0.1",
+			want:   "https://127.0.0.1:8443",
+		},
+		{
+			name:   "no port",
+			server: "https://api.example.com",
+			host:   "localhost",
+			want:   "https://localhost",
+		},
+		{
+			name:   "IPv6 host",
+			server: "https://10.88.0.2:6443",
+			host:   "::1",
+			port:   6443,
+			want:   "https://[::1]:6443",
+		},
+		{
+			name:   "from an IPv6 server",
+			server: "https://[fd00::2]:6443",
+			host:   "127.0.0.1",
+			want:   "https://127.0.0.1:6443",
+		},
+		{
+			name:   "keeps the path",
+			server: "https://10.88.0.2:6443/k8s",
+			host:   "127.0.0.1",
+			port:   40123,
+			want:   "https://127.0.0.1:40123/k8s",
+		},
+		{
+			name:    "invalid server",
+			server:  "10.88.0.2",
+			host:    "127.0.0.1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Clusters: []NamedCluster{{Name: "a", Cluster: map[string]interface{}{"server": tt.server}}}}
+			err := cfg.SetServerHost(tt.host, tt.port)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := cfg.Clusters[0].Cluster["server"]; got != tt.want {
+				t.Errorf("server = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetServerHostAllClusters(t *testing.T) {
+	cfg, err := Parse([]byte(multiKubeconfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cfg.SetServerHost("127.0.0.1", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"https://127.0.0.1", "https://127.0.0.1:8443"}
+	for i, cluster := range cfg.Clusters {
+		if got := cluster.Cluster["server"]; got != want[i] {
+			t.Errorf("server of %s = %q, want %q", cluster.Name, got, want[i])
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	kipod := func(name, server string) *Config {
+		return &Config{
+			Clusters:       []NamedCluster{{Name: name, Cluster: map[string]interface{}{"server": server}}},
+			AuthInfos:      []NamedAuthInfo{{Name: name, AuthInfo: map[string]interface{}{"token": name}}},
+			Contexts:       []NamedContext{{Name: name, Context: Context{Cluster: name, AuthInfo: name}}},
+			CurrentContext: name,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		base         string
+		other        *Config
+		wantClusters []string
+		wantUsers    []string
+		wantContexts []string
+		wantServer   map[string]string
+	}{
+		{
+			name:         "into an empty file",
+			base:         "",
+			other:        kipod("kipod-dev", "https://127.0.0.1:40123"),
+			wantClusters: []string{"kipod-dev"},
+			wantUsers:    []string{"kipod-dev"},
+			wantContexts: []string{"kipod-dev"},
+			wantServer:   map[string]string{"kipod-dev": "https://127.0.0.1:40123"},
+		},
+		{
+			name:         "next to other clusters",
+			base:         multiKubeconfig,
+			other:        kipod("kipod-dev", "https://[::1]:6443"),
+			wantClusters: []string{"prod", "staging", "kipod-dev"},
+			wantUsers:    []string{"sso", "kipod-dev"},
+			wantContexts: []string{"prod", "staging", "kipod-dev"},
+			wantServer:   map[string]string{"prod": "https://prod.example.com", "kipod-dev": "https://[::1]:6443"},
+		},
+		{
+			name:         "replaces entries of the same name",
+			base:         multiKubeconfig,
+			other:        kipod("staging", "https://127.0.0.1:40124"),
+			wantClusters: []string{"prod", "staging"},
+			wantUsers:    []string{"sso", "staging"},
+			wantContexts: []string{"prod", "staging"},
+			wantServer:   map[string]string{"staging": "https://127.0.0.1:40124"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.base))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			current := cfg.CurrentContext
+			cfg.Merge(tt.other)
+
+			if got := clusterNames(cfg); !reflect.DeepEqual(got, tt.wantClusters) {
+				t.Errorf("clusters = %v, want %v", got, tt.wantClusters)
+			}
+			var users, contexts []string
+			for _, user := range cfg.AuthInfos {
+				users = append(users, user.Name)
+			}
+			for _, context := range cfg.Contexts {
+				contexts = append(contexts, context.Name)
+			}
+			if !reflect.DeepEqual(users, tt.wantUsers) {
+				t.Errorf("users = %v, want %v", users, tt.wantUsers)
+			}
+			if !reflect.DeepEqual(contexts, tt.wantContexts) {
+				t.Errorf("contexts = %v, want %v", contexts, tt.wantContexts)
+			}
+			for _, cluster := range cfg.Clusters {
+				if want, ok := tt.wantServer[cluster.Name]; ok && cluster.Cluster["server"] != want {
+					t.Errorf("server of %s = %q, want %q", cluster.Name, cluster.Cluster["server"], want)
+				}
+			}
+			if cfg.CurrentContext != current {
+				t.Errorf("merge changed the current context to %q", cfg.CurrentContext)
+			}
+		})
+	}
+}
+
+func TestRemoveCluster(t *testing.T) {
+	tests := []struct {
+		name         string
+		remove       string
+		wantRemoved  bool
+		wantClusters []string
+		wantUsers    int
+		wantCurrent  string
+	}{
+		{
+			name:         "current cluster",
+			remove:       "staging",
+			wantRemoved:  true,
+			wantClusters: []string{"prod"},
+			wantUsers:    1,
+			wantCurrent:  "",
+		},
+		{
+			name:         "other cluster keeps the current context",
+			remove:       "prod",
+			wantRemoved:  true,
+			wantClusters: []string{"staging"},
+			wantUsers:    1,
+			wantCurrent:  "staging",
+		},
+		{
+			name:         "shared user",
+			remove:       "sso",
+			wantRemoved:  true,
+			wantClusters: []string{"prod", "staging"},
+			wantUsers:    0,
+			wantCurrent:  "staging",
+		},
+		{
+			name:         "unknown",
+			remove:       "kipod-dev",
+			wantRemoved:  false,
+			wantClusters: []string{"prod", "staging"},
+			wantUsers:    1,
+			wantCurrent:  "staging",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(multiKubeconfig))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := cfg.RemoveCluster(tt.remove); got != tt.wantRemoved {
+				t.Errorf("removed = %t, want %t", got, tt.wantRemoved)
+			}
+			if got := clusterNames(cfg); !reflect.DeepEqual(got, tt.wantClusters) {
+				t.Errorf("clusters = %v, want %v", got, tt.wantClusters)
+			}
+			if len(cfg.AuthInfos) != tt.wantUsers {
+				t.Errorf("users = %d, want %d", len(cfg.AuthInfos), tt.wantUsers)
+			}
+			if cfg.HasContext(tt.remove) {
+				t.Errorf("context %s was not removed", tt.remove)
+			}
+			if cfg.CurrentContext != tt.wantCurrent {
+				t.Errorf("current context = %q, want %q", cfg.CurrentContext, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func clusterNames(cfg *Config) []string {
+	var names []string
+	for _, cluster := range cfg.Clusters {
+		names = append(names, cluster.Name)
+	}
+	return names
+}
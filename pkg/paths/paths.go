@@ -0,0 +1,87 @@
+// Package paths resolves the directories kipod keeps files in on the host.
+// They follow the XDG base directory specification, with KIPOD_* overrides,
+// so packaged installs (rpm, deb, Homebrew) and CI runners can relocate them.
+package paths
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// appName is the subdirectory kipod uses below each base directory
+const appName = "kipod"
+
+// HomeDir returns the home directory of the user, from $HOME or, when it is
+// unset, the user database
+func HomeDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return home
+	}
+	if u, err := user.Current(); err == nil {
+		return u.HomeDir
+	}
+	return ""
+}
+
+// DataDir returns where kipod keeps data that outlives a run, such as the
+// state of clusters: $KIPOD_DATA_DIR, $XDG_DATA_HOME/kipod or
+// ~/.local/share/kipod
+func DataDir() string {
+	return resolve("KIPOD_DATA_DIR", "XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// CacheDir returns where kipod keeps files that can be deleted at any time,
+// such as podman command logs: $KIPOD_CACHE_DIR, $XDG_CACHE_HOME/kipod or
+// ~/.cache/kipod
+func CacheDir() string {
+	return resolve("KIPOD_CACHE_DIR", "XDG_CACHE_HOME", ".cache")
+}
+
+// CheckOverrides returns an error when a KIPOD_* directory override is set
+// to a relative path, which would resolve against whatever directory kipod
+// happens to run in
+func CheckOverrides() error {
+	for _, override := range []string{"KIPOD_DATA_DIR", "KIPOD_CACHE_DIR"} {
+		if dir := os.Getenv(override); dir != "" && !filepath.IsAbs(dir) {
+			return fmt.Errorf("%s must be an absolute path, got %q", override, dir)
+		}
+	}
+	return nil
+}
+
+// SharedDataDirs returns the kipod directories of $XDG_DATA_DIRS (default
+// /usr/local/share and /usr/share), where packages install read-only files,
+// in order of preference
+func SharedDataDirs() []string {
+	dirs := filepath.SplitList(os.Getenv("XDG_DATA_DIRS"))
+	if len(dirs) == 0 {
+		dirs = []string{"/usr/local/share", "/usr/share"}
+	}
+	var shared []string
+	for _, dir := range dirs {
+		if filepath.IsAbs(dir) {
+			shared = append(shared, filepath.Join(dir, appName))
+		}
+	}
+	return shared
+}
+
+// KubeDir returns ~/.kube, where kubectl looks for kubeconfigs
+func KubeDir() string {
+	return filepath.Join(HomeDir(), ".kube")
+}
+
+// resolve returns the override variable if set, else the kipod directory
+// below the XDG variable, else below fallback in the home directory. The
+// specification says relative XDG paths are invalid and must be ignored.
+func resolve(override, xdg, fallback string) string {
+	if dir := os.Getenv(override); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv(xdg); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName)
+	}
+	return filepath.Join(HomeDir(), fallback, appName)
+}
@@ -0,0 +1,121 @@
+package podman
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listCacheTTL is how long a listing of the kipod containers is reused. It
+// is short enough that state changed by other processes shows up quickly.
+const listCacheTTL = 2 * time.Second
+
+// listCache holds the last listing of all containers carrying LabelCluster.
+// Concurrent callers share a single podman ps; commands that change
+// containers through this package invalidate it.
+var listCache struct {
+	mu         sync.Mutex
+	containers []Container
+	fetched    time.Time
+}
+
+// invalidateList drops the cached container listing
+func invalidateList() {
+	listCache.mu.Lock()
+	defer listCache.mu.Unlock()
+	listCache.fetched = time.Time{}
+}
+
+// kipodContainers returns all containers carrying LabelCluster, listing
+// them at most once per listCacheTTL
+func kipodContainers() ([]Container, error) {
+	listCache.mu.Lock()
+	defer listCache.mu.Unlock()
+	if !listCache.fetched.IsZero() && time.Since(listCache.fetched) < listCacheTTL {
+		return listCache.containers, nil
+	}
+
+	output, err := Command("ps", "-a", "--filter", "label="+LabelCluster,
+		"--format", "{{.ID}}\t{{.Names}}\t{{.State}}\t{{json .Labels}}\t{{.Status}}\t{{.CreatedAt}}").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w\nOutput: %s", err, output)
+	}
+
+	var containers []Container
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) < 2 {
+			continue
+		}
+		container := Container{ID: parts[0], Name: parts[1], Labels: make(map[string]string)}
+		if len(parts) >= 3 {
+			container.State = parts[2]
+		}
+		if len(parts) >= 4 && parts[3] != "" {
+			// Labels that fail to parse leave the container unlabeled
+			_ = json.Unmarshal([]byte(parts[3]), &container.Labels)
+		}
+		if len(parts) >= 5 {
+			container.Health = statusHealth(parts[4])
+		}
+		if len(parts) >= 6 {
+			container.Created = parseCreatedAt(parts[5])
+		}
+		containers = append(containers, container)
+	}
+
+	listCache.containers = containers
+	listCache.fetched = time.Now()
+	return containers, nil
+}
+
+// parseCreatedAt parses the CreatedAt column of podman ps, a Go time such as
+// "2025-11-20 10:00:01.123456789 +0000 UTC", returning zero if it is not one
+func parseCreatedAt(value string) time.Time {
+	value, _, _ = strings.Cut(value, " m=")
+	created, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return created
+}
+
+// ContainerDetails are the attachments of a container
+type ContainerDetails struct {
+	ID string
+	// Networks are the names of the networks the container is attached to
+	Networks []string
+	// Volumes are the names of the named volumes it mounts
+	Volumes []string
+}
+
+// InspectContainers returns the details of several containers with a single
+// podman inspect. Their IDs are full IDs, even for short ids.
+func InspectContainers(ids []string) ([]ContainerDetails, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	format := `{{.Id}}` + "\t" +
+		`{{range $name, $net := .NetworkSettings.Networks}}{{$name}} {{end}}` + "\t" +
+		`{{range .Mounts}}{{if eq .Type "volume"}}{{.Name}} {{end}}{{end}}`
+	output, err := Command(append([]string{"container", "inspect", "--format", format}, ids...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect containers: %w", err)
+	}
+
+	var details []ContainerDetails
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			continue
+		}
+		details = append(details, ContainerDetails{
+			ID:       parts[0],
+			Networks: strings.Fields(parts[1]),
+			Volumes:  strings.Fields(parts[2]),
+		})
+	}
+	return details, nil
+}
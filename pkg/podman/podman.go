@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,13 +16,61 @@ const (
 	LabelCluster = "io.kipod.cluster"
 	// LabelRole is the label key for node role
 	LabelRole = "io.kipod.role"
+	// LabelPool is the label key for the node pool a node belongs to
+	LabelPool = "io.kipod.pool"
+	// LabelManaged marks networks and volumes created by kipod
+	LabelManaged = "io.kipod.managed"
+	// LabelVersion is the label schema version of everything kipod creates
+	LabelVersion = "io.kipod.version"
+	// LabelNodeName is the Kubernetes node name of a node container
+	LabelNodeName = "io.kipod.node-name"
+	// LabelUserPrefix prefixes the labels users give a cluster, e.g.
+	// io.kipod.label/team=payments
+	LabelUserPrefix = "io.kipod.label/"
+	// LabelOwner is the user who created the cluster of a node container
+	LabelOwner = "io.kipod.owner"
+	// LabelNetwork is the podman network a node container was created on
+	LabelNetwork = "io.kipod.network"
+
+	// SchemaVersion is the current label schema. Objects without
+	// LabelVersion predate it: their containers are named after their node.
+	SchemaVersion = "2"
+
+	// ContainerPrefix starts the names of the containers kipod creates
+	ContainerPrefix = "kipod-"
 )
 
 // Container represents a podman container
 type Container struct {
 	ID     string
 	Name   string
+	State  string // e.g. running, exited, created
 	Labels map[string]string
+	// Health is healthy, unhealthy or starting; empty without a healthcheck
+	Health string
+	// Created is when the container was created; zero if podman did not say
+	Created time.Time
+}
+
+// NodeName returns the Kubernetes node name of a node container, which is
+// the container name for containers created before LabelNodeName
+func (c Container) NodeName() string {
+	if name := c.Labels[LabelNodeName]; name != "" {
+		return name
+	}
+	return c.Name
+}
+
+// labelArgs returns the --label arguments of labels, with the current
+// LabelVersion added
+func labelArgs(labels map[string]string) []string {
+	args := []string{"--label", fmt.Sprintf("%s=%s", LabelVersion, SchemaVersion)}
+	for k, v := range labels {
+		if k != LabelVersion {
+			args = append(args, "--label", fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	return args
 }
 
 // CreateContainerOptions contains options for creating a container
@@ -36,27 +87,57 @@ type CreateContainerOptions struct {
 	SecurityOpts []string
 	Devices      []string
 	Sysctls      map[string]string
+	Ulimits      []string
 	Env          []string
 	Ports        []string // Port mappings in format "hostPort:containerPort"
 	Network      string
+	// NetworkAliases are extra DNS names of the container on Network
+	NetworkAliases []string
+	Systemd        string   // --systemd mode, defaults to "always"
+	Command        []string // Overrides the image command
+	Entrypoint     []string // Overrides the image entrypoint
+	NoStart        bool     // Create the container without starting it
+	// RestartPolicy is the --restart policy, e.g. "on-failure"
+	RestartPolicy string
+	// HealthCmd is a shell command podman runs to check the container
+	HealthCmd         string
+	HealthInterval    string
+	HealthStartPeriod string
+	// VolumeLabels are set on named volumes in Volumes that do not exist yet
+	VolumeLabels map[string]string
 }
 
 // CreateContainer creates a new podman container
 func CreateContainer(opts CreateContainerOptions) (string, error) {
-	args := []string{
-		"run", "-d",
-		"--name", opts.Name,
+	defer invalidateList()
+	args := []string{"run", "-d"}
+	if opts.NoStart {
+		args = []string{"create"}
 	}
+	args = append(args, "--name", opts.Name)
 
 	// Always use --privileged for node containers (required for kubelet)
 	// even in rootless podman mode
 	args = append(args, "--privileged")
 
 	// Enable systemd in container
-	args = append(args, "--systemd=always")
+	systemd := opts.Systemd
+	if systemd == "" {
+		systemd = "always"
+	}
+	args = append(args, "--systemd="+systemd)
 
-	// Increase file descriptor limit for CRI-O
-	args = append(args, "--ulimit", "nofile=65536:65536")
+	// Increase file descriptor limit for CRI-O unless explicitly configured
+	hasNofile := false
+	for _, ulimit := range opts.Ulimits {
+		if strings.HasPrefix(ulimit, "nofile=") {
+			hasNofile = true
+		}
+		args = append(args, "--ulimit", ulimit)
+	}
+	if !hasNofile {
+		args = append(args, "--ulimit", "nofile=65536:65536")
+	}
 
 	// Cgroup namespace mode
 	if opts.Cgroupns != "" {
@@ -85,9 +166,7 @@ func CreateContainer(opts CreateContainerOptions) (string, error) {
 	}
 
 	// Labels
-	for k, v := range opts.Labels {
-		args = append(args, "--label", fmt.Sprintf("%s=%s", k, v))
-	}
+	args = append(args, labelArgs(opts.Labels)...)
 
 	// Volumes (additional to those added in rootless mode)
 	for _, vol := range opts.Volumes {
@@ -95,6 +174,12 @@ func CreateContainer(opts CreateContainerOptions) (string, error) {
 		if opts.Rootless && strings.Contains(vol, "/sys/fs/cgroup") {
 			continue
 		}
+		// Named volumes are created up front so they carry labels
+		if source, _, _ := strings.Cut(vol, ":"); len(opts.VolumeLabels) > 0 && !strings.HasPrefix(source, "/") {
+			if err := CreateVolume(source, opts.VolumeLabels); err != nil {
+				return "", err
+			}
+		}
 		args = append(args, "-v", vol)
 	}
 
@@ -116,12 +201,38 @@ func CreateContainer(opts CreateContainerOptions) (string, error) {
 	// Network
 	if opts.Network != "" {
 		args = append(args, "--network", opts.Network)
+		for _, alias := range opts.NetworkAliases {
+			args = append(args, "--network-alias", alias)
+		}
+	}
+
+	if opts.RestartPolicy != "" {
+		args = append(args, "--restart", opts.RestartPolicy)
+	}
+	if opts.HealthCmd != "" {
+		args = append(args, "--health-cmd", opts.HealthCmd)
+		if opts.HealthInterval != "" {
+			args = append(args, "--health-interval", opts.HealthInterval)
+		}
+		if opts.HealthStartPeriod != "" {
+			args = append(args, "--health-start-period", opts.HealthStartPeriod)
+		}
+	}
+
+	if len(opts.Entrypoint) > 0 {
+		// A JSON array keeps arguments with spaces intact
+		entrypoint, err := json.Marshal(opts.Entrypoint)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode entrypoint: %w", err)
+		}
+		args = append(args, "--entrypoint", string(entrypoint))
 	}
 
 	// Image and command
 	args = append(args, opts.Image)
+	args = append(args, opts.Command...)
 
-	cmd := exec.Command("podman", args...)
+	cmd := Command(args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to create container: %w\nOutput: %s", err, output)
@@ -133,61 +244,131 @@ func CreateContainer(opts CreateContainerOptions) (string, error) {
 
 // DeleteContainer deletes a podman container
 func DeleteContainer(nameOrID string) error {
-	cmd := exec.Command("podman", "rm", "-f", nameOrID)
+	defer invalidateList()
+	closeExecSession(nameOrID)
+	cmd := Command("rm", "-f", nameOrID)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to delete container: %w\nOutput: %s", err, output)
 	}
 	return nil
 }
 
-// ListContainers lists containers with specific labels
-func ListContainers(labels map[string]string) ([]Container, error) {
-	args := []string{"ps", "-a", "--format", "{{.ID}}\t{{.Names}}\t{{json .Labels}}"}
+// StartContainer starts a stopped podman container
+func StartContainer(nameOrID string) error {
+	defer invalidateList()
+	closeExecSession(nameOrID)
+	cmd := Command("start", nameOrID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to start container: %w\nOutput: %s", err, output)
+	}
+	return nil
+}
 
-	for k, v := range labels {
-		args = append(args, "--filter", fmt.Sprintf("label=%s=%s", k, v))
+// StopContainer stops a running podman container
+func StopContainer(nameOrID string) error {
+	defer invalidateList()
+	closeExecSession(nameOrID)
+	cmd := Command("stop", nameOrID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to stop container: %w\nOutput: %s", err, output)
 	}
+	return nil
+}
 
-	cmd := exec.Command("podman", args...)
-	output, err := cmd.CombinedOutput()
+// KillContainer sends SIGKILL to the main process of a container
+func KillContainer(nameOrID string) error {
+	defer invalidateList()
+	closeExecSession(nameOrID)
+	cmd := Command("kill", nameOrID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to kill container: %w\nOutput: %s", err, output)
+	}
+	return nil
+}
+
+// PauseContainer freezes all processes of a container
+func PauseContainer(nameOrID string) error {
+	defer invalidateList()
+	closeExecSession(nameOrID)
+	cmd := Command("pause", nameOrID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to pause container: %w\nOutput: %s", err, output)
+	}
+	return nil
+}
+
+// UnpauseContainer resumes a container frozen by PauseContainer
+func UnpauseContainer(nameOrID string) error {
+	defer invalidateList()
+	cmd := Command("unpause", nameOrID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to unpause container: %w\nOutput: %s", err, output)
+	}
+	return nil
+}
+
+// ListContainers lists the kipod containers with specific labels; an empty
+// value matches any value of the label. Every label must belong to kipod
+// containers, e.g. LabelCluster, since they are filtered from a cached
+// listing of those.
+func ListContainers(labels map[string]string) ([]Container, error) {
+	all, err := kipodContainers()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list containers: %w\nOutput: %s", err, output)
+		return nil, err
 	}
 
 	var containers []Container
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Split(line, "\t")
-		if len(parts) >= 2 {
-			container := Container{
-				ID:     parts[0],
-				Name:   parts[1],
-				Labels: make(map[string]string),
-			}
-
-			if len(parts) >= 3 {
-				labelStr := parts[2]
-				if labelStr != "" {
-					if err := json.Unmarshal([]byte(labelStr), &container.Labels); err != nil {
-						// Ignore parsing errors, just log or skip
-						// fmt.Printf("Warning: failed to parse labels for container %s: %v\n", container.ID, err)
-					}
-				}
+	for _, container := range all {
+		matches := true
+		for k, v := range labels {
+			if value, ok := container.Labels[k]; !ok || (v != "" && value != v) {
+				matches = false
+				break
 			}
+		}
+		if matches {
 			containers = append(containers, container)
 		}
 	}
-
 	return containers, nil
 }
 
+// statusHealth extracts the healthcheck state from a podman ps status like
+// "Up 5 minutes (healthy)"
+func statusHealth(status string) string {
+	for _, health := range []string{"healthy", "unhealthy", "starting"} {
+		if strings.HasSuffix(status, "("+health+")") {
+			return health
+		}
+	}
+	return ""
+}
+
 // Exec executes a command in a container
 func Exec(containerID string, cmd []string) (string, error) {
 	args := append([]string{"exec", containerID}, cmd...)
-	execCmd := exec.Command("podman", args...)
+	if session := pooledSession(containerID); session != nil {
+		start := time.Now()
+		stdout, stderr, exitCode, ok, err := session.run(cmd)
+		session.mu.Unlock()
+		if ok {
+			status := fmt.Sprintf("exit=%d", exitCode)
+			if err != nil {
+				status = fmt.Sprintf("error=%q", err.Error())
+			} else if exitCode != 0 {
+				err = fmt.Errorf("exit status %d", exitCode)
+			}
+			// Traced as the podman exec it replaces
+			writeTrace(start, status+" session", append([]string{"podman"}, args...))
+			if err != nil {
+				return "", fmt.Errorf("failed to exec command: %w\nStderr: %s", err, stderr)
+			}
+			return stdout, nil
+		}
+		closeExecSession(containerID)
+	}
+
+	execCmd := Command(args...)
 
 	var stdout, stderr bytes.Buffer
 	execCmd.Stdout = &stdout
@@ -200,10 +381,24 @@ func Exec(containerID string, cmd []string) (string, error) {
 	return stdout.String(), nil
 }
 
+// ExecStream executes a command in a container, streaming its output to
+// stdout and stderr as it is produced
+func ExecStream(containerID string, cmd []string, stdout, stderr io.Writer) error {
+	args := append([]string{"exec", containerID}, cmd...)
+	execCmd := Command(args...)
+	execCmd.Stdout = stdout
+	execCmd.Stderr = stderr
+
+	if err := execCmd.Run(); err != nil {
+		return fmt.Errorf("failed to exec command: %w", err)
+	}
+	return nil
+}
+
 // ExecInteractive executes a command in a container interactively
 func ExecInteractive(containerID string, cmd []string) error {
 	args := append([]string{"exec", "-it", containerID}, cmd...)
-	execCmd := exec.Command("podman", args...)
+	execCmd := Command(args...)
 	execCmd.Stdin = nil
 	execCmd.Stdout = nil
 	execCmd.Stderr = nil
@@ -211,20 +406,9 @@ func ExecInteractive(containerID string, cmd []string) error {
 	return execCmd.Run()
 }
 
-// GetContainerIP returns the IP address of a container
-func GetContainerIP(containerID string) (string, error) {
-	cmd := exec.Command("podman", "inspect", "-f", "{{.NetworkSettings.IPAddress}}", containerID)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("failed to get container IP: %w\nOutput: %s", err, output)
-	}
-
-	return strings.TrimSpace(string(output)), nil
-}
-
 // NetworkExists checks if a network exists
 func NetworkExists(name string) (bool, error) {
-	cmd := exec.Command("podman", "network", "exists", name)
+	cmd := Command("network", "exists", name)
 	if err := cmd.Run(); err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
 			return false, nil
@@ -234,20 +418,202 @@ func NetworkExists(name string) (bool, error) {
 	return true, nil
 }
 
-// CreateNetwork creates a new podman network
-func CreateNetwork(name string) error {
-	cmd := exec.Command("podman", "network", "create", name)
+// CreateNetwork creates a new podman network with the given labels
+func CreateNetwork(name string, labels map[string]string) error {
+	args := append([]string{"network", "create"}, labelArgs(labels)...)
+	cmd := Command(append(args, name)...)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to create network: %w\nOutput: %s", err, output)
 	}
 	return nil
 }
 
+// DeleteNetwork deletes a podman network
+func DeleteNetwork(name string) error {
+	cmd := Command("network", "rm", name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete network: %w\nOutput: %s", err, output)
+	}
+	return nil
+}
+
+// ListNetworks lists the names of networks with specific labels
+func ListNetworks(labels map[string]string) ([]string, error) {
+	args := []string{"network", "ls", "--format", "{{.Name}}"}
+	for k, v := range labels {
+		args = append(args, "--filter", fmt.Sprintf("label=%s=%s", k, v))
+	}
+	output, err := Command(args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// NetworkContainers returns the IDs of all containers attached to a network
+func NetworkContainers(name string) ([]string, error) {
+	output, err := Command("ps", "-a", "--format", "{{.ID}}", "--filter", "network="+name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers on network %s: %w", name, err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// ConnectNetwork attaches a container to a network
+func ConnectNetwork(network, nameOrID string) error {
+	if output, err := Command("network", "connect", network, nameOrID).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to connect %s to network %s: %w\nOutput: %s", nameOrID, network, err, output)
+	}
+	return nil
+}
+
+// DisconnectNetwork detaches a container from a network
+func DisconnectNetwork(network, nameOrID string) error {
+	if output, err := Command("network", "disconnect", network, nameOrID).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to disconnect %s from network %s: %w\nOutput: %s", nameOrID, network, err, output)
+	}
+	return nil
+}
+
+// ContainerNetworkIP returns the IP address of a container on a network
+func ContainerNetworkIP(nameOrID, network string) (string, error) {
+	cmd := Command("container", "inspect", "--format",
+		fmt.Sprintf(`{{with index .NetworkSettings.Networks %q}}{{.IPAddress}}{{end}}`, network), nameOrID)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get container IP on network %s: %w\nOutput: %s", network, err, output)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CreateVolume creates a named volume with the given labels, unless it
+// already exists
+func CreateVolume(name string, labels map[string]string) error {
+	args := append([]string{"volume", "create", "--ignore"}, labelArgs(labels)...)
+	cmd := Command(append(args, name)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create volume %s: %w\nOutput: %s", name, err, output)
+	}
+	return nil
+}
+
+// Volume is a podman volume
+type Volume struct {
+	Name       string
+	Mountpoint string
+	Labels     map[string]string
+}
+
+// ListVolumes lists volumes with specific labels; dangling limits the
+// result to volumes no container uses
+func ListVolumes(labels map[string]string, dangling bool) ([]Volume, error) {
+	args := []string{"volume", "ls", "--format", "{{.Name}}\t{{.Mountpoint}}\t{{json .Labels}}"}
+	for k, v := range labels {
+		args = append(args, "--filter", fmt.Sprintf("label=%s=%s", k, v))
+	}
+	if dangling {
+		args = append(args, "--filter", "dangling=true")
+	}
+	output, err := Command(args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	var volumes []Volume
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) < 2 {
+			continue
+		}
+		volume := Volume{Name: parts[0], Mountpoint: parts[1], Labels: make(map[string]string)}
+		if len(parts) >= 3 {
+			_ = json.Unmarshal([]byte(parts[2]), &volume.Labels)
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// VolumeSize returns the disk usage of a volume in bytes. The files belong to
+// subordinate IDs, so they are measured inside the rootless user namespace.
+func VolumeSize(volume Volume) (uint64, error) {
+	output, err := Command("unshare", "du", "-sb", volume.Mountpoint).Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure volume %s: %w", volume.Name, err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("failed to measure volume %s: no output", volume.Name)
+	}
+	return strconv.ParseUint(fields[0], 10, 64)
+}
+
 // DeleteVolume deletes a podman volume
 func DeleteVolume(name string) error {
-	cmd := exec.Command("podman", "volume", "rm", "-f", name)
+	cmd := Command("volume", "rm", "-f", name)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to delete volume: %w\nOutput: %s", err, output)
 	}
 	return nil
 }
+
+// CopyToContainer copies a file or directory from the host into a container
+func CopyToContainer(containerID, src, dest string) error {
+	cmd := Command("cp", src, fmt.Sprintf("%s:%s", containerID, dest))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy %s into container: %w\nOutput: %s", src, err, output)
+	}
+	return nil
+}
+
+// CopyFromContainer copies a file or directory from a container to the host
+func CopyFromContainer(containerID, src, dest string) error {
+	cmd := Command("cp", fmt.Sprintf("%s:%s", containerID, src), dest)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to copy %s from container: %w\nOutput: %s", src, err, output)
+	}
+	return nil
+}
+
+// VolumeExists checks if a podman volume exists
+func VolumeExists(name string) (bool, error) {
+	cmd := Command("volume", "exists", name)
+	if err := cmd.Run(); err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check volume existence: %w", err)
+	}
+	return true, nil
+}
+
+// CloneVolume creates volume dest with the given labels and a copy of the
+// contents of volume src
+func CloneVolume(src, dest string, labels map[string]string) error {
+	if err := CreateVolume(dest, labels); err != nil {
+		return err
+	}
+
+	export := Command("volume", "export", src)
+	imp := Command("volume", "import", dest, "-")
+	pipe, err := export.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to export volume %s: %w", src, err)
+	}
+	imp.Stdin = pipe
+
+	var exportErr, importErr bytes.Buffer
+	export.Stderr = &exportErr
+	imp.Stderr = &importErr
+	if err := export.Start(); err != nil {
+		return fmt.Errorf("failed to export volume %s: %w", src, err)
+	}
+	if err := imp.Run(); err != nil {
+		export.Wait()
+		return fmt.Errorf("failed to import volume %s: %w\nStderr: %s", dest, err, importErr.String())
+	}
+	if err := export.Wait(); err != nil {
+		return fmt.Errorf("failed to export volume %s: %w\nStderr: %s", src, err, exportErr.String())
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package podman
+
+import "regexp"
+
+// kubeadmSecrets match the bootstrap tokens and certificate keys kubeadm
+// prints and takes as flags or in its config, which let anyone join the
+// cluster
+var kubeadmSecrets = []*regexp.Regexp{
+	regexp.MustCompile(`(--(?:token|certificate-key)[= ]+)\S+`),
+	regexp.MustCompile(`(Using certificate key:\s*)[0-9a-fA-F]+`),
+	regexp.MustCompile(`(certificateKey:\s*)\S+`),
+	regexp.MustCompile(`()\b[a-z0-9]{6}\.[a-z0-9]{16}\b`),
+}
+
+// RedactSecrets replaces the bootstrap tokens and certificate keys in kubeadm
+// output or in the arguments of a command, e.g. a join config passed to sh -c
+func RedactSecrets(s string) string {
+	for _, re := range kubeadmSecrets {
+		s = re.ReplaceAllString(s, "${1}<redacted>")
+	}
+	return s
+}
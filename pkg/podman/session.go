@@ -0,0 +1,194 @@
+package podman
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// execSessions are the long-lived shells Exec sends commands through when
+// pooling is enabled, by container name or ID. Forking podman exec costs
+// far more than the commands kipod runs during provisioning.
+var execSessions struct {
+	mu       sync.Mutex
+	enabled  bool
+	sessions map[string]*execSession
+}
+
+// EnableExecPool makes Exec run commands through one persistent shell per
+// container instead of a podman exec each. A command finding the shell of
+// its container busy gets its own podman exec, so concurrent callers are
+// not serialized. CloseExecSessions ends the shells.
+func EnableExecPool() {
+	execSessions.mu.Lock()
+	defer execSessions.mu.Unlock()
+	execSessions.enabled = true
+	if execSessions.sessions == nil {
+		execSessions.sessions = make(map[string]*execSession)
+	}
+}
+
+// CloseExecSessions ends all persistent shells and disables pooling
+func CloseExecSessions() {
+	execSessions.mu.Lock()
+	sessions := execSessions.sessions
+	execSessions.sessions = nil
+	execSessions.enabled = false
+	execSessions.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close()
+	}
+}
+
+// closeExecSession ends the shell of a container, e.g. before it stops
+func closeExecSession(nameOrID string) {
+	execSessions.mu.Lock()
+	session := execSessions.sessions[nameOrID]
+	delete(execSessions.sessions, nameOrID)
+	execSessions.mu.Unlock()
+
+	if session != nil {
+		session.close()
+	}
+}
+
+// pooledSession returns the idle shell of a container, opening one if
+// needed, or nil when pooling is disabled or the shell is busy. The caller
+// must unlock the returned session.
+func pooledSession(containerID string) *execSession {
+	execSessions.mu.Lock()
+	defer execSessions.mu.Unlock()
+	if !execSessions.enabled {
+		return nil
+	}
+
+	session := execSessions.sessions[containerID]
+	if session == nil {
+		var err error
+		if session, err = openExecSession(containerID); err != nil {
+			// Plain podman exec reports the error of the command
+			return nil
+		}
+		execSessions.sessions[containerID] = session
+	}
+	if !session.mu.TryLock() {
+		return nil
+	}
+	return session
+}
+
+// execSession is a shell in a container reading commands from stdin. Each
+// command is followed by a marker on stdout, with its exit code, and on
+// stderr, so the output of every command can be told apart.
+type execSession struct {
+	mu     sync.Mutex
+	cmd    *Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bufio.Reader
+	marker string
+	broken bool
+}
+
+func openExecSession(containerID string) (*execSession, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	cmd := Command("exec", "-i", containerID, "sh")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &execSession{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		stderr: bufio.NewReader(stderr),
+		marker: "KIPOD_EXEC_" + hex.EncodeToString(nonce),
+	}, nil
+}
+
+// run runs a command in a subshell, so it cannot change the state of the
+// session, with stdin from /dev/null. ok is false when the session failed
+// before the command started, in which case it should run elsewhere.
+func (s *execSession) run(cmd []string) (stdout, stderr string, exitCode int, ok bool, err error) {
+	if s.broken {
+		return "", "", 0, false, nil
+	}
+	script := fmt.Sprintf("(%s) </dev/null; printf '\\n%s %%d\\n' $?; printf '\\n%s\\n' >&2\n", shellJoin(cmd), s.marker, s.marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		s.broken = true
+		return "", "", 0, false, nil
+	}
+
+	var errOut []byte
+	var errErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		errOut, _, errErr = readUntilMarker(s.stderr, s.marker)
+	}()
+	out, status, outErr := readUntilMarker(s.stdout, s.marker)
+	<-done
+	if outErr != nil || errErr != nil {
+		s.broken = true
+		return string(out), string(errOut), 0, true, fmt.Errorf("exec session in container ended")
+	}
+
+	exitCode, _ = strconv.Atoi(status)
+	return string(out), string(errOut), exitCode, true, nil
+}
+
+// readUntilMarker reads up to the marker line the session writes after
+// each command, returning what came before it and the rest of the line
+func readUntilMarker(r *bufio.Reader, marker string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			buf.Write(line)
+			return buf.Bytes(), "", err
+		}
+		if rest, found := bytes.CutPrefix(line, []byte(marker)); found {
+			// Drop the newline printed before the marker
+			return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), string(bytes.TrimSpace(rest)), nil
+		}
+		buf.Write(line)
+	}
+}
+
+func (s *execSession) close() {
+	s.stdin.Close()
+	done := make(chan struct{})
+	go func() {
+		s.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		if s.cmd.Process != nil {
+			s.cmd.Process.Kill()
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package podman
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/tracing"
+)
+
+var (
+	traceMu sync.Mutex
+	traceW  io.Writer
+)
+
+// EnableTrace logs every podman invocation to w as a replayable shell line,
+// preceded by a comment with its start time, duration and exit status.
+// Bootstrap tokens and certificate keys are redacted.
+func EnableTrace(w io.Writer) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	traceW = w
+}
+
+// Cmd is a podman command that is traced when tracing is enabled
+type Cmd struct {
+	*exec.Cmd
+	start time.Time
+}
+
+// Command returns a podman command with the given arguments. All podman
+// invocations go through it so they can be traced.
+func Command(args ...string) *Cmd {
+	return &Cmd{Cmd: exec.Command("podman", args...)}
+}
+
+// Run starts the command and waits for it to complete
+func (c *Cmd) Run() error {
+	c.start = time.Now()
+	err := c.Cmd.Run()
+	c.trace(err)
+	return err
+}
+
+// Output runs the command and returns its stdout
+func (c *Cmd) Output() ([]byte, error) {
+	c.start = time.Now()
+	output, err := c.Cmd.Output()
+	c.trace(err)
+	return output, err
+}
+
+// CombinedOutput runs the command and returns its combined stdout and stderr
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	c.start = time.Now()
+	output, err := c.Cmd.CombinedOutput()
+	c.trace(err)
+	return output, err
+}
+
+// Start starts the command; Wait traces it once it completes
+func (c *Cmd) Start() error {
+	c.start = time.Now()
+	err := c.Cmd.Start()
+	if err != nil {
+		c.trace(err)
+	}
+	return err
+}
+
+// Wait waits for a command started with Start
+func (c *Cmd) Wait() error {
+	err := c.Cmd.Wait()
+	c.trace(err)
+	return err
+}
+
+// trace writes the finished command to the trace log
+func (c *Cmd) trace(err error) {
+	status := "exit=0"
+	if exitError, ok := err.(*exec.ExitError); ok {
+		status = fmt.Sprintf("exit=%d", exitError.ExitCode())
+	} else if err != nil {
+		status = fmt.Sprintf("error=%q", err.Error())
+	}
+	writeTrace(c.start, status, c.Args)
+}
+
+// writeTrace writes a finished command to the trace log, if enabled, and
+// records it as a span of the current phase when spans are recorded
+func writeTrace(start time.Time, status string, args []string) {
+	recordSpan(start, status, args)
+
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	if traceW == nil {
+		return
+	}
+
+	fmt.Fprintf(traceW, "# %s %.3fs %s\n", start.Format(time.RFC3339Nano), time.Since(start).Seconds(), status)
+	fmt.Fprintln(traceW, RedactSecrets(shellJoin(args)))
+}
+
+// recordSpan records a finished command as a client span named after its
+// podman subcommand, failed unless it exited with 0. The rest of the command
+// line is left out: spans leave the host and arguments can carry secrets.
+func recordSpan(start time.Time, status string, args []string) {
+	if !tracing.Enabled() {
+		return
+	}
+	name := "podman"
+	attrs := map[string]interface{}{
+		"process.executable.name": "podman",
+		"kipod.podman.status":     status,
+	}
+	if len(args) > 1 {
+		name += " " + args[1]
+		attrs["kipod.podman.subcommand"] = args[1]
+	}
+	var err error
+	if fields := strings.Fields(status); len(fields) == 0 || fields[0] != "exit=0" {
+		err = fmt.Errorf("podman %s", status)
+	}
+	tracing.Record(name, tracing.KindClient, start, attrs, err)
+}
+
+// shellJoin quotes and joins args into a shell command line
+func shellJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes s for a POSIX shell when it contains special characters
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,@%+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
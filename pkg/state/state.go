@@ -0,0 +1,89 @@
+// Package state persists the effective configuration of created clusters so
+// they can be inspected and reproduced later
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/config"
+	"github.com/sohankunkerkar/kipod/pkg/paths"
+)
+
+// configFileName is the name of the saved effective config in a cluster's state dir
+const configFileName = "config.yaml"
+
+// ownerFileName is the name of the file recording who created a cluster
+const ownerFileName = "owner"
+
+// Dir returns the directory holding per-cluster state, overridable with
+// KIPOD_STATE_DIR. Hosts that recorded clusters in ~/.kipod/clusters before
+// kipod followed XDG keep using it until it is moved to the data dir.
+func Dir() string {
+	if dir := os.Getenv("KIPOD_STATE_DIR"); dir != "" {
+		return dir
+	}
+	dir := filepath.Join(paths.DataDir(), "clusters")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		legacy := filepath.Join(paths.HomeDir(), ".kipod", "clusters")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+	}
+	return dir
+}
+
+// ClusterDir returns the state directory of a single cluster
+func ClusterDir(name string) string {
+	return filepath.Join(Dir(), name)
+}
+
+// Save records the effective configuration of a cluster
+func Save(cfg *config.ClusterConfig) error {
+	dir := ClusterDir(cfg.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return config.SaveToFile(cfg, filepath.Join(dir, configFileName))
+}
+
+// Load returns the effective configuration recorded for a cluster
+func Load(name string) (*config.ClusterConfig, error) {
+	path := filepath.Join(ClusterDir(name), configFileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no saved state for cluster '%s' (was it created with this version of kipod?)", name)
+	}
+	return config.LoadFromFile(path)
+}
+
+// SaveOwner records the user who created a cluster
+func SaveOwner(name, owner string) error {
+	dir := ClusterDir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ownerFileName), []byte(owner+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to record cluster owner: %w", err)
+	}
+	return nil
+}
+
+// LoadOwner returns the user recorded as the creator of a cluster, or "" if
+// none was recorded
+func LoadOwner(name string) string {
+	data, err := os.ReadFile(filepath.Join(ClusterDir(name), ownerFileName))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Delete removes all state recorded for a cluster
+func Delete(name string) error {
+	if err := os.RemoveAll(ClusterDir(name)); err != nil {
+		return fmt.Errorf("failed to remove state for cluster '%s': %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package style
+
+import "os"
+
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// colorEnabled is true when stdout is a terminal and NO_COLOR is not set
+var colorEnabled = func() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}()
+
+func colorize(color, s string) string {
+	if !colorEnabled {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// Red colors text red
+func Red(s string) string {
+	return colorize(colorRed, s)
+}
+
+// Green colors text green
+func Green(s string) string {
+	return colorize(colorGreen, s)
+}
+
+// Yellow colors text yellow
+func Yellow(s string) string {
+	return colorize(colorYellow, s)
+}
+
+// Bold makes text bold
+func Bold(s string) string {
+	return colorize(colorBold, s)
+}
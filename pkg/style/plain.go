@@ -0,0 +1,33 @@
+package style
+
+import (
+	"strings"
+	"unicode"
+)
+
+// plain disables emoji and colors for logs read by machines or CI systems
+var plain bool
+
+// SetPlain switches to plain progress output without emoji or colors
+func SetPlain(enabled bool) {
+	plain = enabled
+	if enabled {
+		colorEnabled = false
+	}
+}
+
+// IsPlain reports whether plain progress output is enabled
+func IsPlain() bool {
+	return plain
+}
+
+// stripEmoji removes pictographs, variation selectors and joiners from s
+func stripEmoji(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\u200d' || r == '\ufe0e' || r == '\ufe0f' || unicode.Is(unicode.So, r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimRight(s, " ")
+}
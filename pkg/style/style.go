@@ -1,23 +1,64 @@
 package style
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/events"
+)
+
+// out is where progress messages are written
+var out io.Writer = os.Stdout
+
+// SetOutput redirects progress messages, e.g. to stderr when stdout carries
+// machine-readable output
+func SetOutput(w io.Writer) {
+	out = w
+}
 
 // Step prints a step with a checkmark
 func Step(format string, a ...interface{}) {
-	fmt.Printf(" ✓ "+format+"\n", a...)
+	printLine(events.Step, " ✓ ", format, "", a...)
 }
 
 // Info prints an informational message with a bullet point
 func Info(format string, a ...interface{}) {
-	fmt.Printf(" • "+format+"\n", a...)
+	printLine(events.Info, " • ", format, "", a...)
 }
 
 // Success prints a success message with a bullet point and a heart
 func Success(format string, a ...interface{}) {
-	fmt.Printf(" • "+format+" 💚\n", a...)
+	printLine(events.Success, " • ", format, " 💚", a...)
 }
 
 // Header prints a header message without a prefix
 func Header(format string, a ...interface{}) {
-	fmt.Printf(format+"\n", a...)
+	printLine(events.Info, "", format, "", a...)
+}
+
+// printLine writes a formatted line, replacing the decorations in plain mode.
+// When JSON progress events are enabled the line becomes an event instead.
+func printLine(kind, prefix, format, suffix string, a ...interface{}) {
+	if events.Enabled() {
+		msg := strings.TrimSpace(stripEmoji(fmt.Sprintf(format, a...)))
+		if msg == "" {
+			return
+		}
+		if strings.HasPrefix(msg, "Warning: ") {
+			kind = events.Warning
+			msg = strings.TrimPrefix(msg, "Warning: ")
+		}
+		events.Emit(events.Event{Type: kind, Message: msg})
+		return
+	}
+	if !plain {
+		fmt.Fprintf(out, prefix+format+suffix+"\n", a...)
+		return
+	}
+	if prefix != "" {
+		prefix = " - "
+	}
+	fmt.Fprintf(out, "%s%s\n", prefix, stripEmoji(fmt.Sprintf(format, a...)))
 }
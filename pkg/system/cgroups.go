@@ -0,0 +1,124 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CgroupMode is how the host mounts the cgroup hierarchy
+type CgroupMode string
+
+const (
+	// CgroupUnified is cgroup v2 only, at /sys/fs/cgroup
+	CgroupUnified CgroupMode = "unified"
+	// CgroupHybrid is cgroup v1 controllers at /sys/fs/cgroup, with an
+	// empty cgroup v2 hierarchy at /sys/fs/cgroup/unified for systemd
+	CgroupHybrid CgroupMode = "hybrid"
+	// CgroupLegacy is cgroup v1 only
+	CgroupLegacy CgroupMode = "legacy"
+)
+
+// cgroupV2Remedy tells how to boot a systemd host with cgroup v2 only
+const cgroupV2Remedy = "kipod needs cgroup v2 only (the unified hierarchy): node containers run systemd, CRI-O and the kubelet with a delegated cgroup v2 subtree, which cgroup v1 cannot give rootless podman. Boot with systemd.unified_cgroup_hierarchy=1, e.g. sudo grubby --update-kernel=ALL --args=systemd.unified_cgroup_hierarchy=1 (or add it to GRUB_CMDLINE_LINUX and regenerate the grub config), remove any systemd.legacy_systemd_cgroup_controller argument, and reboot"
+
+// HostCgroupMode returns how the host mounts cgroups, from /proc/mounts
+func HostCgroupMode() (CgroupMode, error) {
+	data, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		return "", fmt.Errorf("could not read /proc/mounts: %w", err)
+	}
+	return cgroupMode(string(data)), nil
+}
+
+// cgroupMode classifies the cgroup mounts of a /proc/mounts table
+func cgroupMode(mounts string) CgroupMode {
+	var unified, v1 bool
+	for _, line := range strings.Split(mounts, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		switch {
+		case fields[2] == "cgroup2" && fields[1] == "/sys/fs/cgroup":
+			unified = true
+		case fields[2] == "cgroup":
+			v1 = true
+		}
+	}
+	switch {
+	case unified:
+		return CgroupUnified
+	case v1 && strings.Contains(mounts, " /sys/fs/cgroup/unified cgroup2 "):
+		return CgroupHybrid
+	default:
+		return CgroupLegacy
+	}
+}
+
+// RequireCgroupV2 returns an error explaining how to switch a Linux host that
+// is not in the cgroup v2 unified mode, before any node is created; nodes
+// on such hosts otherwise fail deep inside provisioning. Other hosts run
+// podman in a VM, which kipod check covers.
+func RequireCgroupV2() error {
+	if HostOS() != "linux" {
+		return nil
+	}
+	mode, err := HostCgroupMode()
+	if err != nil || mode == CgroupUnified {
+		return nil
+	}
+	return fmt.Errorf("this host runs cgroups in %s mode (%s). %s", mode, describeCgroupMode(mode), cgroupV2Remedy)
+}
+
+// describeCgroupMode explains a cgroup mode other than unified
+func describeCgroupMode(mode CgroupMode) string {
+	if mode == CgroupHybrid {
+		return "cgroup v1 controllers, with cgroup v2 only at /sys/fs/cgroup/unified for systemd"
+	}
+	return "cgroup v1 only"
+}
+
+func checkCgroupV2() ValidationResult {
+	data, err := os.ReadFile("/proc/filesystems")
+	if err != nil {
+		return ValidationResult{
+			Name:    "Cgroup v2",
+			Passed:  false,
+			Message: "Could not read /proc/filesystems",
+			Fatal:   false,
+		}
+	}
+	if !strings.Contains(string(data), "cgroup2") {
+		return ValidationResult{
+			Name:    "Cgroup v2",
+			Passed:  false,
+			Message: "Cgroup v2 not available in kernel; kipod needs a kernel with cgroup v2 (4.15 or later, 5.2 or later to stop and pause nodes)",
+			Fatal:   true,
+		}
+	}
+
+	mode, err := HostCgroupMode()
+	if err != nil {
+		return ValidationResult{
+			Name:    "Cgroup v2",
+			Passed:  false,
+			Message: "Could not read /proc/mounts",
+			Fatal:   false,
+		}
+	}
+	if mode != CgroupUnified {
+		return ValidationResult{
+			Name:    "Cgroup v2",
+			Passed:  false,
+			Message: fmt.Sprintf("Cgroups run in %s mode (%s). %s", mode, describeCgroupMode(mode), cgroupV2Remedy),
+			Fatal:   true,
+		}
+	}
+	return ValidationResult{
+		Name:    "Cgroup v2",
+		Passed:  true,
+		Message: "Cgroup v2 is mounted at /sys/fs/cgroup (unified mode)",
+		Fatal:   false,
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package system
+
+import "syscall"
+
+// freeSpace returns the bytes available to unprivileged users under path
+func freeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,8 @@
+package system
+
+import "fmt"
+
+// freeSpace is not implemented on Windows, where kipod cannot run nodes
+func freeSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("free space of %s cannot be determined on Windows", path)
+}
@@ -0,0 +1,56 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// hostsFile is the static host name table of the host
+const hostsFile = "/etc/hosts"
+
+// hostsEntry returns the /etc/hosts line kipod manages for name. The comment
+// marks it so removal leaves entries added by hand alone.
+func hostsEntry(name string) string {
+	return fmt.Sprintf("127.0.0.1 %s # kipod", name)
+}
+
+// HasHostsEntry reports whether /etc/hosts maps name to the loopback
+// address with an entry added by AddHostsEntry
+func HasHostsEntry(name string) bool {
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == hostsEntry(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddHostsEntry maps name to the loopback address in /etc/hosts, using sudo
+// unless kipod runs as root. name must be a valid host name.
+func AddHostsEntry(name string) error {
+	if HasHostsEntry(name) {
+		return nil
+	}
+	script := fmt.Sprintf("echo '%s' >> %s", hostsEntry(name), hostsFile)
+	if err := runPrivileged("sh", "-c", script); err != nil {
+		return fmt.Errorf("failed to add %s to %s: %w", name, hostsFile, err)
+	}
+	return nil
+}
+
+// RemoveHostsEntry removes the entry AddHostsEntry added for name
+func RemoveHostsEntry(name string) error {
+	if !HasHostsEntry(name) {
+		return nil
+	}
+	pattern := strings.ReplaceAll(hostsEntry(name), ".", `\.`)
+	if err := runPrivileged("sed", "-i", fmt.Sprintf(`/^[[:space:]]*%s[[:space:]]*$/d`, pattern), hostsFile); err != nil {
+		return fmt.Errorf("failed to remove %s from %s: %w", name, hostsFile, err)
+	}
+	return nil
+}
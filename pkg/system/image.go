@@ -0,0 +1,204 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sohankunkerkar/kipod/pkg/build"
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// ValidateNodeImage boots a throwaway container from a node image and checks
+// that systemd, CRI-O, the Kubernetes binaries and cgroups work on this host
+func ValidateNodeImage(image string) ([]ValidationResult, error) {
+	exists, err := build.ImageExists(image)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return inCategory("Node Image", ValidationResult{
+			Name:    "Node Image",
+			Passed:  false,
+			Message: fmt.Sprintf("Image %s not found. Build it with: kipod build node-image", image),
+			Fatal:   true,
+		}), nil
+	}
+
+	labels, err := build.GetImageLabels(image)
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("kipod-check-%d", time.Now().Unix())
+	containerID, err := podman.CreateContainer(podman.CreateContainerOptions{
+		Name:     name,
+		Image:    image,
+		Hostname: name,
+		Rootless: true,
+		Cgroupns: "private",
+		Tmpfs:    []string{"/var/lib/containers/storage:rw,size=1G"},
+		Env:      []string{"KIPOD_CGROUP_MANAGER=cgroupfs"},
+	})
+	if err != nil {
+		return inCategory("Node Image", ValidationResult{
+			Name:    "Node Container",
+			Passed:  false,
+			Message: fmt.Sprintf("Failed to start a container from %s: %v", image, err),
+			Fatal:   true,
+		}), nil
+	}
+	defer podman.DeleteContainer(containerID)
+
+	results := []ValidationResult{{
+		Name:    "Node Container",
+		Passed:  true,
+		Message: fmt.Sprintf("Started %s from %s", name, image),
+		Fatal:   false,
+	}}
+
+	systemd := checkImageSystemd(containerID)
+	results = append(results, systemd)
+	if !systemd.Passed {
+		// Nothing else can run without systemd
+		return inCategory("Node Image", results...), nil
+	}
+
+	results = append(results,
+		checkImageCRIO(containerID),
+		checkImageBinaryVersion(containerID, "kubelet", []string{"kubelet", "--version"}, labels[build.LabelKubernetesVersion]),
+		checkImageBinaryVersion(containerID, "kubeadm", []string{"kubeadm", "version", "-o", "short"}, labels[build.LabelKubernetesVersion]),
+		checkImageCgroups(containerID),
+	)
+
+	return inCategory("Node Image", results...), nil
+}
+
+func checkImageSystemd(containerID string) ValidationResult {
+	var status string
+	for i := 0; i < 30; i++ {
+		output, _ := podman.Exec(containerID, []string{"systemctl", "is-system-running"})
+		status = strings.TrimSpace(output)
+		if status == "running" || status == "degraded" {
+			return ValidationResult{
+				Name:    "Systemd Boot",
+				Passed:  true,
+				Message: fmt.Sprintf("systemd is %s", status),
+				Fatal:   false,
+			}
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	logs, _ := podman.Exec(containerID, []string{"journalctl", "-b", "-p", "err", "-n", "20", "--no-pager"})
+	return ValidationResult{
+		Name:    "Systemd Boot",
+		Passed:  false,
+		Message: fmt.Sprintf("systemd did not finish booting (state: %q). Recent errors:\n%s", status, strings.TrimSpace(logs)),
+		Fatal:   true,
+	}
+}
+
+func checkImageCRIO(containerID string) ValidationResult {
+	for i := 0; i < 30; i++ {
+		if _, err := podman.Exec(containerID, []string{"crictl", "info"}); err == nil {
+			return ValidationResult{
+				Name:    "CRI-O",
+				Passed:  true,
+				Message: "CRI-O started and answers CRI requests",
+				Fatal:   false,
+			}
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	logs, _ := podman.Exec(containerID, []string{"journalctl", "-u", "crio", "-n", "20", "--no-pager"})
+	return ValidationResult{
+		Name:    "CRI-O",
+		Passed:  false,
+		Message: fmt.Sprintf("CRI-O did not become ready. Logs:\n%s", strings.TrimSpace(logs)),
+		Fatal:   true,
+	}
+}
+
+func checkImageBinaryVersion(containerID, binary string, cmd []string, expected string) ValidationResult {
+	name := fmt.Sprintf("%s Version", binary)
+	output, err := podman.Exec(containerID, cmd)
+	if err != nil {
+		return ValidationResult{
+			Name:    name,
+			Passed:  false,
+			Message: fmt.Sprintf("Could not run %s: %v", binary, err),
+			Fatal:   true,
+		}
+	}
+
+	actual := parseVersion(strings.TrimPrefix(strings.TrimSpace(output), "Kubernetes "))
+	if expected == "" {
+		return ValidationResult{
+			Name:    name,
+			Passed:  true,
+			Message: fmt.Sprintf("%s (image has no %s label to compare)", actual, build.LabelKubernetesVersion),
+			Fatal:   false,
+		}
+	}
+
+	if actual != parseVersion(expected) {
+		return ValidationResult{
+			Name:    name,
+			Passed:  false,
+			Message: fmt.Sprintf("%s is %s but the image is labeled %s", binary, actual, expected),
+			Fatal:   true,
+		}
+	}
+
+	return ValidationResult{
+		Name:    name,
+		Passed:  true,
+		Message: fmt.Sprintf("%s matches image label", actual),
+		Fatal:   false,
+	}
+}
+
+func checkImageCgroups(containerID string) ValidationResult {
+	output, err := podman.Exec(containerID, []string{"cat", "/sys/fs/cgroup/cgroup.controllers"})
+	if err != nil {
+		return ValidationResult{
+			Name:    "Node Cgroups",
+			Passed:  false,
+			Message: "cgroup v2 is not available inside the node container",
+			Fatal:   true,
+		}
+	}
+
+	var missing []string
+	controllers := strings.Fields(output)
+	for _, required := range []string{"cpu", "memory", "pids"} {
+		found := false
+		for _, c := range controllers {
+			if c == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, required)
+		}
+	}
+
+	if len(missing) > 0 {
+		return ValidationResult{
+			Name:    "Node Cgroups",
+			Passed:  false,
+			Message: fmt.Sprintf("Controllers missing inside node: %s (check host cgroup delegation)", strings.Join(missing, ", ")),
+			Fatal:   true,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Node Cgroups",
+		Passed:  true,
+		Message: fmt.Sprintf("Controllers available inside node: %s", strings.Join(controllers, " ")),
+		Fatal:   false,
+	}
+}
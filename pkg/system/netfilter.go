@@ -0,0 +1,177 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// requiredModules must be loaded on the host: rootless node containers cannot load them
+var requiredModules = []string{"overlay", "br_netfilter"}
+
+func checkKernelModules() ValidationResult {
+	var missing, loadable []string
+	for _, module := range requiredModules {
+		switch moduleState(module) {
+		case "loaded":
+		case "available":
+			loadable = append(loadable, module)
+		default:
+			missing = append(missing, module)
+		}
+	}
+
+	if len(missing) > 0 {
+		return ValidationResult{
+			Name:    "Kernel Modules",
+			Passed:  false,
+			Message: fmt.Sprintf("Modules not available in this kernel: %s", strings.Join(missing, ", ")),
+			Fatal:   true,
+		}
+	}
+
+	if len(loadable) > 0 {
+		return ValidationResult{
+			Name:   "Kernel Modules",
+			Passed: false,
+			Message: fmt.Sprintf("Modules not loaded: %s. Load them with: sudo modprobe %s (persist via /etc/modules-load.d/kipod.conf)",
+				strings.Join(loadable, ", "), strings.Join(loadable, " ")),
+			Fatal: false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Kernel Modules",
+		Passed:  true,
+		Message: fmt.Sprintf("Loaded: %s", strings.Join(requiredModules, ", ")),
+		Fatal:   false,
+	}
+}
+
+// moduleState returns "loaded", "available" (loadable or built in but unused) or "missing"
+func moduleState(module string) string {
+	if _, err := os.Stat(filepath.Join("/sys/module", module)); err == nil {
+		return "loaded"
+	}
+
+	release := kernelRelease()
+	if release == "" {
+		return "missing"
+	}
+	if data, err := os.ReadFile(filepath.Join("/lib/modules", release, "modules.builtin")); err == nil {
+		if strings.Contains(string(data), "/"+module+".ko") {
+			return "loaded"
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join("/lib/modules", release, "modules.dep")); err == nil {
+		if strings.Contains(string(data), "/"+module+".ko") {
+			return "available"
+		}
+	}
+	return "missing"
+}
+
+// HostIptablesBackend returns the iptables backend of the host, "nft" or
+// "legacy", which nodes use too: kube-proxy and the CNI plugins pick their
+// backend from the rules the kubelet writes with the node's iptables, and
+// rules of both backends in one kernel make service routing fail silently.
+// Hosts without iptables get "nft" unless legacy tables are loaded. It
+// returns "" off Linux, where podman runs in a VM.
+func HostIptablesBackend() string {
+	if HostOS() != "linux" && HostOS() != "wsl2" {
+		return ""
+	}
+	backend, _, _ := hostIptables()
+	return backend
+}
+
+// hostIptables returns the iptables backend of the host, whether iptables
+// is installed and whether legacy tables are loaded in the kernel
+func hostIptables() (backend string, installed, legacyTables bool) {
+	if data, err := os.ReadFile("/proc/net/ip_tables_names"); err == nil && strings.TrimSpace(string(data)) != "" {
+		legacyTables = true
+	}
+	output, err := exec.Command("iptables", "--version").Output()
+	switch {
+	case err != nil && legacyTables:
+		return "legacy", false, true
+	case err != nil:
+		return "nft", false, false
+	case strings.Contains(string(output), "nf_tables"):
+		return "nft", true, legacyTables
+	default:
+		return "legacy", true, legacyTables
+	}
+}
+
+func checkIptablesBackend() ValidationResult {
+	backend, installed, legacyTables := hostIptables()
+	if !installed {
+		return ValidationResult{
+			Name:    "Iptables Backend",
+			Passed:  true,
+			Message: fmt.Sprintf("iptables not installed on the host; nodes will use iptables-%s", backend),
+			Fatal:   false,
+		}
+	}
+
+	// Rules of another tool in legacy tables conflict with the nft rules
+	// kube-proxy writes, whatever backend the nodes use
+	if backend == "nft" && legacyTables {
+		return ValidationResult{
+			Name:    "Iptables Backend",
+			Passed:  false,
+			Message: "Host uses iptables-nft, but legacy tables are loaded by another tool. Mixed backends can break kube-proxy service routing; move that tool to iptables-nft if possible",
+			Fatal:   false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Iptables Backend",
+		Passed:  true,
+		Message: fmt.Sprintf("Host uses iptables-%s; nodes are switched to it (override with networking.iptablesBackend)", backend),
+		Fatal:   false,
+	}
+}
+
+func checkIptablesInUserNamespace() ValidationResult {
+	if _, err := exec.LookPath("unshare"); err != nil {
+		return ValidationResult{
+			Name:    "Iptables in User Namespace",
+			Passed:  true,
+			Message: "Cannot verify (unshare not found)",
+			Fatal:   false,
+		}
+	}
+
+	// This mirrors what kube-proxy does inside a rootless node container
+	var failed []string
+	for _, tool := range []string{"iptables", "ip6tables"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			continue
+		}
+		cmd := exec.Command("unshare", "--user", "--map-root-user", "--net", tool, "-t", "nat", "-L", "-n")
+		if err := cmd.Run(); err != nil {
+			failed = append(failed, tool)
+		}
+	}
+
+	if len(failed) > 0 {
+		return ValidationResult{
+			Name:   "Iptables in User Namespace",
+			Passed: false,
+			Message: fmt.Sprintf("%s cannot manage the nat table in a user namespace. kube-proxy will fail; load nf_tables/ip_tables/ip6_tables on the host",
+				strings.Join(failed, ", ")),
+			Fatal: false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Iptables in User Namespace",
+		Passed:  true,
+		Message: "iptables/ip6tables work in a user namespace",
+		Fatal:   false,
+	}
+}
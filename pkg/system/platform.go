@@ -0,0 +1,108 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Platform requirements of kipod commands
+const (
+	// RequiresNothing commands only read or write local files
+	RequiresNothing = "none"
+
+	// RequiresPodman commands only talk to podman, which a podman machine
+	// provides on macOS and Windows
+	RequiresPodman = "podman"
+
+	// RequiresNodes commands run node containers and need a Linux (or WSL2)
+	// kernel on the machine kipod runs on
+	RequiresNodes = "nodes"
+)
+
+// SupportedArchitectures are the architectures node images are built for
+var SupportedArchitectures = []string{"amd64", "arm64"}
+
+// PlatformError explains why a command cannot run on this host and how to
+// run it instead
+type PlatformError struct {
+	Command  string
+	Platform string
+	Reason   string
+	Remedy   string
+}
+
+func (e *PlatformError) Error() string {
+	return fmt.Sprintf("%s is not supported on %s: %s\nSupported path: %s", e.Command, e.Platform, e.Reason, e.Remedy)
+}
+
+// CheckPlatform returns a *PlatformError when command, which has the given
+// requirement, cannot run on this host
+func CheckPlatform(command, requirement string) error {
+	platform := fmt.Sprintf("%s/%s", HostOS(), runtime.GOARCH)
+	problem := func(reason, remedy string) error {
+		return &PlatformError{Command: command, Platform: platform, Reason: reason, Remedy: remedy}
+	}
+
+	switch requirement {
+	case RequiresNothing:
+		return nil
+	case RequiresPodman:
+		if _, err := exec.LookPath("podman"); err != nil {
+			return problem("podman is not installed", podmanInstallHint())
+		}
+		return nil
+	}
+
+	if reason, remedy := unsupportedHost(HostOS()); reason != "" {
+		return problem(reason, remedy)
+	}
+	if !supportedArchitecture(runtime.GOARCH) {
+		return problem(
+			fmt.Sprintf("node images are only built for %s", strings.Join(SupportedArchitectures, " and ")),
+			"run kipod on an amd64 or arm64 Linux host or VM",
+		)
+	}
+	return nil
+}
+
+// unsupportedHost explains why nodes cannot run on host, as returned by
+// HostOS, and what to do instead; it returns empty strings for Linux and WSL2
+func unsupportedHost(host string) (reason, remedy string) {
+	switch host {
+	case "linux", "wsl2":
+		return "", ""
+	case "wsl1":
+		return "WSL1 has no real Linux kernel and cannot run containers",
+			"convert the distribution with: wsl --set-version <distro> 2"
+	case "windows":
+		return "kipod needs a Linux host",
+			"install a WSL2 distribution and run kipod inside it, or run it inside the podman machine VM (podman machine ssh)"
+	case "darwin":
+		return "kipod needs a Linux host",
+			"run kipod inside a Linux VM, e.g. the podman machine VM: podman machine init && podman machine start, then podman machine ssh"
+	default:
+		return fmt.Sprintf("kipod needs a Linux host, found %s", host),
+			"run kipod on a Linux host or VM"
+	}
+}
+
+// podmanInstallHint tells users how to get podman on this OS
+func podmanInstallHint() string {
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return "install Podman (e.g. Podman Desktop), then run podman machine init && podman machine start"
+	default:
+		return "install podman with your distribution's package manager, e.g. sudo dnf install podman"
+	}
+}
+
+func supportedArchitecture(arch string) bool {
+	for _, supported := range SupportedArchitectures {
+		if supported == arch {
+			return true
+		}
+	}
+	return false
+}
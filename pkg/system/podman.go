@@ -0,0 +1,176 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+// MinPodmanVersion is the minimum supported podman version
+var MinPodmanVersion = version{4, 0, 0}
+
+// podmanInfo is the subset of `podman info` used by the checks
+type podmanInfo struct {
+	Host struct {
+		NetworkBackend string `json:"networkBackend"`
+	} `json:"host"`
+	Store struct {
+		GraphDriverName string `json:"graphDriverName"`
+	} `json:"store"`
+	Version struct {
+		Version string `json:"Version"`
+	} `json:"version"`
+}
+
+func checkPodman() []ValidationResult {
+	output, err := podman.Command("info", "--format", "json").Output()
+	if err != nil {
+		return []ValidationResult{{
+			Name:    "Podman Installation",
+			Passed:  false,
+			Message: "Podman is not installed, not in PATH, or 'podman info' failed",
+			Fatal:   true,
+		}}
+	}
+
+	var info podmanInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return []ValidationResult{{
+			Name:    "Podman Installation",
+			Passed:  false,
+			Message: fmt.Sprintf("Could not parse 'podman info' output: %v", err),
+			Fatal:   true,
+		}}
+	}
+
+	return []ValidationResult{
+		checkPodmanVersion(info.Version.Version),
+		checkNetworkBackend(info.Host.NetworkBackend),
+		checkSystemdMode(),
+		checkMountPropagation(),
+		checkStorageDriver(info.Store.GraphDriverName),
+	}
+}
+
+func checkPodmanVersion(raw string) ValidationResult {
+	v := parseVersion(raw)
+	if !v.valid() {
+		return ValidationResult{
+			Name:    "Podman Version",
+			Passed:  false,
+			Message: fmt.Sprintf("Could not parse podman version %q", raw),
+			Fatal:   false,
+		}
+	}
+
+	if v.less(MinPodmanVersion) {
+		return ValidationResult{
+			Name:    "Podman Version",
+			Passed:  false,
+			Message: fmt.Sprintf("Podman %s is too old, kipod requires >= %s", v, MinPodmanVersion),
+			Fatal:   true,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Podman Version",
+		Passed:  true,
+		Message: fmt.Sprintf("Podman %s (>= %s)", v, MinPodmanVersion),
+		Fatal:   false,
+	}
+}
+
+func checkNetworkBackend(backend string) ValidationResult {
+	if backend == "netavark" {
+		return ValidationResult{
+			Name:    "Network Backend",
+			Passed:  true,
+			Message: "netavark",
+			Fatal:   false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Network Backend",
+		Passed:  false,
+		Message: fmt.Sprintf("Using %q backend. CNI is deprecated in podman and lacks container name resolution on the kipod network; switch to netavark (network_backend in containers.conf)", backend),
+		Fatal:   false,
+	}
+}
+
+func checkSystemdMode() ValidationResult {
+	output, err := podman.Command("run", "--help").Output()
+	if err != nil || !strings.Contains(string(output), "--systemd") {
+		return ValidationResult{
+			Name:    "Systemd Mode",
+			Passed:  false,
+			Message: "podman run does not support --systemd, required to boot systemd in node containers",
+			Fatal:   true,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Systemd Mode",
+		Passed:  true,
+		Message: "podman run --systemd=always supported",
+		Fatal:   false,
+	}
+}
+
+// checkMountPropagation verifies that / is a shared mount, which podman needs
+// to honour :shared on the volume used by `storage.type: volume`
+func checkMountPropagation() ValidationResult {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return ValidationResult{
+			Name:    "Mount Propagation",
+			Passed:  false,
+			Message: "Could not read /proc/self/mountinfo",
+			Fatal:   false,
+		}
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 7 || fields[4] != "/" {
+			continue
+		}
+		if strings.Contains(line, "shared:") {
+			return ValidationResult{
+				Name:    "Mount Propagation",
+				Passed:  true,
+				Message: "/ is a shared mount (volume :shared propagation supported)",
+				Fatal:   false,
+			}
+		}
+		break
+	}
+
+	return ValidationResult{
+		Name:    "Mount Propagation",
+		Passed:  false,
+		Message: "/ is not a shared mount; storage.type: volume will not work. Fix with: sudo mount --make-rshared /",
+		Fatal:   false,
+	}
+}
+
+func checkStorageDriver(driver string) ValidationResult {
+	if driver == "vfs" {
+		return ValidationResult{
+			Name:    "Storage Driver",
+			Passed:  false,
+			Message: "Podman uses the vfs storage driver, which copies every layer and is very slow. Configure overlay in ~/.config/containers/storage.conf",
+			Fatal:   false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Storage Driver",
+		Passed:  true,
+		Message: driver,
+		Fatal:   false,
+	}
+}
@@ -0,0 +1,145 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the socket state of a listening socket in /proc/net/tcp
+const tcpListen = "0A"
+
+// FreeHostPort returns a TCP port that is free on the host
+func FreeHostPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free host port: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// CheckHostPorts verifies that the host side of podman port mappings
+// ("hostPort:containerPort", optionally with a host IP prefix and a /udp or
+// /tcp suffix) is free, naming the process that holds a conflicting port
+func CheckHostPorts(mappings []string) error {
+	for _, mapping := range mappings {
+		hostIP, hostPort, protocol, err := parsePortMapping(mapping)
+		if err != nil {
+			return err
+		}
+		address := net.JoinHostPort(hostIP, strconv.Itoa(hostPort))
+
+		if protocol == "udp" {
+			conn, err := net.ListenPacket("udp", address)
+			if err != nil {
+				return fmt.Errorf("host port %d/udp is already in use: %w", hostPort, err)
+			}
+			conn.Close()
+			continue
+		}
+
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			owner := portOwner(hostPort)
+			if owner == "" {
+				owner = "another process"
+			}
+			return fmt.Errorf("host port %d is already in use by %s; stop it or delete the cluster using it (kipod get clusters)", hostPort, owner)
+		}
+		listener.Close()
+	}
+	return nil
+}
+
+// parsePortMapping returns the host IP, host port and protocol of a podman
+// port mapping
+func parsePortMapping(mapping string) (string, int, string, error) {
+	spec, protocol, _ := strings.Cut(mapping, "/")
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	parts := strings.Split(spec, ":")
+	var hostIP, hostPort string
+	switch len(parts) {
+	case 2:
+		hostPort = parts[0]
+	case 3:
+		hostIP, hostPort = parts[0], parts[1]
+	default:
+		return "", 0, "", fmt.Errorf("invalid port mapping %q", mapping)
+	}
+
+	port, err := strconv.Atoi(hostPort)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, "", fmt.Errorf("invalid host port in mapping %q", mapping)
+	}
+	return hostIP, port, protocol, nil
+}
+
+// portOwner describes the process listening on a TCP port, e.g.
+// "rootlessport (pid 1234)", or returns "" when it cannot be determined.
+// Processes of other users are not visible to rootless kipod.
+func portOwner(port int) string {
+	inodes := make(map[string]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		for _, inode := range listeningInodes(table, port) {
+			inodes[inode] = true
+		}
+	}
+	if len(inodes) == 0 {
+		return ""
+	}
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		link, err := os.Readlink(fd)
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		if !inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+			continue
+		}
+
+		pid := strings.Split(fd, "/")[2]
+		comm, err := os.ReadFile(filepath.Join("/proc", pid, "comm"))
+		if err != nil {
+			return fmt.Sprintf("pid %s", pid)
+		}
+		return fmt.Sprintf("%s (pid %s)", strings.TrimSpace(string(comm)), pid)
+	}
+	return "a process of another user"
+}
+
+// listeningInodes returns the socket inodes listening on port in a
+// /proc/net/tcp style table
+func listeningInodes(table string, port int) []string {
+	f, err := os.Open(table)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var inodes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListen {
+			continue
+		}
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		if p, err := strconv.ParseInt(hexPort, 16, 32); err == nil && int(p) == port {
+			inodes = append(inodes, fields[9])
+		}
+	}
+	return inodes
+}
@@ -0,0 +1,251 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+const (
+	// minMemoryPerNode is the recommended available memory per planned node
+	minMemoryPerNode = 4 << 30
+
+	// minImageDiskSpace is the free space needed for node images and containers
+	minImageDiskSpace = 10 << 30
+)
+
+// Topology describes the cluster the user intends to create, so resource
+// checks can be sized accordingly
+type Topology struct {
+	Nodes       int
+	StorageType string
+	StorageSize string
+}
+
+// DefaultTopology matches the cluster created without a config file
+func DefaultTopology() Topology {
+	return Topology{
+		Nodes:       1,
+		StorageType: "tmpfs",
+		StorageSize: "10G",
+	}
+}
+
+// ValidateResources checks host memory and disk space against a planned topology
+func ValidateResources(topology Topology) []ValidationResult {
+	return inCategory("Resources",
+		checkMemory(topology),
+		checkDiskSpace(topology),
+		CheckTmpfsCapacity(topology),
+	)
+}
+
+func checkMemory(topology Topology) ValidationResult {
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return ValidationResult{
+			Name:    "Memory",
+			Passed:  false,
+			Message: "Could not read /proc/meminfo",
+			Fatal:   false,
+		}
+	}
+
+	available := meminfo["MemAvailable"]
+	required := uint64(topology.Nodes) * minMemoryPerNode
+	if available < required {
+		return ValidationResult{
+			Name:    "Memory",
+			Passed:  false,
+			Message: fmt.Sprintf("%s available, recommend at least %s for %d node(s)", FormatSize(available), FormatSize(required), topology.Nodes),
+			Fatal:   false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Memory",
+		Passed:  true,
+		Message: fmt.Sprintf("%s available for %d node(s)", FormatSize(available), topology.Nodes),
+		Fatal:   false,
+	}
+}
+
+func checkDiskSpace(topology Topology) ValidationResult {
+	graphRoot, err := podmanGraphRoot()
+	if err != nil {
+		return ValidationResult{
+			Name:    "Disk Space",
+			Passed:  false,
+			Message: fmt.Sprintf("Could not determine podman graphroot: %v", err),
+			Fatal:   false,
+		}
+	}
+
+	free, err := freeSpace(graphRoot)
+	if err != nil {
+		return ValidationResult{
+			Name:    "Disk Space",
+			Passed:  false,
+			Message: fmt.Sprintf("Could not stat %s: %v", graphRoot, err),
+			Fatal:   false,
+		}
+	}
+
+	// Volume-backed node storage lives under the graphroot as well
+	required := uint64(minImageDiskSpace)
+	if topology.StorageType == "volume" {
+		if size, err := ParseSize(topology.StorageSize); err == nil {
+			required += uint64(topology.Nodes) * size
+		}
+	}
+
+	if free < required {
+		return ValidationResult{
+			Name:    "Disk Space",
+			Passed:  false,
+			Message: fmt.Sprintf("%s free under %s, recommend at least %s", FormatSize(free), graphRoot, FormatSize(required)),
+			Fatal:   false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Disk Space",
+		Passed:  true,
+		Message: fmt.Sprintf("%s free under %s", FormatSize(free), graphRoot),
+		Fatal:   false,
+	}
+}
+
+// CheckTmpfsCapacity checks that the tmpfs image storage of all nodes fits
+// in host memory and swap
+func CheckTmpfsCapacity(topology Topology) ValidationResult {
+	if topology.StorageType != "tmpfs" {
+		return ValidationResult{
+			Name:    "Tmpfs Capacity",
+			Passed:  true,
+			Message: fmt.Sprintf("Not using tmpfs storage (type: %s)", topology.StorageType),
+			Fatal:   false,
+		}
+	}
+
+	size, err := ParseSize(topology.StorageSize)
+	if err != nil {
+		return ValidationResult{
+			Name:    "Tmpfs Capacity",
+			Passed:  false,
+			Message: fmt.Sprintf("Invalid storage size %q: %v", topology.StorageSize, err),
+			Fatal:   true,
+		}
+	}
+
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return ValidationResult{
+			Name:    "Tmpfs Capacity",
+			Passed:  false,
+			Message: "Could not read /proc/meminfo",
+			Fatal:   false,
+		}
+	}
+
+	// tmpfs pages live in memory and can be swapped out
+	capacity := meminfo["MemAvailable"] + meminfo["SwapFree"]
+	requested := uint64(topology.Nodes) * size
+	if requested > capacity {
+		return ValidationResult{
+			Name:   "Tmpfs Capacity",
+			Passed: false,
+			Message: fmt.Sprintf("%d node(s) x %s tmpfs = %s exceeds available memory+swap (%s). Image pulls may fail when storage fills up; consider storage.type: volume",
+				topology.Nodes, topology.StorageSize, FormatSize(requested), FormatSize(capacity)),
+			Fatal: false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Tmpfs Capacity",
+		Passed:  true,
+		Message: fmt.Sprintf("%d node(s) x %s tmpfs fits in available memory+swap (%s)", topology.Nodes, topology.StorageSize, FormatSize(capacity)),
+		Fatal:   false,
+	}
+}
+
+// readMeminfo returns /proc/meminfo values in bytes
+func readMeminfo() (map[string]uint64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) >= 3 && fields[2] == "kB" {
+			value *= 1024
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = value
+	}
+	return values, scanner.Err()
+}
+
+func podmanGraphRoot() (string, error) {
+	output, err := podman.Command("info", "--format", "{{.Store.GraphRoot}}").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ParseSize parses a size such as "10G", "512Mi" or "1024" into bytes
+func ParseSize(size string) (uint64, error) {
+	s := strings.TrimSpace(size)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "i")
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := uint64(1)
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		multiplier = 1 << 10
+	case 'm', 'M':
+		multiplier = 1 << 20
+	case 'g', 'G':
+		multiplier = 1 << 30
+	case 't', 'T':
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return value * multiplier, nil
+}
+
+// FormatSize formats bytes as a human readable size
+func FormatSize(bytes uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(bytes)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
@@ -0,0 +1,151 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ValidateSandboxRuntime checks the host features required by an experimental
+// sandboxed runtime ("kata" or "gvisor")
+func ValidateSandboxRuntime(runtime string) []ValidationResult {
+	switch runtime {
+	case "kata":
+		return inCategory("Sandbox Runtime",
+			checkCPUVirtualization(),
+			checkKVMDevice(),
+			checkVhostVsock(),
+		)
+	case "gvisor":
+		return inCategory("Sandbox Runtime",
+			checkSeccomp(),
+		)
+	}
+	return nil
+}
+
+// HasFatalErrors reports whether any failed result is fatal
+func HasFatalErrors(results []ValidationResult) bool {
+	for _, result := range results {
+		if !result.Passed && result.Fatal {
+			return true
+		}
+	}
+	return false
+}
+
+func checkCPUVirtualization() ValidationResult {
+	data, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return ValidationResult{
+			Name:    "CPU Virtualization",
+			Passed:  false,
+			Message: "Could not read /proc/cpuinfo",
+			Fatal:   false,
+		}
+	}
+
+	hasVirt := false
+	isGuest := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "flags") {
+			continue
+		}
+		for _, flag := range strings.Fields(line) {
+			switch flag {
+			case "vmx", "svm":
+				hasVirt = true
+			case "hypervisor":
+				isGuest = true
+			}
+		}
+		break
+	}
+
+	if hasVirt {
+		return ValidationResult{
+			Name:    "CPU Virtualization",
+			Passed:  true,
+			Message: "Hardware virtualization extensions (vmx/svm) available",
+			Fatal:   false,
+		}
+	}
+
+	message := "CPU does not expose vmx/svm. Enable virtualization in the firmware settings."
+	if isGuest {
+		message = "Running in a VM without nested virtualization. Enable nested virtualization in the outer hypervisor (e.g. kvm_intel nested=1)."
+	}
+	return ValidationResult{
+		Name:    "CPU Virtualization",
+		Passed:  false,
+		Message: message,
+		Fatal:   true,
+	}
+}
+
+func checkKVMDevice() ValidationResult {
+	if _, err := os.Stat("/dev/kvm"); err != nil {
+		return ValidationResult{
+			Name:    "KVM Device",
+			Passed:  false,
+			Message: "/dev/kvm not found. Load the kvm_intel or kvm_amd kernel module.",
+			Fatal:   true,
+		}
+	}
+
+	// Kata needs read/write access to /dev/kvm from the rootless user
+	f, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+	if err != nil {
+		return ValidationResult{
+			Name:    "KVM Device",
+			Passed:  false,
+			Message: fmt.Sprintf("/dev/kvm is not accessible: %v. Add your user to the kvm group.", err),
+			Fatal:   true,
+		}
+	}
+	f.Close()
+
+	return ValidationResult{
+		Name:    "KVM Device",
+		Passed:  true,
+		Message: "/dev/kvm is accessible",
+		Fatal:   false,
+	}
+}
+
+func checkVhostVsock() ValidationResult {
+	if _, err := os.Stat("/dev/vhost-vsock"); err != nil {
+		return ValidationResult{
+			Name:    "vhost-vsock",
+			Passed:  false,
+			Message: "/dev/vhost-vsock not found. Load it with: modprobe vhost_vsock",
+			Fatal:   false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "vhost-vsock",
+		Passed:  true,
+		Message: "/dev/vhost-vsock available",
+		Fatal:   false,
+	}
+}
+
+func checkSeccomp() ValidationResult {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil || !strings.Contains(string(data), "Seccomp:") {
+		return ValidationResult{
+			Name:    "Seccomp",
+			Passed:  false,
+			Message: "Kernel seccomp support not detected. gVisor's systrap platform requires it.",
+			Fatal:   true,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Seccomp",
+		Passed:  true,
+		Message: "Kernel seccomp support available for gVisor",
+		Fatal:   false,
+	}
+}
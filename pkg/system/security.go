@@ -0,0 +1,223 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/podman"
+)
+
+func checkSELinux() ValidationResult {
+	data, err := os.ReadFile("/sys/fs/selinux/enforce")
+	if err != nil {
+		return ValidationResult{
+			Name:    "SELinux",
+			Passed:  true,
+			Message: "SELinux not enabled",
+			Fatal:   false,
+		}
+	}
+
+	if strings.TrimSpace(string(data)) != "1" {
+		return ValidationResult{
+			Name:    "SELinux",
+			Passed:  true,
+			Message: "SELinux is permissive",
+			Fatal:   false,
+		}
+	}
+
+	// Enforcing mode needs the container policy, otherwise nested containers are denied
+	if !hasContainerSELinuxPolicy() {
+		return ValidationResult{
+			Name:    "SELinux",
+			Passed:  false,
+			Message: "SELinux is enforcing but the container-selinux policy is not installed. Install it with: sudo dnf install container-selinux",
+			Fatal:   true,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "SELinux",
+		Passed:  true,
+		Message: "SELinux is enforcing with container-selinux policy installed",
+		Fatal:   false,
+	}
+}
+
+func hasContainerSELinuxPolicy() bool {
+	if err := exec.Command("rpm", "-q", "container-selinux").Run(); err == nil {
+		return true
+	}
+	for _, path := range []string{
+		"/usr/share/selinux/packages/container.pp.bz2",
+		"/usr/share/selinux/packages/container.pp",
+		"/var/lib/selinux/targeted/active/modules/200/container",
+	} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func checkAppArmor() ValidationResult {
+	data, err := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+	if err != nil || strings.TrimSpace(string(data)) != "Y" {
+		return ValidationResult{
+			Name:    "AppArmor",
+			Passed:  true,
+			Message: "AppArmor not enabled",
+			Fatal:   false,
+		}
+	}
+
+	// Ubuntu 23.10+ blocks unprivileged user namespaces unless a profile allows them
+	restrict, err := os.ReadFile("/proc/sys/kernel/apparmor_restrict_unprivileged_userns")
+	if err == nil && strings.TrimSpace(string(restrict)) == "1" && !hasPodmanUsernsProfile() {
+		return ValidationResult{
+			Name:    "AppArmor",
+			Passed:  false,
+			Message: "AppArmor restricts unprivileged user namespaces and no podman profile allows them. Install a profile with 'userns,' for podman in /etc/apparmor.d/podman, or run: sudo sysctl -w kernel.apparmor_restrict_unprivileged_userns=0",
+			Fatal:   true,
+		}
+	}
+
+	// podman needs apparmor_parser to load its containers-default profile
+	if _, err := exec.LookPath("apparmor_parser"); err != nil {
+		if _, err := os.Stat("/sbin/apparmor_parser"); err != nil {
+			return ValidationResult{
+				Name:    "AppArmor",
+				Passed:  false,
+				Message: "AppArmor is enabled but apparmor_parser is missing. Install it with: sudo apt install apparmor",
+				Fatal:   true,
+			}
+		}
+	}
+
+	return ValidationResult{
+		Name:    "AppArmor",
+		Passed:  true,
+		Message: "AppArmor is enabled and allows rootless podman",
+		Fatal:   false,
+	}
+}
+
+func hasPodmanUsernsProfile() bool {
+	data, err := os.ReadFile("/etc/apparmor.d/podman")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(data), "userns")
+}
+
+// knownIssue describes a host configuration known to break kipod clusters
+type knownIssue struct {
+	Applies func(kernel, podman version) bool
+	Message string
+	Fatal   bool
+}
+
+var knownIssues = []knownIssue{
+	{
+		Applies: func(kernel, _ version) bool { return kernel.valid() && kernel.less(version{5, 11, 0}) },
+		Message: "Kernel < 5.11 cannot mount overlayfs in user namespaces; node storage falls back to fuse-overlayfs and is much slower.",
+		Fatal:   false,
+	},
+	{
+		Applies: func(kernel, _ version) bool { return kernel.valid() && kernel.less(version{5, 2, 0}) },
+		Message: "Kernel < 5.2 lacks the cgroup v2 freezer needed to stop and pause node containers.",
+		Fatal:   true,
+	},
+}
+
+func checkKnownIssues() ValidationResult {
+	kernel := parseVersion(kernelRelease())
+	podman := parseVersion(podmanVersion())
+
+	var fatal bool
+	var messages []string
+	for _, issue := range knownIssues {
+		if issue.Applies(kernel, podman) {
+			messages = append(messages, issue.Message)
+			fatal = fatal || issue.Fatal
+		}
+	}
+
+	if len(messages) > 0 {
+		return ValidationResult{
+			Name:    "Known Issues",
+			Passed:  false,
+			Message: strings.Join(messages, " "),
+			Fatal:   fatal,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Known Issues",
+		Passed:  true,
+		Message: fmt.Sprintf("No known issues for kernel %s with podman %s", kernel, podman),
+		Fatal:   false,
+	}
+}
+
+func kernelRelease() string {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func podmanVersion() string {
+	output, err := podman.Command("version", "--format", "{{.Client.Version}}").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// version is a parsed major.minor.patch version
+type version [3]int
+
+// parseVersion parses the leading major.minor.patch of a version string such as
+// "6.8.0-45-generic" or "v4.9.3". Unparseable input yields the zero version.
+func parseVersion(s string) version {
+	var v version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	for i, part := range strings.SplitN(s, ".", 3) {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(part[:end])
+		if err != nil {
+			break
+		}
+		v[i] = n
+	}
+	return v
+}
+
+func (v version) valid() bool {
+	return v != version{}
+}
+
+func (v version) less(other version) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+func (v version) String() string {
+	if !v.valid() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
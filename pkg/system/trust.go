@@ -0,0 +1,108 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// trustStore is a host CA trust store: a directory of anchors and the
+// command regenerating the system bundle from it
+type trustStore struct {
+	dir    string
+	update []string
+}
+
+// trustStores are the system trust stores kipod knows, in detection order
+var trustStores = []trustStore{
+	// Fedora, RHEL, CentOS Stream
+	{"/etc/pki/ca-trust/source/anchors", []string{"update-ca-trust", "extract"}},
+	// Debian, Ubuntu
+	{"/usr/local/share/ca-certificates", []string{"update-ca-certificates"}},
+	// Arch Linux
+	{"/etc/ca-certificates/trust-source/anchors", []string{"trust", "extract-compat"}},
+}
+
+// HostCAPath returns where the CA of a cluster is installed in the host
+// trust store
+func HostCAPath(cluster string) (string, error) {
+	store, err := detectTrustStore()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(store.dir, fmt.Sprintf("kipod-%s-ca.crt", cluster)), nil
+}
+
+// TrustHostCA adds a PEM encoded cluster CA to the host trust store, using
+// sudo unless kipod runs as root
+func TrustHostCA(cluster, pem string) error {
+	store, err := detectTrustStore()
+	if err != nil {
+		return err
+	}
+	dest, _ := HostCAPath(cluster)
+
+	tmp, err := os.CreateTemp("", "kipod-ca-*.crt")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(pem); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write CA: %w", err)
+	}
+	tmp.Close()
+
+	if err := runPrivileged("install", "-m", "0644", tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to install CA to %s: %w", dest, err)
+	}
+	if err := runPrivileged(store.update...); err != nil {
+		return fmt.Errorf("failed to update the trust store: %w", err)
+	}
+	return nil
+}
+
+// UntrustHostCA removes the CA of a cluster from the host trust store
+func UntrustHostCA(cluster string) error {
+	store, err := detectTrustStore()
+	if err != nil {
+		return err
+	}
+	dest, _ := HostCAPath(cluster)
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := runPrivileged("rm", "-f", dest); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", dest, err)
+	}
+	if err := runPrivileged(store.update...); err != nil {
+		return fmt.Errorf("failed to update the trust store: %w", err)
+	}
+	return nil
+}
+
+func detectTrustStore() (trustStore, error) {
+	for _, store := range trustStores {
+		if info, err := os.Stat(store.dir); err == nil && info.IsDir() {
+			if _, err := exec.LookPath(store.update[0]); err == nil {
+				return store, nil
+			}
+		}
+	}
+	return trustStore{}, fmt.Errorf("no supported CA trust store found on this host")
+}
+
+// runPrivileged runs a command as root, through sudo for other users. sudo
+// may prompt for a password on the terminal.
+func runPrivileged(args ...string) error {
+	if os.Geteuid() != 0 {
+		args = append([]string{"sudo"}, args...)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
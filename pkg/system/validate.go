@@ -8,67 +8,74 @@ import (
 	"os/user"
 	"strconv"
 	"strings"
+
+	"github.com/sohankunkerkar/kipod/pkg/style"
 )
 
 // ValidationResult represents the result of a validation check
 type ValidationResult struct {
-	Name    string
-	Passed  bool
-	Message string
-	Fatal   bool
+	Category string
+	Name     string
+	Passed   bool
+	Message  string
+	Fatal    bool
 }
 
 // ValidateSystem validates that the host system meets requirements for kipod
 func ValidateSystem() ([]ValidationResult, error) {
-	results := []ValidationResult{}
-
-	// Check if podman is installed
-	results = append(results, checkPodman())
-
-	// Check if running as non-root (rootless mode)
-	results = append(results, checkNonRoot())
-
-	// Check subuid/subgid configuration
-	results = append(results, checkSubUID())
-	results = append(results, checkSubGID())
-
-	// Check cgroup v2
-	results = append(results, checkCgroupV2())
-
-	// Check user namespaces
-	results = append(results, checkUserNamespaces())
-
-	// Check fuse support
-	results = append(results, checkFuseSupport())
-
-	// Check cgroup delegation
-	results = append(results, checkCgroupDelegation())
-
-	// Check max user namespaces
-	results = append(results, checkMaxUserNamespaces())
+	// The remaining checks assume a Linux kernel
+	results := ValidateHost()
+	if HostOS() != "linux" && HostOS() != "wsl2" {
+		return results, nil
+	}
+
+	// Check podman version and features
+	results = append(results, inCategory("Podman", checkPodman()...)...)
+
+	// Check rootless mode and user namespace setup
+	results = append(results, inCategory("User Namespaces",
+		checkNonRoot(),
+		checkSubUID(),
+		checkSubGID(),
+		checkUserNamespaces(),
+		checkMaxUserNamespaces(),
+	)...)
+
+	// Check cgroup v2 and delegation
+	results = append(results, inCategory("Cgroups",
+		checkCgroupV2(),
+		checkCgroupDelegation(),
+	)...)
+
+	// Check kernel features, limits and known-bad kernel/podman combinations
+	results = append(results, inCategory("Kernel",
+		checkFuseSupport(),
+		checkInotifyLimits(),
+		checkKernelModules(),
+		checkKnownIssues(),
+	)...)
+
+	// Check netfilter setup needed by kube-proxy
+	results = append(results, inCategory("Networking",
+		checkIptablesBackend(),
+		checkIptablesInUserNamespace(),
+	)...)
+
+	// Check mandatory access control setup
+	results = append(results, inCategory("Security",
+		checkSELinux(),
+		checkAppArmor(),
+	)...)
 
 	return results, nil
 }
 
-func checkPodman() ValidationResult {
-	cmd := exec.Command("podman", "--version")
-	output, err := cmd.Output()
-	if err != nil {
-		return ValidationResult{
-			Name:    "Podman Installation",
-			Passed:  false,
-			Message: "Podman is not installed or not in PATH",
-			Fatal:   true,
-		}
-	}
-
-	version := strings.TrimSpace(string(output))
-	return ValidationResult{
-		Name:    "Podman Installation",
-		Passed:  true,
-		Message: fmt.Sprintf("Found: %s", version),
-		Fatal:   false,
+// inCategory sets the category of each result
+func inCategory(category string, results ...ValidationResult) []ValidationResult {
+	for i := range results {
+		results[i].Category = category
 	}
+	return results
 }
 
 func checkNonRoot() ValidationResult {
@@ -197,55 +204,6 @@ func checkSubGID() ValidationResult {
 	}
 }
 
-func checkCgroupV2() ValidationResult {
-	// Check if cgroup v2 is mounted
-	data, err := os.ReadFile("/proc/filesystems")
-	if err != nil {
-		return ValidationResult{
-			Name:    "Cgroup v2",
-			Passed:  false,
-			Message: "Could not read /proc/filesystems",
-			Fatal:   false,
-		}
-	}
-
-	if !strings.Contains(string(data), "cgroup2") {
-		return ValidationResult{
-			Name:    "Cgroup v2",
-			Passed:  false,
-			Message: "Cgroup v2 not available in kernel",
-			Fatal:   true,
-		}
-	}
-
-	// Check if cgroup v2 is mounted
-	data, err = os.ReadFile("/proc/mounts")
-	if err != nil {
-		return ValidationResult{
-			Name:    "Cgroup v2 Mount",
-			Passed:  false,
-			Message: "Could not read /proc/mounts",
-			Fatal:   false,
-		}
-	}
-
-	if strings.Contains(string(data), "cgroup2") {
-		return ValidationResult{
-			Name:    "Cgroup v2",
-			Passed:  true,
-			Message: "Cgroup v2 is mounted and available",
-			Fatal:   false,
-		}
-	}
-
-	return ValidationResult{
-		Name:    "Cgroup v2",
-		Passed:  false,
-		Message: "Cgroup v2 not mounted (expected at /sys/fs/cgroup)",
-		Fatal:   true,
-	}
-}
-
 func checkUserNamespaces() ValidationResult {
 	// Check if user namespaces are enabled
 	data, err := os.ReadFile("/proc/sys/kernel/unprivileged_userns_clone")
@@ -421,38 +379,152 @@ func checkMaxUserNamespaces() ValidationResult {
 	}
 }
 
-// PrintValidationResults prints validation results in a nice format
-func PrintValidationResults(results []ValidationResult) {
-	fmt.Println("\n=== System Validation ===\n")
+// Recommended inotify limits for multi-node clusters. Every node runs kubelet,
+// CRI-O and pods that watch files, all counted against the host user's limits.
+const (
+	RecommendedInotifyWatches   = 524288
+	RecommendedInotifyInstances = 512
+)
+
+func checkInotifyLimits() ValidationResult {
+	watches, err := readSysctlInt("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return ValidationResult{
+			Name:    "Inotify Limits",
+			Passed:  false,
+			Message: "Could not read fs.inotify.max_user_watches",
+			Fatal:   false,
+		}
+	}
+	instances, err := readSysctlInt("/proc/sys/fs/inotify/max_user_instances")
+	if err != nil {
+		return ValidationResult{
+			Name:    "Inotify Limits",
+			Passed:  false,
+			Message: "Could not read fs.inotify.max_user_instances",
+			Fatal:   false,
+		}
+	}
+
+	if watches < RecommendedInotifyWatches || instances < RecommendedInotifyInstances {
+		return ValidationResult{
+			Name:   "Inotify Limits",
+			Passed: false,
+			Message: fmt.Sprintf("max_user_watches=%d, max_user_instances=%d are low for multi-node clusters. Set with: sysctl -w fs.inotify.max_user_watches=%d fs.inotify.max_user_instances=%d",
+				watches, instances, RecommendedInotifyWatches, RecommendedInotifyInstances),
+			Fatal: false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "Inotify Limits",
+		Passed:  true,
+		Message: fmt.Sprintf("max_user_watches=%d, max_user_instances=%d (sufficient)", watches, instances),
+		Fatal:   false,
+	}
+}
+
+func readSysctlInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
 
-	fatalErrors := false
-	warnings := false
+// ReportOptions controls how validation results are judged
+type ReportOptions struct {
+	// Strict treats warnings as failures
+	Strict bool
+	// Ignore lists check names to skip, e.g. "SELinux" or "network-backend"
+	Ignore []string
+}
+
+// PrintValidationResults prints validation results grouped by category and
+// returns an error when checks fail
+func PrintValidationResults(results []ValidationResult, opts ReportOptions) error {
+	ignored := make(map[string]bool)
+	for _, name := range opts.Ignore {
+		ignored[checkKey(name)] = false
+	}
 
+	// Group by category, keeping the order checks were run in
+	var categories []string
+	groups := make(map[string][]ValidationResult)
 	for _, result := range results {
-		status := "✓"
-		if !result.Passed {
-			if result.Fatal {
-				status = "✗"
-				fatalErrors = true
-			} else {
-				status = "⚠"
-				warnings = true
+		category := result.Category
+		if category == "" {
+			category = "General"
+		}
+		if _, ok := groups[category]; !ok {
+			categories = append(categories, category)
+		}
+		groups[category] = append(groups[category], result)
+	}
+
+	fmt.Println(style.Bold("\n=== System Validation ==="))
+
+	var passed, warnings, failed, skipped int
+	for _, category := range categories {
+		fmt.Println()
+		fmt.Println(style.Bold(category))
+		for _, result := range groups[category] {
+			key := checkKey(result.Name)
+			if _, ok := ignored[key]; ok {
+				ignored[key] = true
+				skipped++
+				fmt.Printf("  - %s: ignored\n", result.Name)
+				continue
+			}
+
+			status := style.Green("✓")
+			switch {
+			case result.Passed:
+				passed++
+			case result.Fatal:
+				status = style.Red("✗")
+				failed++
+			default:
+				status = style.Yellow("⚠")
+				warnings++
 			}
+			fmt.Printf("  %s %s: %s\n", status, result.Name, result.Message)
 		}
+	}
 
-		fmt.Printf("%s %s: %s\n", status, result.Name, result.Message)
+	fmt.Println()
+	for _, name := range opts.Ignore {
+		if !ignored[checkKey(name)] {
+			fmt.Printf("%s --ignore %q did not match any check\n", style.Yellow("⚠"), name)
+		}
 	}
 
+	fmt.Printf("Summary: %s, %s, %s, %d ignored\n",
+		style.Green(fmt.Sprintf("%d passed", passed)),
+		style.Yellow(fmt.Sprintf("%d warnings", warnings)),
+		style.Red(fmt.Sprintf("%d failed", failed)),
+		skipped)
 	fmt.Println()
 
-	if fatalErrors {
-		fmt.Println("❌ Fatal errors detected. Please fix the issues above before proceeding.")
-		os.Exit(1)
+	if failed > 0 {
+		fmt.Println(style.Red("❌ Fatal errors detected. Please fix the issues above before proceeding."))
+		return fmt.Errorf("%d check(s) failed", failed)
 	}
 
-	if warnings {
-		fmt.Println("⚠️  Warnings detected. Kipod may not work correctly.")
+	if warnings > 0 {
+		if opts.Strict {
+			fmt.Println(style.Red("❌ Warnings detected and --strict is set."))
+			return fmt.Errorf("%d warning(s) treated as failures in strict mode", warnings)
+		}
+		fmt.Println(style.Yellow("⚠️  Warnings detected. Kipod may not work correctly."))
 	} else {
-		fmt.Println("✅ All checks passed! System is ready for kipod.")
+		fmt.Println(style.Green("✅ All checks passed! System is ready for kipod."))
 	}
+
+	return nil
+}
+
+// checkKey normalizes a check name so "Network Backend" and "network-backend" match
+func checkKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "-")
 }
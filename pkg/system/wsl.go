@@ -0,0 +1,175 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// wslConfigHint tells users where WSL2 kernel settings live, since they are
+// set from Windows rather than inside the distribution
+const wslConfigHint = `%UserProfile%\.wslconfig on Windows`
+
+// minWSLKernel is the oldest WSL2 kernel with working cgroup v2 delegation
+var minWSLKernel = version{5, 15, 0}
+
+// HostOS describes the host kipod runs on: "linux", "wsl2", "wsl1" or the
+// GOOS of a non-Linux host
+func HostOS() string {
+	if runtime.GOOS != "linux" {
+		return runtime.GOOS
+	}
+	release := strings.ToLower(kernelRelease())
+	if !strings.Contains(release, "microsoft") {
+		return "linux"
+	}
+	// WSL2 kernels are "...-microsoft-standard-WSL2", WSL1 reports "...-Microsoft"
+	if strings.Contains(release, "wsl2") || strings.Contains(release, "microsoft-standard") {
+		return "wsl2"
+	}
+	return "wsl1"
+}
+
+// IsWSL reports whether kipod runs inside Windows Subsystem for Linux
+func IsWSL() bool {
+	host := HostOS()
+	return host == "wsl2" || host == "wsl1"
+}
+
+func checkHostOS() ValidationResult {
+	host := HostOS()
+	if reason, remedy := unsupportedHost(host); reason != "" {
+		return ValidationResult{
+			Name:    "Host OS",
+			Passed:  false,
+			Message: fmt.Sprintf("%s. To run it, %s", reason, remedy),
+			Fatal:   true,
+		}
+	}
+
+	message := "Linux"
+	if host == "wsl2" {
+		message = fmt.Sprintf("WSL2 (kernel %s)", kernelRelease())
+	}
+	return ValidationResult{
+		Name:    "Host OS",
+		Passed:  true,
+		Message: message,
+		Fatal:   false,
+	}
+}
+
+// ValidateHost checks that the host is Linux and, inside WSL2, the WSL
+// settings kipod depends on
+func ValidateHost() []ValidationResult {
+	results := inCategory("Host", checkHostOS())
+	if HostOS() == "wsl2" {
+		results = append(results, inCategory("WSL2",
+			checkWSLSystemd(),
+			checkWSLCgroups(),
+			checkWSLKernel(),
+		)...)
+	}
+	return results
+}
+
+func checkWSLSystemd() ValidationResult {
+	data, err := os.ReadFile("/proc/1/comm")
+	if err == nil && strings.TrimSpace(string(data)) == "systemd" {
+		return ValidationResult{
+			Name:    "WSL systemd",
+			Passed:  true,
+			Message: "systemd is PID 1",
+			Fatal:   false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "WSL systemd",
+		Passed:  false,
+		Message: "systemd is not running, so rootless podman has no user session or cgroup delegation. Add \"[boot]\" and \"systemd=true\" to /etc/wsl.conf, then run wsl --shutdown from Windows",
+		Fatal:   true,
+	}
+}
+
+func checkWSLCgroups() ValidationResult {
+	data, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		return ValidationResult{
+			Name:    "WSL cgroup mounts",
+			Passed:  false,
+			Message: "Could not read /proc/mounts",
+			Fatal:   false,
+		}
+	}
+
+	var unified bool
+	var options string
+	var v1Controllers []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		switch {
+		case fields[2] == "cgroup2" && fields[1] == "/sys/fs/cgroup":
+			unified = true
+			options = fields[3]
+		case fields[2] == "cgroup":
+			v1Controllers = append(v1Controllers, strings.TrimPrefix(fields[1], "/sys/fs/cgroup/"))
+		}
+	}
+
+	remedy := fmt.Sprintf("Add \"kernelCommandLine = cgroup_no_v1=all\" under [wsl2] in %s, then run wsl --shutdown", wslConfigHint)
+	if len(v1Controllers) > 0 {
+		return ValidationResult{
+			Name:    "WSL cgroup mounts",
+			Passed:  false,
+			Message: fmt.Sprintf("cgroup v1 controllers are mounted (%s), so containers cannot use cgroup v2. %s", strings.Join(v1Controllers, ", "), remedy),
+			Fatal:   true,
+		}
+	}
+	if !unified {
+		return ValidationResult{
+			Name:    "WSL cgroup mounts",
+			Passed:  false,
+			Message: "cgroup v2 is not mounted at /sys/fs/cgroup. " + remedy,
+			Fatal:   true,
+		}
+	}
+	if !strings.Contains(options, "nsdelegate") {
+		return ValidationResult{
+			Name:    "WSL cgroup mounts",
+			Passed:  false,
+			Message: fmt.Sprintf("/sys/fs/cgroup is mounted without nsdelegate (%s); nested cgroup delegation may fail. %s", options, remedy),
+			Fatal:   false,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "WSL cgroup mounts",
+		Passed:  true,
+		Message: fmt.Sprintf("cgroup v2 only, mounted with %s", options),
+		Fatal:   false,
+	}
+}
+
+func checkWSLKernel() ValidationResult {
+	kernel := parseVersion(kernelRelease())
+	if kernel.valid() && kernel.less(minWSLKernel) {
+		return ValidationResult{
+			Name:    "WSL kernel",
+			Passed:  false,
+			Message: fmt.Sprintf("WSL kernel %s is older than %s and lacks cgroup v2 features nodes need. Update it with wsl --update from Windows", kernel, minWSLKernel),
+			Fatal:   true,
+		}
+	}
+
+	return ValidationResult{
+		Name:    "WSL kernel",
+		Passed:  true,
+		Message: fmt.Sprintf("WSL kernel %s", kernel),
+		Fatal:   false,
+	}
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileExporter appends each run to path as one line of OTLP JSON, the
+// format of the OpenTelemetry Collector file exporter, so runs of a CI job
+// can be collected into one file and replayed into any backend. The file is
+// only readable by its owner.
+func FileExporter(path string) Exporter {
+	return func(data []byte) error {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create trace file directory: %w", err)
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open trace file: %w", err)
+		}
+		defer f.Close()
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write trace file: %w", err)
+		}
+		return nil
+	}
+}
+
+// HTTPExporter posts each run to an OTLP/HTTP traces endpoint such as
+// http://localhost:4318/v1/traces, with the given extra headers
+func HTTPExporter(endpoint string, headers map[string]string) Exporter {
+	return func(data []byte) error {
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("invalid trace endpoint: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to export traces: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("failed to export traces: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		return nil
+	}
+}
+
+// EndpointFromEnv returns the OTLP/HTTP traces endpoint and headers of the
+// standard OTEL_EXPORTER_OTLP_* variables, or "" when none is set. A base
+// endpoint (OTEL_EXPORTER_OTLP_ENDPOINT) gets /v1/traces appended; a traces
+// endpoint is used as is.
+func EndpointFromEnv() (string, map[string]string) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+
+	headers := parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	for key, value := range parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")) {
+		headers[key] = value
+	}
+	return endpoint, headers
+}
+
+// parseHeaders parses the key1=value1,key2=value2 list of the OTLP header
+// variables, whose values are URL-encoded
+func parseHeaders(list string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		if decoded, err := url.PathUnescape(strings.TrimSpace(value)); err == nil {
+			value = decoded
+		}
+		headers[key] = value
+	}
+	return headers
+}
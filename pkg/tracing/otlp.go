@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// OTLP/JSON encoding of an ExportTraceServiceRequest, as accepted by the
+// /v1/traces endpoint of OTLP/HTTP collectors. IDs are hex, timestamps and
+// 64-bit integers are strings, per the OTLP JSON mapping.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	// Code 2 is STATUS_CODE_ERROR
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// encodeOTLP encodes spans of one trace as a single OTLP/JSON request
+func encodeOTLP(traceID [16]byte, resource map[string]interface{}, spans []*Span) ([]byte, error) {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/sohankunkerkar/kipod"}}
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(traceID[:]),
+			SpanID:            hex.EncodeToString(span.id[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attrs),
+		}
+		if span.parent != ([8]byte{}) {
+			s.ParentSpanID = hex.EncodeToString(span.parent[:])
+		}
+		if span.err != "" {
+			s.Status = &otlpStatus{Code: 2, Message: span.err}
+		}
+		scope.Spans = append(scope.Spans, s)
+	}
+
+	return json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(resource)},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+}
+
+// otlpAttributes converts attributes to OTLP key-values, sorted by key
+func otlpAttributes(attrs map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attrs[key].(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		case []string:
+			values := make([]map[string]interface{}, 0, len(v))
+			for _, item := range v {
+				values = append(values, map[string]interface{}{"stringValue": item})
+			}
+			value = map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpAttribute{Key: key, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,179 @@
+// Package tracing records OpenTelemetry spans of a kipod run, one for the
+// command, one per provisioning phase and one per podman invocation, and
+// exports them as OTLP JSON when the run ends, to a file or an OTLP/HTTP
+// collector. Recording is off until Enable is called; spans started before
+// that are nil and ignore every call.
+package tracing
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// Span kinds of the OTLP data model
+const (
+	KindInternal = 1
+	KindClient   = 3
+)
+
+// Span is one timed operation of a run
+type Span struct {
+	id     [8]byte
+	parent [8]byte
+	name   string
+	kind   int
+	start  time.Time
+	end    time.Time
+	attrs  map[string]interface{}
+	err    string
+}
+
+// Exporter sends the encoded spans of a run somewhere
+type Exporter func(data []byte) error
+
+var (
+	mu       sync.Mutex
+	exporter Exporter
+	traceID  [16]byte
+	resource map[string]interface{}
+	root     *Span
+	// active are the open spans started with Start, innermost last
+	active   []*Span
+	finished []*Span
+)
+
+// Enable starts recording spans under a root span named name. attrs
+// describe the run, e.g. service.version, and are attached to every span as
+// OTLP resource attributes. export receives the spans on Shutdown.
+func Enable(name string, attrs map[string]interface{}, export Exporter) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	exporter = export
+	_, _ = rand.Read(traceID[:])
+	resource = map[string]interface{}{"service.name": "kipod"}
+	for key, value := range attrs {
+		resource[key] = value
+	}
+	root = newSpan(name, KindInternal, [8]byte{})
+	active = nil
+	finished = nil
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return exporter != nil
+}
+
+// Start opens a span as a child of the innermost open span, or of the root
+// span. Spans started from concurrent goroutines nest under whichever span
+// was opened last.
+func Start(name string) *Span {
+	mu.Lock()
+	defer mu.Unlock()
+	if exporter == nil {
+		return nil
+	}
+	span := newSpan(name, KindInternal, currentParent())
+	active = append(active, span)
+	return span
+}
+
+// Record adds a span that already finished, such as a podman invocation, as
+// a child of the innermost open span
+func Record(name string, kind int, start time.Time, attrs map[string]interface{}, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if exporter == nil {
+		return
+	}
+	span := newSpan(name, kind, currentParent())
+	span.start = start
+	span.end = time.Now()
+	span.attrs = attrs
+	if err != nil {
+		span.err = err.Error()
+	}
+	finished = append(finished, span)
+}
+
+// SetAttribute attaches a string, bool, integer or float value to the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]interface{})
+	}
+	s.attrs[key] = value
+}
+
+// End closes the span, marking it failed when err is set
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	for i := len(active) - 1; i >= 0; i-- {
+		if active[i] == s {
+			active = append(active[:i], active[i+1:]...)
+			break
+		}
+	}
+	finished = append(finished, s)
+}
+
+// Shutdown ends the root span, marking it failed when err is set, along
+// with spans left open, and exports every span. Recording stops.
+func Shutdown(err error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if exporter == nil {
+		return nil
+	}
+	export := exporter
+	exporter = nil
+
+	now := time.Now()
+	for _, span := range active {
+		span.end = now
+		span.err = "not finished when kipod exited"
+		finished = append(finished, span)
+	}
+	root.end = now
+	if err != nil {
+		root.err = err.Error()
+	}
+	spans := append([]*Span{root}, finished...)
+	active, finished = nil, nil
+
+	data, encodeErr := encodeOTLP(traceID, resource, spans)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	return export(data)
+}
+
+// currentParent returns the ID of the innermost open span; mu must be held
+func currentParent() [8]byte {
+	if len(active) > 0 {
+		return active[len(active)-1].id
+	}
+	return root.id
+}
+
+func newSpan(name string, kind int, parent [8]byte) *Span {
+	span := &Span{name: name, kind: kind, parent: parent, start: time.Now()}
+	_, _ = rand.Read(span.id[:])
+	return span
+}